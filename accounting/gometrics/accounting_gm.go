@@ -125,35 +125,41 @@ func (g *gometricsAccountingStore) Vitals() (interface{}, errors.Error) {
 		Req95:          time.Duration(request_timer.Percentile(.95)).String(),
 		Req99:          time.Duration(request_timer.Percentile(.99)).String(),
 		Prepared:       prepPercent,
+		Workload:       server.WorkloadStats(),
+		ResultCache:    server.ResultCacheStats(),
+		UserLimits:     server.UserLimitsStats(),
 	}, nil
 
 }
 
 type VitalsRecord struct {
-	Uptime         string  `json:"uptime"`
-	LocalTime      string  `json:"local.time"`
-	Version        string  `json:"version"`
-	TotThreads     int     `json:"total.threads"`
-	Cores          int     `json:"cores"`
-	GCNum          uint64  `json:"gc.num"`
-	GCPauseTime    string  `json:"gc.pause.time"`
-	GCPausePercent float64 `json:"gc.pause.percent"`
-	MemoryUsage    uint64  `json:"memory.usage"`
-	MemoryTotal    uint64  `json:"memory.total"`
-	MemorySys      uint64  `json:"memory.system"`
-	CPUUser        float64 `json:"cpu.user.percent"`
-	CPUSys         float64 `json:"cpu.sys.percent"`
-	ReqCount       int64   `json:"request.completed.count"`
-	ActCount       int64   `json:"request.active.count"`
-	Req1min        float64 `json:"request.per.sec.1min"`
-	Req5min        float64 `json:"request.per.sec.5min"`
-	Req15min       float64 `json:"request.per.sec.15min"`
-	ReqMean        string  `json:"request_time.mean"`
-	ReqMedian      string  `json:"request_time.median"`
-	Req80          string  `json:"request_time.80percentile"`
-	Req95          string  `json:"request_time.95percentile"`
-	Req99          string  `json:"request_time.99percentile"`
-	Prepared       float64 `json:"request.prepared.percent"`
+	Uptime         string                 `json:"uptime"`
+	LocalTime      string                 `json:"local.time"`
+	Version        string                 `json:"version"`
+	TotThreads     int                    `json:"total.threads"`
+	Cores          int                    `json:"cores"`
+	GCNum          uint64                 `json:"gc.num"`
+	GCPauseTime    string                 `json:"gc.pause.time"`
+	GCPausePercent float64                `json:"gc.pause.percent"`
+	MemoryUsage    uint64                 `json:"memory.usage"`
+	MemoryTotal    uint64                 `json:"memory.total"`
+	MemorySys      uint64                 `json:"memory.system"`
+	CPUUser        float64                `json:"cpu.user.percent"`
+	CPUSys         float64                `json:"cpu.sys.percent"`
+	ReqCount       int64                  `json:"request.completed.count"`
+	ActCount       int64                  `json:"request.active.count"`
+	Req1min        float64                `json:"request.per.sec.1min"`
+	Req5min        float64                `json:"request.per.sec.5min"`
+	Req15min       float64                `json:"request.per.sec.15min"`
+	ReqMean        string                 `json:"request_time.mean"`
+	ReqMedian      string                 `json:"request_time.median"`
+	Req80          string                 `json:"request_time.80percentile"`
+	Req95          string                 `json:"request_time.95percentile"`
+	Req99          string                 `json:"request_time.99percentile"`
+	Prepared       float64                `json:"request.prepared.percent"`
+	Workload       map[string]interface{} `json:"workload,omitempty"`
+	ResultCache    map[string]interface{} `json:"result.cache,omitempty"`
+	UserLimits     []interface{}          `json:"user.limits,omitempty"`
 
 	// FIXME Active vs Queued threads, local time, version, direct vs prepared, network
 }
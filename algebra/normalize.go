@@ -0,0 +1,84 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package algebra
+
+import (
+	"strings"
+
+	"github.com/couchbase/query/expression"
+)
+
+/*
+Replace literals with positional parameters, numbered after any the
+statement already uses, so that statements differing only in literals have
+the same text.
+Returns the text, the number of literals replaced, and whether they could
+be replaced, in which case the statement is modified.
+Statements that cannot be rendered back to text, such as DML, are
+represented by their type, target and normalized expressions.
+*/
+func NormalizeStatement(stmt Statement, text string) (string, int, bool) {
+	start := maxPosition(stmt.Expressions())
+	mapper := newLiteralMapper(start)
+	if stmt.MapExpressions(mapper) != nil {
+		return text, 0, false
+	}
+	literals := mapper.position - start
+
+	if s, ok := stmt.(interface{ String() string }); ok {
+		return s.String(), literals, true
+	}
+
+	var buf strings.Builder
+
+	buf.WriteString(strings.ToLower(stmt.Type()))
+	if k, ok := stmt.(interface{ KeyspaceRef() *KeyspaceRef }); ok && k.KeyspaceRef() != nil {
+		buf.WriteByte(' ')
+		buf.WriteString(k.KeyspaceRef().FullName())
+	}
+	for _, expr := range stmt.Expressions() {
+		if expr != nil {
+			buf.WriteByte(' ')
+			buf.WriteString(expr.String())
+		}
+	}
+	return buf.String(), literals, true
+}
+
+func maxPosition(exprs expression.Expressions) int {
+	rv := 0
+	for _, expr := range exprs {
+		if expr == nil {
+			continue
+		}
+		if p, ok := expr.(expression.PositionalParameter); ok && p.Position() > rv {
+			rv = p.Position()
+		}
+		if n := maxPosition(expr.Children()); n > rv {
+			rv = n
+		}
+	}
+	return rv
+}
+
+type literalMapper struct {
+	expression.MapperBase
+	position int
+}
+
+func newLiteralMapper(position int) *literalMapper {
+	rv := &literalMapper{position: position}
+	rv.SetMapper(rv)
+	return rv
+}
+
+func (this *literalMapper) VisitConstant(expr *expression.Constant) (interface{}, error) {
+	this.position++
+	return NewPositionalParameter(this.position), nil
+}
@@ -0,0 +1,377 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package algebra
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type HintType int
+
+const (
+	HINT_INVALID = HintType(iota)
+	HINT_INDEX
+	HINT_NO_INDEX
+	HINT_INDEX_FTS
+	HINT_ORDERED
+	HINT_USE_HASH
+	HINT_USE_NL
+)
+
+var _HINT_NAMES = map[string]HintType{
+	"INDEX":     HINT_INDEX,
+	"NO_INDEX":  HINT_NO_INDEX,
+	"INDEX_FTS": HINT_INDEX_FTS,
+	"ORDERED":   HINT_ORDERED,
+	"USE_HASH":  HINT_USE_HASH,
+	"USE_NL":    HINT_USE_NL,
+}
+
+type HintState int
+
+const (
+	HINT_STATE_UNKNOWN = HintState(iota)
+	HINT_STATE_FOLLOWED
+	HINT_STATE_NOT_FOLLOWED
+	HINT_STATE_INVALID
+)
+
+// Hint Errors
+
+const (
+	HINT_INVALID_NAME       = "Invalid hint name"
+	HINT_INVALID_ARGUMENTS  = "Invalid hint arguments"
+	HINT_UNKNOWN_KEYSPACE   = "Keyspace is not in the FROM clause of the query block"
+	HINT_NOT_KEYSPACE       = "Hint applies to keyspaces only"
+	HINT_FIRST_KEYSPACE     = "Join hint cannot be specified on the first keyspace of the FROM clause"
+	HINT_NOT_ANSI_JOIN      = "Join hint applies to the right-hand side of an ANSI JOIN or NEST only"
+	HINT_DUPLICATE          = "Duplicate or conflicting hints"
+	HINT_USE_CLAUSE         = "Hint conflicts with the USE clause of the keyspace"
+	HINT_INDEX_NOT_FOLLOWED = "Index hint cannot be followed"
+)
+
+/*
+A single optimizer hint, given in a hint comment of a query block.
+Index hints name a keyspace alias and optionally indexes, join hints
+name a keyspace alias.
+*/
+type OptimHint struct {
+	hintType HintType
+	text     string
+	keyspace string
+	indexes  []string
+	joinHint JoinHint
+	state    HintState
+	reason   string
+}
+
+func (this *OptimHint) Type() HintType {
+	return this.hintType
+}
+
+func (this *OptimHint) Keyspace() string {
+	return this.keyspace
+}
+
+func (this *OptimHint) Indexes() []string {
+	return this.indexes
+}
+
+/*
+Returns the join hint equivalent to the USE_HASH or USE_NL hint.
+*/
+func (this *OptimHint) JoinHint() JoinHint {
+	return this.joinHint
+}
+
+func (this *OptimHint) State() HintState {
+	return this.state
+}
+
+func (this *OptimHint) Reason() string {
+	return this.reason
+}
+
+/*
+Set the state of the hint, and the reason it was not followed or
+is invalid.
+*/
+func (this *OptimHint) SetState(state HintState, reason string) {
+	this.state = state
+	this.reason = reason
+}
+
+/*
+Representation of the hint as given in a hint comment.
+*/
+func (this *OptimHint) String() string {
+	switch this.hintType {
+	case HINT_INVALID:
+		return this.text
+	case HINT_ORDERED:
+		return "ORDERED"
+	}
+
+	name := ""
+	for n, t := range _HINT_NAMES {
+		if t == this.hintType {
+			name = n
+			break
+		}
+	}
+
+	args := make([]string, 0, 1+len(this.indexes))
+	keyspace := "`" + this.keyspace + "`"
+	switch this.joinHint {
+	case USE_HASH_BUILD:
+		keyspace += "/BUILD"
+	case USE_HASH_PROBE:
+		keyspace += "/PROBE"
+	}
+	args = append(args, keyspace)
+	for _, index := range this.indexes {
+		args = append(args, "`"+index+"`")
+	}
+	return name + "(" + strings.Join(args, " ") + ")"
+}
+
+/*
+The hints of a query block, given in a hint comment following
+SELECT. Hints that cannot be parsed are kept as invalid hints
+rather than failing the statement.
+*/
+type OptimHints struct {
+	hints []*OptimHint
+}
+
+func NewOptimHints(text string) *OptimHints {
+	text = strings.TrimPrefix(text, "/*+")
+	text = strings.TrimSuffix(text, "*/")
+	return &OptimHints{hints: parseOptimHints(text)}
+}
+
+func (this *OptimHints) Hints() []*OptimHint {
+	return this.hints
+}
+
+/*
+Reset the state of the valid hints, before a query block is planned.
+*/
+func (this *OptimHints) ResetStates() {
+	for _, hint := range this.hints {
+		if hint.hintType != HINT_INVALID {
+			hint.SetState(HINT_STATE_UNKNOWN, "")
+		}
+	}
+}
+
+/*
+Representation as a N1QL hint comment.
+*/
+func (this *OptimHints) String() string {
+	s := make([]string, 0, len(this.hints)+2)
+	s = append(s, "/*+")
+	for _, hint := range this.hints {
+		s = append(s, hint.String())
+	}
+	s = append(s, "*/")
+	return strings.Join(s, " ")
+}
+
+/*
+Groups the hints of the query blocks of a statement by state, for
+the EXPLAIN output.
+*/
+func OptimHintsSummary(blocks []*OptimHints) map[string]interface{} {
+	var followed, notFollowed, invalid []interface{}
+	for _, block := range blocks {
+		for _, hint := range block.hints {
+			switch hint.state {
+			case HINT_STATE_FOLLOWED:
+				followed = append(followed, hint.String())
+			case HINT_STATE_INVALID:
+				invalid = append(invalid, map[string]interface{}{"hint": hint.String(), "error": hint.reason})
+			default:
+				// hints that were not considered are not followed either
+				r := map[string]interface{}{"hint": hint.String()}
+				if hint.reason != "" {
+					r["reason"] = hint.reason
+				}
+				notFollowed = append(notFollowed, r)
+			}
+		}
+	}
+
+	rv := make(map[string]interface{}, 3)
+	if len(followed) > 0 {
+		rv["hints_followed"] = followed
+	}
+	if len(notFollowed) > 0 {
+		rv["hints_not_followed"] = notFollowed
+	}
+	if len(invalid) > 0 {
+		rv["invalid_hints"] = invalid
+	}
+	if len(rv) == 0 {
+		return nil
+	}
+	return rv
+}
+
+/*
+Hint comments hold a sequence of hints, each a name optionally
+followed by a parenthesized list of arguments separated by spaces
+or commas. Arguments are identifiers, possibly quoted with back
+quotes, and keyspaces of USE_HASH may be followed by /BUILD or
+/PROBE.
+*/
+func parseOptimHints(text string) []*OptimHint {
+	var hints []*OptimHint
+
+	rest := strings.TrimSpace(text)
+	for rest != "" {
+		end := strings.IndexFunc(rest, func(r rune) bool {
+			return !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_')
+		})
+		if end < 0 {
+			end = len(rest)
+		}
+		name := rest[:end]
+		rest = strings.TrimSpace(rest[end:])
+
+		var args []string
+		hasArgs := strings.HasPrefix(rest, "(")
+		ok := name != ""
+		if hasArgs {
+			end := strings.IndexByte(rest, ')')
+			if end < 0 {
+				hints = append(hints, invalidHint(name+rest, HINT_INVALID_ARGUMENTS))
+				break
+			}
+			args, ok = hintArgs(rest[1:end])
+			ok = ok && name != ""
+			name += rest[:end+1]
+			rest = strings.TrimSpace(rest[end+1:])
+		} else if !ok {
+			// skip an unexpected character
+			_, size := utf8.DecodeRuneInString(rest)
+			name = rest[:size]
+			rest = strings.TrimSpace(rest[size:])
+		}
+
+		hintType, found := _HINT_NAMES[strings.ToUpper(hintName(name))]
+		if !ok || !found {
+			reason := HINT_INVALID_ARGUMENTS
+			if !found {
+				reason = HINT_INVALID_NAME
+			}
+			hints = append(hints, invalidHint(name, reason))
+			continue
+		}
+
+		hints = append(hints, newOptimHints(hintType, name, args, hasArgs)...)
+	}
+
+	return hints
+}
+
+func newOptimHints(hintType HintType, text string, args []string, hasArgs bool) []*OptimHint {
+	switch hintType {
+	case HINT_ORDERED:
+		if hasArgs {
+			return []*OptimHint{invalidHint(text, HINT_INVALID_ARGUMENTS)}
+		}
+		return []*OptimHint{&OptimHint{hintType: hintType}}
+
+	case HINT_USE_HASH, HINT_USE_NL:
+		if len(args) == 0 {
+			return []*OptimHint{invalidHint(text, HINT_INVALID_ARGUMENTS)}
+		}
+		// one hint per keyspace
+		hints := make([]*OptimHint, 0, len(args))
+		for _, arg := range args {
+			hint := &OptimHint{hintType: hintType, keyspace: arg, joinHint: USE_NL}
+			if hintType == HINT_USE_HASH {
+				hint.joinHint = USE_HASH_BUILD
+				if slash := strings.LastIndexByte(arg, '/'); slash > 0 && !strings.HasSuffix(arg, "`") {
+					hint.keyspace = arg[:slash]
+					switch strings.ToUpper(arg[slash+1:]) {
+					case "BUILD":
+					case "PROBE":
+						hint.joinHint = USE_HASH_PROBE
+					default:
+						hint = invalidHint(hintName(text)+"("+arg+")", HINT_INVALID_ARGUMENTS)
+					}
+				}
+			}
+			hint.keyspace = unquote(hint.keyspace)
+			hints = append(hints, hint)
+		}
+		return hints
+
+	default:
+		if len(args) == 0 {
+			return []*OptimHint{invalidHint(text, HINT_INVALID_ARGUMENTS)}
+		}
+		indexes := make([]string, 0, len(args)-1)
+		for _, arg := range args[1:] {
+			indexes = append(indexes, unquote(arg))
+		}
+		return []*OptimHint{&OptimHint{hintType: hintType, keyspace: unquote(args[0]), indexes: indexes}}
+	}
+}
+
+func invalidHint(text, reason string) *OptimHint {
+	return &OptimHint{hintType: HINT_INVALID, text: text, state: HINT_STATE_INVALID, reason: reason}
+}
+
+/*
+Splits hint arguments on spaces and commas, outside of back quotes.
+*/
+func hintArgs(text string) ([]string, bool) {
+	var args []string
+	var arg []rune
+	quoted := false
+	for _, r := range text {
+		switch {
+		case r == '`':
+			quoted = !quoted
+			arg = append(arg, r)
+		case !quoted && (unicode.IsSpace(r) || r == ','):
+			if len(arg) > 0 {
+				args = append(args, string(arg))
+				arg = arg[:0]
+			}
+		case !quoted && !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '/' || r == '-'):
+			return nil, false
+		default:
+			arg = append(arg, r)
+		}
+	}
+	if len(arg) > 0 {
+		args = append(args, string(arg))
+	}
+	return args, !quoted
+}
+
+func hintName(text string) string {
+	if paren := strings.IndexByte(text, '('); paren >= 0 {
+		return strings.TrimSpace(text[:paren])
+	}
+	return text
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && s[0] == '`' && s[len(s)-1] == '`' {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
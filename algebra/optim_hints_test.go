@@ -0,0 +1,99 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package algebra
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseOptimHints(t *testing.T) {
+	hints := NewOptimHints("/*+ INDEX(b idx1 idx2) no_index(`c-1`, `idx 3`) INDEX_FTS(d) ORDERED " +
+		"USE_HASH(e/PROBE f) USE_NL(g) */").Hints()
+
+	expected := []struct {
+		hintType HintType
+		keyspace string
+		indexes  []string
+		joinHint JoinHint
+	}{
+		{HINT_INDEX, "b", []string{"idx1", "idx2"}, JOIN_HINT_NONE},
+		{HINT_NO_INDEX, "c-1", []string{"idx 3"}, JOIN_HINT_NONE},
+		{HINT_INDEX_FTS, "d", []string{}, JOIN_HINT_NONE},
+		{HINT_ORDERED, "", nil, JOIN_HINT_NONE},
+		{HINT_USE_HASH, "e", nil, USE_HASH_PROBE},
+		{HINT_USE_HASH, "f", nil, USE_HASH_BUILD},
+		{HINT_USE_NL, "g", nil, USE_NL},
+	}
+	if len(hints) != len(expected) {
+		t.Fatalf("Expected %v hints, got %v", len(expected), len(hints))
+	}
+	for i, e := range expected {
+		hint := hints[i]
+		if hint.Type() != e.hintType || hint.Keyspace() != e.keyspace || hint.JoinHint() != e.joinHint ||
+			!reflect.DeepEqual(hint.Indexes(), e.indexes) {
+			t.Errorf("Expected %v, got %v", e, hint)
+		}
+		if hint.State() != HINT_STATE_UNKNOWN {
+			t.Errorf("Expected %v to be valid, got %v", hint, hint.Reason())
+		}
+	}
+
+	// hints are given back as written, bar quotes
+	if hint := hints[4].String(); hint != "USE_HASH(`e`/PROBE)" {
+		t.Errorf("Unexpected representation %v", hint)
+	}
+}
+
+func TestInvalidOptimHints(t *testing.T) {
+	tests := []struct {
+		text   string
+		reason string
+	}{
+		{"INDEX", HINT_INVALID_ARGUMENTS},
+		{"INDEX()", HINT_INVALID_ARGUMENTS},
+		{"INDEX(b;idx1)", HINT_INVALID_ARGUMENTS},
+		{"INDEX(`b idx1)", HINT_INVALID_ARGUMENTS},
+		{"ORDERED(b)", HINT_INVALID_ARGUMENTS},
+		{"USE_NL", HINT_INVALID_ARGUMENTS},
+		{"USE_HASH(b/SIDEWAYS)", HINT_INVALID_ARGUMENTS},
+		{"INDEX(b idx1", HINT_INVALID_ARGUMENTS},
+		{"FULL_SCAN(b)", HINT_INVALID_NAME},
+		{"!", HINT_INVALID_NAME},
+	}
+	for _, test := range tests {
+		hints := parseOptimHints(test.text)
+		if len(hints) != 1 {
+			t.Errorf("Expected one hint for %v, got %v", test.text, len(hints))
+			continue
+		}
+		hint := hints[0]
+		if hint.Type() != HINT_INVALID || hint.State() != HINT_STATE_INVALID || hint.Reason() != test.reason {
+			t.Errorf("Expected %v to be invalid with %q, got %v %q", test.text, test.reason, hint.Type(), hint.Reason())
+		}
+		if hint.String() != test.text {
+			t.Errorf("Expected invalid hint %q to be kept as written, got %q", test.text, hint.String())
+		}
+	}
+
+	// invalid hints do not affect the others
+	hints := parseOptimHints("FULL_SCAN(b) INDEX(b idx1) ORDERED(c)")
+	if len(hints) != 3 || hints[0].Type() != HINT_INVALID || hints[1].Type() != HINT_INDEX ||
+		hints[2].Type() != HINT_INVALID {
+		t.Errorf("Expected the valid hint to be kept, got %v", hints)
+	}
+
+	// states are reset for valid hints only
+	optimHints := &OptimHints{hints: hints}
+	hints[1].SetState(HINT_STATE_FOLLOWED, "")
+	optimHints.ResetStates()
+	if hints[0].State() != HINT_STATE_INVALID || hints[1].State() != HINT_STATE_UNKNOWN {
+		t.Errorf("Unexpected states %v and %v", hints[0].State(), hints[1].State())
+	}
+}
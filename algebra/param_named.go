@@ -10,6 +10,7 @@ package algebra
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/couchbase/query/errors"
 	"github.com/couchbase/query/expression"
@@ -69,6 +70,8 @@ func (this *NamedParameter) Evaluate(item value.Value, context expression.Contex
 
 	if ok {
 		return val, nil
+	} else if strings.HasPrefix(this.name, SESSION_VARIABLE_PREFIX) {
+		return nil, fmt.Errorf("No value for session variable %s%v.", this.name, this.ErrorContext())
 	} else {
 		return nil, fmt.Errorf("No value for named parameter $%s%v.", this.name, this.ErrorContext())
 	}
@@ -31,6 +31,7 @@ type Subselect struct {
 	projection *Projection           `json:"projection"`
 	window     WindowTerms           `json:"window"`
 	correlated bool                  `json:"correlated"`
+	optimHints *OptimHints           `json:"optimizer_hints"`
 }
 
 /*
@@ -297,7 +298,11 @@ func (this *Subselect) String() string {
 		s += withBindings(this.with)
 	}
 
-	s += "select " + this.projection.String()
+	s += "select "
+	if this.optimHints != nil {
+		s += this.optimHints.String() + " "
+	}
+	s += this.projection.String()
 
 	if this.from != nil {
 		s += " from " + this.from.String()
@@ -389,6 +394,18 @@ func (this *Subselect) ResetWindow() {
 	this.window = nil
 }
 
+/*
+Returns the optimizer hints of the subselect, given in a
+hint comment following SELECT.
+*/
+func (this *Subselect) OptimHints() *OptimHints {
+	return this.optimHints
+}
+
+func (this *Subselect) SetOptimHints(optimHints *OptimHints) {
+	this.optimHints = optimHints
+}
+
 /*
    Representation as a N1QL string.
 */
@@ -0,0 +1,230 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package algebra
+
+import (
+	"encoding/json"
+
+	"github.com/couchbase/query/auth"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/value"
+)
+
+/*
+Session variables are named parameters whose name starts with @: statements
+running in a session refer to them as @name, and SET @name = expr sets them
+for the statements that follow.
+*/
+const SESSION_VARIABLE_PREFIX = "@"
+
+/*
+Represents the SET @name = expr statement.
+*/
+type SetVariable struct {
+	statementBase
+
+	name string                `json:"name"`
+	expr expression.Expression `json:"expr"`
+}
+
+func NewSetVariable(name string, expr expression.Expression) *SetVariable {
+	rv := &SetVariable{
+		name: name,
+		expr: expr,
+	}
+
+	rv.stmt = rv
+	return rv
+}
+
+func (this *SetVariable) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitSetVariable(this)
+}
+
+func (this *SetVariable) Signature() value.Value {
+	return _JSON_SIGNATURE
+}
+
+func (this *SetVariable) Type() string {
+	return "SET_VARIABLE"
+}
+
+func (this *SetVariable) MapExpressions(mapper expression.Mapper) (err error) {
+	this.expr, err = mapper.Map(this.expr)
+	return
+}
+
+func (this *SetVariable) Expressions() expression.Expressions {
+	return expression.Expressions{this.expr}
+}
+
+/*
+Returns the privileges required by the expression.
+*/
+func (this *SetVariable) Privileges() (*auth.Privileges, errors.Error) {
+	exprs := this.Expressions()
+	privs, err := subqueryPrivileges(exprs)
+	if err != nil {
+		return nil, err
+	}
+	for _, expr := range exprs {
+		privs.AddAll(expr.Privileges())
+	}
+	return privs, nil
+}
+
+func (this *SetVariable) Formalize() error {
+	return this.MapExpressions(expression.NewFormalizer("", nil))
+}
+
+/*
+Returns the variable name, without the @.
+*/
+func (this *SetVariable) Name() string {
+	return this.name
+}
+
+func (this *SetVariable) Expression() expression.Expression {
+	return this.expr
+}
+
+func (this *SetVariable) MarshalJSON() ([]byte, error) {
+	r := map[string]interface{}{"type": "setVariable"}
+	r["name"] = this.name
+	r["expr"] = expression.NewStringer().Visit(this.expr)
+	return json.Marshal(r)
+}
+
+/*
+Represents the CREATE TEMPORARY COLLECTION statement. Temporary collections
+belong to the session the statement runs in, and need no privileges.
+*/
+type CreateTemporaryCollection struct {
+	statementBase
+
+	name         string `json:"name"`
+	failIfExists bool   `json:"failIfExists"`
+}
+
+func NewCreateTemporaryCollection(name string, failIfExists bool) *CreateTemporaryCollection {
+	rv := &CreateTemporaryCollection{
+		name:         name,
+		failIfExists: failIfExists,
+	}
+
+	rv.stmt = rv
+	return rv
+}
+
+func (this *CreateTemporaryCollection) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitCreateTemporaryCollection(this)
+}
+
+func (this *CreateTemporaryCollection) Signature() value.Value {
+	return nil
+}
+
+func (this *CreateTemporaryCollection) Type() string {
+	return "CREATE_TEMPORARY_COLLECTION"
+}
+
+func (this *CreateTemporaryCollection) MapExpressions(mapper expression.Mapper) error {
+	return nil
+}
+
+func (this *CreateTemporaryCollection) Expressions() expression.Expressions {
+	return nil
+}
+
+func (this *CreateTemporaryCollection) Privileges() (*auth.Privileges, errors.Error) {
+	return auth.NewPrivileges(), nil
+}
+
+func (this *CreateTemporaryCollection) Formalize() error {
+	return nil
+}
+
+func (this *CreateTemporaryCollection) Name() string {
+	return this.name
+}
+
+func (this *CreateTemporaryCollection) FailIfExists() bool {
+	return this.failIfExists
+}
+
+func (this *CreateTemporaryCollection) MarshalJSON() ([]byte, error) {
+	r := map[string]interface{}{"type": "createTemporaryCollection"}
+	r["name"] = this.name
+	r["failIfExists"] = this.failIfExists
+	return json.Marshal(r)
+}
+
+/*
+Represents the DROP TEMPORARY COLLECTION statement.
+*/
+type DropTemporaryCollection struct {
+	statementBase
+
+	name            string `json:"name"`
+	failIfNotExists bool   `json:"failIfNotExists"`
+}
+
+func NewDropTemporaryCollection(name string, failIfNotExists bool) *DropTemporaryCollection {
+	rv := &DropTemporaryCollection{
+		name:            name,
+		failIfNotExists: failIfNotExists,
+	}
+
+	rv.stmt = rv
+	return rv
+}
+
+func (this *DropTemporaryCollection) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitDropTemporaryCollection(this)
+}
+
+func (this *DropTemporaryCollection) Signature() value.Value {
+	return nil
+}
+
+func (this *DropTemporaryCollection) Type() string {
+	return "DROP_TEMPORARY_COLLECTION"
+}
+
+func (this *DropTemporaryCollection) MapExpressions(mapper expression.Mapper) error {
+	return nil
+}
+
+func (this *DropTemporaryCollection) Expressions() expression.Expressions {
+	return nil
+}
+
+func (this *DropTemporaryCollection) Privileges() (*auth.Privileges, errors.Error) {
+	return auth.NewPrivileges(), nil
+}
+
+func (this *DropTemporaryCollection) Formalize() error {
+	return nil
+}
+
+func (this *DropTemporaryCollection) Name() string {
+	return this.name
+}
+
+func (this *DropTemporaryCollection) FailIfNotExists() bool {
+	return this.failIfNotExists
+}
+
+func (this *DropTemporaryCollection) MarshalJSON() ([]byte, error) {
+	r := map[string]interface{}{"type": "dropTemporaryCollection"}
+	r["name"] = this.name
+	r["failIfNotExists"] = this.failIfNotExists
+	return json.Marshal(r)
+}
@@ -94,6 +94,13 @@ type Visitor interface {
 	VisitRollbackTransaction(stmt *RollbackTransaction) (interface{}, error)
 	VisitTransactionIsolation(stmt *TransactionIsolation) (interface{}, error)
 	VisitSavepoint(stmt *Savepoint) (interface{}, error)
+
+	/*
+	   Visitor for Session statements.
+	*/
+	VisitSetVariable(stmt *SetVariable) (interface{}, error)
+	VisitCreateTemporaryCollection(stmt *CreateTemporaryCollection) (interface{}, error)
+	VisitDropTemporaryCollection(stmt *DropTemporaryCollection) (interface{}, error)
 }
 
 type NodeVisitor interface {
@@ -492,6 +492,9 @@ const (
 	API_ADMIN_INDEXES_TRANSACTIONS       = 28727
 	API_ADMIN_FUNCTIONS_BACKUP           = 28728
 	API_ADMIN_SHUTDOWN                   = 28729
+	API_ADMIN_PLAN_BASELINES             = 28730
+	API_ADMIN_RESULT_CACHE               = 28731
+	API_ADMIN_STATEMENT_STATS            = 28732
 )
 
 func SubmitApiRequest(event *ApiAuditFields) {
@@ -32,6 +32,9 @@ import (
 
 const SYSTEM_NAMESPACE = "#system"
 
+// Temporary keyspaces live in the session namespace, as #session:<session id>._default.<name>
+const SESSION_NAMESPACE = "#session"
+
 // Datastore represents a cluster or single-node server.
 type Datastore interface {
 	Id() string                                                                            // Id of this datastore
@@ -244,6 +247,7 @@ type KeyspaceMetadata interface {
 // Globally accessible Datastore instance
 var _DATASTORE Datastore
 var _SYSTEMSTORE Systemstore
+var _SESSIONS Namespace
 
 func SetDatastore(datastore Datastore) {
 	_DATASTORE = datastore
@@ -261,6 +265,10 @@ func GetSystemstore() Systemstore {
 	return _SYSTEMSTORE
 }
 
+func SetSessionNamespace(namespace Namespace) {
+	_SESSIONS = namespace
+}
+
 func getNamespace(parts ...string) (Namespace, errors.Error) {
 	var datastore Datastore
 
@@ -269,7 +277,9 @@ func getNamespace(parts ...string) (Namespace, errors.Error) {
 		return nil, errors.NewDatastoreInvalidPathError("empty path")
 	}
 	namespace := parts[0]
-	if namespace == SYSTEM_NAMESPACE {
+	if namespace == SESSION_NAMESPACE && _SESSIONS != nil {
+		return _SESSIONS, nil
+	} else if namespace == SYSTEM_NAMESPACE {
 		datastore = _SYSTEMSTORE
 	} else {
 		datastore = _DATASTORE
@@ -0,0 +1,455 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package file
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/value"
+)
+
+// Keyspaces in the file datastore normally hold one JSON document per file.
+// A keyspace can instead be backed by delimited text files (CSV, TSV), in
+// which case every record of every file becomes a read only document, with
+// field names taken from the header line and field types sniffed from the
+// text.
+
+const (
+	FORMAT_JSON = "json"
+	FORMAT_CSV  = "csv"
+)
+
+type csvOptions struct {
+	delimiter rune   // field separator, defaults to ',' (tab for .tsv files)
+	quote     rune   // quoting character, 0 to disable quoting
+	header    bool   // first record holds field names
+	key       string // field used as document key, defaults to file name and record number
+	sniff     bool   // convert numbers, booleans and dates
+}
+
+func defaultCSVOptions() *csvOptions {
+	return &csvOptions{
+		delimiter: ',',
+		quote:     '"',
+		header:    true,
+		sniff:     true,
+	}
+}
+
+// newCSVOptions processes a WITH clause style object, eg
+// {"format":"csv", "delimiter":";", "quote":"'", "header":true, "key":"id", "sniff":true}
+// A nil options object yields the defaults.
+// The boolean return is false if the object does not request the csv format.
+func newCSVOptions(with value.Value) (*csvOptions, bool, errors.Error) {
+	options := defaultCSVOptions()
+	if with == nil {
+		return options, true, nil
+	}
+	if with.Type() != value.OBJECT {
+		return nil, false, errors.NewFileCSVOptionError(nil, fmt.Sprintf("options must be an object, not %v", with))
+	}
+
+	format, ok := with.Field("format")
+	if !ok {
+		return nil, false, nil
+	}
+	if format.Type() != value.STRING {
+		return nil, false, errors.NewFileCSVOptionError(nil, "format must be a string")
+	}
+	switch strings.ToLower(format.ToString()) {
+	case FORMAT_JSON:
+		return nil, false, nil
+	case FORMAT_CSV:
+	case "tsv":
+		options.delimiter = '\t'
+	default:
+		return nil, false, errors.NewFileCSVOptionError(nil, "unknown format "+format.ToString())
+	}
+
+	for name, val := range with.Fields() {
+		v := value.NewValue(val)
+		switch strings.ToLower(name) {
+		case "format":
+		case "delimiter":
+			r, err := csvRune(name, v, false)
+			if err != nil {
+				return nil, false, err
+			}
+			options.delimiter = r
+		case "quote":
+			r, err := csvRune(name, v, true)
+			if err != nil {
+				return nil, false, err
+			}
+			options.quote = r
+		case "header":
+			if v.Type() != value.BOOLEAN {
+				return nil, false, errors.NewFileCSVOptionError(nil, "header must be a boolean")
+			}
+			options.header = v.Truth()
+		case "sniff":
+			if v.Type() != value.BOOLEAN {
+				return nil, false, errors.NewFileCSVOptionError(nil, "sniff must be a boolean")
+			}
+			options.sniff = v.Truth()
+		case "key":
+			if v.Type() != value.STRING {
+				return nil, false, errors.NewFileCSVOptionError(nil, "key must be a string")
+			}
+			options.key = v.ToString()
+		default:
+			return nil, false, errors.NewFileCSVOptionError(nil, "unknown option "+name)
+		}
+	}
+	if options.delimiter == options.quote {
+		return nil, false, errors.NewFileCSVOptionError(nil, "delimiter and quote must differ")
+	}
+	return options, true, nil
+}
+
+func csvRune(name string, v value.Value, allowEmpty bool) (rune, errors.Error) {
+	if v.Type() != value.STRING {
+		return 0, errors.NewFileCSVOptionError(nil, name+" must be a string")
+	}
+	s := v.ToString()
+	if s == "" && allowEmpty {
+		return 0, nil
+	}
+	if s == "\\t" {
+		return '\t', nil
+	}
+	r, size := utf8.DecodeRuneInString(s)
+	if r == utf8.RuneError || size != len(s) || r == '\n' || r == '\r' {
+		return 0, errors.NewFileCSVOptionError(nil, name+" must be a single character")
+	}
+	return r, nil
+}
+
+// csvSource holds the documents parsed from the delimited files of a keyspace.
+// Documents are reloaded whenever the files change.
+type csvSource struct {
+	sync.Mutex
+	options *csvOptions
+	path    string
+	stamp   string
+	keys    []string
+	docs    map[string]value.Value
+	size    int64
+}
+
+func newCSVSource(path string, options *csvOptions) *csvSource {
+	return &csvSource{path: path, options: options}
+}
+
+func isCSVFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv", ".tsv":
+		return true
+	}
+	return false
+}
+
+// hasCSVFiles reports whether a keyspace directory is made of delimited
+// files only, in which case it is treated as a csv keyspace by default.
+func hasCSVFiles(path string) bool {
+	dirEntries, er := ioutil.ReadDir(path)
+	if er != nil {
+		return false
+	}
+	found := false
+	for _, dirEntry := range dirEntries {
+		if dirEntry.IsDir() {
+			continue
+		}
+		if !isCSVFile(dirEntry.Name()) {
+			return false
+		}
+		found = true
+	}
+	return found
+}
+
+// load returns the current keys and documents, parsing the files again if
+// they have been modified since the last load.
+func (this *csvSource) load() ([]string, map[string]value.Value, int64, errors.Error) {
+	dirEntries, er := ioutil.ReadDir(this.path)
+	if er != nil {
+		return nil, nil, 0, errors.NewFileDatastoreError(er, "")
+	}
+
+	files := make([]os.FileInfo, 0, len(dirEntries))
+	stamp := ""
+	for _, dirEntry := range dirEntries {
+		if !dirEntry.IsDir() && isCSVFile(dirEntry.Name()) {
+			files = append(files, dirEntry)
+			stamp += fmt.Sprintf("%s:%d:%d;", dirEntry.Name(), dirEntry.Size(), dirEntry.ModTime().UnixNano())
+		}
+	}
+
+	this.Lock()
+	defer this.Unlock()
+
+	if this.docs != nil && stamp == this.stamp {
+		return this.keys, this.docs, this.size, nil
+	}
+
+	keys := make([]string, 0, len(files))
+	docs := make(map[string]value.Value, len(files))
+	size := int64(0)
+	for _, file := range files {
+		err := this.loadFile(file.Name(), docs, &keys)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		size += file.Size()
+	}
+	sort.Strings(keys)
+
+	this.stamp = stamp
+	this.keys = keys
+	this.docs = docs
+	this.size = size
+	return keys, docs, size, nil
+}
+
+func (this *csvSource) loadFile(name string, docs map[string]value.Value, keys *[]string) errors.Error {
+	f, er := os.Open(filepath.Join(this.path, name))
+	if er != nil {
+		return errors.NewFileDatastoreError(er, "")
+	}
+	defer f.Close()
+
+	delimiter := this.options.delimiter
+	if delimiter == ',' && strings.EqualFold(filepath.Ext(name), ".tsv") {
+		delimiter = '\t'
+	}
+	reader := newCSVReader(f, delimiter, this.options.quote)
+
+	base := documentPathToId(name)
+	var names []string
+	recNo := 0
+	for {
+		record, err := reader.read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return errors.NewFileCSVParseError(err, fmt.Sprintf("%s line %d", name, reader.line))
+		}
+
+		if names == nil {
+			names = csvFieldNames(record, this.options.header)
+			if this.options.header {
+				continue
+			}
+		}
+
+		recNo++
+		doc := make(map[string]interface{}, len(record))
+		for i, field := range record {
+			var fieldName string
+			if i < len(names) {
+				fieldName = names[i]
+			} else {
+				fieldName = fmt.Sprintf("_%d", i+1)
+			}
+			if this.options.sniff {
+				doc[fieldName] = sniffCSVField(field)
+			} else {
+				doc[fieldName] = field
+			}
+		}
+
+		key := ""
+		if this.options.key != "" {
+			k, ok := doc[this.options.key]
+			if !ok || k == nil {
+				return errors.NewFileCSVParseError(nil, fmt.Sprintf("%s record %d has no key field %s", name, recNo, this.options.key))
+			}
+			key = fmt.Sprint(k)
+		} else {
+			key = base + "::" + strconv.Itoa(recNo)
+		}
+		if _, ok := docs[key]; ok {
+			return errors.NewFileCSVParseError(nil, fmt.Sprintf("%s record %d duplicate key %s", name, recNo, key))
+		}
+		docs[key] = value.NewValue(doc)
+		*keys = append(*keys, key)
+	}
+	return nil
+}
+
+func csvFieldNames(record []string, header bool) []string {
+	names := make([]string, len(record))
+	seen := make(map[string]bool, len(record))
+	for i, field := range record {
+		name := strings.TrimSpace(field)
+		if !header || name == "" || seen[name] {
+			name = fmt.Sprintf("_%d", i+1)
+		}
+		seen[name] = true
+		names[i] = name
+	}
+	return names
+}
+
+var csvDateFormats = []string{
+	"2006-01-02",
+	"2006-01-02T15:04:05Z07:00",
+	"2006-01-02T15:04:05.999999999Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04:05Z07:00",
+}
+
+// sniffCSVField converts the text of a field to a null, boolean, number or
+// ISO-8601 date if it unambiguously looks like one, and leaves it as a
+// string otherwise.
+func sniffCSVField(field string) interface{} {
+	s := strings.TrimSpace(field)
+	if s == "" {
+		return nil
+	}
+
+	switch strings.ToLower(s) {
+	case "true":
+		return true
+	case "false":
+		return false
+	case "null":
+		return nil
+	}
+
+	// leading zeros are most likely identifiers, such as zip codes
+	if c := s[0]; c == '-' || c == '+' || c == '.' || (c >= '0' && c <= '9') {
+		digits := strings.TrimLeft(s, "+-")
+		if len(digits) < 2 || digits[0] != '0' || digits[1] == '.' {
+			if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+				return i
+			}
+			if f, err := strconv.ParseFloat(s, 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+				return f
+			}
+		}
+	}
+
+	for _, format := range csvDateFormats {
+		if t, err := time.Parse(format, s); err == nil {
+			if format == "2006-01-02" {
+				return t.Format("2006-01-02")
+			}
+			return t.Format(time.RFC3339Nano)
+		}
+	}
+	return field
+}
+
+// csvReader splits delimited text into records.
+// encoding/csv is not used as it does not allow for a configurable quote.
+type csvReader struct {
+	reader    *bufio.Reader
+	delimiter rune
+	quote     rune
+	line      int
+}
+
+func newCSVReader(r io.Reader, delimiter, quote rune) *csvReader {
+	return &csvReader{reader: bufio.NewReader(r), delimiter: delimiter, quote: quote}
+}
+
+// read returns the next non empty record, or io.EOF
+func (this *csvReader) read() ([]string, error) {
+	for {
+		record, err := this.readRecord()
+		if err != nil {
+			return nil, err
+		}
+		if len(record) > 1 || (len(record) == 1 && record[0] != "") {
+			return record, nil
+		}
+	}
+}
+
+func (this *csvReader) readRecord() ([]string, error) {
+	var record []string
+	var field strings.Builder
+
+	quoted := false
+	inQuotes := false
+	started := false
+	this.line++
+	for {
+		r, _, err := this.reader.ReadRune()
+		if err == io.EOF {
+			if inQuotes {
+				return nil, fmt.Errorf("unterminated quoted field")
+			}
+			if !started {
+				return nil, io.EOF
+			}
+			return append(record, field.String()), nil
+		} else if err != nil {
+			return nil, err
+		}
+		started = true
+
+		if inQuotes {
+			if r == this.quote {
+				next, _, err := this.reader.ReadRune()
+				if err == nil && next == this.quote {
+					field.WriteRune(r)
+					continue
+				}
+				if err == nil {
+					this.reader.UnreadRune()
+				}
+				inQuotes = false
+				continue
+			}
+			if r == '\n' {
+				this.line++
+			}
+			field.WriteRune(r)
+			continue
+		}
+
+		switch {
+		case r == this.delimiter:
+			record = append(record, field.String())
+			field.Reset()
+			quoted = false
+		case r == '\n':
+			return append(record, field.String()), nil
+		case r == '\r':
+			next, _, err := this.reader.ReadRune()
+			if err == nil && next != '\n' {
+				this.reader.UnreadRune()
+			}
+			return append(record, field.String()), nil
+		case r == this.quote && this.quote != 0 && field.Len() == 0 && !quoted:
+			quoted = true
+			inQuotes = true
+		default:
+			field.WriteRune(r)
+		}
+	}
+}
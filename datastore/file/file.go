@@ -23,6 +23,7 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/couchbase/query/auth"
 	"github.com/couchbase/query/datastore"
@@ -41,6 +42,7 @@ type store struct {
 	namespaces     map[string]*namespace
 	namespaceNames []string
 	inferencer     datastore.Inferencer // what we use to infer schemas
+	csvOptions     *csvOptions          // keyspaces default to csv format if set
 
 	users map[string]*datastore.User
 }
@@ -198,12 +200,30 @@ func (s *store) TransactionDeltaKeyScan(keyspace string, conn *datastore.IndexCo
 
 // NewStore creates a new file-based store for the given filepath.
 func NewDatastore(path string) (s datastore.Datastore, e errors.Error) {
+	return newDatastore(path, nil)
+}
+
+// NewCSVDatastore creates a new file-based store for the given filepath,
+// in which keyspaces are made of delimited text files.
+// The options are the same as for CREATE PRIMARY INDEX ... WITH {"format":"csv"}
+func NewCSVDatastore(path string, with value.Value) (s datastore.Datastore, e errors.Error) {
+	options, ok, e := newCSVOptions(with)
+	if e != nil {
+		return nil, e
+	}
+	if !ok {
+		options = defaultCSVOptions()
+	}
+	return newDatastore(path, options)
+}
+
+func newDatastore(path string, options *csvOptions) (s datastore.Datastore, e errors.Error) {
 	path, er := filepath.Abs(path)
 	if er != nil {
 		return nil, errors.NewFileDatastoreError(er, "")
 	}
 
-	fs := &store{path: path, users: make(map[string]*datastore.User, 4)}
+	fs := &store{path: path, users: make(map[string]*datastore.User, 4), csvOptions: options}
 
 	e = fs.loadNamespaces()
 	if e != nil {
@@ -380,6 +400,22 @@ type keyspace struct {
 	name      string
 	fi        datastore.Indexer
 	fileLock  sync.Mutex
+	csvLock   sync.RWMutex
+	csv       *csvSource // set for keyspaces made of delimited text files
+	mutations *datastore.MutationLog
+}
+
+// the format can change under running requests, which keep the source they started with
+func (b *keyspace) csvSource() *csvSource {
+	b.csvLock.RLock()
+	defer b.csvLock.RUnlock()
+	return b.csv
+}
+
+func (b *keyspace) setCSVSource(csv *csvSource) {
+	b.csvLock.Lock()
+	b.csv = csv
+	b.csvLock.Unlock()
 }
 
 func (b *keyspace) NamespaceId() string {
@@ -444,6 +480,10 @@ func (this *keyspace) Stats(context datastore.QueryContext, which []datastore.Ke
 }
 
 func (b *keyspace) Count(context datastore.QueryContext) (int64, errors.Error) {
+	if csv := b.csvSource(); csv != nil {
+		keys, _, _, err := csv.load()
+		return int64(len(keys)), err
+	}
 	dirEntries, er := ioutil.ReadDir(b.path())
 	if er != nil {
 		return 0, errors.NewFileDatastoreError(er, "")
@@ -452,6 +492,10 @@ func (b *keyspace) Count(context datastore.QueryContext) (int64, errors.Error) {
 }
 
 func (b *keyspace) Size(context datastore.QueryContext) (int64, errors.Error) {
+	if csv := b.csvSource(); csv != nil {
+		_, _, size, err := csv.load()
+		return size, err
+	}
 	dirEntries, er := ioutil.ReadDir(b.path())
 	if er != nil {
 		return 0, errors.NewFileDatastoreError(er, "")
@@ -475,6 +519,10 @@ func (b *keyspace) Fetch(keys []string, keysMap map[string]value.AnnotatedValue,
 	context datastore.QueryContext, subPaths []string) []errors.Error {
 	var errs []errors.Error
 
+	if csv := b.csvSource(); csv != nil {
+		return b.fetchCSV(csv, keys, keysMap)
+	}
+
 	for _, k := range keys {
 		item, e := b.fetchOne(k)
 
@@ -510,6 +558,26 @@ func (b *keyspace) fetchOne(key string) (value.AnnotatedValue, errors.Error) {
 	return item, e
 }
 
+func (b *keyspace) fetchCSV(csv *csvSource, keys []string, keysMap map[string]value.AnnotatedValue) []errors.Error {
+	_, docs, _, err := csv.load()
+	if err != nil {
+		return []errors.Error{err}
+	}
+
+	for _, k := range keys {
+		doc, ok := docs[k]
+		if !ok {
+			continue
+		}
+
+		// documents are shared between requests, hand out copies
+		item := value.NewAnnotatedValue(doc.CopyForUpdate())
+		item.SetId(k)
+		keysMap[k] = item
+	}
+	return nil
+}
+
 const (
 	INSERT = 0x01
 	UPDATE = 0x02
@@ -532,6 +600,10 @@ func opToString(op int) string {
 
 func (b *keyspace) performOp(op int, kvPairs []value.Pair, context datastore.QueryContext) ([]value.Pair, errors.Error) {
 
+	if b.csvSource() != nil {
+		return nil, errors.NewFileReadOnlyError(nil, b.Name())
+	}
+
 	if len(kvPairs) == 0 {
 		return nil, errors.NewFileNoKeysInsertError(nil, "keyspace "+b.Name())
 	}
@@ -551,9 +623,13 @@ func (b *keyspace) performOp(op int, kvPairs []value.Pair, context datastore.Que
 		value, _ := json.Marshal(kv.Value.Actual())
 		filename := filepath.Join(b.path(), key+".json")
 
+		mutationType := datastore.MUTATION_UPDATE
+
 		switch op {
 
 		case INSERT:
+			mutationType = datastore.MUTATION_INSERT
+
 			// add the key only if it doesn't exist
 			if _, err = os.Stat(filename); err == nil {
 				err = errors.NewFileKeyExists(nil, "Key (File) "+filename)
@@ -575,6 +651,10 @@ func (b *keyspace) performOp(op int, kvPairs []value.Pair, context datastore.Que
 			}
 
 		case UPSERT:
+			if _, err = os.Stat(filename); err != nil {
+				mutationType = datastore.MUTATION_INSERT
+			}
+
 			// open the file for writing, if doesn't exist then create
 			if file, err = os.OpenFile(filename, os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0666); err == nil {
 				_, err = file.Write(value)
@@ -586,6 +666,7 @@ func (b *keyspace) performOp(op int, kvPairs []value.Pair, context datastore.Que
 			returnErr = errors.NewFileDMLError(returnErr, opToString(op)+" Failed "+err.Error())
 		} else {
 			insertedKeys = append(insertedKeys, kv)
+			b.mutations.Add(mutationType, key, kv.Value)
 		}
 	}
 
@@ -606,6 +687,9 @@ func (b *keyspace) Upsert(upserts []value.Pair, context datastore.QueryContext)
 }
 
 func (b *keyspace) Delete(deletes []value.Pair, context datastore.QueryContext) ([]value.Pair, errors.Error) {
+	if b.csvSource() != nil {
+		return nil, errors.NewFileReadOnlyError(nil, b.Name())
+	}
 
 	var fileError []string
 	var deleted []value.Pair
@@ -618,6 +702,7 @@ func (b *keyspace) Delete(deletes []value.Pair, context datastore.QueryContext)
 			}
 		} else {
 			deleted = append(deleted, pair)
+			b.mutations.Add(datastore.MUTATION_DELETE, key, nil)
 		}
 	}
 
@@ -629,6 +714,16 @@ func (b *keyspace) Delete(deletes []value.Pair, context datastore.QueryContext)
 	return deleted, nil
 }
 
+// Only changes made through the datastore are reported, not changes made
+// to the files directly
+func (b *keyspace) Mutations(since uint64, limit int, wait time.Duration) ([]*datastore.Mutation, errors.Error) {
+	return b.mutations.Mutations(since, limit, wait)
+}
+
+func (b *keyspace) LastSeqno() uint64 {
+	return b.mutations.LastSeqno()
+}
+
 func (b *keyspace) Release(close bool) {
 }
 
@@ -657,6 +752,7 @@ func newKeyspace(p *namespace, dir string) (b *keyspace, e errors.Error) {
 	b = new(keyspace)
 	b.namespace = p
 	b.name = dir
+	b.mutations = datastore.NewMutationLog(0)
 
 	fi, er := os.Stat(b.path())
 	if er != nil {
@@ -667,6 +763,12 @@ func newKeyspace(p *namespace, dir string) (b *keyspace, e errors.Error) {
 		return nil, errors.NewFileKeyspaceNotDirError(nil, "Keyspace path "+dir)
 	}
 
+	if p.store.csvOptions != nil {
+		b.csv = newCSVSource(b.path(), p.store.csvOptions)
+	} else if hasCSVFiles(b.path()) {
+		b.csv = newCSVSource(b.path(), defaultCSVOptions())
+	}
+
 	b.fi = newFileIndexer(b)
 	b.fi.CreatePrimaryIndex("", "#primary", nil)
 
@@ -739,8 +841,24 @@ func (fi *fileIndexer) Indexes() ([]datastore.Index, errors.Error) {
 	return []datastore.Index{fi.primary}, nil
 }
 
+// The file datastore always has a primary index, so creating one is only
+// of use to change the format of the keyspace documents via the WITH clause
 func (fi *fileIndexer) CreatePrimaryIndex(requestId, name string, with value.Value) (
 	datastore.PrimaryIndex, errors.Error) {
+	if with != nil {
+		if _, ok := with.Field("format"); ok {
+			options, isCSV, err := newCSVOptions(with)
+			if err != nil {
+				return nil, err
+			}
+			if isCSV {
+				fi.keyspace.setCSVSource(newCSVSource(fi.keyspace.path(), options))
+			} else {
+				fi.keyspace.setCSVSource(nil)
+			}
+		}
+	}
+
 	if fi.primary == nil {
 		pi := new(primaryIndex)
 		fi.primary = pi
@@ -875,6 +993,11 @@ func (pi *primaryIndex) Scan(requestId string, span *datastore.Span, distinct bo
 		}
 	}
 
+	if csv := pi.keyspace.csvSource(); csv != nil {
+		pi.scanCSV(csv, span, low, high, limit, conn)
+		return
+	}
+
 	dirEntries, er := ioutil.ReadDir(pi.keyspace.path())
 	if er != nil {
 		conn.Error(errors.NewFileDatastoreError(er, ""))
@@ -917,6 +1040,11 @@ func (pi *primaryIndex) ScanEntries(requestId string, limit int64, cons datastor
 	vector timestamp.Vector, conn *datastore.IndexConnection) {
 	defer conn.Sender().Close()
 
+	if csv := pi.keyspace.csvSource(); csv != nil {
+		pi.scanCSV(csv, nil, "", "", limit, conn)
+		return
+	}
+
 	dirEntries, er := ioutil.ReadDir(pi.keyspace.path())
 	if er != nil {
 		conn.Error(errors.NewFileDatastoreError(er, ""))
@@ -934,6 +1062,43 @@ func (pi *primaryIndex) ScanEntries(requestId string, limit int64, cons datastor
 	}
 }
 
+// scanCSV sends the keys of a csv keyspace that fall within the span, if any
+func (pi *primaryIndex) scanCSV(csv *csvSource, span *datastore.Span, low, high string, limit int64,
+	conn *datastore.IndexConnection) {
+	keys, _, _, err := csv.load()
+	if err != nil {
+		conn.Error(err)
+		return
+	}
+
+	var n int64 = 0
+	for _, id := range keys {
+		if limit > 0 && n >= limit {
+			break
+		}
+
+		if span != nil {
+			if low != "" &&
+				(id < low ||
+					(id == low && (span.Range.Inclusion&datastore.LOW == 0))) {
+				continue
+			}
+
+			if high != "" &&
+				(id > high ||
+					(id == high && (span.Range.Inclusion&datastore.HIGH == 0))) {
+				break
+			}
+		}
+
+		entry := datastore.IndexEntry{PrimaryKey: id}
+		if !conn.Sender().SendEntry(&entry) {
+			return
+		}
+		n++
+	}
+}
+
 func fetch(path string) (item value.AnnotatedValue, e errors.Error) {
 	bytes, er := ioutil.ReadFile(path)
 	if er != nil {
@@ -10,7 +10,10 @@ package file
 
 import (
 	"fmt"
+	"io/ioutil"
 	"math"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -186,6 +189,93 @@ func TestFile(t *testing.T) {
 
 }
 
+func TestCSV(t *testing.T) {
+	dir, er := ioutil.TempDir("", "csvstore")
+	if er != nil {
+		t.Fatalf("failed to create directory: %v", er)
+	}
+	defer os.RemoveAll(dir)
+
+	ksPath := filepath.Join(dir, "default", "people")
+	os.MkdirAll(ksPath, 0755)
+	data := "id;name;age;member;joined;zip\n" +
+		"1;'Smith; John';42;true;2020-01-31;01234\n" +
+		"2;'O''Brien';3.5;false;;98765\n"
+	er = ioutil.WriteFile(filepath.Join(ksPath, "people.csv"), []byte(data), 0644)
+	if er != nil {
+		t.Fatalf("failed to write csv file: %v", er)
+	}
+
+	options := value.NewValue(map[string]interface{}{"format": "csv", "delimiter": ";", "quote": "'", "key": "id"})
+	store, err := NewCSVDatastore(dir, options)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+
+	namespace, err := store.NamespaceByName("default")
+	if err != nil {
+		t.Fatalf("failed to get namespace: %v", err)
+	}
+
+	keyspace, err := namespace.KeyspaceByName("people")
+	if err != nil {
+		t.Fatalf("failed to get keyspace: %v", err)
+	}
+
+	count, err := keyspace.Count(datastore.NULL_QUERY_CONTEXT)
+	if err != nil || count != 2 {
+		t.Errorf("expected 2 documents, got %v %v", count, err)
+	}
+
+	docs := make(map[string]value.AnnotatedValue, 2)
+	errs := keyspace.Fetch([]string{"1", "2", "3"}, docs, datastore.NULL_QUERY_CONTEXT, nil)
+	if errs != nil || len(docs) != 2 {
+		t.Fatalf("failed to fetch documents: %v", errs)
+	}
+
+	expected := map[string]interface{}{
+		"id": int64(1), "name": "Smith; John", "age": int64(42), "member": true, "joined": "2020-01-31", "zip": "01234",
+	}
+	if !docs["1"].Equals(value.NewValue(expected)).Truth() {
+		t.Errorf("unexpected document %v", docs["1"])
+	}
+
+	expected = map[string]interface{}{
+		"id": int64(2), "name": "O'Brien", "age": 3.5, "member": false, "joined": nil, "zip": int64(98765),
+	}
+	if !docs["2"].Equals(value.NewValue(expected)).Truth() {
+		t.Errorf("unexpected document %v", docs["2"])
+	}
+
+	_, err = keyspace.Insert([]value.Pair{value.Pair{Name: "3", Value: docs["1"]}}, datastore.NULL_QUERY_CONTEXT)
+	if err == nil {
+		t.Errorf("insert into a csv keyspace should have failed")
+	}
+
+	// requests keep the format they started with while it changes
+	indexer, _ := keyspace.Indexer(datastore.DEFAULT)
+	done := make(chan bool)
+	go func() {
+		for i := 0; i < 100; i++ {
+			indexer.CreatePrimaryIndex("", "#primary", options)
+		}
+		close(done)
+	}()
+	for i := 0; i < 100; i++ {
+		count, err = keyspace.Count(datastore.NULL_QUERY_CONTEXT)
+		if err != nil || count != 2 {
+			t.Errorf("expected 2 documents, got %v %v", count, err)
+		}
+	}
+	<-done
+
+	_, err = indexer.CreatePrimaryIndex("", "#primary", value.NewValue(map[string]interface{}{"format": "json"}))
+	count, _ = keyspace.Count(datastore.NULL_QUERY_CONTEXT)
+	if err != nil || count != 1 {
+		t.Errorf("expected the csv file as the only document, got %v %v", count, err)
+	}
+}
+
 type testingContext struct {
 	t *testing.T
 }
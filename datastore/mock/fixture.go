@@ -0,0 +1,625 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package mock
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/parser/n1ql"
+	"github.com/couchbase/query/value"
+)
+
+// A fixture describes the contents of a mock store, so that plans can be
+// produced and verified against realistic index layouts without a cluster:
+//
+//	{
+//	  "namespaces": [{
+//	    "name": "default",
+//	    "keyspaces": [ <keyspace>, ... ],
+//	    "buckets": [{
+//	      "name": "travel",
+//	      "scopes": [{"name": "inventory", "collections": [ <keyspace>, ... ]}]
+//	    }]
+//	  }]
+//	}
+//
+// where a keyspace is
+//
+//	{
+//	  "name": "airline",
+//	  "documents": {"airline_10": {...}, ...},
+//	  "indexes": [
+//	    {"name": "#primary", "primary": true},
+//	    {"name": "ix_country", "keys": ["country", "name DESC"], "condition": "type = \"airline\"", "state": "online"}
+//	  ],
+//	  "histograms": {
+//	    "country": {"count": 187, "distinct": 3, "min": "France", "max": "United States",
+//	      "bins": [{"count": 21, "distinct": 1, "min": "France", "max": "France"}, ...]}
+//	  }
+//	}
+//
+// Index states default to online. Histograms are keyed by expression and are
+// returned as index statistics for the leading key of an index, and by
+// UPDATE STATISTICS, which computes them from the documents for terms that
+// the fixture does not declare.
+
+type fixture struct {
+	Namespaces []*fixtureNamespace `json:"namespaces"`
+}
+
+type fixtureNamespace struct {
+	Name      string             `json:"name"`
+	Keyspaces []*fixtureKeyspace `json:"keyspaces"`
+	Buckets   []*fixtureBucket   `json:"buckets"`
+}
+
+type fixtureBucket struct {
+	Name   string          `json:"name"`
+	Scopes []*fixtureScope `json:"scopes"`
+}
+
+type fixtureScope struct {
+	Name        string             `json:"name"`
+	Collections []*fixtureKeyspace `json:"collections"`
+}
+
+type fixtureKeyspace struct {
+	Name       string                       `json:"name"`
+	Documents  map[string]json.RawMessage   `json:"documents"`
+	Indexes    []*fixtureIndex              `json:"indexes"`
+	Histograms map[string]*fixtureHistogram `json:"histograms"`
+}
+
+type fixtureIndex struct {
+	Name      string   `json:"name"`
+	Primary   bool     `json:"primary"`
+	Keys      []string `json:"keys"`
+	Condition string   `json:"condition"`
+	State     string   `json:"state"`
+	Message   string   `json:"message"`
+}
+
+type fixtureHistogram struct {
+	Count    int64               `json:"count"`
+	Distinct int64               `json:"distinct"`
+	Min      json.RawMessage     `json:"min"`
+	Max      json.RawMessage     `json:"max"`
+	Bins     []*fixtureHistogram `json:"bins"`
+}
+
+// NewFixtureDatastore creates a mock store from the fixture file at path
+func NewFixtureDatastore(path string) (datastore.Datastore, errors.Error) {
+	bytes, e := ioutil.ReadFile(path)
+	if e != nil {
+		return nil, errors.NewOtherDatastoreError(e, "could not read mock fixture "+path)
+	}
+	f := &fixture{}
+	e = json.Unmarshal(bytes, f)
+	if e != nil {
+		return nil, errors.NewOtherDatastoreError(e, "could not parse mock fixture "+path)
+	}
+
+	s := &store{path: "fixture=" + path, params: map[string]int{}, namespaces: map[string]*namespace{}, namespaceNames: []string{}}
+	s.statUpdater = &statUpdater{store: s}
+	for _, fn := range f.Namespaces {
+		if fn.Name == "" || s.namespaces[fn.Name] != nil {
+			return nil, fixtureError(path, "namespace", fn.Name)
+		}
+		p := &namespace{store: s, name: fn.Name, keyspaces: map[string]*keyspace{}, keyspaceNames: []string{},
+			buckets: map[string]*bucket{}, bucketNames: []string{}}
+		for _, fk := range fn.Keyspaces {
+			if fk.Name == "" || p.keyspaces[fk.Name] != nil {
+				return nil, fixtureError(path, "keyspace", fk.Name)
+			}
+			b, err := newFixtureKeyspace(p, nil, fk)
+			if err != nil {
+				return nil, err
+			}
+			p.keyspaces[b.name] = b
+			p.keyspaceNames = append(p.keyspaceNames, b.name)
+		}
+		for _, fb := range fn.Buckets {
+			if fb.Name == "" || p.buckets[fb.Name] != nil {
+				return nil, fixtureError(path, "bucket", fb.Name)
+			}
+			bu := &bucket{namespace: p, name: fb.Name, scopes: map[string]*scope{}, scopeNames: []string{}}
+			for _, fs := range fb.Scopes {
+				if fs.Name == "" || bu.scopes[fs.Name] != nil {
+					return nil, fixtureError(path, "scope", fs.Name)
+				}
+				sc := &scope{bucket: bu, name: fs.Name, keyspaces: map[string]*keyspace{}, keyspaceNames: []string{}}
+				for _, fk := range fs.Collections {
+					if fk.Name == "" || sc.keyspaces[fk.Name] != nil {
+						return nil, fixtureError(path, "collection", fk.Name)
+					}
+					b, err := newFixtureKeyspace(p, sc, fk)
+					if err != nil {
+						return nil, err
+					}
+					sc.keyspaces[b.name] = b
+					sc.keyspaceNames = append(sc.keyspaceNames, b.name)
+				}
+				bu.scopes[sc.name] = sc
+				bu.scopeNames = append(bu.scopeNames, sc.name)
+			}
+			p.buckets[bu.name] = bu
+			p.bucketNames = append(p.bucketNames, bu.name)
+		}
+		s.namespaces[p.name] = p
+		s.namespaceNames = append(s.namespaceNames, p.name)
+	}
+	return s, nil
+}
+
+func fixtureError(path, what, name string) errors.Error {
+	return errors.NewOtherDatastoreError(nil, fmt.Sprintf("invalid or duplicate %s name '%s' in mock fixture %s", what, name, path))
+}
+
+func newFixtureKeyspace(p *namespace, sc *scope, fk *fixtureKeyspace) (*keyspace, errors.Error) {
+	b := &keyspace{namespace: p, scope: sc, name: fk.Name, docs: make(map[string]value.Value, len(fk.Documents)),
+		docKeys: make([]string, 0, len(fk.Documents)), mutations: datastore.NewMutationLog(0),
+		stats: make(map[string]*histogram, len(fk.Histograms))}
+
+	for k, d := range fk.Documents {
+		b.docs[k] = value.NewValue([]byte(d))
+		b.docKeys = append(b.docKeys, k)
+		b.docSize += int64(len(d))
+	}
+	sort.Strings(b.docKeys)
+
+	for term, fh := range fk.Histograms {
+		key, e := parseIndexKey(term)
+		if e != nil {
+			return nil, errors.NewOtherDatastoreError(e, "invalid histogram term "+term+" for "+b.QualifiedName())
+		}
+		b.stats[key.Expr.String()] = newFixtureHistogram(fh)
+	}
+
+	mi := newMockIndexer(b).(*mockIndexer)
+	b.mi = mi
+	for _, fi := range fk.Indexes {
+		if fi.Primary {
+			name := fi.Name
+			if name == "" {
+				name = "#primary"
+			}
+			mi.CreatePrimaryIndex("", name, nil)
+			continue
+		}
+		if fi.Name == "" || mi.indexes[fi.Name] != nil {
+			return nil, errors.NewOtherDatastoreError(nil, "invalid or duplicate index name '"+fi.Name+"' for "+b.QualifiedName())
+		}
+		idx, err := newSecondaryIndex(mi, fi)
+		if err != nil {
+			return nil, err
+		}
+		mi.indexes[idx.name] = idx
+	}
+	return b, nil
+}
+
+// bucket is a mock-based Bucket, with its scopes and collections loaded from a fixture
+type bucket struct {
+	namespace  *namespace
+	name       string
+	scopes     map[string]*scope
+	scopeNames []string
+}
+
+func (b *bucket) Id() string {
+	return b.Name()
+}
+
+func (b *bucket) Name() string {
+	return b.name
+}
+
+func (b *bucket) AuthKey() string {
+	return b.name
+}
+
+func (b *bucket) Uid() string {
+	return b.name
+}
+
+func (b *bucket) NamespaceId() string {
+	return b.namespace.Id()
+}
+
+func (b *bucket) Namespace() datastore.Namespace {
+	return b.namespace
+}
+
+func (b *bucket) DefaultKeyspace() (datastore.Keyspace, errors.Error) {
+	if sc, ok := b.scopes["_default"]; ok {
+		if ks, ok := sc.keyspaces["_default"]; ok {
+			return ks, nil
+		}
+	}
+	return nil, errors.NewBucketNoDefaultCollectionError(b.name)
+}
+
+func (b *bucket) ScopeIds() ([]string, errors.Error) {
+	return b.ScopeNames()
+}
+
+func (b *bucket) ScopeNames() ([]string, errors.Error) {
+	return b.scopeNames, nil
+}
+
+func (b *bucket) ScopeById(id string) (datastore.Scope, errors.Error) {
+	return b.ScopeByName(id)
+}
+
+func (b *bucket) ScopeByName(name string) (datastore.Scope, errors.Error) {
+	sc, ok := b.scopes[name]
+	if !ok {
+		return nil, errors.NewCbScopeNotFoundError(nil, b.namespace.name+":"+b.name+"."+name)
+	}
+	return sc, nil
+}
+
+func (b *bucket) CreateScope(name string) errors.Error {
+	return errors.NewOtherNotSupportedError(nil, "CREATE SCOPE is not supported for mock datastore.")
+}
+
+func (b *bucket) DropScope(name string) errors.Error {
+	return errors.NewOtherNotSupportedError(nil, "DROP SCOPE is not supported for mock datastore.")
+}
+
+// scope is a mock-based Scope
+type scope struct {
+	bucket        *bucket
+	name          string
+	keyspaces     map[string]*keyspace
+	keyspaceNames []string
+}
+
+func (sc *scope) Id() string {
+	return sc.Name()
+}
+
+func (sc *scope) Name() string {
+	return sc.name
+}
+
+func (sc *scope) AuthKey() string {
+	return sc.bucket.name + ":" + sc.name
+}
+
+func (sc *scope) BucketId() string {
+	return sc.bucket.Id()
+}
+
+func (sc *scope) Bucket() datastore.Bucket {
+	return sc.bucket
+}
+
+func (sc *scope) KeyspaceIds() ([]string, errors.Error) {
+	return sc.KeyspaceNames()
+}
+
+func (sc *scope) KeyspaceNames() ([]string, errors.Error) {
+	return sc.keyspaceNames, nil
+}
+
+func (sc *scope) KeyspaceById(id string) (datastore.Keyspace, errors.Error) {
+	return sc.KeyspaceByName(id)
+}
+
+func (sc *scope) KeyspaceByName(name string) (datastore.Keyspace, errors.Error) {
+	ks, ok := sc.keyspaces[name]
+	if !ok {
+		return nil, errors.NewOtherKeyspaceNotFoundError(nil, name+" for Mock datastore")
+	}
+	return ks, nil
+}
+
+func (sc *scope) CreateCollection(name string) errors.Error {
+	return errors.NewOtherNotSupportedError(nil, "CREATE COLLECTION is not supported for mock datastore.")
+}
+
+func (sc *scope) DropCollection(name string) errors.Error {
+	return errors.NewOtherNotSupportedError(nil, "DROP COLLECTION is not supported for mock datastore.")
+}
+
+// histogram is the distribution of the values of a term, as declared by a
+// fixture or computed by UPDATE STATISTICS.
+// It implements datastore.Statistics.
+type histogram struct {
+	count    int64
+	distinct int64
+	min      value.Value
+	max      value.Value
+	bins     []*histogram
+}
+
+func newFixtureHistogram(fh *fixtureHistogram) *histogram {
+	rv := &histogram{count: fh.Count, distinct: fh.Distinct}
+	if len(fh.Min) > 0 {
+		rv.min = value.NewValue([]byte(fh.Min))
+	}
+	if len(fh.Max) > 0 {
+		rv.max = value.NewValue([]byte(fh.Max))
+	}
+	for _, b := range fh.Bins {
+		rv.bins = append(rv.bins, newFixtureHistogram(b))
+	}
+	return rv
+}
+
+// newHistogram computes a histogram of nbins bins of roughly equal size
+// from values sorted in collation order
+func newHistogram(vals value.Values, nbins int) *histogram {
+	rv := &histogram{count: int64(len(vals)), distinct: countDistinct(vals)}
+	if len(vals) == 0 {
+		return rv
+	}
+	rv.min = vals[0]
+	rv.max = vals[len(vals)-1]
+	if nbins <= 1 {
+		return rv
+	}
+
+	size := (len(vals) + nbins - 1) / nbins
+	for start := 0; start < len(vals); {
+		end := start + size
+		if end > len(vals) {
+			end = len(vals)
+		}
+
+		// a value never spans two bins
+		for end < len(vals) && vals[end].Collate(vals[end-1]) == 0 {
+			end++
+		}
+		bin := vals[start:end]
+		rv.bins = append(rv.bins, &histogram{count: int64(len(bin)), distinct: countDistinct(bin),
+			min: bin[0], max: bin[len(bin)-1]})
+		start = end
+	}
+	return rv
+}
+
+func countDistinct(sorted value.Values) int64 {
+	var rv int64
+	for i, v := range sorted {
+		if i == 0 || v.Collate(sorted[i-1]) != 0 {
+			rv++
+		}
+	}
+	return rv
+}
+
+func (this *histogram) Count() (int64, errors.Error) {
+	return this.count, nil
+}
+
+func (this *histogram) Min() (value.Values, errors.Error) {
+	if this.min == nil {
+		return nil, nil
+	}
+	return value.Values{this.min}, nil
+}
+
+func (this *histogram) Max() (value.Values, errors.Error) {
+	if this.max == nil {
+		return nil, nil
+	}
+	return value.Values{this.max}, nil
+}
+
+func (this *histogram) DistinctCount() (int64, errors.Error) {
+	return this.distinct, nil
+}
+
+func (this *histogram) Bins() ([]datastore.Statistics, errors.Error) {
+	rv := make([]datastore.Statistics, len(this.bins))
+	for i, b := range this.bins {
+		rv[i] = b
+	}
+	return rv, nil
+}
+
+func (this *histogram) value(keyspace, term string) value.Value {
+	rv := map[string]interface{}{
+		"count":    this.count,
+		"distinct": this.distinct,
+	}
+	if keyspace != "" {
+		rv["keyspace"] = keyspace
+		rv["term"] = term
+	}
+	if this.min != nil {
+		rv["min"] = this.min
+	}
+	if this.max != nil {
+		rv["max"] = this.max
+	}
+	if len(this.bins) > 0 {
+		bins := make([]interface{}, len(this.bins))
+		for i, b := range this.bins {
+			bins[i] = b.value("", "")
+		}
+		rv["bins"] = bins
+	}
+	return value.NewValue(rv)
+}
+
+func (b *keyspace) histogram(term expression.Expression) *histogram {
+	b.RLock()
+	defer b.RUnlock()
+	return b.stats[term.String()]
+}
+
+const _DEF_HISTOGRAM_BINS = 10
+
+// statUpdater returns the histograms of the requested terms, and of the
+// keys of the requested indexes.
+// Histograms missing from the fixture are computed from the documents.
+type statUpdater struct {
+	store *store
+}
+
+func (this *statUpdater) Name() datastore.StatUpdaterType {
+	return datastore.UPDSTAT_DEFAULT
+}
+
+func (this *statUpdater) UpdateStatistics(ks datastore.Keyspace, indexes []datastore.Index, terms expression.Expressions,
+	with value.Value, conn *datastore.ValueConnection, exContext interface{}, internal bool) {
+	defer close(conn.ValueChannel())
+
+	b, ok := ks.(*keyspace)
+	if !ok {
+		conn.Error(errors.NewOtherNotSupportedError(nil, "UPDATE STATISTICS on "+ks.QualifiedName()))
+		return
+	}
+
+	nbins := _DEF_HISTOGRAM_BINS
+	if with != nil {
+		if v, ok := with.Field("bins"); ok {
+			if n, ok := v.Actual().(float64); ok && n >= 1 {
+				nbins = int(n)
+			} else {
+				conn.Error(errors.NewOtherDatastoreError(nil, fmt.Sprintf("invalid number of bins: %v", v)))
+				return
+			}
+		}
+	}
+
+	all := make(expression.Expressions, 0, len(terms))
+	seen := make(map[string]bool, len(terms))
+	add := func(expr expression.Expression) {
+		if s := expr.String(); !seen[s] {
+			seen[s] = true
+			all = append(all, expr)
+		}
+	}
+	for _, term := range terms {
+		add(term)
+	}
+	for _, index := range indexes {
+		for _, key := range index.RangeKey() {
+			add(key)
+		}
+	}
+
+	for _, term := range all {
+		h := b.histogram(term)
+		if h == nil {
+			vals, err := b.termValues(term)
+			if err != nil {
+				conn.Error(err)
+				return
+			}
+			h = newHistogram(vals, nbins)
+			b.Lock()
+			b.stats[term.String()] = h
+			b.Unlock()
+		}
+
+		select {
+		case conn.ValueChannel() <- h.value(b.QualifiedName(), term.String()):
+		case <-conn.StopChannel():
+			return
+		}
+	}
+}
+
+func (this *statUpdater) DeleteStatistics(ks datastore.Keyspace, terms expression.Expressions,
+	conn *datastore.ValueConnection, exContext interface{}) {
+	defer close(conn.ValueChannel())
+
+	b, ok := ks.(*keyspace)
+	if !ok {
+		conn.Error(errors.NewOtherNotSupportedError(nil, "UPDATE STATISTICS on "+ks.QualifiedName()))
+		return
+	}
+
+	b.Lock()
+	defer b.Unlock()
+	if len(terms) == 0 {
+		b.stats = make(map[string]*histogram)
+		return
+	}
+	for _, term := range terms {
+		delete(b.stats, term.String())
+	}
+}
+
+// termValues evaluates a term over the documents of a keyspace, returning the
+// values that are not missing in collation order
+func (b *keyspace) termValues(term expression.Expression) (value.Values, errors.Error) {
+	context := expression.NewIndexContext()
+	keys := b.keys(0)
+	rv := make(value.Values, 0, len(keys))
+	for _, k := range keys {
+		doc, err := b.fetchOne(k)
+		if err != nil {
+			continue
+		}
+		doc.SetId(k)
+		v, e := term.Evaluate(doc, context)
+		if e != nil {
+			return nil, errors.NewEvaluationError(e, term.String())
+		}
+		if v.Type() != value.MISSING {
+			rv = append(rv, v)
+		}
+	}
+	sort.Slice(rv, func(i, j int) bool { return rv[i].Collate(rv[j]) < 0 })
+	return rv, nil
+}
+
+// parseIndexKey parses a fixture index key such as
+// "DISTINCT ARRAY v.name FOR v IN visits END INCLUDE MISSING DESC"
+func parseIndexKey(key string) (*datastore.IndexKey, error) {
+	rv := &datastore.IndexKey{}
+	text := strings.TrimSpace(key)
+	for {
+		upper := strings.ToUpper(text)
+		if strings.HasSuffix(upper, " DESC") {
+			rv.Attributes |= datastore.IK_DESC
+			text = strings.TrimSpace(text[:len(text)-5])
+		} else if strings.HasSuffix(upper, " ASC") {
+			text = strings.TrimSpace(text[:len(text)-4])
+		} else if strings.HasSuffix(upper, " INCLUDE MISSING") {
+			rv.Attributes |= datastore.IK_MISSING
+			text = strings.TrimSpace(text[:len(text)-16])
+		} else {
+			break
+		}
+	}
+
+	// array keys are not expressions in their own right
+	all, distinct := false, false
+	for _, prefix := range []string{"ALL ", "EACH ", "DISTINCT "} {
+		if strings.HasPrefix(strings.ToUpper(text), prefix) {
+			all = true
+			distinct = distinct || prefix == "DISTINCT "
+			text = strings.TrimSpace(text[len(prefix):])
+		}
+	}
+
+	expr, err := n1ql.ParseExpression(text)
+	if err != nil {
+		return nil, err
+	}
+	if all {
+		expr = expression.NewAll(expr, distinct)
+	}
+	rv.Expr = expr
+	return rv, nil
+}
@@ -0,0 +1,377 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package mock
+
+import (
+	"sort"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/parser/n1ql"
+	"github.com/couchbase/query/timestamp"
+	"github.com/couchbase/query/value"
+)
+
+// secondaryIndex is an index declared by a fixture.
+// Entries are computed from the documents at scan time, so that they
+// reflect DML applied to the keyspace.
+type secondaryIndex struct {
+	name      string
+	indexer   *mockIndexer
+	rangeKey  datastore.IndexKeys
+	condition expression.Expression
+	state     datastore.IndexState
+	msg       string
+}
+
+type indexEntry struct {
+	keys value.Values
+	id   string
+}
+
+func newSecondaryIndex(indexer *mockIndexer, fi *fixtureIndex) (*secondaryIndex, errors.Error) {
+	qualifiedName := indexer.keyspace.QualifiedName()
+	if len(fi.Keys) == 0 {
+		return nil, errors.NewOtherDatastoreError(nil, "no keys for index "+fi.Name+" on "+qualifiedName)
+	}
+
+	rv := &secondaryIndex{
+		name:     fi.Name,
+		indexer:  indexer,
+		rangeKey: make(datastore.IndexKeys, len(fi.Keys)),
+		state:    datastore.ONLINE,
+		msg:      fi.Message,
+	}
+	if fi.State != "" {
+		rv.state = datastore.IndexState(fi.State)
+	}
+
+	for i, k := range fi.Keys {
+		key, e := parseIndexKey(k)
+		if e != nil {
+			return nil, errors.NewOtherDatastoreError(e, "invalid key "+k+" for index "+fi.Name+" on "+qualifiedName)
+		}
+		rv.rangeKey[i] = key
+	}
+	if fi.Condition != "" {
+		expr, e := n1ql.ParseExpression(fi.Condition)
+		if e != nil {
+			return nil, errors.NewOtherDatastoreError(e, "invalid condition for index "+fi.Name+" on "+qualifiedName)
+		}
+		rv.condition = expr
+	}
+	return rv, nil
+}
+
+func (this *secondaryIndex) BucketId() string {
+	return this.indexer.BucketId()
+}
+
+func (this *secondaryIndex) ScopeId() string {
+	return this.indexer.ScopeId()
+}
+
+func (this *secondaryIndex) KeyspaceId() string {
+	return this.indexer.KeyspaceId()
+}
+
+func (this *secondaryIndex) Id() string {
+	return this.Name()
+}
+
+func (this *secondaryIndex) Name() string {
+	return this.name
+}
+
+func (this *secondaryIndex) Type() datastore.IndexType {
+	return datastore.DEFAULT
+}
+
+func (this *secondaryIndex) Indexer() datastore.Indexer {
+	return this.indexer
+}
+
+func (this *secondaryIndex) SeekKey() expression.Expressions {
+	return nil
+}
+
+func (this *secondaryIndex) RangeKey() expression.Expressions {
+	rv := make(expression.Expressions, len(this.rangeKey))
+	for i, k := range this.rangeKey {
+		rv[i] = k.Expr
+	}
+	return rv
+}
+
+func (this *secondaryIndex) RangeKey2() datastore.IndexKeys {
+	return this.rangeKey
+}
+
+func (this *secondaryIndex) Condition() expression.Expression {
+	return this.condition
+}
+
+func (this *secondaryIndex) IsPrimary() bool {
+	return false
+}
+
+func (this *secondaryIndex) State() (state datastore.IndexState, msg string, err errors.Error) {
+	return this.state, this.msg, nil
+}
+
+// Statistics returns the histogram of the leading key, if known
+func (this *secondaryIndex) Statistics(requestId string, span *datastore.Span) (datastore.Statistics, errors.Error) {
+	h := this.indexer.keyspace.histogram(this.rangeKey[0].Expr)
+	if h == nil {
+		return nil, nil
+	}
+	return h, nil
+}
+
+func (this *secondaryIndex) Drop(requestId string) errors.Error {
+	return errors.NewOtherIdxNoDrop(nil, "This index cannot be dropped for Mock datastore.")
+}
+
+func (this *secondaryIndex) Scan(requestId string, span *datastore.Span, distinct bool, limit int64,
+	cons datastore.ScanConsistency, vector timestamp.Vector, conn *datastore.IndexConnection) {
+	this.Scan2(requestId, spans2(span, len(this.rangeKey)), false, distinct, false, nil, 0, limit, cons, vector, conn)
+}
+
+func (this *secondaryIndex) Scan2(requestId string, spans datastore.Spans2, reverse, distinctAfterProjection,
+	ordered bool, projection *datastore.IndexProjection, offset, limit int64,
+	cons datastore.ScanConsistency, vector timestamp.Vector, conn *datastore.IndexConnection) {
+	defer conn.Sender().Close()
+
+	entries, err := this.scan(spans)
+	if err != nil {
+		conn.Error(err)
+		return
+	}
+	if reverse {
+		for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+			entries[i], entries[j] = entries[j], entries[i]
+		}
+	}
+
+	var seen map[string]bool
+	if distinctAfterProjection {
+		seen = make(map[string]bool, len(entries))
+	}
+
+	n := int64(0)
+	for _, e := range entries {
+		entryKey := e.keys
+		if projection != nil {
+			entryKey = make(value.Values, 0, len(projection.EntryKeys))
+			for _, k := range projection.EntryKeys {
+				if k >= 0 && k < len(e.keys) {
+					entryKey = append(entryKey, e.keys[k])
+				}
+			}
+		}
+		if seen != nil {
+			projected := value.NewValue(entryKey).String()
+			if projection == nil || projection.PrimaryKey {
+				projected += e.id
+			}
+			if seen[projected] {
+				continue
+			}
+			seen[projected] = true
+		}
+
+		if offset > 0 {
+			offset--
+			continue
+		}
+		if limit > 0 && n >= limit {
+			break
+		}
+		if !conn.Sender().SendEntry(&datastore.IndexEntry{PrimaryKey: e.id, EntryKey: entryKey}) {
+			return
+		}
+		n++
+	}
+}
+
+func (this *secondaryIndex) Count(span *datastore.Span, cons datastore.ScanConsistency,
+	vector timestamp.Vector) (int64, errors.Error) {
+	return this.Count2("", spans2(span, len(this.rangeKey)), cons, vector)
+}
+
+func (this *secondaryIndex) Count2(requestId string, spans datastore.Spans2, cons datastore.ScanConsistency,
+	vector timestamp.Vector) (int64, errors.Error) {
+	entries, err := this.scan(spans)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(entries)), nil
+}
+
+func (this *secondaryIndex) CanCountDistinct() bool {
+	return true
+}
+
+// CountDistinct counts the distinct values of the leading key
+func (this *secondaryIndex) CountDistinct(requestId string, spans datastore.Spans2, cons datastore.ScanConsistency,
+	vector timestamp.Vector) (int64, errors.Error) {
+	entries, err := this.scan(spans)
+	if err != nil {
+		return 0, err
+	}
+	var count int64
+	for i, e := range entries {
+		if e.keys[0].Type() > value.NULL && (i == 0 || e.keys[0].Collate(entries[i-1].keys[0]) != 0) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// scan returns the entries matching any of the spans, in index order
+func (this *secondaryIndex) scan(spans datastore.Spans2) ([]*indexEntry, errors.Error) {
+	entries, err := this.entries()
+	if err != nil || len(spans) == 0 {
+		return entries, err
+	}
+
+	rv := entries[:0]
+	for _, e := range entries {
+		for _, span := range spans {
+			if this.matches(e, span) {
+				rv = append(rv, e)
+				break
+			}
+		}
+	}
+	return rv, nil
+}
+
+func (this *secondaryIndex) matches(e *indexEntry, span *datastore.Span2) bool {
+	for i, rg := range span.Ranges {
+		if i >= len(e.keys) {
+			break
+		}
+		v := e.keys[i]
+		if rg.Low != nil {
+			c := v.Collate(rg.Low)
+			if c < 0 || (c == 0 && rg.Inclusion&datastore.LOW == 0) {
+				return false
+			}
+		}
+		if rg.High != nil {
+			c := v.Collate(rg.High)
+			if c > 0 || (c == 0 && rg.Inclusion&datastore.HIGH == 0) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// entries computes the index entries from the current documents.
+// Like GSI, documents for which the leading key is missing are not indexed,
+// unless the key includes missing values, and array keys produce one entry
+// per distinct element.
+func (this *secondaryIndex) entries() ([]*indexEntry, errors.Error) {
+	ks := this.indexer.keyspace
+	context := expression.NewIndexContext()
+	keys := ks.keys(0)
+	rv := make([]*indexEntry, 0, len(keys))
+
+	for _, k := range keys {
+		doc, err := ks.fetchOne(k)
+		if err != nil {
+			continue
+		}
+		doc.SetId(k)
+
+		if this.condition != nil {
+			cv, e := this.condition.Evaluate(doc, context)
+			if e != nil {
+				return nil, errors.NewEvaluationError(e, "index condition")
+			}
+			if !cv.Truth() {
+				continue
+			}
+		}
+
+		tuples := []value.Values{make(value.Values, 0, len(this.rangeKey))}
+		for i, ik := range this.rangeKey {
+			v, vals, e := ik.Expr.EvaluateForIndex(doc, context)
+			if e != nil {
+				return nil, errors.NewEvaluationError(e, "index key")
+			}
+			if vals == nil {
+				vals = value.Values{v}
+			}
+
+			expanded := make([]value.Values, 0, len(tuples)*len(vals))
+			for _, val := range vals {
+				if i == 0 && val.Type() == value.MISSING && !ik.HasAttribute(datastore.IK_MISSING) {
+					continue
+				}
+				for _, t := range tuples {
+					expanded = append(expanded, append(t[:len(t):len(t)], val))
+				}
+			}
+			tuples = expanded
+		}
+
+		for _, t := range tuples {
+			rv = append(rv, &indexEntry{keys: t, id: k})
+		}
+	}
+
+	sort.Slice(rv, func(i, j int) bool {
+		return this.compare(rv[i], rv[j]) < 0
+	})
+	return rv, nil
+}
+
+func (this *secondaryIndex) compare(e1, e2 *indexEntry) int {
+	for i, ik := range this.rangeKey {
+		c := e1.keys[i].Collate(e2.keys[i])
+		if c != 0 {
+			if ik.HasAttribute(datastore.IK_DESC) {
+				return -c
+			}
+			return c
+		}
+	}
+	switch {
+	case e1.id < e2.id:
+		return -1
+	case e1.id > e2.id:
+		return 1
+	}
+	return 0
+}
+
+// spans2 converts a span of the original index API
+func spans2(span *datastore.Span, nkeys int) datastore.Spans2 {
+	if span == nil {
+		return nil
+	}
+	ranges := make(datastore.Ranges2, 0, nkeys)
+	for i := 0; i < nkeys && (i < len(span.Range.Low) || i < len(span.Range.High)); i++ {
+		rg := &datastore.Range2{}
+		if i < len(span.Range.Low) {
+			rg.Low = span.Range.Low[i]
+			rg.Inclusion |= span.Range.Inclusion & datastore.LOW
+		}
+		if i < len(span.Range.High) {
+			rg.High = span.Range.High[i]
+			rg.Inclusion |= span.Range.Inclusion & datastore.HIGH
+		}
+		ranges = append(ranges, rg)
+	}
+	return datastore.Spans2{&datastore.Span2{Ranges: ranges}}
+}
@@ -20,8 +20,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/couchbase/query/auth"
 	"github.com/couchbase/query/datastore"
@@ -45,6 +48,7 @@ type store struct {
 	namespaces     map[string]*namespace
 	namespaceNames []string
 	params         map[string]int
+	statUpdater    *statUpdater
 }
 
 func (s *store) Id() string {
@@ -104,7 +108,7 @@ func (s *store) Inferencers() ([]datastore.Inferencer, errors.Error) {
 }
 
 func (s *store) StatUpdater() (datastore.StatUpdater, errors.Error) {
-	return nil, errors.NewOtherNotImplementedError(nil, "UPDATE STATISTICS")
+	return s.statUpdater, nil
 }
 
 func (s *store) SetConnectionSecurityConfig(conSecConfig *datastore.ConnectionSecurityConfig) {
@@ -186,6 +190,8 @@ type namespace struct {
 	name          string
 	keyspaces     map[string]*keyspace
 	keyspaceNames []string
+	buckets       map[string]*bucket
+	bucketNames   []string
 }
 
 func (p *namespace) DatastoreId() string {
@@ -209,12 +215,14 @@ func (p *namespace) KeyspaceNames() ([]string, errors.Error) {
 }
 
 func (p *namespace) Objects(preload bool) ([]datastore.Object, errors.Error) {
-	rv := make([]datastore.Object, len(p.keyspaceNames))
-	i := 0
+	rv := make([]datastore.Object, 0, len(p.keyspaceNames)+len(p.bucketNames))
 	for _, k := range p.keyspaceNames {
-		rv[i] = datastore.Object{Id: k, Name: k, IsKeyspace: true}
-		i++
+		rv = append(rv, datastore.Object{Id: k, Name: k, IsKeyspace: true})
 	}
+	for _, b := range p.bucketNames {
+		rv = append(rv, datastore.Object{Id: b, Name: b, IsBucket: true})
+	}
+	sort.Slice(rv, func(i, j int) bool { return rv[i].Name < rv[j].Name })
 	return rv, nil
 }
 
@@ -244,27 +252,45 @@ func (p *namespace) MetadataId() string {
 }
 
 func (p *namespace) BucketIds() ([]string, errors.Error) {
-	return datastore.NO_STRINGS, nil
+	return p.BucketNames()
 }
 
 func (p *namespace) BucketNames() ([]string, errors.Error) {
-	return datastore.NO_STRINGS, nil
+	if len(p.bucketNames) == 0 {
+		return datastore.NO_STRINGS, nil
+	}
+	return p.bucketNames, nil
 }
 
 func (p *namespace) BucketById(name string) (datastore.Bucket, errors.Error) {
-	return nil, errors.NewOtherNoBuckets("mock")
+	return p.BucketByName(name)
 }
 
 func (p *namespace) BucketByName(name string) (datastore.Bucket, errors.Error) {
-	return nil, errors.NewOtherNoBuckets("mock")
+	if len(p.buckets) == 0 {
+		return nil, errors.NewOtherNoBuckets("mock")
+	}
+	b, ok := p.buckets[name]
+	if !ok {
+		return nil, errors.NewOtherKeyspaceNotFoundError(nil, name+" for Mock datastore")
+	}
+	return b, nil
 }
 
 // keyspace is a mock-based keyspace.
 type keyspace struct {
+	sync.RWMutex
 	namespace *namespace
 	name      string
 	nitems    int
+	docs      map[string]value.Value // documents loaded from a fixture
+	docKeys   []string               // keys of the fixture documents, in key order
+	docSize   int64
+	scope     *scope // nil unless the keyspace is a collection
 	mi        datastore.Indexer
+	changes   map[string]value.Value // documents modified by DML, nil if deleted
+	mutations *datastore.MutationLog
+	stats     map[string]*histogram // histograms by term
 }
 
 func (b *keyspace) NamespaceId() string {
@@ -276,11 +302,17 @@ func (b *keyspace) Namespace() datastore.Namespace {
 }
 
 func (b *keyspace) ScopeId() string {
-	return ""
+	if b.scope == nil {
+		return ""
+	}
+	return b.scope.Id()
 }
 
 func (b *keyspace) Scope() datastore.Scope {
-	return nil
+	if b.scope == nil {
+		return nil
+	}
+	return b.scope
 }
 
 func (b *keyspace) Id() string {
@@ -296,10 +328,16 @@ func (b *keyspace) Uid() string {
 }
 
 func (b *keyspace) QualifiedName() string {
+	if b.scope != nil {
+		return b.namespace.name + ":" + b.scope.bucket.name + "." + b.scope.name + "." + b.name
+	}
 	return b.namespace.name + ":" + b.name
 }
 
 func (b *keyspace) AuthKey() string {
+	if b.scope != nil {
+		return b.scope.bucket.name + ":" + b.scope.name + ":" + b.name
+	}
 	return b.name
 }
 
@@ -329,11 +367,22 @@ func (this *keyspace) Stats(context datastore.QueryContext, which []datastore.Ke
 }
 
 func (b *keyspace) Count(context datastore.QueryContext) (int64, errors.Error) {
-	return int64(b.nitems), nil
+	b.RLock()
+	defer b.RUnlock()
+	count := int64(b.nitems + len(b.docs))
+	for k, v := range b.changes {
+		base := b.inBase(k)
+		if v == nil && base {
+			count--
+		} else if v != nil && !base {
+			count++
+		}
+	}
+	return count, nil
 }
 
 func (b *keyspace) Size(context datastore.QueryContext) (int64, errors.Error) {
-	return int64(b.nitems) * 25, nil // assumes each document is 25 bytes, see genItem()
+	return int64(b.nitems)*25 + b.docSize, nil // assumes each generated document is 25 bytes, see genItem()
 }
 
 func (b *keyspace) Indexer(name datastore.IndexType) (datastore.Indexer, errors.Error) {
@@ -368,6 +417,19 @@ func (b *keyspace) Fetch(keys []string, keysMap map[string]value.AnnotatedValue,
 }
 
 func (b *keyspace) fetchOne(key string) (value.AnnotatedValue, errors.Error) {
+	b.RLock()
+	doc, changed := b.changes[key]
+	b.RUnlock()
+	if changed {
+		if doc == nil {
+			return nil, errors.NewOtherKeyNotFoundError(nil, fmt.Sprintf("no mock item: %v", key))
+		}
+		return value.NewAnnotatedValue(doc.CopyForUpdate()), nil
+	}
+	if doc, ok := b.docs[key]; ok {
+		return value.NewAnnotatedValue(doc.CopyForUpdate()), nil
+	}
+
 	i, e := strconv.Atoi(key)
 	if e != nil {
 		return nil, errors.NewOtherKeyNotFoundError(e, fmt.Sprintf("no mock item: %v", key))
@@ -388,24 +450,134 @@ func genItem(i int, nitems int) (value.AnnotatedValue, errors.Error) {
 	return doc, nil
 }
 
+// generated returns the position of a key amongst the generated documents
+func (b *keyspace) generated(key string) (int, bool) {
+	i, e := strconv.Atoi(key)
+	return i, e == nil && i >= 0 && i < b.nitems && strconv.Itoa(i) == key
+}
+
+// inBase tells if a key belongs to the generated or fixture documents
+func (b *keyspace) inBase(key string) bool {
+	if _, ok := b.docs[key]; ok {
+		return true
+	}
+	_, generated := b.generated(key)
+	return generated
+}
+
+// exists must be called with the keyspace lock held
+func (b *keyspace) exists(key string) bool {
+	doc, changed := b.changes[key]
+	if changed {
+		return doc != nil
+	}
+	return b.inBase(key)
+}
+
+// DML statements are applied to an in memory overlay of the generated and fixture documents
+func (b *keyspace) performOp(op datastore.MutationType, upsert bool, pairs []value.Pair) ([]value.Pair, errors.Error) {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.changes == nil {
+		b.changes = make(map[string]value.Value, len(pairs))
+	}
+
+	var err errors.Error
+	done := make([]value.Pair, 0, len(pairs))
+	for _, pair := range pairs {
+		exists := b.exists(pair.Name)
+		mutationType := op
+		if upsert {
+			if exists {
+				mutationType = datastore.MUTATION_UPDATE
+			} else {
+				mutationType = datastore.MUTATION_INSERT
+			}
+		} else if exists == (op == datastore.MUTATION_INSERT) {
+			if op == datastore.MUTATION_INSERT {
+				err = errors.NewOtherDatastoreError(nil, "duplicate key: "+pair.Name)
+			}
+			continue
+		}
+
+		if mutationType == datastore.MUTATION_DELETE {
+			b.changes[pair.Name] = nil
+			b.mutations.Add(mutationType, pair.Name, nil)
+		} else {
+			b.changes[pair.Name] = pair.Value.CopyForUpdate()
+			b.mutations.Add(mutationType, pair.Name, pair.Value)
+		}
+		done = append(done, pair)
+	}
+	return done, err
+}
+
 func (b *keyspace) Insert(inserts []value.Pair, context datastore.QueryContext) ([]value.Pair, errors.Error) {
-	// FIXME
-	return nil, errors.NewOtherNotImplementedError(nil, "for Mock datastore")
+	return b.performOp(datastore.MUTATION_INSERT, false, inserts)
 }
 
 func (b *keyspace) Update(updates []value.Pair, context datastore.QueryContext) ([]value.Pair, errors.Error) {
-	// FIXME
-	return nil, errors.NewOtherNotImplementedError(nil, "for Mock datastore")
+	return b.performOp(datastore.MUTATION_UPDATE, false, updates)
 }
 
 func (b *keyspace) Upsert(upserts []value.Pair, context datastore.QueryContext) ([]value.Pair, errors.Error) {
-	// FIXME
-	return nil, errors.NewOtherNotImplementedError(nil, "for Mock datastore")
+	return b.performOp(datastore.MUTATION_UPDATE, true, upserts)
 }
 
 func (b *keyspace) Delete(deletes []value.Pair, context datastore.QueryContext) ([]value.Pair, errors.Error) {
-	// FIXME
-	return nil, errors.NewOtherNotImplementedError(nil, "for Mock datastore")
+	return b.performOp(datastore.MUTATION_DELETE, false, deletes)
+}
+
+func (b *keyspace) Mutations(since uint64, limit int, wait time.Duration) ([]*datastore.Mutation, errors.Error) {
+	return b.mutations.Mutations(since, limit, wait)
+}
+
+func (b *keyspace) LastSeqno() uint64 {
+	return b.mutations.LastSeqno()
+}
+
+// keys returns the keys of the documents, in the order they are generated,
+// followed by the keys of the fixture documents and of inserted documents,
+// each in key order
+func (b *keyspace) keys(limit int64) []string {
+	b.RLock()
+	defer b.RUnlock()
+
+	total := int64(b.nitems + len(b.docKeys) + len(b.changes))
+	if limit <= 0 || limit > total {
+		limit = total
+	}
+	rv := make([]string, 0, limit)
+	for i := 0; i < b.nitems && int64(len(rv)) < limit; i++ {
+		id := strconv.Itoa(i)
+		if doc, changed := b.changes[id]; !changed || doc != nil {
+			rv = append(rv, id)
+		}
+	}
+	for _, k := range b.docKeys {
+		if int64(len(rv)) >= limit {
+			break
+		}
+		if doc, changed := b.changes[k]; !changed || doc != nil {
+			rv = append(rv, k)
+		}
+	}
+
+	inserted := make([]string, 0, len(b.changes))
+	for k, v := range b.changes {
+		if v != nil && !b.inBase(k) {
+			inserted = append(inserted, k)
+		}
+	}
+	sort.Strings(inserted)
+	for _, k := range inserted {
+		if int64(len(rv)) >= limit {
+			break
+		}
+		rv = append(rv, k)
+	}
+	return rv
 }
 
 func (b *keyspace) Release(close bool) {
@@ -424,7 +596,7 @@ func (b *keyspace) Flush() errors.Error {
 }
 
 func (b *keyspace) IsBucket() bool {
-	return true
+	return b.scope == nil
 }
 
 type mockIndexer struct {
@@ -458,11 +630,7 @@ func (mi *mockIndexer) Name() datastore.IndexType {
 }
 
 func (mi *mockIndexer) IndexIds() ([]string, errors.Error) {
-	rv := make([]string, 0, len(mi.indexes))
-	for name, _ := range mi.indexes {
-		rv = append(rv, name)
-	}
-	return rv, nil
+	return mi.IndexNames()
 }
 
 func (mi *mockIndexer) IndexNames() ([]string, errors.Error) {
@@ -470,6 +638,7 @@ func (mi *mockIndexer) IndexNames() ([]string, errors.Error) {
 	for name, _ := range mi.indexes {
 		rv = append(rv, name)
 	}
+	sort.Strings(rv)
 	return rv, nil
 }
 
@@ -486,11 +655,25 @@ func (mi *mockIndexer) IndexByName(name string) (datastore.Index, errors.Error)
 }
 
 func (mi *mockIndexer) PrimaryIndexes() ([]datastore.PrimaryIndex, errors.Error) {
+	if mi.primary == nil {
+		return nil, nil
+	}
 	return []datastore.PrimaryIndex{mi.primary}, nil
 }
 
+// Indexes returns the primary index, if any, followed by the fixture indexes in name order
 func (mi *mockIndexer) Indexes() ([]datastore.Index, errors.Error) {
-	return []datastore.Index{mi.primary}, nil
+	names, _ := mi.IndexNames()
+	rv := make([]datastore.Index, 0, len(names))
+	if mi.primary != nil {
+		rv = append(rv, mi.primary)
+	}
+	for _, name := range names {
+		if index := mi.indexes[name]; index != datastore.Index(mi.primary) {
+			rv = append(rv, index)
+		}
+	}
+	return rv, nil
 }
 
 func (mi *mockIndexer) CreatePrimaryIndex(requestId, name string, with value.Value) (datastore.PrimaryIndex, errors.Error) {
@@ -539,10 +722,15 @@ func (mi *mockIndexer) SetConnectionSecurityConfig(conSecConfig *datastore.Conne
 // keyspace with 50000 items.  By default, you get...
 // mock:namespaces=1,keyspaces=1,items=100000 Which is what you'd get
 // by specifying a path of just...  mock:
+// Alternatively, mock:fixture=<file> loads the store from a fixture file,
+// see NewFixtureDatastore.
 func NewDatastore(path string) (datastore.Datastore, errors.Error) {
 	if strings.HasPrefix(path, "mock:") {
 		path = path[5:]
 	}
+	if strings.HasPrefix(path, "fixture=") {
+		return NewFixtureDatastore(path[8:])
+	}
 	params := map[string]int{}
 	for _, kv := range strings.Split(path, ",") {
 		if kv == "" {
@@ -561,10 +749,12 @@ func NewDatastore(path string) (datastore.Datastore, errors.Error) {
 	nkeyspaces := paramVal(params, "keyspaces", DEFAULT_NUM_KEYSPACES)
 	nitems := paramVal(params, "items", DEFAULT_NUM_ITEMS)
 	s := &store{path: path, params: params, namespaces: map[string]*namespace{}, namespaceNames: []string{}}
+	s.statUpdater = &statUpdater{store: s}
 	for i := 0; i < nnamespaces; i++ {
 		p := &namespace{store: s, name: "p" + strconv.Itoa(i), keyspaces: map[string]*keyspace{}, keyspaceNames: []string{}}
 		for j := 0; j < nkeyspaces; j++ {
-			b := &keyspace{namespace: p, name: "b" + strconv.Itoa(j), nitems: nitems}
+			b := &keyspace{namespace: p, name: "b" + strconv.Itoa(j), nitems: nitems,
+				mutations: datastore.NewMutationLog(0), stats: make(map[string]*histogram)}
 
 			b.mi = newMockIndexer(b)
 			b.mi.CreatePrimaryIndex("", "#primary", nil)
@@ -681,12 +871,11 @@ func (pi *primaryIndex) Scan(requestId string, span *datastore.Span, distinct bo
 		}
 	}
 
-	if limit == 0 {
-		limit = int64(pi.keyspace.nitems)
-	}
-
-	for i := 0; i < pi.keyspace.nitems && int64(i) < limit; i++ {
-		id := strconv.Itoa(i)
+	n := int64(0)
+	for _, id := range pi.keyspace.keys(0) {
+		if limit > 0 && n >= limit {
+			break
+		}
 
 		if low != "" &&
 			(id < low ||
@@ -694,16 +883,16 @@ func (pi *primaryIndex) Scan(requestId string, span *datastore.Span, distinct bo
 			continue
 		}
 
-		low = ""
-
+		// keys are not in collation order, so check both bounds for every key
 		if high != "" &&
 			(id > high ||
 				(id == high && (span.Range.Inclusion&datastore.HIGH == 0))) {
-			break
+			continue
 		}
 
 		entry := datastore.IndexEntry{PrimaryKey: id}
 		conn.Sender().SendEntry(&entry)
+		n++
 	}
 }
 
@@ -711,12 +900,8 @@ func (pi *primaryIndex) ScanEntries(requestId string, limit int64, cons datastor
 	vector timestamp.Vector, conn *datastore.IndexConnection) {
 	defer conn.Sender().Close()
 
-	if limit == 0 {
-		limit = int64(pi.keyspace.nitems)
-	}
-
-	for i := 0; i < pi.keyspace.nitems && int64(i) < limit; i++ {
-		entry := datastore.IndexEntry{PrimaryKey: strconv.Itoa(i)}
+	for _, id := range pi.keyspace.keys(limit) {
+		entry := datastore.IndexEntry{PrimaryKey: id}
 		conn.Sender().SendEntry(&entry)
 	}
 }
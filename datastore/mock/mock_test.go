@@ -263,3 +263,176 @@ func doIndexScan(t *testing.T, b datastore.Keyspace, span *datastore.Span) (
 
 	return
 }
+
+func TestMockMutations(t *testing.T) {
+	s, err := NewDatastore("mock:items=10")
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	p, _ := s.NamespaceByName("p0")
+	b, _ := p.KeyspaceByName("b0")
+	feed, ok := b.(datastore.MutationFeed)
+	if !ok {
+		t.Fatalf("expected mock keyspace to be a mutation feed")
+	}
+
+	doc := value.NewValue(map[string]interface{}{"a": 1})
+	_, err = b.Insert([]value.Pair{value.Pair{Name: "new", Value: doc}}, datastore.NULL_QUERY_CONTEXT)
+	if err != nil {
+		t.Fatalf("failed to insert: %v", err)
+	}
+	_, err = b.Insert([]value.Pair{value.Pair{Name: "1", Value: doc}}, datastore.NULL_QUERY_CONTEXT)
+	if err == nil {
+		t.Fatalf("expected duplicate key insert to fail")
+	}
+	_, err = b.Upsert([]value.Pair{value.Pair{Name: "1", Value: doc}}, datastore.NULL_QUERY_CONTEXT)
+	if err != nil {
+		t.Fatalf("failed to upsert: %v", err)
+	}
+	_, err = b.Delete([]value.Pair{value.Pair{Name: "2"}}, datastore.NULL_QUERY_CONTEXT)
+	if err != nil {
+		t.Fatalf("failed to delete: %v", err)
+	}
+
+	c, _ := b.Count(datastore.NULL_QUERY_CONTEXT)
+	if c != 10 {
+		t.Errorf("expected 10 documents, got %v", c)
+	}
+
+	mutations, err := feed.Mutations(0, 0, 0)
+	if err != nil || len(mutations) != 3 {
+		t.Fatalf("expected 3 mutations, got %v %v", len(mutations), err)
+	}
+	expected := []datastore.MutationType{datastore.MUTATION_INSERT, datastore.MUTATION_UPDATE, datastore.MUTATION_DELETE}
+	for i, m := range mutations {
+		if m.Type != expected[i] {
+			t.Errorf("mutation %v: expected %v, got %v", i, expected[i], m.Type)
+		}
+	}
+
+	// resuming from the last checkpoint waits for new mutations
+	last := feed.LastSeqno()
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		b.Delete([]value.Pair{value.Pair{Name: "new"}}, datastore.NULL_QUERY_CONTEXT)
+	}()
+	mutations, err = feed.Mutations(last, 0, 5*time.Second)
+	if err != nil || len(mutations) != 1 || mutations[0].Key != "new" || mutations[0].Seqno <= last {
+		t.Errorf("expected delete of new, got %v %v", mutations, err)
+	}
+
+	_, err = feed.Mutations(1, 0, 0)
+	if err == nil {
+		t.Errorf("expected error for stale checkpoint")
+	}
+}
+
+func TestMockFixture(t *testing.T) {
+	s, err := NewDatastore("mock:fixture=testdata/fixture.json")
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	p, err := s.NamespaceByName("default")
+	if err != nil {
+		t.Fatalf("expected namespace default: %v", err)
+	}
+	b, err := p.KeyspaceByName("orders")
+	if err != nil {
+		t.Fatalf("expected keyspace orders: %v", err)
+	}
+	count, _ := b.Count(datastore.NULL_QUERY_CONTEXT)
+	if count != 5 {
+		t.Fatalf("unexpected count %v", count)
+	}
+
+	indexer, _ := b.Indexer(datastore.DEFAULT)
+	indexes, _ := indexer.Indexes()
+	if len(indexes) != 4 || indexes[0].Name() != "#primary" || indexes[1].Name() != "ix_city" {
+		t.Fatalf("unexpected indexes %v", indexes)
+	}
+
+	idx, _ := indexer.IndexByName("ix_deferred")
+	if state, _, _ := idx.State(); state != datastore.DEFERRED {
+		t.Errorf("unexpected state %v for ix_deferred", state)
+	}
+
+	// documents without a city are not indexed, and totals are descending
+	idx, _ = indexer.IndexByName("ix_city")
+	paris := datastore.Spans2{&datastore.Span2{Ranges: datastore.Ranges2{
+		&datastore.Range2{Low: value.NewValue("Paris"), High: value.NewValue("Paris"), Inclusion: datastore.BOTH}}}}
+	items := doIndexScan2(t, idx.(datastore.Index2), paris, &datastore.IndexProjection{EntryKeys: []int{1}, PrimaryKey: true})
+	if len(items) != 2 || items[0].PrimaryKey != "o3" || items[1].PrimaryKey != "o1" ||
+		len(items[0].EntryKey) != 1 || items[0].EntryKey[0].Actual() != float64(40) {
+		t.Errorf("unexpected ix_city entries %v", items)
+	}
+
+	n, _ := idx.(datastore.CountIndex2).Count2("", nil, datastore.UNBOUNDED, nil)
+	d, _ := idx.(datastore.CountIndex2).CountDistinct("", nil, datastore.UNBOUNDED, nil)
+	if n != 3 || d != 2 {
+		t.Errorf("unexpected ix_city counts %v %v", n, d)
+	}
+
+	stats, _ := idx.Statistics("", nil)
+	if stats == nil {
+		t.Fatalf("expected statistics for ix_city")
+	}
+	if c, _ := stats.Count(); c != 4 {
+		t.Errorf("unexpected statistics count %v", c)
+	}
+	if bins, _ := stats.Bins(); len(bins) != 2 {
+		t.Errorf("unexpected statistics bins %v", bins)
+	}
+
+	// one entry per distinct array element
+	idx, _ = indexer.IndexByName("ix_items")
+	items = doIndexScan2(t, idx.(datastore.Index2), nil, nil)
+	if len(items) != 3 || items[0].EntryKey[0].Actual() != "a" || items[2].PrimaryKey != "o2" {
+		t.Errorf("unexpected ix_items entries %v", items)
+	}
+
+	// statistics missing from the fixture are computed
+	updater, _ := s.StatUpdater()
+	term, _ := indexer.IndexByName("ix_deferred")
+	conn := datastore.NewValueConnection(&testingContext{t})
+	go updater.UpdateStatistics(b, nil, term.RangeKey(), nil, conn, nil, false)
+	vals := []value.Value{}
+	for v := range conn.ValueChannel() {
+		vals = append(vals, v)
+	}
+	if len(vals) != 1 {
+		t.Fatalf("unexpected statistics %v", vals)
+	}
+	if distinct, _ := vals[0].Field("distinct"); distinct.Actual() != float64(5) {
+		t.Errorf("unexpected statistics %v", vals[0])
+	}
+
+	bucket, err := p.BucketByName("travel")
+	if err != nil {
+		t.Fatalf("expected bucket travel: %v", err)
+	}
+	scope, _ := bucket.ScopeByName("inventory")
+	airline, err := scope.KeyspaceByName("airline")
+	if err != nil || airline.QualifiedName() != "default:travel.inventory.airline" {
+		t.Fatalf("unexpected collection %v %v", airline, err)
+	}
+	docs := map[string]value.AnnotatedValue{}
+	airline.Fetch([]string{"airline_10"}, docs, datastore.NULL_QUERY_CONTEXT, nil)
+	if name, _ := docs["airline_10"].Field("name"); name.Actual() != "40-Mile Air" {
+		t.Errorf("unexpected document %v", docs["airline_10"])
+	}
+}
+
+func doIndexScan2(t *testing.T, idx datastore.Index2, spans datastore.Spans2,
+	projection *datastore.IndexProjection) []*datastore.IndexEntry {
+	conn := datastore.NewIndexConnection(&testingContext{t})
+	go idx.Scan2("", spans, false, false, true, projection, 0, 0, datastore.UNBOUNDED, nil, conn)
+
+	rv := []*datastore.IndexEntry{}
+	for {
+		entry, ok := conn.Sender().GetEntry()
+		if entry == nil || !ok {
+			return rv
+		}
+		rv = append(rv, entry)
+	}
+}
@@ -0,0 +1,150 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package datastore
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/value"
+)
+
+type MutationType string
+
+const (
+	MUTATION_INSERT MutationType = "insert"
+	MUTATION_UPDATE MutationType = "update"
+	MUTATION_DELETE MutationType = "delete"
+)
+
+// Mutation is a change to a document of a keyspace.
+// Sequence numbers are strictly increasing within a keyspace and serve as
+// checkpoints: a client resumes a feed from the last sequence number it processed.
+type Mutation struct {
+	Seqno uint64
+	Type  MutationType
+	Key   string
+	Value value.Value // nil for deletes
+	Time  time.Time
+}
+
+// MutationFeed is implemented by keyspaces that allow clients to follow their changes
+type MutationFeed interface {
+	Keyspace
+
+	// Up to limit mutations with a sequence number greater than since, in sequence number order.
+	// If there are none, waits up to wait for some to happen.
+	// since 0 starts from the oldest mutation retained.
+	Mutations(since uint64, limit int, wait time.Duration) ([]*Mutation, errors.Error)
+
+	// Sequence number of the latest mutation, 0 if none
+	LastSeqno() uint64
+}
+
+const DEF_MUTATION_LOG_SIZE = 65536
+
+// MutationLog is an in memory MutationFeed implementation, retaining the
+// latest mutations of a keyspace.
+// Sequence numbers start from the creation time of the log, so that checkpoints
+// from a previous incarnation of a keyspace are detected as too old.
+type MutationLog struct {
+	sync.Mutex
+	mutations []*Mutation
+	size      int
+	next      uint64
+	first     uint64
+	notify    chan struct{}
+}
+
+func NewMutationLog(size int) *MutationLog {
+	if size <= 0 {
+		size = DEF_MUTATION_LOG_SIZE
+	}
+	start := uint64(time.Now().UnixNano())
+	return &MutationLog{
+		mutations: make([]*Mutation, 0, 64),
+		size:      size,
+		next:      start,
+		first:     start,
+		notify:    make(chan struct{}),
+	}
+}
+
+// Add records a mutation and wakes up waiting clients
+func (this *MutationLog) Add(mutationType MutationType, key string, val value.Value) {
+	if val != nil {
+		val = val.CopyForUpdate()
+	}
+
+	this.Lock()
+	this.next++
+	this.mutations = append(this.mutations, &Mutation{Seqno: this.next, Type: mutationType, Key: key, Value: val, Time: time.Now()})
+	if len(this.mutations) > this.size {
+		drop := len(this.mutations) - this.size
+		this.first = this.mutations[drop-1].Seqno
+		this.mutations = append(this.mutations[:0], this.mutations[drop:]...)
+	}
+	notify := this.notify
+	this.notify = make(chan struct{})
+	this.Unlock()
+
+	close(notify)
+}
+
+func (this *MutationLog) LastSeqno() uint64 {
+	this.Lock()
+	defer this.Unlock()
+	if len(this.mutations) == 0 {
+		return 0
+	}
+	return this.next
+}
+
+func (this *MutationLog) Mutations(since uint64, limit int, wait time.Duration) ([]*Mutation, errors.Error) {
+	rv, notify, err := this.mutationsSince(since, limit)
+	if err != nil || len(rv) > 0 || wait <= 0 {
+		return rv, err
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-notify:
+		rv, _, err = this.mutationsSince(since, limit)
+	case <-timer.C:
+	}
+	return rv, err
+}
+
+func (this *MutationLog) mutationsSince(since uint64, limit int) ([]*Mutation, chan struct{}, errors.Error) {
+	this.Lock()
+	defer this.Unlock()
+
+	// mutations between the checkpoint and the oldest retained have been lost,
+	// or the checkpoint belongs to a different incarnation of the keyspace
+	if since != 0 && (since < this.first || since > this.next) {
+		return nil, nil, errors.NewMutationCheckpointError(since)
+	}
+
+	i := sort.Search(len(this.mutations), func(i int) bool {
+		return this.mutations[i].Seqno > since
+	})
+	n := len(this.mutations) - i
+	if limit > 0 && n > limit {
+		n = limit
+	}
+	if n == 0 {
+		return nil, this.notify, nil
+	}
+	rv := make([]*Mutation, n)
+	copy(rv, this.mutations[i:i+n])
+	return rv, this.notify, nil
+}
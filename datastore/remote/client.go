@@ -0,0 +1,220 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/logging"
+)
+
+const _SERVICE_PATH = "/query/service"
+
+// remotes that do not connect or start responding within this time are given up on
+const _REMOTE_TIMEOUT = 30 * time.Second
+
+// how often a remote call checks whether its scan has been stopped
+const _STOP_POLL = 100 * time.Millisecond
+
+// the request, or the scan, a remote call is made for
+type deadliner interface {
+	GetReqDeadline() time.Time
+}
+
+// client submits N1QL statements to a remote query service and streams
+// back the results
+type client struct {
+	url        string
+	username   string
+	password   string
+	httpClient *http.Client
+}
+
+// newClient accepts either the address of a query node (http://host:8093) or
+// the full service endpoint. Credentials can be passed as URL user info.
+func newClient(uri string) (*client, errors.Error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, errors.NewRemoteDatastoreError(err, "invalid url "+uri)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, errors.NewRemoteDatastoreError(nil, "invalid url scheme "+uri)
+	}
+
+	rv := &client{httpClient: &http.Client{Transport: &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           (&net.Dialer{Timeout: _REMOTE_TIMEOUT}).DialContext,
+		TLSHandshakeTimeout:   _REMOTE_TIMEOUT,
+		ResponseHeaderTimeout: _REMOTE_TIMEOUT,
+		MaxIdleConnsPerHost:   16,
+	}}}
+	if u.User != nil {
+		rv.username = u.User.Username()
+		rv.password, _ = u.User.Password()
+		u.User = nil
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = _SERVICE_PATH
+	}
+	rv.url = u.String()
+	return rv, nil
+}
+
+type remoteError struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+}
+
+// query runs a statement on the remote service, calling rowFn for each result
+// as it is received. rowFn returns false to stop processing results.
+// The call is abandoned at the deadline of the requester, if any, or as soon
+// as stopped returns true.
+func (this *client) query(requester deadliner, stopped func() bool, statement string, args []interface{},
+	rowFn func(row []byte) bool) errors.Error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	form := url.Values{}
+	form.Set("statement", statement)
+	if requester != nil {
+		if deadline := requester.GetReqDeadline(); !deadline.IsZero() {
+			var cancelDeadline context.CancelFunc
+
+			ctx, cancelDeadline = context.WithDeadline(ctx, deadline)
+			defer cancelDeadline()
+			if remaining := time.Until(deadline); remaining > 0 {
+				form.Set("timeout", remaining.String())
+			}
+		}
+	}
+	if stopped != nil {
+		done := make(chan bool)
+		defer close(done)
+		go func() {
+			ticker := time.NewTicker(_STOP_POLL)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-done:
+					return
+				case <-ticker.C:
+					if stopped() {
+						cancel()
+						return
+					}
+				}
+			}
+		}()
+	}
+	if len(args) > 0 {
+		bytes, err := json.Marshal(args)
+		if err != nil {
+			return errors.NewRemoteQueryError(err, statement)
+		}
+		form.Set("args", string(bytes))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", this.url, strings.NewReader(form.Encode()))
+	if err != nil {
+		return errors.NewRemoteQueryError(err, statement)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if this.username != "" {
+		req.SetBasicAuth(this.username, this.password)
+	}
+
+	logging.Debugf("remote query <ud>%v</ud> args <ud>%v</ud>", statement, args)
+	resp, err := this.httpClient.Do(req)
+	if err != nil {
+		if stopped != nil && stopped() {
+			return nil
+		}
+		return errors.NewRemoteQueryError(err, statement)
+	}
+
+	// closing the body before the end of the results terminates the remote request
+	defer resp.Body.Close()
+
+	rv := this.decode(resp.Body, statement, rowFn)
+
+	// a stopped scan is not interested in why the results ended
+	if rv != nil && stopped != nil && stopped() {
+		return nil
+	}
+	return rv
+}
+
+// decode walks the top level fields of the response, handing out each
+// element of the results array without buffering the whole response
+func (this *client) decode(body io.Reader, statement string, rowFn func(row []byte) bool) errors.Error {
+	decoder := json.NewDecoder(body)
+	if err := expectDelim(decoder, '{'); err != nil {
+		return errors.NewRemoteQueryError(err, statement)
+	}
+
+	for decoder.More() {
+		tok, err := decoder.Token()
+		if err != nil {
+			return errors.NewRemoteQueryError(err, statement)
+		}
+		field, _ := tok.(string)
+		switch field {
+		case "results":
+			if err = expectDelim(decoder, '['); err != nil {
+				return errors.NewRemoteQueryError(err, statement)
+			}
+			for decoder.More() {
+				var row json.RawMessage
+				if err = decoder.Decode(&row); err != nil {
+					return errors.NewRemoteQueryError(err, statement)
+				}
+				if !rowFn(row) {
+					return nil
+				}
+			}
+			if err = expectDelim(decoder, ']'); err != nil {
+				return errors.NewRemoteQueryError(err, statement)
+			}
+		case "errors":
+			var errs []remoteError
+			if err = decoder.Decode(&errs); err != nil {
+				return errors.NewRemoteQueryError(err, statement)
+			}
+			if len(errs) > 0 {
+				return errors.NewRemoteQueryError(nil, fmt.Sprintf("%v: %v (%v)", statement, errs[0].Msg, errs[0].Code))
+			}
+		default:
+			var skip json.RawMessage
+			if err = decoder.Decode(&skip); err != nil {
+				return errors.NewRemoteQueryError(err, statement)
+			}
+		}
+	}
+	return nil
+}
+
+func expectDelim(decoder *json.Decoder, delim json.Delim) error {
+	tok, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := tok.(json.Delim); !ok || d != delim {
+		return fmt.Errorf("unexpected token %v, expected %v", tok, delim)
+	}
+	return nil
+}
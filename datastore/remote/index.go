@@ -0,0 +1,566 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package remote
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/logging"
+	"github.com/couchbase/query/parser/n1ql"
+	"github.com/couchbase/query/timestamp"
+	"github.com/couchbase/query/value"
+)
+
+const _PRIMARY_NAME = "#primary"
+
+// how long the list of remote indexes is trusted before being reloaded
+const _INDEX_REFRESH = 10 * time.Second
+
+// indexer exposes the secondary indexes of a remote keyspace, so that the
+// local planner can push filters down to them, plus a primary index
+type indexer struct {
+	sync.RWMutex
+	keyspace  *keyspace
+	primary   *primaryIndex
+	indexes   map[string]*index
+	refreshed time.Time
+}
+
+func newIndexer(keyspace *keyspace) *indexer {
+	rv := &indexer{keyspace: keyspace, indexes: make(map[string]*index)}
+	rv.primary = &primaryIndex{name: _PRIMARY_NAME, keyspace: keyspace, indexer: rv}
+	return rv
+}
+
+func (this *indexer) BucketId() string {
+	return ""
+}
+
+func (this *indexer) ScopeId() string {
+	return ""
+}
+
+func (this *indexer) KeyspaceId() string {
+	return this.keyspace.Id()
+}
+
+func (this *indexer) Name() datastore.IndexType {
+	return datastore.DEFAULT
+}
+
+func (this *indexer) IndexIds() ([]string, errors.Error) {
+	return this.IndexNames()
+}
+
+func (this *indexer) IndexNames() ([]string, errors.Error) {
+	indexes, err := this.Indexes()
+	if err != nil {
+		return nil, err
+	}
+	rv := make([]string, len(indexes))
+	for i, idx := range indexes {
+		rv[i] = idx.Name()
+	}
+	return rv, nil
+}
+
+func (this *indexer) IndexById(id string) (datastore.Index, errors.Error) {
+	return this.IndexByName(id)
+}
+
+func (this *indexer) IndexByName(name string) (datastore.Index, errors.Error) {
+	if name == this.primary.name {
+		return this.primary, nil
+	}
+	this.refresh(false)
+	this.RLock()
+	defer this.RUnlock()
+	idx, ok := this.indexes[name]
+	if !ok {
+		return nil, errors.NewRemoteIdxNotFoundError(nil, name)
+	}
+	return idx, nil
+}
+
+func (this *indexer) PrimaryIndexes() ([]datastore.PrimaryIndex, errors.Error) {
+	return []datastore.PrimaryIndex{this.primary}, nil
+}
+
+func (this *indexer) Indexes() ([]datastore.Index, errors.Error) {
+	err := this.refresh(false)
+	if err != nil {
+		return nil, err
+	}
+	this.RLock()
+	defer this.RUnlock()
+	rv := make([]datastore.Index, 0, len(this.indexes)+1)
+	rv = append(rv, this.primary)
+	for _, idx := range this.indexes {
+		rv = append(rv, idx)
+	}
+	return rv, nil
+}
+
+func (this *indexer) CreatePrimaryIndex(requestId, name string, with value.Value) (datastore.PrimaryIndex, errors.Error) {
+	return this.primary, nil
+}
+
+func (this *indexer) CreateIndex(requestId, name string, seekKey, rangeKey expression.Expressions,
+	where expression.Expression, with value.Value) (datastore.Index, errors.Error) {
+	return nil, errors.NewRemoteNotSupportedError(nil, "CREATE INDEX")
+}
+
+func (this *indexer) BuildIndexes(requestId string, names ...string) errors.Error {
+	return errors.NewRemoteNotSupportedError(nil, "BUILD INDEX")
+}
+
+func (this *indexer) Refresh() errors.Error {
+	return this.refresh(true)
+}
+
+func (this *indexer) MetadataVersion() uint64 {
+	return 0
+}
+
+func (this *indexer) SetLogLevel(level logging.Level) {
+	// No-op, uses query engine logger
+}
+
+func (this *indexer) SetConnectionSecurityConfig(conSecConfig *datastore.ConnectionSecurityConfig) {
+	// Do nothing.
+}
+
+type indexInfo struct {
+	Name      string   `json:"name"`
+	IndexKey  []string `json:"index_key"`
+	Condition string   `json:"condition"`
+}
+
+// refresh loads the online secondary indexes of the remote keyspace
+func (this *indexer) refresh(force bool) errors.Error {
+	this.RLock()
+	fresh := !force && time.Since(this.refreshed) < _INDEX_REFRESH
+	this.RUnlock()
+	if fresh {
+		return nil
+	}
+
+	indexes := make(map[string]*index)
+	statement := "SELECT name, index_key, `condition` FROM system:indexes " +
+		"WHERE namespace_id = $1 AND keyspace_id = $2 AND `bucket_id` IS MISSING " +
+		"AND state = \"online\" AND IFMISSING(is_primary, false) = false AND `using` != \"fts\""
+	args := []interface{}{_REMOTE_NAMESPACE, this.keyspace.name}
+	err := this.keyspace.namespace.client.query(nil, nil, statement, args, func(row []byte) bool {
+		var info indexInfo
+		if json.Unmarshal(row, &info) != nil || len(info.IndexKey) == 0 {
+			return true
+		}
+		idx, er := newIndex(this, &info)
+		if er != nil {
+			logging.Infof("Ignoring remote index %v on %v: %v", info.Name, this.keyspace.QualifiedName(), er)
+			return true
+		}
+		indexes[idx.name] = idx
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	this.Lock()
+	this.indexes = indexes
+	this.refreshed = time.Now()
+	this.Unlock()
+	return nil
+}
+
+// primaryIndex scans the document keys of the remote keyspace
+type primaryIndex struct {
+	name     string
+	keyspace *keyspace
+	indexer  *indexer
+}
+
+func (pi *primaryIndex) KeyspaceId() string {
+	return pi.keyspace.Id()
+}
+
+func (pi *primaryIndex) Id() string {
+	return pi.Name()
+}
+
+func (pi *primaryIndex) Name() string {
+	return pi.name
+}
+
+func (pi *primaryIndex) Type() datastore.IndexType {
+	return datastore.DEFAULT
+}
+
+func (pi *primaryIndex) Indexer() datastore.Indexer {
+	return pi.indexer
+}
+
+func (pi *primaryIndex) SeekKey() expression.Expressions {
+	return nil
+}
+
+func (pi *primaryIndex) RangeKey() expression.Expressions {
+	return nil
+}
+
+func (pi *primaryIndex) Condition() expression.Expression {
+	return nil
+}
+
+func (pi *primaryIndex) IsPrimary() bool {
+	return true
+}
+
+func (pi *primaryIndex) State() (state datastore.IndexState, msg string, err errors.Error) {
+	return datastore.ONLINE, "", nil
+}
+
+func (pi *primaryIndex) Statistics(requestId string, span *datastore.Span) (datastore.Statistics, errors.Error) {
+	return nil, nil
+}
+
+func (pi *primaryIndex) Drop(requestId string) errors.Error {
+	return errors.NewRemoteNotSupportedError(nil, "DROP INDEX")
+}
+
+func (pi *primaryIndex) Scan(requestId string, span *datastore.Span, distinct bool, limit int64,
+	cons datastore.ScanConsistency, vector timestamp.Vector, conn *datastore.IndexConnection) {
+	defer conn.Sender().Close()
+
+	var low, high value.Value
+	if len(span.Range.Low) > 0 {
+		low = span.Range.Low[0]
+	}
+	if len(span.Range.High) > 0 {
+		high = span.Range.High[0]
+	}
+
+	args := make([]interface{}, 0, 2)
+	filter := rangeFilter("META().id", low, high, span.Range.Inclusion, &args)
+	pi.scan(filter, args, limit, conn)
+}
+
+func (pi *primaryIndex) ScanEntries(requestId string, limit int64, cons datastore.ScanConsistency,
+	vector timestamp.Vector, conn *datastore.IndexConnection) {
+	defer conn.Sender().Close()
+
+	pi.scan("", nil, limit, conn)
+}
+
+func (pi *primaryIndex) scan(filter string, args []interface{}, limit int64, conn *datastore.IndexConnection) {
+	statement := "SELECT RAW META().id FROM " + pi.keyspace.path()
+	if filter != "" {
+		statement += " WHERE " + filter
+	}
+	statement += " ORDER BY META().id"
+	if limit > 0 && limit < _MAX_LIMIT {
+		statement += " LIMIT " + strconv.FormatInt(limit, 10)
+	}
+
+	err := pi.keyspace.namespace.client.query(conn, conn.Sender().IsStopped, statement, args, func(row []byte) bool {
+		var key string
+		if json.Unmarshal(row, &key) != nil {
+			return true
+		}
+		return conn.Sender().SendEntry(&datastore.IndexEntry{PrimaryKey: key})
+	})
+	if err != nil {
+		conn.Error(err)
+	}
+}
+
+// anything above this is as good as no limit
+const _MAX_LIMIT = int64(1) << 53
+
+// index is a secondary index of the remote keyspace. Scans are turned into
+// statements that filter on the index keys, so that the remote can use the
+// index, and that return the keys for covering scans.
+type index struct {
+	name      string
+	indexer   *indexer
+	keys      []string
+	rangeKey  datastore.IndexKeys
+	condition expression.Expression
+	where     string
+}
+
+func newIndex(indexer *indexer, info *indexInfo) (*index, error) {
+	rv := &index{
+		name:     info.Name,
+		indexer:  indexer,
+		keys:     make([]string, len(info.IndexKey)),
+		rangeKey: make(datastore.IndexKeys, len(info.IndexKey)),
+	}
+
+	for i, k := range info.IndexKey {
+		attr := datastore.IK_NONE
+		text := strings.TrimSpace(k)
+		upper := strings.ToUpper(text)
+		if strings.HasSuffix(upper, " DESC") {
+			attr = datastore.IK_DESC
+			text = strings.TrimSpace(text[:len(text)-5])
+		} else if strings.HasSuffix(upper, " ASC") {
+			text = strings.TrimSpace(text[:len(text)-4])
+		}
+
+		expr, err := n1ql.ParseExpression(text)
+		if err != nil {
+			return nil, err
+		}
+		rv.keys[i] = text
+		rv.rangeKey[i] = &datastore.IndexKey{Expr: expr, Attributes: attr}
+	}
+
+	if info.Condition != "" {
+		expr, err := n1ql.ParseExpression(info.Condition)
+		if err != nil {
+			return nil, err
+		}
+		rv.condition = expr
+		rv.where = info.Condition
+	}
+	return rv, nil
+}
+
+func (this *index) KeyspaceId() string {
+	return this.indexer.KeyspaceId()
+}
+
+func (this *index) Id() string {
+	return this.Name()
+}
+
+func (this *index) Name() string {
+	return this.name
+}
+
+func (this *index) Type() datastore.IndexType {
+	return datastore.DEFAULT
+}
+
+func (this *index) Indexer() datastore.Indexer {
+	return this.indexer
+}
+
+func (this *index) SeekKey() expression.Expressions {
+	return nil
+}
+
+func (this *index) RangeKey() expression.Expressions {
+	rv := make(expression.Expressions, len(this.rangeKey))
+	for i, k := range this.rangeKey {
+		rv[i] = k.Expr
+	}
+	return rv
+}
+
+func (this *index) RangeKey2() datastore.IndexKeys {
+	return this.rangeKey
+}
+
+func (this *index) Condition() expression.Expression {
+	return this.condition
+}
+
+func (this *index) IsPrimary() bool {
+	return false
+}
+
+func (this *index) State() (state datastore.IndexState, msg string, err errors.Error) {
+	return datastore.ONLINE, "", nil
+}
+
+func (this *index) Statistics(requestId string, span *datastore.Span) (datastore.Statistics, errors.Error) {
+	return nil, nil
+}
+
+func (this *index) Drop(requestId string) errors.Error {
+	return errors.NewRemoteNotSupportedError(nil, "DROP INDEX")
+}
+
+func (this *index) Scan(requestId string, span *datastore.Span, distinct bool, limit int64,
+	cons datastore.ScanConsistency, vector timestamp.Vector, conn *datastore.IndexConnection) {
+
+	ranges := make(datastore.Ranges2, len(this.keys))
+	for i := range ranges {
+		rg := &datastore.Range2{}
+		if i < len(span.Range.Low) {
+			rg.Low = span.Range.Low[i]
+			rg.Inclusion |= span.Range.Inclusion & datastore.LOW
+		}
+		if i < len(span.Range.High) {
+			rg.High = span.Range.High[i]
+			rg.Inclusion |= span.Range.Inclusion & datastore.HIGH
+		}
+		ranges[i] = rg
+	}
+	this.Scan2(requestId, datastore.Spans2{&datastore.Span2{Ranges: ranges}}, false, distinct,
+		false, nil, 0, limit, cons, vector, conn)
+}
+
+func (this *index) Scan2(requestId string, spans datastore.Spans2, reverse, distinctAfterProjection,
+	ordered bool, projection *datastore.IndexProjection, offset, limit int64,
+	cons datastore.ScanConsistency, vector timestamp.Vector, conn *datastore.IndexConnection) {
+	defer conn.Sender().Close()
+
+	args := make([]interface{}, 0, 2*len(spans)*len(this.keys))
+	statement := this.statement(spans, reverse, ordered, projection, offset, limit, &args)
+
+	err := this.indexer.keyspace.namespace.client.query(conn, conn.Sender().IsStopped, statement, args, func(row []byte) bool {
+		var entry []json.RawMessage
+		var key string
+
+		if json.Unmarshal(row, &entry) != nil || len(entry) != 2 || json.Unmarshal(entry[0], &key) != nil {
+			conn.Error(errors.NewRemoteQueryError(nil, fmt.Sprintf("unexpected scan result %s", row)))
+			return false
+		}
+
+		var keys []json.RawMessage
+		json.Unmarshal(entry[1], &keys)
+		entryKey := make(value.Values, len(keys))
+		for i, k := range keys {
+			entryKey[i] = value.NewValue([]byte(k))
+		}
+		return conn.Sender().SendEntry(&datastore.IndexEntry{PrimaryKey: key, EntryKey: entryKey})
+	})
+	if err != nil {
+		conn.Error(err)
+	}
+}
+
+// statement produces the N1QL for an index scan: each row is a pair of
+// document key and the (projected) index keys
+func (this *index) statement(spans datastore.Spans2, reverse, ordered bool,
+	projection *datastore.IndexProjection, offset, limit int64, args *[]interface{}) string {
+
+	projected := make([]string, 0, len(this.keys))
+	if projection == nil {
+		projected = append(projected, this.keys...)
+	} else {
+		for _, k := range projection.EntryKeys {
+			if k >= 0 && k < len(this.keys) {
+				projected = append(projected, this.keys[k])
+			}
+		}
+	}
+
+	statement := "SELECT RAW [META().id, [" + strings.Join(projected, ", ") + "]] FROM " +
+		this.indexer.keyspace.path()
+
+	// entries only exist for documents where the leading key is not missing
+	filters := []string{this.keys[0] + " IS NOT MISSING"}
+	if this.where != "" {
+		filters = append(filters, "("+this.where+")")
+	}
+	if spanFilter := this.spansFilter(spans, args); spanFilter != "" {
+		filters = append(filters, spanFilter)
+	}
+	statement += " WHERE " + strings.Join(filters, " AND ")
+
+	if ordered {
+		order := make([]string, 0, len(this.keys)+1)
+		for i, k := range this.keys {
+			desc := (this.rangeKey[i].Attributes & datastore.IK_DESC) != 0
+			if desc != reverse {
+				order = append(order, k+" DESC")
+			} else {
+				order = append(order, k)
+			}
+		}
+		if reverse {
+			order = append(order, "META().id DESC")
+		} else {
+			order = append(order, "META().id")
+		}
+		statement += " ORDER BY " + strings.Join(order, ", ")
+	}
+	if offset > 0 {
+		statement += " OFFSET " + strconv.FormatInt(offset, 10)
+	}
+	if limit > 0 && limit < _MAX_LIMIT {
+		statement += " LIMIT " + strconv.FormatInt(limit, 10)
+	}
+	return statement
+}
+
+// spansFilter turns spans into a disjunction of conjunctions of ranges
+func (this *index) spansFilter(spans datastore.Spans2, args *[]interface{}) string {
+	terms := make([]string, 0, len(spans))
+	for _, span := range spans {
+		conds := make([]string, 0, len(span.Ranges))
+		for i, rg := range span.Ranges {
+			if i >= len(this.keys) {
+				break
+			}
+			if cond := rangeFilter(this.keys[i], rg.Low, rg.High, rg.Inclusion, args); cond != "" {
+				conds = append(conds, cond)
+			}
+		}
+
+		// a span with no bounds matches everything
+		if len(conds) == 0 {
+			return ""
+		}
+		terms = append(terms, "("+strings.Join(conds, " AND ")+")")
+	}
+	return strings.Join(terms, " OR ")
+}
+
+// rangeFilter renders a range on a key as comparisons to positional parameters.
+// Comparisons to NULL are never true, so NULL bounds, which the planner uses
+// for IS NOT NULL, IS NULL and < predicates, are rendered as IS tests instead.
+func rangeFilter(key string, low, high value.Value, inclusion datastore.Inclusion, args *[]interface{}) string {
+	conds := make([]string, 0, 2)
+	nullLow := low != nil && low.Type() == value.NULL
+	if nullLow {
+
+		// an inclusive NULL bound admits every value the scan can return
+		if inclusion&datastore.LOW == 0 {
+			conds = append(conds, key+" IS NOT NULL")
+		}
+	} else if low != nil && low.Type() != value.MISSING {
+		*args = append(*args, low)
+		op := " > $"
+		if inclusion&datastore.LOW != 0 {
+			op = " >= $"
+		}
+		conds = append(conds, key+op+strconv.Itoa(len(*args)))
+	}
+	if high != nil && high.Type() == value.NULL {
+		if inclusion&datastore.HIGH == 0 {
+			conds = append(conds, key+" IS MISSING")
+		} else if nullLow {
+			conds = append(conds, key+" IS NULL")
+		} else {
+			conds = append(conds, key+" IS NOT VALUED")
+		}
+	} else if high != nil && high.Type() != value.MISSING {
+		*args = append(*args, high)
+		op := " < $"
+		if inclusion&datastore.HIGH != 0 {
+			op = " <= $"
+		}
+		conds = append(conds, key+op+strconv.Itoa(len(*args)))
+	}
+	return strings.Join(conds, " AND ")
+}
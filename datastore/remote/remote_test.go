@@ -0,0 +1,126 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package remote
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/value"
+)
+
+func TestDecode(t *testing.T) {
+	c := &client{}
+	body := `{"requestID":"1","signature":{"*":"*"},"results":[{"a":1},[1,2],"x"],"status":"success"}`
+	rows := []string{}
+	err := c.decode(strings.NewReader(body), "", func(row []byte) bool {
+		rows = append(rows, string(row))
+		return true
+	})
+	if err != nil || len(rows) != 3 || rows[0] != `{"a":1}` || rows[2] != `"x"` {
+		t.Errorf("unexpected results %v %v", rows, err)
+	}
+
+	body = `{"requestID":"1","errors":[{"code":12003,"msg":"Keyspace not found"}],"status":"fatal"}`
+	err = c.decode(strings.NewReader(body), "", func(row []byte) bool {
+		return true
+	})
+	if err == nil || !strings.Contains(err.Error(), "Keyspace not found") {
+		t.Errorf("expected remote error, got %v", err)
+	}
+}
+
+func TestIndexStatement(t *testing.T) {
+	ks := &keyspace{name: "orders"}
+	ix := newIndexer(ks)
+	idx, err := newIndex(ix, &indexInfo{Name: "ix1", IndexKey: []string{"`city`", "`total` DESC"}, Condition: "(`type` = \"order\")"})
+	if err != nil {
+		t.Fatalf("failed to create index: %v", err)
+	}
+
+	spans := datastore.Spans2{
+		&datastore.Span2{Ranges: datastore.Ranges2{
+			&datastore.Range2{Low: value.NewValue("Paris"), High: value.NewValue("Paris"), Inclusion: datastore.BOTH},
+			&datastore.Range2{Low: value.NewValue(10), Inclusion: datastore.NEITHER},
+		}},
+	}
+	args := []interface{}{}
+	stmt := idx.statement(spans, false, true, &datastore.IndexProjection{EntryKeys: []int{1}, PrimaryKey: true}, 0, 5, &args)
+	expected := "SELECT RAW [META().id, [`total`]] FROM `default`:`orders` " +
+		"WHERE `city` IS NOT MISSING AND ((`type` = \"order\")) AND (`city` >= $1 AND `city` <= $2 AND `total` > $3) " +
+		"ORDER BY `city`, `total` DESC, META().id LIMIT 5"
+	if stmt != expected {
+		t.Errorf("unexpected statement\n%v\nexpected\n%v", stmt, expected)
+	}
+	if len(args) != 3 {
+		t.Errorf("expected 3 arguments, got %v", args)
+	}
+}
+
+func TestNullBounds(t *testing.T) {
+	tests := []struct {
+		low, high value.Value
+		inclusion datastore.Inclusion
+		expected  string
+	}{
+		// key < 10, IS NOT NULL
+		{value.NULL_VALUE, value.NewValue(10), datastore.NEITHER, "`k` IS NOT NULL AND `k` < $1"},
+		{value.NULL_VALUE, nil, datastore.NEITHER, "`k` IS NOT NULL"},
+
+		// IS NULL
+		{value.NULL_VALUE, value.NULL_VALUE, datastore.BOTH, "`k` IS NULL"},
+		{value.NULL_VALUE, nil, datastore.LOW, ""},
+	}
+	for _, test := range tests {
+		args := []interface{}{}
+		cond := rangeFilter("`k`", test.low, test.high, test.inclusion, &args)
+		if cond != test.expected {
+			t.Errorf("unexpected filter %v, expected %v", cond, test.expected)
+		}
+		for _, arg := range args {
+			if v, ok := arg.(value.Value); ok && v.Type() == value.NULL {
+				t.Errorf("unexpected NULL argument in %v", cond)
+			}
+		}
+	}
+}
+
+func TestFetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if !strings.Contains(r.Form.Get("statement"), "USE KEYS") || r.Form.Get("args") != `[["k1","k2"]]` {
+			w.Write([]byte(`{"errors":[{"code":1,"msg":"unexpected request"}]}`))
+			return
+		}
+		w.Write([]byte(`{"results":[["k1",{"name":"one"}]],"status":"success"}`))
+	}))
+	defer server.Close()
+
+	c, err := newClient(server.URL)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	ks := newKeyspace(&namespace{name: "remote", client: c}, "things")
+
+	docs := make(map[string]value.AnnotatedValue)
+	errs := ks.Fetch([]string{"k1", "k2"}, docs, datastore.NULL_QUERY_CONTEXT, nil)
+	if len(errs) > 0 {
+		t.Fatalf("fetch failed: %v", errs)
+	}
+	if len(docs) != 1 || docs["k1"] == nil {
+		t.Fatalf("unexpected documents %v", docs)
+	}
+	name, _ := docs["k1"].Field("name")
+	if name.ToString() != "one" {
+		t.Errorf("unexpected document %v", docs["k1"])
+	}
+}
@@ -10,6 +10,7 @@ package resolver
 
 import (
 	"fmt"
+	"net/url"
 	"strings"
 
 	"github.com/couchbase/query/datastore"
@@ -17,6 +18,7 @@ import (
 	"github.com/couchbase/query/datastore/file"
 	"github.com/couchbase/query/datastore/mock"
 	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/value"
 )
 
 func NewDatastore(uri string) (datastore.Datastore, errors.Error) {
@@ -36,9 +38,37 @@ func NewDatastore(uri string) (datastore.Datastore, errors.Error) {
 		return file.NewDatastore(uri[5:])
 	}
 
+	if strings.HasPrefix(uri, "csv:") {
+		return newCSVDatastore(uri[4:])
+	}
+
 	if strings.HasPrefix(uri, "mock:") {
 		return mock.NewDatastore(uri)
 	}
 
 	return nil, errors.NewError(nil, fmt.Sprintf("Invalid datastore uri: %s", uri))
 }
+
+// csv:<path>[?delimiter=<char>&quote=<char>&header=<bool>&key=<field>&sniff=<bool>]
+func newCSVDatastore(uri string) (datastore.Datastore, errors.Error) {
+	path := uri
+	with := map[string]interface{}{"format": "csv"}
+	if i := strings.IndexByte(uri, '?'); i >= 0 {
+		path = uri[:i]
+		query, err := url.ParseQuery(uri[i+1:])
+		if err != nil {
+			return nil, errors.NewError(err, fmt.Sprintf("Invalid datastore uri: %s", uri))
+		}
+		for k, v := range query {
+			switch v[0] {
+			case "true":
+				with[k] = true
+			case "false":
+				with[k] = false
+			default:
+				with[k] = v[0]
+			}
+		}
+	}
+	return file.NewCSVDatastore(path, value.NewValue(with))
+}
@@ -47,7 +47,11 @@ const KEYSPACE_NAME_MY_USER_INFO = "my_user_info"
 const KEYSPACE_NAME_NODES = "nodes"
 const KEYSPACE_NAME_APPLICABLE_ROLES = "applicable_roles"
 const KEYSPACE_NAME_TASKS_CACHE = "tasks_cache"
+const KEYSPACE_NAME_PLAN_BASELINES = "plan_baselines"
+const KEYSPACE_NAME_RESULT_CACHE = "result_cache"
+const KEYSPACE_NAME_STATEMENT_STATS = "statement_stats"
 const KEYSPACE_NAME_TRANSACTIONS = "transactions"
+const KEYSPACE_NAME_MUTATIONS = "mutations"
 
 // TODO, sync with fetch timeout
 const scanTimeout = 30 * time.Second
@@ -63,7 +67,8 @@ func (s *store) PrivilegesFromPath(fullname string, keyspace string, privilege a
 		switch keyspace {
 
 		// currently these keyspaces require system read for delete
-		case KEYSPACE_NAME_ACTIVE, KEYSPACE_NAME_REQUESTS, KEYSPACE_NAME_PREPAREDS, KEYSPACE_NAME_FUNCTIONS_CACHE, KEYSPACE_NAME_DICTIONARY_CACHE:
+		case KEYSPACE_NAME_ACTIVE, KEYSPACE_NAME_REQUESTS, KEYSPACE_NAME_PREPAREDS, KEYSPACE_NAME_FUNCTIONS_CACHE, KEYSPACE_NAME_DICTIONARY_CACHE,
+			KEYSPACE_NAME_PLAN_BASELINES, KEYSPACE_NAME_RESULT_CACHE, KEYSPACE_NAME_STATEMENT_STATS:
 			privs.Add("", auth.PRIV_SYSTEM_READ, auth.PRIV_PROPS_NONE)
 
 			// for all other keyspaces, we rely on the implementation do deny access
@@ -95,6 +100,7 @@ func (s *store) PrivilegesFromPath(fullname string, keyspace string, privilege a
 		case KEYSPACE_NAME_INDEXES:
 		case KEYSPACE_NAME_ALL_INDEXES:
 		case KEYSPACE_NAME_MY_USER_INFO:
+		case KEYSPACE_NAME_MUTATIONS:
 
 		// system read for everything else
 		default:
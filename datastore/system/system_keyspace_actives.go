@@ -163,6 +163,7 @@ func (b *activeRequestsKeyspace) Fetch(keys []string, keysMap map[string]value.A
 				if usedMemory != 0 {
 					item.SetField("usedMemory", usedMemory)
 				}
+				item.SetField("progress", server.RequestProgress(request))
 
 				if request.Prepared() != nil {
 					p := request.Prepared()
@@ -185,6 +186,12 @@ func (b *activeRequestsKeyspace) Fetch(keys []string, keysMap map[string]value.A
 				if userAgent != "" {
 					item.SetField("userAgent", userAgent)
 				}
+				if group := request.WorkloadGroup(); group != nil {
+					item.SetField("workloadGroup", group.Name())
+				}
+				if handle := request.AsyncHandle(); handle != "" {
+					item.SetField("asyncHandle", handle)
+				}
 				memoryQuota := request.MemoryQuota()
 				if memoryQuota != 0 {
 					item.SetField("memoryQuota", memoryQuota)
@@ -0,0 +1,291 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package system
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/timestamp"
+	"github.com/couchbase/query/value"
+)
+
+// mutationsKeyspace lists the mutations retained by the keyspaces of the
+// actual store that support mutation feeds.
+// Keys are of the form namespace:keyspace:seqno
+// Mutations carry document bodies, so only those of keyspaces the user can
+// select from are listed.
+type mutationsKeyspace struct {
+	keyspaceBase
+	si datastore.Indexer
+}
+
+func (b *mutationsKeyspace) Release(close bool) {
+}
+
+func (b *mutationsKeyspace) NamespaceId() string {
+	return b.namespace.Id()
+}
+
+func (b *mutationsKeyspace) Id() string {
+	return b.Name()
+}
+
+func (b *mutationsKeyspace) Name() string {
+	return b.name
+}
+
+func (b *mutationsKeyspace) Count(context datastore.QueryContext) (int64, errors.Error) {
+	var count int64
+
+	err := b.forEachFeed(context, func(namespace string, feed datastore.MutationFeed) bool {
+		mutations, _ := feed.Mutations(0, 0, 0)
+		count += int64(len(mutations))
+		return true
+	})
+	return count, err
+}
+
+func (b *mutationsKeyspace) Size(context datastore.QueryContext) (int64, errors.Error) {
+	return -1, nil
+}
+
+func (b *mutationsKeyspace) Indexer(name datastore.IndexType) (datastore.Indexer, errors.Error) {
+	return b.si, nil
+}
+
+func (b *mutationsKeyspace) Indexers() ([]datastore.Indexer, errors.Error) {
+	return []datastore.Indexer{b.si}, nil
+}
+
+func (b *mutationsKeyspace) Fetch(keys []string, keysMap map[string]value.AnnotatedValue,
+	context datastore.QueryContext, subPaths []string) (errs []errors.Error) {
+	store := b.namespace.store.actualStore
+
+	for _, k := range keys {
+		first := strings.IndexByte(k, ':')
+		last := strings.LastIndexByte(k, ':')
+		if first < 0 || last <= first {
+			continue
+		}
+		seqno, e := strconv.ParseUint(k[last+1:], 10, 64)
+		if e != nil || seqno == 0 {
+			continue
+		}
+
+		namespace, err := store.NamespaceByName(k[:first])
+		if err != nil {
+			continue
+		}
+		keyspace, err := namespace.KeyspaceByName(k[first+1 : last])
+		if err != nil {
+			continue
+		}
+		feed, ok := keyspace.(datastore.MutationFeed)
+		if !ok {
+			continue
+		}
+		if !canRead(context, namespace.Name(), keyspace.Name()) {
+			context.Warning(errors.NewSystemFilteredRowsWarning("system:mutations"))
+			continue
+		}
+
+		// mutations no longer retained are not found
+		mutations, err := feed.Mutations(seqno-1, 1, 0)
+		if err != nil || len(mutations) == 0 || mutations[0].Seqno != seqno {
+			continue
+		}
+
+		item := value.NewAnnotatedValue(mutationDoc(namespace.Name(), keyspace.Name(), mutations[0]))
+		item.NewMeta()["keyspace"] = b.fullName
+		item.SetId(k)
+		keysMap[k] = item
+	}
+	return
+}
+
+func mutationDoc(namespace, keyspace string, mutation *datastore.Mutation) map[string]interface{} {
+	doc := map[string]interface{}{
+		"namespace": namespace,
+		"keyspace":  keyspace,
+		"seqno":     mutation.Seqno,
+		"type":      string(mutation.Type),
+		"key":       mutation.Key,
+		"time":      mutation.Time.Format(expression.DEFAULT_FORMAT),
+	}
+	if mutation.Value != nil {
+		doc["value"] = mutation.Value
+	}
+	return doc
+}
+
+// forEachFeed calls f for every namespace level keyspace that supports
+// mutation feeds and that the user can read, until f returns false
+func (b *mutationsKeyspace) forEachFeed(context datastore.QueryContext,
+	f func(namespace string, feed datastore.MutationFeed) bool) errors.Error {
+	store := b.namespace.store.actualStore
+	namespaces, err := store.NamespaceNames()
+	if err != nil {
+		return err
+	}
+	for _, n := range namespaces {
+		namespace, err := store.NamespaceByName(n)
+		if err != nil {
+			continue
+		}
+		keyspaces, err := namespace.KeyspaceNames()
+		if err != nil {
+			continue
+		}
+		for _, k := range keyspaces {
+			keyspace, err := namespace.KeyspaceByName(k)
+			if err != nil {
+				continue
+			}
+			if feed, ok := keyspace.(datastore.MutationFeed); ok {
+				if context == nil || !canRead(context, namespace.Name(), keyspace.Name()) {
+					continue
+				}
+				if !f(namespace.Name(), feed) {
+					return nil
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (b *mutationsKeyspace) Insert(inserts []value.Pair, context datastore.QueryContext) ([]value.Pair, errors.Error) {
+	return nil, errors.NewSystemNotSupportedError(nil, "")
+}
+
+func (b *mutationsKeyspace) Update(updates []value.Pair, context datastore.QueryContext) ([]value.Pair, errors.Error) {
+	return nil, errors.NewSystemNotSupportedError(nil, "")
+}
+
+func (b *mutationsKeyspace) Upsert(upserts []value.Pair, context datastore.QueryContext) ([]value.Pair, errors.Error) {
+	return nil, errors.NewSystemNotSupportedError(nil, "")
+}
+
+func (b *mutationsKeyspace) Delete(deletes []value.Pair, context datastore.QueryContext) ([]value.Pair, errors.Error) {
+	return nil, errors.NewSystemNotSupportedError(nil, "")
+}
+
+func newMutationsKeyspace(p *namespace) (*mutationsKeyspace, errors.Error) {
+	b := new(mutationsKeyspace)
+	setKeyspaceBase(&b.keyspaceBase, p, KEYSPACE_NAME_MUTATIONS)
+
+	primary := &mutationsIndex{name: "#primary", keyspace: b}
+	b.si = newSystemIndexer(b, primary)
+	setIndexBase(&primary.indexBase, b.si)
+
+	return b, nil
+}
+
+type mutationsIndex struct {
+	indexBase
+	name     string
+	keyspace *mutationsKeyspace
+}
+
+func (pi *mutationsIndex) KeyspaceId() string {
+	return pi.name
+}
+
+func (pi *mutationsIndex) Id() string {
+	return pi.Name()
+}
+
+func (pi *mutationsIndex) Name() string {
+	return pi.name
+}
+
+func (pi *mutationsIndex) Type() datastore.IndexType {
+	return datastore.SYSTEM
+}
+
+func (pi *mutationsIndex) SeekKey() expression.Expressions {
+	return nil
+}
+
+func (pi *mutationsIndex) RangeKey() expression.Expressions {
+	return nil
+}
+
+func (pi *mutationsIndex) Condition() expression.Expression {
+	return nil
+}
+
+func (pi *mutationsIndex) IsPrimary() bool {
+	return true
+}
+
+func (pi *mutationsIndex) State() (state datastore.IndexState, msg string, err errors.Error) {
+	return datastore.ONLINE, "", nil
+}
+
+func (pi *mutationsIndex) Statistics(requestId string, span *datastore.Span) (
+	datastore.Statistics, errors.Error) {
+	return nil, nil
+}
+
+func (pi *mutationsIndex) Drop(requestId string) errors.Error {
+	return errors.NewSystemIdxNoDropError(nil, pi.Name())
+}
+
+func (pi *mutationsIndex) Scan(requestId string, span *datastore.Span, distinct bool, limit int64,
+	cons datastore.ScanConsistency, vector timestamp.Vector, conn *datastore.IndexConnection) {
+	if span == nil {
+		pi.ScanEntries(requestId, limit, cons, vector, conn)
+	} else {
+		defer conn.Sender().Close()
+		spanEvaluator, err := compileSpan(span)
+		if err != nil {
+			conn.Error(err)
+			return
+		}
+		pi.scan(spanEvaluator, limit, conn)
+	}
+}
+
+func (pi *mutationsIndex) ScanEntries(requestId string, limit int64, cons datastore.ScanConsistency,
+	vector timestamp.Vector, conn *datastore.IndexConnection) {
+	defer conn.Sender().Close()
+	pi.scan(nil, limit, conn)
+}
+
+func (pi *mutationsIndex) scan(spanEvaluator *compiledSpan, limit int64, conn *datastore.IndexConnection) {
+	var numProduced int64 = 0
+
+	err := pi.keyspace.forEachFeed(conn.QueryContext(), func(namespace string, feed datastore.MutationFeed) bool {
+		mutations, _ := feed.Mutations(0, 0, 0)
+		prefix := namespace + ":" + feed.Name() + ":"
+		for _, m := range mutations {
+			key := prefix + strconv.FormatUint(m.Seqno, 10)
+			if spanEvaluator != nil && !spanEvaluator.evaluate(key) {
+				continue
+			}
+			entry := datastore.IndexEntry{PrimaryKey: key}
+			if !sendSystemKey(conn, &entry) {
+				return false
+			}
+			numProduced++
+			if limit > 0 && numProduced >= limit {
+				return false
+			}
+		}
+		return true
+	})
+	if err != nil {
+		conn.Error(err)
+	}
+}
@@ -0,0 +1,127 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package system
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/couchbase/query/auth"
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/datastore/mock"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/value"
+)
+
+// grants SELECT on the listed keyspaces only
+type selectStore struct {
+	datastore.Datastore
+	allowed map[string]bool
+}
+
+func (s *selectStore) Authorize(privs *auth.Privileges, creds *auth.Credentials) (auth.AuthenticatedUsers, errors.Error) {
+	for _, pair := range privs.List {
+		if pair.Priv != auth.PRIV_QUERY_SELECT || !s.allowed[pair.Target] {
+			return nil, errors.NewDatastoreInsufficientCredentials(pair.Target)
+		}
+	}
+	return nil, nil
+}
+
+type mutationsContext struct {
+	queryContextImpl
+	warnings int
+}
+
+func (ci *mutationsContext) Warning(warn errors.Error) {
+	ci.warnings++
+}
+
+func (ci *mutationsContext) GetScanCap() int64 {
+	return 16
+}
+
+func (ci *mutationsContext) MaxParallelism() int {
+	return 1
+}
+
+func (ci *mutationsContext) Error(err errors.Error) {
+	ci.t.Logf("scan error: %v", err)
+}
+
+func (ci *mutationsContext) Fatal(fatal errors.Error) {
+	ci.t.Logf("scan fatal: %v", fatal)
+}
+
+func TestMutationsPrivileges(t *testing.T) {
+	m, err := mock.NewDatastore("mock:namespaces=1,keyspaces=2,items=10")
+	if err != nil {
+		t.Fatalf("failed to create mock store: %v", err)
+	}
+	datastore.SetDatastore(&selectStore{Datastore: m, allowed: map[string]bool{"p0:b0": true}})
+	defer datastore.SetDatastore(m)
+
+	namespace, _ := m.NamespaceByName("p0")
+	keys := make(map[string]string, 2)
+	for _, name := range []string{"b0", "b1"} {
+		keyspace, err := namespace.KeyspaceByName(name)
+		if err != nil {
+			t.Fatalf("failed to get keyspace %v: %v", name, err)
+		}
+		doc := value.NewValue(map[string]interface{}{"secret": name})
+		_, err = keyspace.Upsert([]value.Pair{value.Pair{Name: "k", Value: doc}}, datastore.NULL_QUERY_CONTEXT)
+		if err != nil {
+			t.Fatalf("failed to upsert into %v: %v", name, err)
+		}
+		seqno := keyspace.(datastore.MutationFeed).LastSeqno()
+		keys[name] = "p0:" + name + ":" + strconv.FormatUint(seqno, 10)
+	}
+
+	s, err := NewDatastore(m)
+	if err != nil {
+		t.Fatalf("failed to create system store: %v", err)
+	}
+	p, _ := s.NamespaceByName(datastore.SYSTEM_NAMESPACE)
+	mutations, err := p.KeyspaceByName(KEYSPACE_NAME_MUTATIONS)
+	if err != nil {
+		t.Fatalf("failed to get keyspace by name %v", err)
+	}
+
+	context := &mutationsContext{queryContextImpl: queryContextImpl{t: t}}
+	count, err := mutations.Count(context)
+	if err != nil || count != 1 {
+		t.Errorf("expected the mutations of one keyspace only, got %v %v", count, err)
+	}
+
+	indexer, _ := mutations.Indexer(datastore.SYSTEM)
+	primary, _ := indexer.PrimaryIndexes()
+	conn := datastore.NewIndexConnection(context)
+	go primary[0].ScanEntries("", 0, datastore.UNBOUNDED, nil, conn)
+	scanned := []string{}
+	for {
+		entry, ok := conn.Sender().GetEntry()
+		if !ok || entry == nil {
+			break
+		}
+		scanned = append(scanned, entry.PrimaryKey)
+	}
+	if len(scanned) != 1 || scanned[0] != keys["b0"] {
+		t.Errorf("expected only %v to be scanned, got %v", keys["b0"], scanned)
+	}
+
+	// fetching keys directly does not get around the privileges
+	docs := make(map[string]value.AnnotatedValue)
+	mutations.Fetch([]string{keys["b0"], keys["b1"]}, docs, context, nil)
+	if len(docs) != 1 || docs[keys["b0"]] == nil {
+		t.Errorf("expected only %v to be fetched, got %v", keys["b0"], docs)
+	}
+	if context.warnings != 1 {
+		t.Errorf("expected a filtered rows warning, got %v", context.warnings)
+	}
+}
@@ -0,0 +1,337 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package system
+
+import (
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/distributed"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/expression/parser"
+	"github.com/couchbase/query/prepareds"
+	"github.com/couchbase/query/timestamp"
+	"github.com/couchbase/query/value"
+)
+
+type planBaselinesKeyspace struct {
+	keyspaceBase
+	indexer datastore.Indexer
+}
+
+func (b *planBaselinesKeyspace) Release(close bool) {
+}
+
+func (b *planBaselinesKeyspace) NamespaceId() string {
+	return b.namespace.Id()
+}
+
+func (b *planBaselinesKeyspace) Id() string {
+	return b.Name()
+}
+
+func (b *planBaselinesKeyspace) Name() string {
+	return b.name
+}
+
+func (b *planBaselinesKeyspace) Count(context datastore.QueryContext) (int64, errors.Error) {
+	var count int
+
+	count = 0
+	distributed.RemoteAccess().GetRemoteKeys([]string{}, "plan_baselines", func(id string) bool {
+		count++
+		return true
+	}, func(warn errors.Error) {
+		context.Warning(warn)
+	})
+	return int64(prepareds.CountBaselines() + count), nil
+}
+
+func (b *planBaselinesKeyspace) Size(context datastore.QueryContext) (int64, errors.Error) {
+	return -1, nil
+}
+
+func (b *planBaselinesKeyspace) Indexer(name datastore.IndexType) (datastore.Indexer, errors.Error) {
+	return b.indexer, nil
+}
+
+func (b *planBaselinesKeyspace) Indexers() ([]datastore.Indexer, errors.Error) {
+	return []datastore.Indexer{b.indexer}, nil
+}
+
+func (b *planBaselinesKeyspace) Fetch(keys []string, keysMap map[string]value.AnnotatedValue,
+	context datastore.QueryContext, subPaths []string) (errs []errors.Error) {
+
+	// now that the node name can change in flight, use a consistent one across fetches
+	whoAmI := distributed.RemoteAccess().WhoAmI()
+	for _, key := range keys {
+		node, localKey := distributed.RemoteAccess().SplitKey(key)
+
+		// remote entry
+		if len(node) != 0 && node != whoAmI {
+			distributed.RemoteAccess().GetRemoteDoc(node, localKey,
+				"plan_baselines", "POST",
+				func(doc map[string]interface{}) {
+
+					remoteValue := value.NewAnnotatedValue(doc)
+					remoteValue.SetField("node", node)
+					remoteValue.NewMeta()["keyspace"] = b.fullName
+					remoteValue.SetId(key)
+					keysMap[key] = remoteValue
+				},
+				func(warn errors.Error) {
+					context.Warning(warn)
+				}, distributed.NO_CREDS, "")
+		} else {
+
+			// local entry
+			prepareds.BaselineDo(localKey, func(baseline *prepareds.Baseline) {
+				itemMap := baseline.Format(true)
+				if node != "" {
+					itemMap["node"] = node
+				}
+
+				item := value.NewAnnotatedValue(itemMap)
+				item.NewMeta()["keyspace"] = b.fullName
+				item.SetId(key)
+				keysMap[key] = item
+			})
+		}
+	}
+	return
+}
+
+func (b *planBaselinesKeyspace) Insert(inserts []value.Pair, context datastore.QueryContext) ([]value.Pair, errors.Error) {
+	return nil, errors.NewSystemNotSupportedError(nil, "")
+}
+
+func (b *planBaselinesKeyspace) Update(updates []value.Pair, context datastore.QueryContext) ([]value.Pair, errors.Error) {
+	return nil, errors.NewSystemNotSupportedError(nil, "")
+}
+
+func (b *planBaselinesKeyspace) Upsert(upserts []value.Pair, context datastore.QueryContext) ([]value.Pair, errors.Error) {
+	return nil, errors.NewSystemNotSupportedError(nil, "")
+}
+
+func (b *planBaselinesKeyspace) Delete(deletes []value.Pair, context datastore.QueryContext) ([]value.Pair, errors.Error) {
+
+	// now that the node name can change in flight, use a consistent one across deletes
+	whoAmI := distributed.RemoteAccess().WhoAmI()
+	for _, pair := range deletes {
+		name := pair.Name
+		node, localKey := distributed.RemoteAccess().SplitKey(name)
+
+		// remote entry
+		if len(node) != 0 && node != whoAmI {
+
+			distributed.RemoteAccess().GetRemoteDoc(node, localKey,
+				"plan_baselines", "DELETE", nil,
+				func(warn errors.Error) {
+					context.Warning(warn)
+				},
+				distributed.NO_CREDS, "")
+
+		} else {
+			// local entry
+			prepareds.DeleteBaseline(localKey)
+		}
+	}
+	return deletes, nil
+}
+
+func newPlanBaselinesKeyspace(p *namespace) (*planBaselinesKeyspace, errors.Error) {
+	b := new(planBaselinesKeyspace)
+	setKeyspaceBase(&b.keyspaceBase, p, KEYSPACE_NAME_PLAN_BASELINES)
+
+	primary := &planBaselinesIndex{
+		name:     "#primary",
+		keyspace: b,
+		primary:  true,
+	}
+	b.indexer = newSystemIndexer(b, primary)
+	setIndexBase(&primary.indexBase, b.indexer)
+
+	// add a secondary index on `node`
+	expr, err := parser.Parse(`node`)
+
+	if err == nil {
+		key := expression.Expressions{expr}
+		nodes := &planBaselinesIndex{
+			name:     "#nodes",
+			keyspace: b,
+			primary:  false,
+			idxKey:   key,
+		}
+		setIndexBase(&nodes.indexBase, b.indexer)
+		b.indexer.(*systemIndexer).AddIndex(nodes.name, nodes)
+	} else {
+		return nil, errors.NewSystemDatastoreError(err, "")
+	}
+
+	return b, nil
+}
+
+type planBaselinesIndex struct {
+	indexBase
+	name     string
+	keyspace *planBaselinesKeyspace
+	primary  bool
+	idxKey   expression.Expressions
+}
+
+func (pi *planBaselinesIndex) KeyspaceId() string {
+	return pi.keyspace.Id()
+}
+
+func (pi *planBaselinesIndex) Id() string {
+	return pi.Name()
+}
+
+func (pi *planBaselinesIndex) Name() string {
+	return pi.name
+}
+
+func (pi *planBaselinesIndex) Type() datastore.IndexType {
+	return datastore.SYSTEM
+}
+
+func (pi *planBaselinesIndex) SeekKey() expression.Expressions {
+	return pi.idxKey
+}
+
+func (pi *planBaselinesIndex) RangeKey() expression.Expressions {
+	return pi.idxKey
+}
+
+func (pi *planBaselinesIndex) Condition() expression.Expression {
+	return nil
+}
+
+func (pi *planBaselinesIndex) IsPrimary() bool {
+	return pi.primary
+}
+
+func (pi *planBaselinesIndex) State() (state datastore.IndexState, msg string, err errors.Error) {
+	if pi.primary || distributed.RemoteAccess().WhoAmI() != "" {
+		return datastore.ONLINE, "", nil
+	} else {
+		return datastore.OFFLINE, "", nil
+	}
+}
+
+func (pi *planBaselinesIndex) Statistics(requestId string, span *datastore.Span) (
+	datastore.Statistics, errors.Error) {
+	return nil, nil
+}
+
+func (pi *planBaselinesIndex) Drop(requestId string) errors.Error {
+	return errors.NewSystemIdxNoDropError(nil, "")
+}
+
+func (pi *planBaselinesIndex) Scan(requestId string, span *datastore.Span, distinct bool, limit int64,
+	cons datastore.ScanConsistency, vector timestamp.Vector, conn *datastore.IndexConnection) {
+
+	if span == nil || pi.primary {
+		pi.ScanEntries(requestId, limit, cons, vector, conn)
+	} else {
+		var entry *datastore.IndexEntry
+		defer conn.Sender().Close()
+
+		spanEvaluator, err := compileSpan(span)
+		if err != nil {
+			conn.Error(err)
+			return
+		}
+		if spanEvaluator.isEquals() {
+
+			// now that the node name can change in flight, use a consistent one across the scan
+			whoAmI := distributed.RemoteAccess().WhoAmI()
+			if spanEvaluator.key() == whoAmI {
+				prepareds.BaselinesForeach(func(name string, baseline *prepareds.Baseline) bool {
+					entry = &datastore.IndexEntry{
+						PrimaryKey: distributed.RemoteAccess().MakeKey(whoAmI, name),
+						EntryKey:   value.Values{value.NewValue(whoAmI)},
+					}
+					return true
+				}, func() bool {
+					return sendSystemKey(conn, entry)
+				})
+			} else {
+				nodes := []string{spanEvaluator.key()}
+				distributed.RemoteAccess().GetRemoteKeys(nodes, "plan_baselines", func(id string) bool {
+					n, _ := distributed.RemoteAccess().SplitKey(id)
+					indexEntry := datastore.IndexEntry{
+						PrimaryKey: id,
+						EntryKey:   value.Values{value.NewValue(n)},
+					}
+					return sendSystemKey(conn, &indexEntry)
+				}, func(warn errors.Error) {
+					conn.Warning(warn)
+				})
+			}
+		} else {
+
+			// now that the node name can change in flight, use a consistent one across the scan
+			whoAmI := distributed.RemoteAccess().WhoAmI()
+			nodes := distributed.RemoteAccess().GetNodeNames()
+			eligibleNodes := []string{}
+			for _, node := range nodes {
+				if spanEvaluator.evaluate(node) {
+					if node == whoAmI {
+
+						prepareds.BaselinesForeach(func(name string, baseline *prepareds.Baseline) bool {
+							entry = &datastore.IndexEntry{
+								PrimaryKey: distributed.RemoteAccess().MakeKey(whoAmI, name),
+								EntryKey:   value.Values{value.NewValue(whoAmI)},
+							}
+							return true
+						}, func() bool {
+							return sendSystemKey(conn, entry)
+						})
+					} else {
+						eligibleNodes = append(eligibleNodes, node)
+					}
+				}
+			}
+			if len(eligibleNodes) > 0 {
+				distributed.RemoteAccess().GetRemoteKeys(eligibleNodes, "plan_baselines", func(id string) bool {
+					n, _ := distributed.RemoteAccess().SplitKey(id)
+					indexEntry := datastore.IndexEntry{
+						PrimaryKey: id,
+						EntryKey:   value.Values{value.NewValue(n)},
+					}
+					return sendSystemKey(conn, &indexEntry)
+				}, func(warn errors.Error) {
+					conn.Warning(warn)
+				})
+			}
+		}
+	}
+}
+
+func (pi *planBaselinesIndex) ScanEntries(requestId string, limit int64, cons datastore.ScanConsistency,
+	vector timestamp.Vector, conn *datastore.IndexConnection) {
+	var entry *datastore.IndexEntry
+
+	defer conn.Sender().Close()
+
+	// now that the node name can change in flight, use a consistent one across the scan
+	whoAmI := distributed.RemoteAccess().WhoAmI()
+	prepareds.BaselinesForeach(func(name string, baseline *prepareds.Baseline) bool {
+		entry = &datastore.IndexEntry{PrimaryKey: distributed.RemoteAccess().MakeKey(whoAmI, name)}
+		return true
+	}, func() bool {
+		return sendSystemKey(conn, entry)
+	})
+	distributed.RemoteAccess().GetRemoteKeys([]string{}, "plan_baselines", func(id string) bool {
+		indexEntry := datastore.IndexEntry{PrimaryKey: id}
+		return sendSystemKey(conn, &indexEntry)
+	}, func(warn errors.Error) {
+		conn.Warning(warn)
+	})
+}
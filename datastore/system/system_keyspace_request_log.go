@@ -177,6 +177,9 @@ func (b *requestLogKeyspace) Fetch(keys []string, keysMap map[string]value.Annot
 				if entry.UserAgent != "" {
 					item.SetField("userAgent", entry.UserAgent)
 				}
+				if entry.AsyncHandle != "" {
+					item.SetField("asyncHandle", entry.AsyncHandle)
+				}
 				if entry.Tag != "" {
 					item.SetField("~tag", entry.Tag)
 				}
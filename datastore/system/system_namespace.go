@@ -204,6 +204,30 @@ func (p *namespace) loadKeyspaces() (e errors.Error) {
 
 	p.keyspaces[tasksCache.Name()] = tasksCache
 
+	baselines, e := newPlanBaselinesKeyspace(p)
+	if e != nil {
+		return e
+	}
+	p.keyspaces[baselines.Name()] = baselines
+
+	resultCache, e := newResultCacheKeyspace(p)
+	if e != nil {
+		return e
+	}
+	p.keyspaces[resultCache.Name()] = resultCache
+
+	statementStats, e := newStatementStatsKeyspace(p)
+	if e != nil {
+		return e
+	}
+	p.keyspaces[statementStats.Name()] = statementStats
+
+	mutations, e := newMutationsKeyspace(p)
+	if e != nil {
+		return e
+	}
+	p.keyspaces[mutations.Name()] = mutations
+
 	reqs, e := newRequestsKeyspace(p)
 	if e != nil {
 		return e
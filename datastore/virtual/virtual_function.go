@@ -0,0 +1,92 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package virtual
+
+import (
+	"strconv"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/functions"
+	"github.com/couchbase/query/value"
+)
+
+// FunctionKeyspace is a virtual keyspace whose documents are the rows
+// returned by a table function for a given set of arguments.
+// Rows have no document key, so they are identified by their position.
+type FunctionKeyspace struct {
+	virtualKeyspace
+	name functions.FunctionName
+	args value.Values
+}
+
+func NewFunctionKeyspace(namespace datastore.Namespace, name functions.FunctionName, args value.Values) (*FunctionKeyspace, errors.Error) {
+	path := name.Path()
+	if len(path) != 2 && len(path) != 4 {
+		return nil, errors.NewDatastoreInvalidPathError(name.Name())
+	}
+
+	rv := &FunctionKeyspace{
+		virtualKeyspace: virtualKeyspace{
+			path:      path,
+			namespace: namespace,
+		},
+		name: name,
+		args: args,
+	}
+	if len(path) == 4 {
+		scope := &virtualScope{id: path[2], keyspace: &rv.virtualKeyspace}
+		bucket := &virtualBucket{id: path[1], namespace: namespace, scope: scope}
+		scope.bucket = bucket
+		rv.scope = scope
+	}
+	return rv, nil
+}
+
+func (this *FunctionKeyspace) Function() functions.FunctionName {
+	return this.name
+}
+
+func (this *FunctionKeyspace) Indexer(name datastore.IndexType) (datastore.Indexer, errors.Error) {
+	return nil, errors.NewVirtualKSNotSupportedError(nil, "Indexer for table function "+this.name.Name()+".")
+}
+
+func (this *FunctionKeyspace) Indexers() ([]datastore.Indexer, errors.Error) {
+	return nil, nil
+}
+
+// Rows executes the function and returns its result rows
+func (this *FunctionKeyspace) Rows(context functions.Context) (value.AnnotatedValues, errors.Error) {
+	val, err := functions.ExecuteFunction(this.name, functions.READONLY, this.args, context)
+	if err != nil {
+		return nil, err
+	}
+
+	return functionRows(this.name.Name(), val)
+}
+
+// functions returning NULL or MISSING have no rows
+func functionRows(name string, val value.Value) (value.AnnotatedValues, errors.Error) {
+	var rows []interface{}
+	switch actual := val.Actual().(type) {
+	case []interface{}:
+		rows = actual
+	case nil:
+	default:
+		return nil, errors.NewTableFunctionResultError(name, val.Type().String())
+	}
+
+	rv := make(value.AnnotatedValues, len(rows))
+	for i, row := range rows {
+		av := value.NewAnnotatedValue(row)
+		av.SetId(strconv.Itoa(i))
+		rv[i] = av
+	}
+	return rv, nil
+}
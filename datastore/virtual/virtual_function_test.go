@@ -0,0 +1,49 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package virtual
+
+import (
+	"testing"
+
+	"github.com/couchbase/query/value"
+)
+
+func TestFunctionRows(t *testing.T) {
+	rows, err := functionRows("f", value.NewValue([]interface{}{
+		map[string]interface{}{"a": 1},
+		map[string]interface{}{"a": 2},
+	}))
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("Expected 2 rows, got %v", len(rows))
+	}
+	for i, id := range []string{"0", "1"} {
+		if rows[i].GetId() != id {
+			t.Errorf("Expected id %v, got %v", id, rows[i].GetId())
+		}
+		a, _ := rows[i].Field("a")
+		if a.Actual() != int64(i+1) {
+			t.Errorf("Unexpected row %v", rows[i])
+		}
+	}
+
+	for _, val := range []value.Value{value.NULL_VALUE, value.MISSING_VALUE, value.EMPTY_ARRAY_VALUE} {
+		rows, err = functionRows("f", val)
+		if err != nil || len(rows) != 0 {
+			t.Errorf("Expected no rows for %v, got %v %v", val, rows, err)
+		}
+	}
+
+	_, err = functionRows("f", value.NewValue("x"))
+	if err == nil || err.Code() != 10110 {
+		t.Errorf("Expected table function result error, got %v", err)
+	}
+}
@@ -163,3 +163,13 @@ func NewAdminBodyError(e error) Error {
 	return &err{level: EXCEPTION, ICode: 2220, IKey: "admin.accounting.bad_body", ICause: e,
 		InternalMsg: "Error getting request body", InternalCaller: CallerN(1)}
 }
+
+func NewAdminWorkloadGroupError(group string, e error) Error {
+	return &err{level: EXCEPTION, ICode: 2230, IKey: "admin.workload.invalid_group", ICause: e,
+		InternalMsg: fmt.Sprintf("Invalid workload group %v", group), InternalCaller: CallerN(1)}
+}
+
+func NewAdminUserLimitError(limit string, e error) Error {
+	return &err{level: EXCEPTION, ICode: 2240, IKey: "admin.user_limit.invalid_limit", ICause: e,
+		InternalMsg: fmt.Sprintf("Invalid user limit %v", limit), InternalCaller: CallerN(1)}
+}
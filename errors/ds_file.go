@@ -71,3 +71,18 @@ func NewFilePrimaryIdxNoDropError(e error, msg string) Error {
 	return &err{level: EXCEPTION, ICode: 15011, IKey: "datastore.file.primary_idx_no_drop", ICause: e,
 		InternalMsg: "Primary Index cannot be dropped " + msg, InternalCaller: CallerN(1)}
 }
+
+func NewFileCSVOptionError(e error, msg string) Error {
+	return &err{level: EXCEPTION, ICode: 15012, IKey: "datastore.file.csv_option", ICause: e,
+		InternalMsg: "Invalid CSV option " + msg, InternalCaller: CallerN(1)}
+}
+
+func NewFileCSVParseError(e error, msg string) Error {
+	return &err{level: EXCEPTION, ICode: 15013, IKey: "datastore.file.csv_parse", ICause: e,
+		InternalMsg: "Error parsing CSV file " + msg, InternalCaller: CallerN(1)}
+}
+
+func NewFileReadOnlyError(e error, msg string) Error {
+	return &err{level: EXCEPTION, ICode: 15014, IKey: "datastore.file.read_only", ICause: e,
+		InternalMsg: "Keyspace is read only " + msg, InternalCaller: CallerN(1)}
+}
@@ -8,7 +8,9 @@
 
 package errors
 
-import ()
+import (
+	"fmt"
+)
 
 // Error codes for all other datastores, e.g Mock
 
@@ -76,3 +78,13 @@ func NewNoFlushError(k string) Error {
 	return &err{level: EXCEPTION, ICode: 16040, IKey: "datastore.other.flush_disabled",
 		InternalMsg: "Keyspace does not support flush: " + k, InternalCaller: CallerN(1)}
 }
+
+func NewMutationFeedNotSupportedError(k string) Error {
+	return &err{level: EXCEPTION, ICode: 16050, IKey: "datastore.other.mutation_feed_not_supported",
+		InternalMsg: "Keyspace does not support mutation feeds: " + k, InternalCaller: CallerN(1)}
+}
+
+func NewMutationCheckpointError(seqno uint64) Error {
+	return &err{level: EXCEPTION, ICode: 16051, IKey: "datastore.other.mutation_checkpoint",
+		InternalMsg: fmt.Sprintf("Mutations since checkpoint %v are no longer available", seqno), InternalCaller: CallerN(1)}
+}
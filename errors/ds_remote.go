@@ -0,0 +1,41 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package errors
+
+// Remote (federated) datastore error codes
+
+func NewRemoteDatastoreError(e error, msg string) Error {
+	return &err{level: EXCEPTION, ICode: 19000, IKey: "datastore.remote.generic_error", ICause: e,
+		InternalMsg: "Error in remote datastore " + msg, InternalCaller: CallerN(1)}
+}
+
+func NewRemoteNamespaceNotFoundError(e error, msg string) Error {
+	return &err{level: EXCEPTION, ICode: 19001, IKey: "datastore.remote.namespace_not_found", ICause: e,
+		InternalMsg: "Namespace not found in remote datastore " + msg, InternalCaller: CallerN(1)}
+}
+
+func NewRemoteKeyspaceNotFoundError(e error, msg string) Error {
+	return &err{level: EXCEPTION, ICode: 19002, IKey: "datastore.remote.keyspace_not_found", ICause: e,
+		InternalMsg: "Keyspace not found in remote datastore " + msg, InternalCaller: CallerN(1)}
+}
+
+func NewRemoteQueryError(e error, msg string) Error {
+	return &err{level: EXCEPTION, ICode: 19003, IKey: "datastore.remote.query_error", ICause: e,
+		InternalMsg: "Remote query failed " + msg, InternalCaller: CallerN(1)}
+}
+
+func NewRemoteNotSupportedError(e error, msg string) Error {
+	return &err{level: EXCEPTION, ICode: 19004, IKey: "datastore.remote.not_supported", ICause: e,
+		InternalMsg: "Operation not supported on remote keyspaces " + msg, InternalCaller: CallerN(1)}
+}
+
+func NewRemoteIdxNotFoundError(e error, msg string) Error {
+	return &err{level: EXCEPTION, ICode: 19005, IKey: "datastore.remote.idx_not_found", ICause: e,
+		InternalMsg: "Index not found in remote datastore " + msg, InternalCaller: CallerN(1)}
+}
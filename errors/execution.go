@@ -330,3 +330,43 @@ func NewNilEvaluateParamError(param string) Error {
 		InternalMsg:    fmt.Sprintf("nil '%s' parameter for evaluation", param),
 		InternalCaller: CallerN(1)}
 }
+
+const EXE_SESSION_REQUIRED = 5510
+
+func NewSessionRequiredError(stmt string) Error {
+	return &err{level: EXCEPTION, ICode: EXE_SESSION_REQUIRED, IKey: "execution.session.required",
+		InternalMsg:    fmt.Sprintf("%s requires a session; set the session_id request parameter", stmt),
+		InternalCaller: CallerN(1)}
+}
+
+const (
+	EXE_TEMP_COLLECTION_EXISTS    = 5520
+	EXE_TEMP_COLLECTION_NOT_FOUND = 5521
+	EXE_TEMP_COLLECTION_PREPARE   = 5522
+)
+
+func NewTempCollectionExistsError(name string) Error {
+	return &err{level: EXCEPTION, ICode: EXE_TEMP_COLLECTION_EXISTS, IKey: "execution.session.collection_exists",
+		InternalMsg:    fmt.Sprintf("Temporary collection %s already exists", name),
+		InternalCaller: CallerN(1)}
+}
+
+func NewTempCollectionNotFoundError(name string) Error {
+	return &err{level: EXCEPTION, ICode: EXE_TEMP_COLLECTION_NOT_FOUND, IKey: "execution.session.collection_not_found",
+		InternalMsg:    fmt.Sprintf("Temporary collection %s not found", name),
+		InternalCaller: CallerN(1)}
+}
+
+func NewTempCollectionPrepareError(name string) Error {
+	return &err{level: EXCEPTION, ICode: EXE_TEMP_COLLECTION_PREPARE, IKey: "execution.session.collection_prepare",
+		InternalMsg:    fmt.Sprintf("Temporary collection %s cannot be used in prepared statements", name),
+		InternalCaller: CallerN(1)}
+}
+
+const EXE_SESSION_MEMORY_QUOTA = 5530
+
+func NewSessionMemoryQuotaExceededError(quota uint64) Error {
+	return &err{level: EXCEPTION, ICode: EXE_SESSION_MEMORY_QUOTA, IKey: "execution.session.memory_quota.exceeded",
+		InternalMsg:    fmt.Sprintf("Session has exceeded its memory quota of %vMB", quota),
+		InternalCaller: CallerN(1)}
+}
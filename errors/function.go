@@ -109,3 +109,9 @@ func NewFunctionExecutionError(what string, name string, reason error) Error {
 		InternalMsg:    fmt.Sprintf("Error executing function %v %v: %v", name, what, reason),
 		InternalCaller: CallerN(1)}
 }
+
+func NewTableFunctionResultError(name string, what string) Error {
+	return &err{level: EXCEPTION, ICode: 10110, IKey: "function.table.result.error",
+		InternalMsg:    fmt.Sprintf("Table function %v must return an array, not %v", name, what),
+		InternalCaller: CallerN(1)}
+}
@@ -104,6 +104,27 @@ func NewPredefinedPreparedNameError(msg string) Error {
 		InternalMsg: fmt.Sprintf("Prepared name %s is predefined (reserved). ", msg), InternalCaller: CallerN(1)}
 }
 
+const NO_SUCH_PLAN_BASELINE = 4093
+
+func NewNoSuchPlanBaselineError(name string) Error {
+	return &err{level: EXCEPTION, ICode: NO_SUCH_PLAN_BASELINE, IKey: "plan.baseline.no_such_name",
+		InternalMsg: fmt.Sprintf("No such plan baseline: %s", name), InternalCaller: CallerN(1)}
+}
+
+const NO_SUCH_BASELINE_PLAN = 4094
+
+func NewNoSuchBaselinePlanError(name, id string) Error {
+	return &err{level: EXCEPTION, ICode: NO_SUCH_BASELINE_PLAN, IKey: "plan.baseline.no_such_plan",
+		InternalMsg: fmt.Sprintf("No such plan %s in plan baseline %s", id, name), InternalCaller: CallerN(1)}
+}
+
+const PLAN_BASELINE_MODE = 4095
+
+func NewPlanBaselineModeError(mode string) Error {
+	return &err{level: EXCEPTION, ICode: PLAN_BASELINE_MODE, IKey: "plan.baseline.mode",
+		InternalMsg: fmt.Sprintf("Invalid plan baseline mode: %s (use off, use or capture)", mode), InternalCaller: CallerN(1)}
+}
+
 const NO_INDEX_JOIN = 4100
 
 func NewNoIndexJoinError(alias, op string) Error {
@@ -120,3 +120,131 @@ func NewServiceShutDownError() Error {
 	return &err{level: EXCEPTION, ICode: 1181, IKey: "service.shutdown",
 		InternalMsg: "Service shut down", InternalCaller: CallerN(1)}
 }
+
+const SERVICE_NO_SUCH_ASYNC = 1190
+
+func NewServiceErrorNoSuchAsync(handle string) Error {
+	return &err{level: EXCEPTION, ICode: SERVICE_NO_SUCH_ASYNC, IKey: "service.async.no_such_handle",
+		InternalMsg: fmt.Sprintf("No such asynchronous request: %s", handle), InternalCaller: CallerN(1)}
+}
+
+func NewServiceErrorAsyncSpool(e error, handle string) Error {
+	return &err{level: EXCEPTION, ICode: 1191, IKey: "service.async.spool", ICause: e,
+		InternalMsg: fmt.Sprintf("Unable to access the results of asynchronous request %s", handle), InternalCaller: CallerN(1)}
+}
+
+const SERVICE_NO_SUCH_CURSOR = 1192
+
+func NewServiceErrorNoSuchCursor(token string) Error {
+	return &err{level: EXCEPTION, ICode: SERVICE_NO_SUCH_CURSOR, IKey: "service.cursor.no_such_token",
+		InternalMsg: fmt.Sprintf("No such cursor: %s", token), InternalCaller: CallerN(1)}
+}
+
+func NewServiceErrorCursorTimeout(timeout time.Duration) Error {
+	return &err{level: EXCEPTION, ICode: 1193, IKey: "service.cursor.timeout",
+		InternalMsg: fmt.Sprintf("Cursor idle for longer than %v", timeout), InternalCaller: CallerN(1)}
+}
+
+const SERVICE_NO_SUCH_RESULT_CACHE_ENTRY = 1194
+
+func NewServiceErrorNoSuchResultCacheEntry(key string) Error {
+	return &err{level: EXCEPTION, ICode: SERVICE_NO_SUCH_RESULT_CACHE_ENTRY, IKey: "service.result_cache.no_such_entry",
+		InternalMsg: fmt.Sprintf("No such result cache entry: %s", key), InternalCaller: CallerN(1)}
+}
+
+const SERVICE_NO_SUCH_STATEMENT_STATS = 1195
+
+func NewServiceErrorNoSuchStatementStats(fingerprint string) Error {
+	return &err{level: EXCEPTION, ICode: SERVICE_NO_SUCH_STATEMENT_STATS, IKey: "service.statement_stats.no_such_fingerprint",
+		InternalMsg: fmt.Sprintf("No statistics for statement: %s", fingerprint), InternalCaller: CallerN(1)}
+}
+
+const (
+	SERVICE_USER_RATE_LIMIT    = 1196
+	SERVICE_USER_REQUEST_LIMIT = 1197
+	SERVICE_USER_MEMORY_LIMIT  = 1198
+)
+
+// the cause carries the limit, the user, and how long to wait before retrying
+func NewServiceErrorUserRateLimit(limit, user string, rate float64, retryAfter time.Duration) Error {
+	return &err{level: EXCEPTION, ICode: SERVICE_USER_RATE_LIMIT, IKey: "service.user_limit.rate",
+		InternalMsg:    fmt.Sprintf("User %s exceeded the limit of %v requests per second (%s)", user, rate, limit),
+		InternalCaller: CallerN(1), retry: true, cause: userLimitCause(limit, user, retryAfter)}
+}
+
+func NewServiceErrorUserRequestLimit(limit, user string, maxRequests int, retryAfter time.Duration) Error {
+	return &err{level: EXCEPTION, ICode: SERVICE_USER_REQUEST_LIMIT, IKey: "service.user_limit.requests",
+		InternalMsg:    fmt.Sprintf("User %s exceeded the limit of %v concurrent requests (%s)", user, maxRequests, limit),
+		InternalCaller: CallerN(1), retry: true, cause: userLimitCause(limit, user, retryAfter)}
+}
+
+func NewServiceErrorUserMemoryLimit(limit, user string, memoryQuota uint64, retryAfter time.Duration) Error {
+	return &err{level: EXCEPTION, ICode: SERVICE_USER_MEMORY_LIMIT, IKey: "service.user_limit.memory",
+		InternalMsg:    fmt.Sprintf("User %s exceeded the memory quota of %vMB (%s)", user, memoryQuota, limit),
+		InternalCaller: CallerN(1), retry: true, cause: userLimitCause(limit, user, retryAfter)}
+}
+
+func userLimitCause(limit, user string, retryAfter time.Duration) map[string]interface{} {
+	return map[string]interface{}{
+		"limit":       limit,
+		"user":        user,
+		"retry_after": int64((retryAfter + time.Second - 1) / time.Second),
+	}
+}
+
+const SERVICE_NO_SUCH_SESSION = 1199
+
+func NewServiceErrorNoSuchSession(id string) Error {
+	return &err{level: EXCEPTION, ICode: SERVICE_NO_SUCH_SESSION, IKey: "service.session.no_such_session",
+		InternalMsg: fmt.Sprintf("No such session: %s", id), InternalCaller: CallerN(1)}
+}
+
+const (
+	SERVICE_WS_NO_SUCH_REQUEST   = 1200
+	SERVICE_WS_DUPLICATE_REQUEST = 1201
+	SERVICE_WS_TOO_MANY_REQUESTS = 1202
+	SERVICE_WS_MESSAGE           = 1203
+)
+
+func NewServiceErrorWsNoSuchRequest(id string) Error {
+	return &err{level: EXCEPTION, ICode: SERVICE_WS_NO_SUCH_REQUEST, IKey: "service.websocket.no_such_request",
+		InternalMsg: fmt.Sprintf("No such request on this connection: %s", id), InternalCaller: CallerN(1)}
+}
+
+func NewServiceErrorWsDuplicateRequest(id string) Error {
+	return &err{level: EXCEPTION, ICode: SERVICE_WS_DUPLICATE_REQUEST, IKey: "service.websocket.duplicate_request",
+		InternalMsg: fmt.Sprintf("Request %s is already running on this connection", id), InternalCaller: CallerN(1)}
+}
+
+func NewServiceErrorWsTooManyRequests(limit int) Error {
+	return &err{level: EXCEPTION, ICode: SERVICE_WS_TOO_MANY_REQUESTS, IKey: "service.websocket.too_many_requests",
+		InternalMsg: fmt.Sprintf("No more than %d requests can run on a connection", limit), InternalCaller: CallerN(1)}
+}
+
+func NewServiceErrorWsMessage(e error) Error {
+	return &err{level: EXCEPTION, ICode: SERVICE_WS_MESSAGE, IKey: "service.websocket.bad_message", ICause: e,
+		InternalMsg: "Invalid websocket message", InternalCaller: CallerN(1)}
+}
+
+// paged requests hold on to their servicer while paused
+const SERVICE_TOO_MANY_CURSORS = 1204
+
+func NewServiceErrorTooManyCursors(limit int) Error {
+	return &err{level: EXCEPTION, ICode: SERVICE_TOO_MANY_CURSORS, IKey: "service.cursor.too_many",
+		InternalMsg: fmt.Sprintf("No more than %d paged requests can be open at a time", limit), InternalCaller: CallerN(1)}
+}
+
+// the results of asynchronous requests are kept on disk until they expire
+const SERVICE_TOO_MANY_ASYNC = 1205
+
+func NewServiceErrorTooManyAsync(limit int) Error {
+	return &err{level: EXCEPTION, ICode: SERVICE_TOO_MANY_ASYNC, IKey: "service.async.too_many",
+		InternalMsg: fmt.Sprintf("No more than %d asynchronous requests can be kept at a time", limit), InternalCaller: CallerN(1)}
+}
+
+const SERVICE_ASYNC_QUOTA = 1206
+
+func NewServiceErrorAsyncQuota(quota uint64) Error {
+	return &err{level: EXCEPTION, ICode: SERVICE_ASYNC_QUOTA, IKey: "service.async.quota",
+		InternalMsg: fmt.Sprintf("The results of asynchronous requests exceed their quota of %d MB", quota), InternalCaller: CallerN(1)}
+}
@@ -73,6 +73,20 @@ const (
 	BATCH_MODE_MSG      = "Error when running in batch mode for Analytics. Incorrect input value"
 	STRING_WRITE        = 143
 	STRING_WRITE_MSG    = "Cannot write to string buffer. "
+	OUTPUT_FORMAT       = 144
+	OUTPUT_FORMAT_MSG   = "Invalid output format. Valid formats are json, table, csv, tsv and vertical. "
+	PLAN_MODE           = 145
+	PLAN_MODE_MSG       = "Invalid value for \\PLAN. Use ON or OFF. "
+	BULK_SYNTAX         = 146
+	BULK_SYNTAX_MSG     = "Invalid arguments. Usage : "
+	BENCH_ARGS          = 147
+	BENCH_ARGS_MSG      = "Invalid arguments to \\BENCH. Usage : "
+	PROFILE             = 148
+	PROFILE_MSG         = "Invalid connection profile. "
+	WATCH_ARGS          = 149
+	WATCH_ARGS_MSG      = "Invalid arguments to \\WATCH. Usage : "
+	FORMAT_STMT         = 150
+	FORMAT_STMT_MSG     = "Cannot format statement. "
 
 	//Generic Errors (170 - 199)
 	OPERATION_TIMEOUT           = 170
@@ -217,6 +231,41 @@ func NewShellErrorStringWrite(msg string) Error {
 
 }
 
+func NewShellErrorOutputFormat(msg string) Error {
+	return &err{level: EXCEPTION, ICode: OUTPUT_FORMAT, IKey: "shell.output.format.invalid", InternalMsg: OUTPUT_FORMAT_MSG + msg, InternalCaller: CallerN(1)}
+
+}
+
+func NewShellErrorPlanMode(msg string) Error {
+	return &err{level: EXCEPTION, ICode: PLAN_MODE, IKey: "shell.plan.mode.invalid", InternalMsg: PLAN_MODE_MSG + msg, InternalCaller: CallerN(1)}
+
+}
+
+func NewShellErrorBulkSyntax(msg string) Error {
+	return &err{level: EXCEPTION, ICode: BULK_SYNTAX, IKey: "shell.bulk.syntax.invalid", InternalMsg: BULK_SYNTAX_MSG + msg, InternalCaller: CallerN(1)}
+
+}
+
+func NewShellErrorBenchArgs(msg string) Error {
+	return &err{level: EXCEPTION, ICode: BENCH_ARGS, IKey: "shell.bench.args.invalid", InternalMsg: BENCH_ARGS_MSG + msg, InternalCaller: CallerN(1)}
+
+}
+
+func NewShellErrorProfile(msg string) Error {
+	return &err{level: EXCEPTION, ICode: PROFILE, IKey: "shell.profile.invalid", InternalMsg: PROFILE_MSG + msg, InternalCaller: CallerN(1)}
+
+}
+
+func NewShellErrorWatchArgs(msg string) Error {
+	return &err{level: EXCEPTION, ICode: WATCH_ARGS, IKey: "shell.watch.args.invalid", InternalMsg: WATCH_ARGS_MSG + msg, InternalCaller: CallerN(1)}
+
+}
+
+func NewShellErrorFormatStmt(msg string) Error {
+	return &err{level: EXCEPTION, ICode: FORMAT_STMT, IKey: "shell.format.statement", InternalMsg: FORMAT_STMT_MSG + msg, InternalCaller: CallerN(1)}
+
+}
+
 //Generic Errors
 
 func NewShellErrorOperationTimeout(msg string) Error {
@@ -98,6 +98,13 @@ func (this *Authorize) RunOnce(context *Context, parent value.Value) {
 				return
 			}
 
+			privs, err := sessionPrivileges(privs, context.session)
+			if err != nil {
+				context.Fatal(err)
+				this.fail(context)
+				return
+			}
+
 			authenticatedUsers, err := ds.Authorize(privs, context.Credentials())
 			if err != nil {
 				context.Fatal(err)
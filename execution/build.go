@@ -754,3 +754,16 @@ func (this *builder) VisitTransactionIsolation(plan *plan.TransactionIsolation)
 func (this *builder) VisitSavepoint(plan *plan.Savepoint) (interface{}, error) {
 	return checkOp(NewSavepoint(plan, this.context), this.context)
 }
+
+// Sessions
+func (this *builder) VisitSetVariable(plan *plan.SetVariable) (interface{}, error) {
+	return checkOp(NewSetVariable(plan, this.context), this.context)
+}
+
+func (this *builder) VisitCreateTemporaryCollection(plan *plan.CreateTemporaryCollection) (interface{}, error) {
+	return checkOp(NewCreateTemporaryCollection(plan, this.context), this.context)
+}
+
+func (this *builder) VisitDropTemporaryCollection(plan *plan.DropTemporaryCollection) (interface{}, error) {
+	return checkOp(NewDropTemporaryCollection(plan, this.context), this.context)
+}
@@ -29,7 +29,9 @@ import (
 	"github.com/couchbase/query/logging"
 	"github.com/couchbase/query/plan"
 	"github.com/couchbase/query/planner"
+	"github.com/couchbase/query/sessions"
 	"github.com/couchbase/query/timestamp"
+	"github.com/couchbase/query/tracing"
 	"github.com/couchbase/query/transactions"
 	"github.com/couchbase/query/value"
 )
@@ -197,6 +199,9 @@ type Context struct {
 	result              func(context *Context, item value.AnnotatedValue) bool
 	likeRegexMap        map[*expression.Like]*expression.LikeRegex
 	udfValueMap         map[string]interface{}
+	traceSpan           *tracing.Span
+	session             *sessions.Session
+	progress            *scanProgress
 }
 
 func NewContext(requestId string, datastore datastore.Datastore, systemstore datastore.Systemstore,
@@ -238,6 +243,7 @@ func NewContext(requestId string, datastore datastore.Datastore, systemstore dat
 		result:           setup,
 		likeRegexMap:     nil,
 		reqTimeout:       reqTimeout,
+		progress:         &scanProgress{},
 	}
 
 	if rv.maxParallelism <= 0 || rv.maxParallelism > runtime.NumCPU() {
@@ -282,6 +288,9 @@ func (this *Context) Copy() *Context {
 		preserveExpiry:      this.preserveExpiry,
 		flags:               this.flags,
 		reqTimeout:          this.reqTimeout,
+		traceSpan:           this.traceSpan,
+		session:             this.session,
+		progress:            this.progress,
 	}
 
 	rv.SetDurability(this.DurabilityLevel(), this.DurabilityTimeout())
@@ -319,6 +328,24 @@ func (this *Context) SetPositionalArgs(positionalArgs value.Values) {
 	this.positionalArgs = positionalArgs
 }
 
+// the result cache interposes itself between the operators and the request
+func (this *Context) Output() Output {
+	return this.output
+}
+
+func (this *Context) SetOutput(output Output) {
+	this.output = output
+}
+
+// datastore calls are traced as children of the execution span
+func (this *Context) SetTraceSpan(span *tracing.Span) {
+	this.traceSpan = span
+}
+
+func (this *Context) StartSpan(name string) *tracing.Span {
+	return this.traceSpan.StartChild(name, tracing.CLIENT)
+}
+
 func (this *Context) SetPrepared(prepared *plan.Prepared) {
 	this.prepared = prepared
 }
@@ -395,6 +422,15 @@ func (this *Context) SetTxContext(tc interface{}) {
 	this.txContext, _ = tc.(*transactions.TranContext)
 }
 
+// the session the request runs in, if any
+func (this *Context) Session() *sessions.Session {
+	return this.session
+}
+
+func (this *Context) SetSession(session *sessions.Session) {
+	this.session = session
+}
+
 func (this *Context) AdjustTimeout(timeout time.Duration, stmtType string, isPrepare bool) time.Duration {
 
 	if this.txContext != nil {
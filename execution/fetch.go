@@ -154,7 +154,16 @@ func (this *Fetch) flushBatch(context *Context) bool {
 	this.switchPhase(_SERVTIME)
 
 	// Fetch
+	span := context.StartSpan("kv.fetch")
+	if span != nil {
+		span.SetAttribute("db.couchbase.keyspace", this.keyspace.QualifiedName())
+		span.SetAttribute("db.couchbase.keys", len(fetchKeys))
+	}
 	errs := this.keyspace.Fetch(fetchKeys, fetchMap, context, this.plan.SubPaths())
+	if len(errs) > 0 {
+		span.SetError(errs[0].Error())
+	}
+	span.End()
 
 	this.switchPhase(_EXECTIME)
 
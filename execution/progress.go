@@ -0,0 +1,110 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package execution
+
+import (
+	"sync"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/plan"
+)
+
+// Scans record their plan operator as they start, so that the documents
+// scanned can be set against the documents the plan expects to scan.
+// Estimates come from the optimizer, which uses index statistics, or else
+// from the number of documents in the keyspace: they are worked out once per
+// scan, in the background as the scan starts, so that neither the scan nor
+// progress requests wait on keyspace counts.
+type scanProgress struct {
+	sync.Mutex
+	scans map[plan.Operator]*scanEstimate
+}
+
+type scanEstimate struct {
+	count int64
+	known bool
+	done  bool
+}
+
+func (this *Context) addScan(op plan.Operator) {
+	p := this.progress
+	if p == nil {
+		return
+	}
+	p.Lock()
+	if p.scans == nil {
+		p.scans = make(map[plan.Operator]*scanEstimate, 4)
+	}
+	_, ok := p.scans[op]
+	if !ok {
+		p.scans[op] = &scanEstimate{}
+	}
+	p.Unlock()
+	if !ok {
+		go p.estimate(op, this)
+	}
+}
+
+func (this *scanProgress) estimate(op plan.Operator, context *Context) {
+	count, known := estimateScan(op, context)
+	this.Lock()
+	this.scans[op] = &scanEstimate{count: count, known: known, done: true}
+	this.Unlock()
+}
+
+// The documents the scans started so far are expected to return, if all have been estimated
+func (this *Context) ScanEstimate() (int64, bool) {
+	p := this.progress
+	if p == nil {
+		return 0, false
+	}
+
+	total := int64(0)
+	p.Lock()
+	defer p.Unlock()
+	if len(p.scans) == 0 {
+		return 0, false
+	}
+	for _, e := range p.scans {
+		if !e.done || !e.known {
+			return 0, false
+		}
+		total += e.count
+	}
+	return total, true
+}
+
+func estimateScan(op plan.Operator, context *Context) (int64, bool) {
+	if cardinality := op.Cardinality(); cardinality > 0 {
+		return int64(cardinality), true
+	}
+
+	var keyspace datastore.Keyspace
+	switch op := op.(type) {
+	case *plan.PrimaryScan:
+		keyspace = op.Keyspace()
+	case *plan.PrimaryScan3:
+		keyspace = op.Keyspace()
+	case *plan.IndexScan3:
+		keyspace = op.Keyspace()
+	case *plan.IndexScan:
+		if path := op.Term().Path(); path != nil {
+			keyspace, _ = datastore.GetKeyspace(path.Parts()...)
+		}
+	case *plan.IndexScan2:
+		if path := op.Term().Path(); path != nil {
+			keyspace, _ = datastore.GetKeyspace(path.Parts()...)
+		}
+	}
+	if keyspace == nil {
+		return 0, false
+	}
+	count, err := keyspace.Count(context)
+	return count, err == nil
+}
@@ -0,0 +1,84 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package execution
+
+import (
+	"testing"
+	"time"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/datastore/mock"
+	"github.com/couchbase/query/plan"
+)
+
+// estimates are worked out in the background
+func waitScanEstimates(t *testing.T, context *Context) {
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(time.Millisecond) {
+		done := true
+		context.progress.Lock()
+		for _, e := range context.progress.scans {
+			done = done && e.done
+		}
+		context.progress.Unlock()
+		if done {
+			return
+		}
+	}
+	t.Fatalf("Scan estimates not done")
+}
+
+func TestScanEstimate(t *testing.T) {
+	context := &Context{progress: &scanProgress{}}
+	if _, ok := context.ScanEstimate(); ok {
+		t.Errorf("Expected no estimate before scans start")
+	}
+
+	// optimizer estimates
+	estimated := plan.NewDiscard(1, 100, 0, 0)
+	context.addScan(estimated)
+	waitScanEstimates(t, context)
+	if count, ok := context.ScanEstimate(); !ok || count != 100 {
+		t.Errorf("Expected 100 documents, got %v %v", count, ok)
+	}
+
+	// keyspace counts
+	store, err := mock.NewDatastore("mock:namespaces=1,keyspaces=1,items=50")
+	if err != nil {
+		t.Fatalf("failed to create mock store: %v", err)
+	}
+	namespace, _ := store.NamespaceByName("p0")
+	keyspace, _ := namespace.KeyspaceByName("b0")
+	indexer, _ := keyspace.Indexer(datastore.DEFAULT)
+	primary, _ := indexer.PrimaryIndexes()
+	counted := plan.NewPrimaryScan(primary[0], keyspace, nil, nil, false)
+	context.addScan(counted)
+	context.addScan(estimated)
+	waitScanEstimates(t, context)
+	if count, ok := context.ScanEstimate(); !ok || count != 150 {
+		t.Errorf("Expected 150 documents, got %v %v", count, ok)
+	}
+
+	// scans still being estimated, or that cannot be, leave the total unknown
+	pending := plan.NewDiscard(1, 10, 0, 0)
+	context.progress.Lock()
+	context.progress.scans[pending] = &scanEstimate{}
+	context.progress.Unlock()
+	if _, ok := context.ScanEstimate(); ok {
+		t.Errorf("Expected no estimate while scans are being estimated")
+	}
+	context.progress.Lock()
+	delete(context.progress.scans, pending)
+	context.progress.Unlock()
+
+	context.addScan(plan.NewDiscard(1, 0, 0, 0))
+	waitScanEstimates(t, context)
+	if _, ok := context.ScanEstimate(); ok {
+		t.Errorf("Expected no estimate with a scan that cannot be estimated")
+	}
+}
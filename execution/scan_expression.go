@@ -12,7 +12,10 @@ import (
 	"encoding/json"
 	_ "fmt"
 
+	"github.com/couchbase/query/datastore/virtual"
 	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/functions"
 	"github.com/couchbase/query/plan"
 	"github.com/couchbase/query/value"
 )
@@ -69,6 +72,12 @@ func (this *ExpressionScan) RunOnce(context *Context, parent value.Value) {
 			return
 		}
 
+		// table functions produce rows with ids
+		if udf, ok := this.plan.FromExpr().(*expression.UserDefinedFunction); ok && functions.ReturnsTable(udf.FunctionName()) {
+			this.scanFunction(udf, context, parent, correlated)
+			return
+		}
+
 		ev, e := this.plan.FromExpr().Evaluate(parent, context)
 		if e != nil {
 			context.Error(errors.NewEvaluationError(e, "ExpressionScan"))
@@ -119,6 +128,65 @@ func (this *ExpressionScan) RunOnce(context *Context, parent value.Value) {
 
 }
 
+func (this *ExpressionScan) scanFunction(udf *expression.UserDefinedFunction, context *Context,
+	parent value.Value, correlated bool) {
+	name := udf.FunctionName()
+	namespace, err := context.Datastore().NamespaceByName(name.Path()[0])
+	if err != nil {
+		context.Error(err)
+		return
+	}
+
+	args := make(value.Values, len(udf.Operands()))
+	for i, op := range udf.Operands() {
+		var e error
+		args[i], e = op.Evaluate(parent, context)
+		if e != nil {
+			context.Error(errors.NewEvaluationError(e, "ExpressionScan"))
+			return
+		}
+	}
+
+	keyspace, err := virtual.NewFunctionKeyspace(namespace, name, args)
+	if err != nil {
+		context.Error(err)
+		return
+	}
+
+	// the function checks its own privileges
+	rows, err := keyspace.Rows(context)
+	if err != nil {
+		context.Error(err)
+		return
+	}
+
+	if !correlated {
+		this.results = make(value.AnnotatedValues, 0, len(rows))
+	}
+	for _, row := range rows {
+		actv := value.NewScopeValue(make(map[string]interface{}), parent)
+		actv.SetField(this.plan.Alias(), row)
+		av := value.NewAnnotatedValue(actv)
+		av.SetId(row.GetId())
+
+		if this.plan.Filter() != nil {
+			result, err := this.plan.Filter().Evaluate(av, context)
+			if err != nil {
+				context.Error(errors.NewEvaluationError(err, "expression scan filter"))
+				return
+			}
+			if !result.Truth() {
+				continue
+			}
+		}
+
+		if !correlated {
+			this.results = append(this.results, av)
+		}
+		this.sendItem(av)
+	}
+}
+
 func (this *ExpressionScan) MarshalJSON() ([]byte, error) {
 	r := this.plan.MarshalBase(func(r map[string]interface{}) {
 		this.marshalTimes(r)
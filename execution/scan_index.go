@@ -64,6 +64,7 @@ func (this *IndexScan) RunOnce(context *Context, parent value.Value) {
 		n := len(spans)
 		this.SetKeepAlive(n, context)
 		this.setExecPhase(INDEX_SCAN, context)
+		context.addScan(this.plan)
 		defer func() { this.switchPhase(_NOTIME) }() // accrue current phase's time
 
 		if !active || !context.assert(n != 0, "Index scan has no spans") {
@@ -285,6 +286,8 @@ func (this *spanScan) scan(context *Context, conn *datastore.IndexConnection, pa
 
 	keyspaceTerm := this.plan.Term()
 	scanVector := context.ScanVectorSource().ScanVector(keyspaceTerm.Namespace(), keyspaceTerm.Path().Bucket())
+	span := startScanSpan(context, this.plan.Index(), keyspaceTerm)
+	defer span.End()
 	this.plan.Index().Scan(context.RequestId(), dspan, this.plan.Distinct(), limit,
 		context.ScanConsistency(), scanVector, conn)
 }
@@ -62,6 +62,7 @@ func (this *IndexScan2) RunOnce(context *Context, parent value.Value) {
 		defer this.close(context)
 		this.switchPhase(_EXECTIME)
 		this.setExecPhase(INDEX_SCAN, context)
+		context.addScan(this.plan)
 		defer func() { this.switchPhase(_NOTIME) }() // accrue current phase's time
 		defer this.notify()                          // Notify that I have stopped
 		if !active {
@@ -194,6 +195,8 @@ func (this *IndexScan2) scan(context *Context, conn *datastore.IndexConnection,
 		indexProjection = &datastore.IndexProjection{EntryKeys: proj.EntryKeys, PrimaryKey: proj.PrimaryKey}
 	}
 
+	span := startScanSpan(context, plan.Index(), plan.Term())
+	defer span.End()
 	plan.Index().Scan2(context.RequestId(), dspans, plan.Reverse(), plan.Distinct(), plan.Ordered(),
 		indexProjection, offset, limit,
 		context.ScanConsistency(), scanVector, conn)
@@ -60,6 +60,7 @@ func (this *IndexScan3) RunOnce(context *Context, parent value.Value) {
 		defer this.close(context)
 		this.switchPhase(_EXECTIME)
 		this.setExecPhase(INDEX_SCAN, context)
+		context.addScan(this.plan)
 		defer func() { this.switchPhase(_NOTIME) }() // accrue current phase's time
 		defer this.notify()                          // Notify that I have stopped
 		if !active {
@@ -209,6 +210,8 @@ func (this *IndexScan3) scan(context *Context, conn *datastore.IndexConnection,
 	indexProjection, indexOrder, indexGroupAggs := planToScanMapping(plan.Index(), plan.Projection(),
 		plan.OrderTerms(), plan.GroupAggs(), plan.Covers())
 
+	span := startScanSpan(context, plan.Index(), plan.Term())
+	defer span.End()
 	plan.Index().Scan3(context.RequestId(), dspans, plan.Reverse(), plan.Distinct(),
 		indexProjection, offset, limit, indexGroupAggs, indexOrder,
 		context.ScanConsistency(), scanVector, conn)
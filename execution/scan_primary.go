@@ -58,6 +58,7 @@ func (this *PrimaryScan) RunOnce(context *Context, parent value.Value) {
 		active := this.active()
 		defer this.close(context)
 		this.setExecPhase(PRIMARY_SCAN, context)
+		context.addScan(this.plan)
 		defer this.notify() // Notify that I have stopped
 		if !active {
 			return
@@ -201,6 +202,8 @@ func (this *PrimaryScan) scanEntries(context *Context, conn *datastore.IndexConn
 	scanVector := context.ScanVectorSource().ScanVector(term.Namespace(), term.Path().Bucket())
 
 	index := this.plan.Index()
+	span := startScanSpan(context, index, term)
+	defer span.End()
 	index.ScanEntries(context.RequestId(), limit, context.ScanConsistency(), scanVector, conn)
 }
 
@@ -214,6 +217,8 @@ func (this *PrimaryScan) scanChunk(context *Context, conn *datastore.IndexConnec
 	}
 	term := this.plan.Term()
 	scanVector := context.ScanVectorSource().ScanVector(term.Namespace(), term.Path().Bucket())
+	span := startScanSpan(context, this.plan.Index(), term)
+	defer span.End()
 	this.plan.Index().Scan(context.RequestId(), ds, true, limit,
 		context.ScanConsistency(), scanVector, conn)
 }
@@ -58,6 +58,7 @@ func (this *PrimaryScan3) RunOnce(context *Context, parent value.Value) {
 		active := this.active()
 		defer this.close(context)
 		this.setExecPhase(PRIMARY_SCAN, context)
+		context.addScan(this.plan)
 		defer this.notify() // Notify that I have stopped
 		if !active {
 			return
@@ -205,6 +206,8 @@ func (this *PrimaryScan3) scanEntries(context *Context, conn *datastore.IndexCon
 	indexProjection, indexOrder, indexGroupAggs := planToScanMapping(index, this.plan.Projection(),
 		this.plan.OrderTerms(), this.plan.GroupAggs(), nil)
 
+	span := startScanSpan(context, index, term)
+	defer span.End()
 	index.ScanEntries3(context.RequestId(), indexProjection, offset, limit, indexGroupAggs, indexOrder,
 		context.ScanConsistency(), scanVector, conn)
 }
@@ -219,6 +222,8 @@ func (this *PrimaryScan3) scanChunk(context *Context, conn *datastore.IndexConne
 	}
 	term := this.plan.Term()
 	scanVector := context.ScanVectorSource().ScanVector(term.Namespace(), term.Path().Bucket())
+	span := startScanSpan(context, this.plan.Index(), term)
+	defer span.End()
 	this.plan.Index().Scan(context.RequestId(), ds, true, limit,
 		context.ScanConsistency(), scanVector, conn)
 }
@@ -13,6 +13,7 @@ import (
 	"github.com/couchbase/query/datastore"
 	"github.com/couchbase/query/errors"
 	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/tracing"
 	"github.com/couchbase/query/util"
 	"github.com/couchbase/query/value"
 )
@@ -100,3 +101,13 @@ func getKeyspace(keyspace datastore.Keyspace, expr expression.Expression, contex
 var _INDEX_SCAN_POOL = NewOperatorPool(16)
 var _INDEX_VALUE_POOL = value.NewStringAnnotatedPool(1024)
 var _INDEX_BIT_POOL = util.NewStringInt64Pool(1024)
+
+// index scans are traced as children of the execution span
+func startScanSpan(context *Context, index datastore.Index, term *algebra.KeyspaceTerm) *tracing.Span {
+	span := context.StartSpan("index.scan")
+	if span != nil {
+		span.SetAttribute("db.couchbase.index", index.Name())
+		span.SetAttribute("db.couchbase.keyspace", term.PathString())
+	}
+	return span
+}
@@ -0,0 +1,270 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package execution
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/couchbase/query/auth"
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/plan"
+	"github.com/couchbase/query/sessions"
+	"github.com/couchbase/query/value"
+)
+
+type SetVariable struct {
+	base
+	plan *plan.SetVariable
+}
+
+func NewSetVariable(plan *plan.SetVariable, context *Context) *SetVariable {
+	rv := &SetVariable{
+		plan: plan,
+	}
+
+	newRedirectBase(&rv.base)
+	rv.output = rv
+	return rv
+}
+
+func (this *SetVariable) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitSetVariable(this)
+}
+
+func (this *SetVariable) Copy() Operator {
+	rv := &SetVariable{plan: this.plan}
+	this.base.copy(&rv.base)
+	return rv
+}
+
+func (this *SetVariable) PlanOp() plan.Operator {
+	return this.plan
+}
+
+func (this *SetVariable) RunOnce(context *Context, parent value.Value) {
+	this.once.Do(func() {
+		defer context.Recover(&this.base) // Recover from any panic
+		active := this.active()
+		defer this.close(context)
+		this.switchPhase(_EXECTIME)
+		defer this.switchPhase(_NOTIME)
+		defer this.notify() // Notify that I have stopped
+
+		if !active || context.Readonly() {
+			return
+		}
+
+		if context.session == nil {
+			context.Error(errors.NewSessionRequiredError("SET"))
+			return
+		}
+
+		val, err := this.plan.Expression().Evaluate(parent, context)
+		if err != nil {
+			context.Error(errors.NewEvaluationError(err, "SetVariable"))
+			return
+		}
+		context.session.SetVariable(this.plan.Name(), value.NewValue(val.Actual()))
+	})
+}
+
+func (this *SetVariable) MarshalJSON() ([]byte, error) {
+	r := this.plan.MarshalBase(func(r map[string]interface{}) {
+		this.marshalTimes(r)
+	})
+	return json.Marshal(r)
+}
+
+func (this *SetVariable) Done() {
+	this.baseDone()
+	if this.isComplete() {
+		this.plan = nil
+	}
+}
+
+type CreateTemporaryCollection struct {
+	base
+	plan *plan.CreateTemporaryCollection
+}
+
+func NewCreateTemporaryCollection(plan *plan.CreateTemporaryCollection, context *Context) *CreateTemporaryCollection {
+	rv := &CreateTemporaryCollection{
+		plan: plan,
+	}
+
+	newRedirectBase(&rv.base)
+	rv.output = rv
+	return rv
+}
+
+func (this *CreateTemporaryCollection) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitCreateTemporaryCollection(this)
+}
+
+func (this *CreateTemporaryCollection) Copy() Operator {
+	rv := &CreateTemporaryCollection{plan: this.plan}
+	this.base.copy(&rv.base)
+	return rv
+}
+
+func (this *CreateTemporaryCollection) PlanOp() plan.Operator {
+	return this.plan
+}
+
+func (this *CreateTemporaryCollection) RunOnce(context *Context, parent value.Value) {
+	this.once.Do(func() {
+		defer context.Recover(&this.base) // Recover from any panic
+		active := this.active()
+		defer this.close(context)
+		this.switchPhase(_EXECTIME)
+		defer this.switchPhase(_NOTIME)
+		defer this.notify() // Notify that I have stopped
+
+		if !active || context.Readonly() {
+			return
+		}
+
+		if context.session == nil {
+			context.Error(errors.NewSessionRequiredError("CREATE TEMPORARY COLLECTION"))
+			return
+		}
+
+		err := context.session.CreateKeyspace(this.plan.Name(), this.plan.FailIfExists())
+		if err != nil {
+			context.Error(err)
+		}
+	})
+}
+
+func (this *CreateTemporaryCollection) MarshalJSON() ([]byte, error) {
+	r := this.plan.MarshalBase(func(r map[string]interface{}) {
+		this.marshalTimes(r)
+	})
+	return json.Marshal(r)
+}
+
+func (this *CreateTemporaryCollection) Done() {
+	this.baseDone()
+	if this.isComplete() {
+		this.plan = nil
+	}
+}
+
+type DropTemporaryCollection struct {
+	base
+	plan *plan.DropTemporaryCollection
+}
+
+func NewDropTemporaryCollection(plan *plan.DropTemporaryCollection, context *Context) *DropTemporaryCollection {
+	rv := &DropTemporaryCollection{
+		plan: plan,
+	}
+
+	newRedirectBase(&rv.base)
+	rv.output = rv
+	return rv
+}
+
+func (this *DropTemporaryCollection) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitDropTemporaryCollection(this)
+}
+
+func (this *DropTemporaryCollection) Copy() Operator {
+	rv := &DropTemporaryCollection{plan: this.plan}
+	this.base.copy(&rv.base)
+	return rv
+}
+
+func (this *DropTemporaryCollection) PlanOp() plan.Operator {
+	return this.plan
+}
+
+func (this *DropTemporaryCollection) RunOnce(context *Context, parent value.Value) {
+	this.once.Do(func() {
+		defer context.Recover(&this.base) // Recover from any panic
+		active := this.active()
+		defer this.close(context)
+		this.switchPhase(_EXECTIME)
+		defer this.switchPhase(_NOTIME)
+		defer this.notify() // Notify that I have stopped
+
+		if !active || context.Readonly() {
+			return
+		}
+
+		if context.session == nil {
+			context.Error(errors.NewSessionRequiredError("DROP TEMPORARY COLLECTION"))
+			return
+		}
+
+		err := context.session.DropKeyspace(this.plan.Name(), this.plan.FailIfNotExists())
+		if err != nil {
+			context.Error(err)
+		}
+	})
+}
+
+func (this *DropTemporaryCollection) MarshalJSON() ([]byte, error) {
+	r := this.plan.MarshalBase(func(r map[string]interface{}) {
+		this.marshalTimes(r)
+	})
+	return json.Marshal(r)
+}
+
+func (this *DropTemporaryCollection) Done() {
+	this.baseDone()
+	if this.isComplete() {
+		this.plan = nil
+	}
+}
+
+/*
+Temporary collections need no privileges, but can only be used by requests
+running in the session they belong to: privileges on the collections of the
+request's own session are dropped, and any other session is treated as not
+existing.
+*/
+func sessionPrivileges(privs *auth.Privileges, session *sessions.Session) (*auth.Privileges, errors.Error) {
+	if privs == nil {
+		return nil, nil
+	}
+
+	prefix := datastore.SESSION_NAMESPACE + ":"
+	own := ""
+	if session != nil {
+		own = prefix + session.Id()
+	}
+
+	var rv *auth.Privileges
+	for i, p := range privs.List {
+		if !strings.HasPrefix(p.Target, prefix) {
+			if rv != nil {
+				rv.List = append(rv.List, p)
+			}
+			continue
+		}
+		if own == "" || (p.Target != own && !strings.HasPrefix(p.Target, own+".")) {
+			id := strings.TrimPrefix(p.Target, prefix)
+			if n := strings.IndexByte(id, '.'); n >= 0 {
+				id = id[:n]
+			}
+			return nil, errors.NewServiceErrorNoSuchSession(id)
+		}
+		if rv == nil {
+			rv = auth.NewPrivileges()
+			rv.List = append(rv.List, privs.List[:i]...)
+		}
+	}
+	if rv == nil {
+		return privs, nil
+	}
+	return rv, nil
+}
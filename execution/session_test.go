@@ -0,0 +1,68 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package execution
+
+import (
+	"testing"
+
+	"github.com/couchbase/query/auth"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/parser/n1ql"
+	"github.com/couchbase/query/sessions"
+)
+
+func sessionStatementPrivileges(t *testing.T, text string, session *sessions.Session) *auth.Privileges {
+	stmt, err := n1ql.ParseStatement3(text, "default", "", session.KeyspacePath)
+	if err != nil {
+		t.Fatalf("Cannot parse %v: %v", text, err)
+	}
+	privs, err := stmt.Privileges()
+	if err != nil {
+		t.Fatalf("No privileges for %v: %v", text, err)
+	}
+	return privs
+}
+
+func TestSessionPrivileges(t *testing.T) {
+	alice, _ := sessions.Start("local:alice", 0, nil, "")
+	defer sessions.End(alice.Id(), []string{"local:alice"})
+	bob, _ := sessions.Start("local:bob", 0, nil, "")
+	defer sessions.End(bob.Id(), []string{"local:bob"})
+	bob.CreateKeyspace("staging", true)
+
+	// a session's own collections need no privileges
+	privs := sessionStatementPrivileges(t, "SELECT * FROM staging JOIN travel ON staging.id = travel.id", bob)
+	privs, err := sessionPrivileges(privs, bob)
+	if err != nil || privs.Num() != 1 || privs.List[0].Target != "default:travel" {
+		t.Errorf("Expected only the privileges on travel, got %v %v", privs, err)
+	}
+
+	// names only resolve to the collections of the request's own session
+	privs = sessionStatementPrivileges(t, "SELECT * FROM staging", alice)
+	if privs.Num() != 1 || privs.List[0].Target != "default:staging" {
+		t.Errorf("Expected staging to be a regular keyspace, got %v", privs)
+	}
+
+	// and another session's collections cannot be named explicitly
+	other := "`#session`:`" + bob.Id() + "`._default.staging"
+	for _, text := range []string{"SELECT * FROM " + other, "INSERT INTO " + other + " VALUES ('k', {})",
+		"DELETE FROM " + other} {
+
+		privs = sessionStatementPrivileges(t, text, alice)
+		if _, err = sessionPrivileges(privs, alice); err == nil || err.Code() != errors.SERVICE_NO_SUCH_SESSION {
+			t.Errorf("Expected no such session for %v, got %v", text, err)
+		}
+		if _, err = sessionPrivileges(privs, nil); err == nil || err.Code() != errors.SERVICE_NO_SUCH_SESSION {
+			t.Errorf("Expected no such session outside sessions for %v, got %v", text, err)
+		}
+		if _, err = sessionPrivileges(privs, bob); err != nil {
+			t.Errorf("Unexpected error in the owning session for %v: %v", text, err)
+		}
+	}
+}
@@ -163,4 +163,9 @@ type Visitor interface {
 	VisitRollbackTransaction(op *RollbackTransaction) (interface{}, error)
 	VisitTransactionIsolation(op *TransactionIsolation) (interface{}, error)
 	VisitSavepoint(op *Savepoint) (interface{}, error)
+
+	// Sessions
+	VisitSetVariable(op *SetVariable) (interface{}, error)
+	VisitCreateTemporaryCollection(op *CreateTemporaryCollection) (interface{}, error)
+	VisitDropTemporaryCollection(op *DropTemporaryCollection) (interface{}, error)
 }
@@ -53,6 +53,10 @@ func NewUserDefinedFunction(name functions.FunctionName, operands ...Expression)
 	return rv
 }
 
+func (this *UserDefinedFunction) FunctionName() functions.FunctionName {
+	return this.name
+}
+
 /*
 Visitor pattern.
 */
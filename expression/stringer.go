@@ -14,6 +14,7 @@ import (
 	"io"
 	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/couchbase/query/util"
 	"github.com/couchbase/query/value"
@@ -653,6 +654,10 @@ func (this *Stringer) VisitSubquery(expr Subquery) (interface{}, error) {
 
 // NamedParameter
 func (this *Stringer) VisitNamedParameter(expr NamedParameter) (interface{}, error) {
+	// session variables keep their @
+	if strings.HasPrefix(expr.Name(), "@") {
+		return expr.Name(), nil
+	}
 	return "$" + expr.Name(), nil
 }
 
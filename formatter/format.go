@@ -0,0 +1,477 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+/*
+Package formatter pretty-prints parsed statements in a canonical layout, and
+reports constructs that are likely to perform poorly.
+
+Queries are rebuilt from the algebra tree: clause keywords are upper case,
+each clause starts on its own line, projections, joins and WHERE conjuncts
+are placed one per line, and subqueries in the FROM clause are indented.
+Expressions are rendered by the expression Stringer, so that identifiers are
+fully qualified and operators are explicitly parenthesized.
+Statements that the algebra cannot render back to text are returned as they
+were written.
+*/
+package formatter
+
+import (
+	"strings"
+
+	"github.com/couchbase/query/algebra"
+	"github.com/couchbase/query/expression"
+)
+
+const _INDENT = "    "
+
+type printer struct {
+	buf   strings.Builder
+	depth int
+}
+
+// Format a parsed statement
+// The text is what the statement was parsed from.
+func Format(stmt algebra.Statement, text string) string {
+	p := &printer{}
+	if !p.statement(stmt) {
+		return strings.TrimSpace(text)
+	}
+	return p.buf.String()
+}
+
+func (this *printer) statement(stmt algebra.Statement) bool {
+	switch stmt := stmt.(type) {
+	case *algebra.Select:
+		this.selectStmt(stmt)
+	case *algebra.Explain:
+		this.buf.WriteString("EXPLAIN")
+		this.newline()
+		return this.statement(stmt.Statement())
+	case *algebra.Advise:
+		this.buf.WriteString("ADVISE")
+		this.newline()
+		return this.statement(stmt.Statement())
+	case *algebra.Prepare:
+		this.buf.WriteString("PREPARE ")
+		if stmt.Name() != "" {
+			this.buf.WriteString("`" + stmt.Name() + "` AS")
+		}
+		this.newline()
+		return this.statement(stmt.Statement())
+	default:
+		return false
+	}
+	return true
+}
+
+// start a new line at the current depth
+func (this *printer) newline() {
+	this.buf.WriteByte('\n')
+	for i := 0; i < this.depth; i++ {
+		this.buf.WriteString(_INDENT)
+	}
+}
+
+// items go on their own line, one level deeper than their clause
+func (this *printer) items(items []string, separator string) {
+	this.depth++
+	for i, item := range items {
+		this.newline()
+		this.buf.WriteString(item)
+		if i < len(items)-1 {
+			this.buf.WriteString(separator)
+		}
+	}
+	this.depth--
+}
+
+func (this *printer) selectStmt(stmt *algebra.Select) {
+	this.subresult(stmt.Subresult())
+
+	if order := stmt.Order(); order != nil {
+		terms := order.Terms()
+		sort := make([]string, len(terms))
+		for i, term := range terms {
+			sort[i] = term.String()
+		}
+		this.newline()
+		this.buf.WriteString("ORDER BY " + strings.Join(sort, ", "))
+	}
+	if limit := stmt.Limit(); limit != nil {
+		this.newline()
+		this.buf.WriteString("LIMIT " + exprString(limit))
+	}
+	if offset := stmt.Offset(); offset != nil {
+		this.newline()
+		this.buf.WriteString("OFFSET " + exprString(offset))
+	}
+}
+
+func (this *printer) subresult(subresult algebra.Subresult) {
+	var op string
+	var first, second algebra.Subresult
+
+	switch s := subresult.(type) {
+	case *algebra.Subselect:
+		this.subselect(s)
+		return
+	case *algebra.Union:
+		op, first, second = "UNION", s.First(), s.Second()
+	case *algebra.UnionAll:
+		op, first, second = "UNION ALL", s.First(), s.Second()
+	case *algebra.Intersect:
+		op, first, second = "INTERSECT", s.First(), s.Second()
+	case *algebra.IntersectAll:
+		op, first, second = "INTERSECT ALL", s.First(), s.Second()
+	case *algebra.Except:
+		op, first, second = "EXCEPT", s.First(), s.Second()
+	case *algebra.ExceptAll:
+		op, first, second = "EXCEPT ALL", s.First(), s.Second()
+	default:
+		this.buf.WriteString(subresult.String())
+		return
+	}
+
+	this.subresult(first)
+	this.newline()
+	this.buf.WriteString(op)
+	this.newline()
+	this.subresult(second)
+}
+
+func (this *printer) subselect(s *algebra.Subselect) {
+	if with := s.With(); len(with) > 0 {
+		this.buf.WriteString("WITH ")
+		for i, b := range with {
+			if i > 0 {
+				this.buf.WriteString(",")
+				this.newline()
+				this.buf.WriteString(_INDENT)
+			}
+			this.buf.WriteString("`" + b.Variable() + "` AS ")
+			this.subqueryOrExpr(b.Expression())
+		}
+		this.newline()
+	}
+
+	projection := s.Projection()
+	this.buf.WriteString("SELECT")
+	if hints := s.OptimHints(); hints != nil {
+		this.buf.WriteString(" " + hints.String())
+	}
+	if projection.Distinct() {
+		this.buf.WriteString(" DISTINCT")
+	}
+	if projection.Raw() {
+		this.buf.WriteString(" RAW")
+	}
+	terms := projection.Terms()
+	if len(terms) == 1 {
+		this.buf.WriteString(" " + resultTerm(terms[0]))
+	} else {
+		items := make([]string, len(terms))
+		for i, term := range terms {
+			items[i] = resultTerm(term)
+		}
+		this.items(items, ",")
+	}
+
+	if from := s.From(); from != nil {
+		this.newline()
+		this.buf.WriteString("FROM ")
+		this.from(from, true)
+	}
+	if let := s.Let(); len(let) > 0 {
+		this.newline()
+		this.buf.WriteString("LET " + bindings(let))
+	}
+	if where := s.Where(); where != nil {
+		this.newline()
+		this.buf.WriteString("WHERE ")
+		this.conjuncts(where)
+	}
+	if group := s.Group(); group != nil {
+		if by := group.By(); len(by) > 0 {
+			keys := make([]string, len(by))
+			for i, key := range by {
+				keys[i] = exprString(key)
+			}
+			this.newline()
+			this.buf.WriteString("GROUP BY " + strings.Join(keys, ", "))
+		}
+		if letting := group.Letting(); len(letting) > 0 {
+			this.newline()
+			this.buf.WriteString("LETTING " + bindings(letting))
+		}
+		if having := group.Having(); having != nil {
+			this.newline()
+			this.buf.WriteString("HAVING ")
+			this.conjuncts(having)
+		}
+	}
+	if window := s.Window(); len(window) > 0 {
+		this.newline()
+		this.buf.WriteString(window.String())
+	}
+}
+
+// top level AND terms go on their own line
+func (this *printer) conjuncts(expr expression.Expression) {
+	terms := Conjuncts(expr)
+	this.buf.WriteString(exprString(terms[0]))
+	this.depth++
+	for _, term := range terms[1:] {
+		this.newline()
+		this.buf.WriteString("AND " + exprString(term))
+	}
+	this.depth--
+}
+
+// the first term of a FROM clause follows the keyword, joins go on their own line
+func (this *printer) from(term algebra.FromTerm, first bool) {
+	switch t := term.(type) {
+	case *algebra.AnsiJoin:
+		this.from(t.Left(), first)
+		if t.IsCommaJoin() {
+			this.buf.WriteString(",")
+			this.joinLine("")
+			this.simpleTerm(t.Right())
+			return
+		}
+		this.joinLine(outer(t.Outer()) + "JOIN ")
+		this.simpleTerm(t.Right())
+		this.buf.WriteString(" ON " + exprString(t.Onclause()))
+	case *algebra.AnsiNest:
+		this.from(t.Left(), first)
+		this.joinLine(outer(t.Outer()) + "NEST ")
+		this.simpleTerm(t.Right())
+		this.buf.WriteString(" ON " + exprString(t.Onclause()))
+	case *algebra.Join:
+		this.from(t.Left(), first)
+		this.joinLine(outer(t.Outer()) + "JOIN ")
+		this.simpleTerm(t.Right())
+	case *algebra.Nest:
+		this.from(t.Left(), first)
+		this.joinLine(outer(t.Outer()) + "NEST ")
+		this.simpleTerm(t.Right())
+	case *algebra.IndexJoin:
+		this.from(t.Left(), first)
+		this.joinLine(outer(t.Outer()) + "JOIN ")
+		this.simpleTerm(t.Right())
+		this.buf.WriteString(" FOR `" + t.For() + "`")
+	case *algebra.IndexNest:
+		this.from(t.Left(), first)
+		this.joinLine(outer(t.Outer()) + "NEST ")
+		this.simpleTerm(t.Right())
+		this.buf.WriteString(" FOR `" + t.For() + "`")
+	case *algebra.Unnest:
+		this.from(t.Left(), first)
+		this.joinLine(outer(t.Outer()) + "UNNEST ")
+		this.buf.WriteString(exprString(t.Expression()))
+		if t.As() != "" {
+			this.buf.WriteString(" AS `" + t.As() + "`")
+		}
+	case algebra.SimpleFromTerm:
+		if !first {
+			this.joinLine("")
+		}
+		this.simpleTerm(t)
+	default:
+		this.buf.WriteString(term.String())
+	}
+}
+
+func (this *printer) joinLine(keyword string) {
+	this.depth++
+	this.newline()
+	this.depth--
+	this.buf.WriteString(keyword)
+}
+
+func outer(isOuter bool) string {
+	if isOuter {
+		return "LEFT OUTER "
+	}
+	return ""
+}
+
+func (this *printer) simpleTerm(term algebra.SimpleFromTerm) {
+	switch t := term.(type) {
+	case *algebra.KeyspaceTerm:
+		this.buf.WriteString(keyspaceTerm(t))
+	case *algebra.ExpressionTerm:
+		if t.IsKeyspace() {
+			this.buf.WriteString(keyspaceTerm(t.KeyspaceTerm()))
+			return
+		}
+		expr := t.ExpressionTerm()
+		s := exprString(expr)
+		if _, ok := expr.(*expression.Identifier); ok {
+			s = "(" + s + ")"
+		}
+		this.buf.WriteString(s)
+		if t.Alias() != "" {
+			this.buf.WriteString(" AS `" + t.Alias() + "`")
+		}
+		this.joinHint(t.JoinHint())
+	case *algebra.SubqueryTerm:
+		this.subquery(t.Subquery())
+		this.buf.WriteString(" AS `" + t.Alias() + "`")
+		this.joinHint(t.JoinHint())
+	default:
+		this.buf.WriteString(term.String())
+	}
+}
+
+func (this *printer) subquery(sub *algebra.Select) {
+	this.buf.WriteString("(")
+	this.depth++
+	this.newline()
+	this.selectStmt(sub)
+	this.depth--
+	this.newline()
+	this.buf.WriteString(")")
+}
+
+// WITH clauses bind subqueries, or plain expressions
+func (this *printer) subqueryOrExpr(expr expression.Expression) {
+	if sub, ok := expr.(*algebra.Subquery); ok {
+		this.subquery(sub.Select())
+	} else {
+		this.buf.WriteString("(" + exprString(expr) + ")")
+	}
+}
+
+func (this *printer) joinHint(hint algebra.JoinHint) {
+	switch hint {
+	case algebra.USE_HASH_BUILD:
+		this.buf.WriteString(" USE HASH(BUILD)")
+	case algebra.USE_HASH_PROBE:
+		this.buf.WriteString(" USE HASH(PROBE)")
+	case algebra.USE_NL:
+		this.buf.WriteString(" USE NL")
+	}
+}
+
+func keyspaceTerm(t *algebra.KeyspaceTerm) string {
+	var s string
+
+	if t.Path() != nil {
+		s = t.Path().ProtectedString()
+	} else {
+		s = exprString(t.FromExpression())
+	}
+	if t.As() != "" {
+		s += " AS `" + t.As() + "`"
+	}
+
+	if t.JoinKeys() != nil {
+		if t.IsIndexJoinNest() {
+			s += " ON KEY " + exprString(t.JoinKeys())
+		} else {
+			s += " ON KEYS " + exprString(t.JoinKeys())
+		}
+	} else if t.Keys() != nil {
+		s += " USE KEYS " + exprString(t.Keys())
+	} else if indexes := t.Indexes(); len(indexes) > 0 {
+		names := make([]string, len(indexes))
+		for i, index := range indexes {
+			names[i] = "`" + index.Name() + "`"
+			if using := string(index.Using()); using != "" && using != "default" {
+				names[i] += " USING " + strings.ToUpper(using)
+			}
+		}
+		s += " USE INDEX (" + strings.Join(names, ", ") + ")"
+	}
+
+	switch t.JoinHint() {
+	case algebra.USE_HASH_BUILD:
+		s += " USE HASH(BUILD)"
+	case algebra.USE_HASH_PROBE:
+		s += " USE HASH(PROBE)"
+	case algebra.USE_NL:
+		s += " USE NL"
+	}
+	return s
+}
+
+func resultTerm(term *algebra.ResultTerm) string {
+	s := ""
+	if term.Expression() != nil {
+		s = exprString(term.Expression())
+	}
+	if term.Star() {
+		if s == "" {
+			s = "*"
+		} else {
+			s += ".*"
+		}
+	}
+	if term.As() != "" {
+		s += " AS `" + term.As() + "`"
+	}
+	return s
+}
+
+func bindings(bindings expression.Bindings) string {
+	s := make([]string, len(bindings))
+	for i, b := range bindings {
+		s[i] = "`" + b.Variable() + "` = " + exprString(b.Expression())
+	}
+	return strings.Join(s, ", ")
+}
+
+// Split a predicate into its top level AND terms
+func Conjuncts(expr expression.Expression) expression.Expressions {
+	and, ok := expr.(*expression.And)
+	if !ok {
+		return expression.Expressions{expr}
+	}
+	var rv expression.Expressions
+	for _, op := range and.Operands() {
+		rv = append(rv, Conjuncts(op)...)
+	}
+	return rv
+}
+
+func exprString(expr expression.Expression) string {
+	return unwrap(expr.String())
+}
+
+// strip the parentheses the stringer puts around the outermost operator
+func unwrap(s string) string {
+	if len(s) < 2 || s[0] != '(' || s[len(s)-1] != ')' {
+		return s
+	}
+
+	depth := 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if quote != 0 {
+			if c == '\\' && quote != '`' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '"', '\'', '`':
+			quote = c
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 && i < len(s)-1 {
+				return s
+			}
+		}
+	}
+	return s[1 : len(s)-1]
+}
@@ -0,0 +1,128 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package formatter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/couchbase/query/algebra"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/parser/n1ql"
+)
+
+func parse(t *testing.T, text string) algebra.Statement {
+	stmt, err := n1ql.ParseStatement2(text, "default", "")
+	if err != nil {
+		t.Fatalf("Cannot parse %v: %v", text, err)
+	}
+	return stmt
+}
+
+func TestFormat(t *testing.T) {
+	text := "select b.name, b.abv from beer b join brewery r on b.brewery_id = meta(r).id " +
+		"where b.type = 'beer' and r.country = 'Belgium' order by b.name limit 10"
+	lines := strings.Split(Format(parse(t, text), text), "\n")
+
+	expected := []string{
+		"SELECT",
+		"    `b`.`name`,",
+		"    `b`.`abv`",
+		"FROM `default`:`beer` AS `b`",
+		"WHERE `b`.`type` = \"beer\"",
+		"    AND `r`.`country` = \"Belgium\"",
+		"ORDER BY `b`.`name`",
+		"LIMIT 10",
+	}
+	for _, e := range expected {
+		found := false
+		for _, l := range lines {
+			if l == e {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("Expected line %q in %q", e, lines)
+		}
+	}
+	if len(lines) != len(expected)+1 || !strings.HasPrefix(lines[4], "    JOIN `default`:`brewery` AS `r` ON ") {
+		t.Errorf("Unexpected join in %q", lines)
+	}
+
+	// the canonical form parses to the same statement
+	formatted := strings.Join(lines, "\n")
+	if again := Format(parse(t, formatted), formatted); again != formatted {
+		t.Errorf("Formatting is not stable:\n%v\n%v", formatted, again)
+	}
+
+	// statements the algebra cannot render are left as they are
+	text = "  delete from beer where abv = 0  "
+	if Format(parse(t, text), text) != strings.TrimSpace(text) {
+		t.Errorf("Unexpected format of %v", text)
+	}
+}
+
+func TestUnwrap(t *testing.T) {
+	for in, out := range map[string]string{
+		"(a = 1)":               "a = 1",
+		"(a = 1) and (b = 2)":   "(a = 1) and (b = 2)",
+		"((a = 1) and (b = 2))": "(a = 1) and (b = 2)",
+		"(a = \")(\")":          "a = \")(\"",
+		"(`a)` = 1)":            "`a)` = 1",
+		"a":                     "a",
+	} {
+		if unwrap(in) != out {
+			t.Errorf("Expected %v for %v, got %v", out, in, unwrap(in))
+		}
+	}
+}
+
+type testCatalog struct{}
+
+func (this *testCatalog) Count(path *algebra.Path) (int64, bool) {
+	return 1000000, path.Keyspace() == "beer"
+}
+
+func (this *testCatalog) Sargable(path *algebra.Path, alias string, pred expression.Expression) (bool, bool) {
+	return !strings.Contains(pred.String(), "`abv`"), true
+}
+
+func TestLint(t *testing.T) {
+	for text, rules := range map[string][]string{
+		"select b.name from beer b where b.type = 'beer' order by b.name limit 10":                                         nil,
+		"select b.name from beer b where b.type = 'beer' order by b.name":                                                  {ORDER_WITHOUT_LIMIT},
+		"select * from beer b where b.type = 'beer'":                                                                       {SELECT_STAR},
+		"select * from brewery r where r.type = 'brewery'":                                                                 nil,
+		"select b.name from beer b where b.abv = 5":                                                                        {NON_SARGABLE},
+		"select b.name from beer b, brewery r where b.type = 'beer'":                                                       {CARTESIAN_JOIN},
+		"select b.name from beer b, brewery r where b.type = 'beer' and b.brewery_id = meta(r).id":                         nil,
+		"select b.name from beer b join brewery r on true where b.type = 'beer'":                                           {CARTESIAN_JOIN},
+		"select millis(b.updated), 'millis(' from beer b where b.type = 'beer' /* now_local() */":                          {DEPRECATED_FUNCTION},
+		"update beer b set b.abv = 0 where b.abv is missing":                                                               {NON_SARGABLE},
+		"select b.name from beer b where b.type = 'beer' and b.name in (select raw r.name from brewery r order by r.name)": {ORDER_WITHOUT_LIMIT},
+	} {
+		warnings := Lint(parse(t, text), text, &testCatalog{})
+		if len(warnings) != len(rules) {
+			t.Errorf("Expected %v for %v, got %v", rules, text, warnings)
+			continue
+		}
+		for i, w := range warnings {
+			if w.Rule != rules[i] {
+				t.Errorf("Expected %v for %v, got %v", rules, text, warnings)
+			}
+		}
+	}
+
+	// data dependent rules need a catalog
+	text := "select * from beer b where b.abv = 5"
+	if warnings := Lint(parse(t, text), text, nil); len(warnings) != 0 {
+		t.Errorf("Unexpected warnings without a catalog %v", warnings)
+	}
+}
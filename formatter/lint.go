@@ -0,0 +1,431 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package formatter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/couchbase/query/algebra"
+	"github.com/couchbase/query/expression"
+)
+
+// Lint rules
+const (
+	SELECT_STAR         = "select_star"
+	ORDER_WITHOUT_LIMIT = "order_without_limit"
+	NON_SARGABLE        = "non_sargable"
+	CARTESIAN_JOIN      = "cartesian_join"
+	DEPRECATED_FUNCTION = "deprecated_function"
+)
+
+// SELECT * is only reported on keyspaces with at least this many documents
+const _LARGE_KEYSPACE = 100000
+
+// Legacy spellings that are still accepted, and what to use instead
+var _DEPRECATED_FUNCTIONS = map[string]string{
+	"clock_local":     "clock_str",
+	"now_local":       "now_str",
+	"millis":          "str_to_millis",
+	"millis_to_local": "millis_to_str",
+	"millis_to_tz":    "millis_to_zone_name",
+	"str_to_tz":       "str_to_zone_name",
+	"base64":          "base64_encode",
+	"contains_regex":  "regexp_contains",
+	"regex_contains":  "regexp_contains",
+	"regex_like":      "regexp_like",
+	"regex_position":  "regexp_position",
+	"regex_replace":   "regexp_replace",
+}
+
+type Warning struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+/*
+Checks that depend on the data and the indexes go through a catalog, so
+that statements can also be linted where there is no datastore, in which
+case those checks are skipped.
+*/
+type Catalog interface {
+	// the number of documents in the keyspace, if known
+	Count(path *algebra.Path) (int64, bool)
+
+	// whether any index on the keyspace can be used for the predicate, if known
+	Sargable(path *algebra.Path, alias string, pred expression.Expression) (bool, bool)
+}
+
+type linter struct {
+	catalog  Catalog
+	warnings []*Warning
+	seen     map[string]bool
+}
+
+// Lint a parsed statement
+// The text is what the statement was parsed from; the catalog can be nil.
+func Lint(stmt algebra.Statement, text string, catalog Catalog) []*Warning {
+	l := &linter{
+		catalog:  catalog,
+		warnings: []*Warning{},
+		seen:     make(map[string]bool),
+	}
+	l.statement(stmt)
+	l.deprecatedFunctions(text)
+	return l.warnings
+}
+
+// a statement can be visited more than once, through its subqueries
+func (this *linter) warn(rule, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	if this.seen[rule+msg] {
+		return
+	}
+	this.seen[rule+msg] = true
+	this.warnings = append(this.warnings, &Warning{Rule: rule, Message: msg})
+}
+
+func (this *linter) statement(stmt algebra.Statement) {
+	switch stmt := stmt.(type) {
+	case *algebra.Select:
+		this.selectStmt(stmt)
+	case *algebra.Explain:
+		this.statement(stmt.Statement())
+	case *algebra.Advise:
+		this.statement(stmt.Statement())
+	case *algebra.Prepare:
+		this.statement(stmt.Statement())
+	case *algebra.Insert:
+		if stmt.Select() != nil {
+			this.selectStmt(stmt.Select())
+		}
+	case *algebra.Upsert:
+		if stmt.Select() != nil {
+			this.selectStmt(stmt.Select())
+		}
+	case *algebra.Update:
+		this.mutation(stmt.KeyspaceRef(), stmt.Keys(), stmt.Where())
+		this.subqueries(stmt.Expressions())
+	case *algebra.Delete:
+		this.mutation(stmt.KeyspaceRef(), stmt.Keys(), stmt.Where())
+		this.subqueries(stmt.Expressions())
+	default:
+		this.subqueries(stmt.Expressions())
+	}
+}
+
+func (this *linter) subqueries(exprs expression.Expressions) {
+	for _, expr := range exprs {
+		if expr == nil {
+			continue
+		}
+		if sub, ok := expr.(*algebra.Subquery); ok {
+			this.selectStmt(sub.Select())
+		} else {
+			this.subqueries(expr.Children())
+		}
+	}
+}
+
+func (this *linter) selectStmt(stmt *algebra.Select) {
+	if stmt.Order() != nil && stmt.Limit() == nil {
+		this.warn(ORDER_WITHOUT_LIMIT, "ORDER BY %s without LIMIT sorts the entire result",
+			stmt.Order().Terms().String())
+	}
+	this.subresult(stmt.Subresult())
+}
+
+func (this *linter) subresult(subresult algebra.Subresult) {
+	switch s := subresult.(type) {
+	case *algebra.Subselect:
+		this.subselect(s)
+	case interface {
+		First() algebra.Subresult
+		Second() algebra.Subresult
+	}:
+		this.subresult(s.First())
+		this.subresult(s.Second())
+	}
+}
+
+// a term of the FROM clause, and what it is joined on
+type fromTerm struct {
+	alias    string
+	keyspace *algebra.KeyspaceTerm
+	onclause expression.Expression
+	left     []string // the aliases of the terms it is joined to, if any
+}
+
+func (this *linter) subselect(s *algebra.Subselect) {
+	var terms []*fromTerm
+	if s.From() != nil {
+		terms = this.from(s.From(), terms)
+	}
+	aliases := make(map[string]bool, len(terms))
+	for _, t := range terms {
+		aliases[t.alias] = true
+	}
+	var where expression.Expressions
+	if s.Where() != nil {
+		where = Conjuncts(s.Where())
+	}
+
+	this.selectStar(s.Projection(), terms)
+	for _, t := range terms {
+		preds := where
+		if t.onclause != nil {
+			preds = append(Conjuncts(t.onclause), where...)
+		}
+		if len(t.left) > 0 {
+			this.cartesian(t, preds, aliases)
+		}
+		if t.keyspace != nil && t.keyspace.Keys() == nil && t.keyspace.JoinKeys() == nil {
+			this.sargable(t.keyspace.Path(), t.alias, keyspacePreds(t, preds, aliases))
+		}
+	}
+
+	this.subqueries(s.Expressions())
+}
+
+// collect the terms, left to right
+func (this *linter) from(term algebra.FromTerm, terms []*fromTerm) []*fromTerm {
+	var left algebra.FromTerm
+	var right algebra.SimpleFromTerm
+	var onclause expression.Expression
+
+	switch t := term.(type) {
+	case *algebra.AnsiJoin:
+		left, right, onclause = t.Left(), t.Right(), t.Onclause()
+	case *algebra.AnsiNest:
+		left, right, onclause = t.Left(), t.Right(), t.Onclause()
+	case *algebra.Join:
+		left, right = t.Left(), t.Right()
+	case *algebra.Nest:
+		left, right = t.Left(), t.Right()
+	case *algebra.IndexJoin:
+		left, right = t.Left(), t.Right()
+	case *algebra.IndexNest:
+		left, right = t.Left(), t.Right()
+	case *algebra.Unnest:
+		terms = this.from(t.Left(), terms)
+		return append(terms, &fromTerm{alias: t.Alias()})
+	case algebra.SimpleFromTerm:
+		right = t
+	default:
+		return terms
+	}
+
+	var leftAliases []string
+	if left != nil {
+		terms = this.from(left, terms)
+		for _, t := range terms {
+			leftAliases = append(leftAliases, t.alias)
+		}
+	}
+	rv := &fromTerm{
+		alias:    right.Alias(),
+		onclause: onclause,
+		left:     leftAliases,
+	}
+	switch r := right.(type) {
+	case *algebra.KeyspaceTerm:
+		rv.keyspace = r
+	case *algebra.ExpressionTerm:
+		if r.IsKeyspace() {
+			rv.keyspace = r.KeyspaceTerm()
+		}
+	case *algebra.SubqueryTerm:
+		this.selectStmt(r.Subquery())
+	}
+
+	// lookup joins are on keys
+	if rv.keyspace != nil && rv.keyspace.JoinKeys() != nil {
+		rv.left = nil
+	}
+	return append(terms, rv)
+}
+
+// SELECT * fetches whole documents
+func (this *linter) selectStar(projection *algebra.Projection, terms []*fromTerm) {
+	if this.catalog == nil || projection == nil {
+		return
+	}
+	for _, term := range projection.Terms() {
+		if !term.Star() {
+			continue
+		}
+		ident, _ := term.Expression().(*expression.Identifier)
+		for _, t := range terms {
+			if t.keyspace == nil || t.keyspace.Path() == nil ||
+				(term.Expression() != nil && (ident == nil || ident.Identifier() != t.alias)) {
+				continue
+			}
+			count, ok := this.catalog.Count(t.keyspace.Path())
+			if ok && count >= _LARGE_KEYSPACE {
+				this.warn(SELECT_STAR, "SELECT * on %s, which holds %v documents: project only the fields needed",
+					t.keyspace.Path().ProtectedString(), count)
+			}
+		}
+	}
+}
+
+// a join needs a predicate relating the joined term to the terms before it
+func (this *linter) cartesian(t *fromTerm, preds expression.Expressions, aliases map[string]bool) {
+	left := make(map[string]bool, len(t.left))
+	for _, a := range t.left {
+		left[a] = true
+	}
+	for _, pred := range preds {
+		refs := references(pred, aliases)
+		if !refs[t.alias] {
+			continue
+		}
+		for a, _ := range refs {
+			if left[a] {
+				return
+			}
+		}
+	}
+	this.warn(CARTESIAN_JOIN, "`%s` is joined to `%s` with no predicate relating them: every pair of documents is returned",
+		t.alias, strings.Join(t.left, "`, `"))
+}
+
+/*
+The predicates an index scan on a keyspace could use: for the first term,
+those that refer to it alone, and for joined terms, those that refer to it
+and, possibly, the terms it is joined to.
+*/
+func keyspacePreds(t *fromTerm, preds expression.Expressions, aliases map[string]bool) expression.Expressions {
+	var rv expression.Expressions
+	for _, pred := range preds {
+		refs := references(pred, aliases)
+		if !refs[t.alias] || (len(t.left) == 0 && len(refs) > 1) {
+			continue
+		}
+		rv = append(rv, pred)
+	}
+	return rv
+}
+
+func (this *linter) mutation(ref *algebra.KeyspaceRef, keys, where expression.Expression) {
+	if ref == nil || keys != nil || where == nil {
+		return
+	}
+	this.sargable(ref.Path(), ref.Alias(), Conjuncts(where))
+}
+
+// report keyspaces that no index can be used for
+func (this *linter) sargable(path *algebra.Path, alias string, preds expression.Expressions) {
+	if this.catalog == nil || path == nil || len(preds) == 0 {
+		return
+	}
+	for _, pred := range preds {
+		sargable, ok := this.catalog.Sargable(path, alias, pred)
+		if !ok || sargable {
+			return
+		}
+	}
+	s := make([]string, len(preds))
+	for i, pred := range preds {
+		s[i] = exprString(pred)
+	}
+	this.warn(NON_SARGABLE, "No index on %s can be used for %s: the whole keyspace is scanned",
+		path.ProtectedString(), strings.Join(s, " AND "))
+}
+
+// the FROM aliases an expression refers to
+func references(expr expression.Expression, aliases map[string]bool) map[string]bool {
+	rv := make(map[string]bool)
+	var walk func(expression.Expression)
+	walk = func(expr expression.Expression) {
+		if expr == nil {
+			return
+		}
+		if ident, ok := expr.(*expression.Identifier); ok && aliases[ident.Identifier()] {
+			rv[ident.Identifier()] = true
+		}
+		for _, child := range expr.Children() {
+			walk(child)
+		}
+	}
+	walk(expr)
+	return rv
+}
+
+/*
+Function names are resolved by the parser, so legacy spellings are found
+by scanning the text for names followed by a parenthesis, skipping string
+literals, quoted identifiers and comments.
+*/
+func (this *linter) deprecatedFunctions(text string) {
+	found := make(map[string]bool)
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == '"' || c == '\'' || c == '`':
+			i = skipQuoted(text, i)
+		case strings.HasPrefix(text[i:], "/*"):
+			end := strings.Index(text[i+2:], "*/")
+			if end < 0 {
+				return
+			}
+			i += end + 4
+		case strings.HasPrefix(text[i:], "--"):
+			end := strings.IndexByte(text[i:], '\n')
+			if end < 0 {
+				return
+			}
+			i += end + 1
+		case c == '_' || unicode.IsLetter(rune(c)):
+			j := i + 1
+			for j < len(text) && (text[j] == '_' || unicode.IsLetter(rune(text[j])) || unicode.IsDigit(rune(text[j]))) {
+				j++
+			}
+			name := strings.ToLower(text[i:j])
+			k := j
+			for k < len(text) && unicode.IsSpace(rune(text[k])) {
+				k++
+			}
+			if k < len(text) && text[k] == '(' && _DEPRECATED_FUNCTIONS[name] != "" && (i == 0 || text[i-1] != '.') {
+				found[name] = true
+			}
+			i = j
+		default:
+			i++
+		}
+	}
+
+	names := make([]string, 0, len(found))
+	for name, _ := range found {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		this.warn(DEPRECATED_FUNCTION, "%s() is deprecated: use %s()",
+			strings.ToUpper(name), strings.ToUpper(_DEPRECATED_FUNCTIONS[name]))
+	}
+}
+
+// the index just past the quoted string or identifier starting at i
+func skipQuoted(text string, i int) int {
+	quote := text[i]
+	for i++; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			if quote != '`' {
+				i++
+			}
+		case quote:
+			return i + 1
+		}
+	}
+	return i
+}
@@ -63,6 +63,29 @@ type FunctionBody interface {
 	SwitchContext() value.Tristate
 	IsExternal() bool
 	Privileges() (*auth.Privileges, errors.Error)
+	ReturnsTable() bool
+	SetReturnsTable(table bool)
+}
+
+// TableReturn is embedded in function bodies to record if the function
+// returns a table, ie an array of rows that can be used as a FROM term
+type TableReturn struct {
+	table bool
+}
+
+func (this *TableReturn) ReturnsTable() bool {
+	return this.table
+}
+
+func (this *TableReturn) SetReturnsTable(table bool) {
+	this.table = table
+}
+
+// TableSignature adds the return type to the body definition
+func (this *TableReturn) TableSignature(object map[string]interface{}) {
+	if this.table {
+		object["returns"] = "table"
+	}
 }
 
 type FunctionEntry struct {
@@ -358,6 +381,12 @@ func CheckDelete(name FunctionName, context Context) errors.Error {
 	return e
 }
 
+// ReturnsTable tells if a function has been declared to return a table
+func ReturnsTable(name FunctionName) bool {
+	f := preLoad(name)
+	return f != nil && f.ReturnsTable()
+}
+
 func Indexable(name FunctionName) value.Tristate {
 	f := preLoad(name)
 	if f == nil {
@@ -480,6 +509,12 @@ func ExecuteFunction(name FunctionName, modifiers Modifier, values []value.Value
 	start := time.Now()
 	val, err := languages[entry.Lang()].Execute(name, body, modifiers, values, newContext)
 
+	// tables can be empty, but if there are rows they come as an array
+	if err == nil && body.ReturnsTable() && val != nil && val.Type() > value.NULL && val.Type() != value.ARRAY {
+		err = errors.NewTableFunctionResultError(name.Name(), val.Type().String())
+		val = nil
+	}
+
 	// update stats
 	serviceTime := time.Since(start)
 	atomic.AddInt32(&entry.Uses, 1)
@@ -547,6 +582,7 @@ func (this *empty) Execute(name FunctionName, body FunctionBody, modifiers Modif
 
 // dummy language throwing errors, for caching missing entries
 type missing struct {
+	TableReturn
 }
 
 func (this *missing) Lang() Language {
@@ -27,6 +27,7 @@ type golang struct {
 }
 
 type golangBody struct {
+	functions.TableReturn
 	varNames []string
 	library  string
 	object   string
@@ -121,6 +122,7 @@ func (this *golangBody) Lang() functions.Language {
 
 func (this *golangBody) Body(object map[string]interface{}) {
 	object["#language"] = "golang"
+	this.TableSignature(object)
 	object["library"] = this.library
 	object["object"] = this.object
 	if this.varNames != nil {
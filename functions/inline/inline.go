@@ -23,6 +23,7 @@ type inline struct {
 }
 
 type inlineBody struct {
+	functions.TableReturn
 	expr     expression.Expression
 	varNames []string
 }
@@ -111,6 +112,7 @@ func (this *inlineBody) Lang() functions.Language {
 
 func (this *inlineBody) Body(object map[string]interface{}) {
 	object["#language"] = "inline"
+	this.TableSignature(object)
 	object["expression"] = this.expr.String()
 	if this.varNames != nil {
 		vars := make([]value.Value, len(this.varNames))
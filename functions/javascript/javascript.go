@@ -31,6 +31,7 @@ type javascript struct {
 }
 
 type javascriptBody struct {
+	functions.TableReturn
 	varNames []string
 	library  string
 	object   string
@@ -134,6 +135,7 @@ func (this *javascriptBody) Lang() functions.Language {
 
 func (this *javascriptBody) Body(object map[string]interface{}) {
 	object["#language"] = "javascript"
+	this.TableSignature(object)
 	object["library"] = this.library
 	object["object"] = this.object
 	if this.varNames != nil {
@@ -70,13 +70,22 @@ func MakePath(bytes []byte) ([]string, errors.Error) {
 func MakeBody(name string, bytes []byte) (functions.FunctionBody, errors.Error) {
 	var language_type struct {
 		Language string `json:"#language"`
+		Returns  string `json:"returns"`
 	}
 
 	err := json.Unmarshal(bytes, &language_type)
 	if err != nil {
 		return nil, errors.NewFunctionEncodingError("decode body", name, err)
 	}
-	switch language_type.Language {
+	body, newErr := makeBody(name, language_type.Language, bytes)
+	if body != nil && language_type.Returns == "table" {
+		body.SetReturnsTable(true)
+	}
+	return body, newErr
+}
+
+func makeBody(name string, language string, bytes []byte) (functions.FunctionBody, errors.Error) {
+	switch language {
 	case "inline":
 
 		var expr expression.Expression
@@ -146,6 +155,6 @@ func MakeBody(name string, bytes []byte) (functions.FunctionBody, errors.Error)
 		return body, newErr
 
 	default:
-		return nil, errors.NewFunctionEncodingError("decode body", "unknown", fmt.Errorf("unknown language %v", language_type.Language))
+		return nil, errors.NewFunctionEncodingError("decode body", "unknown", fmt.Errorf("unknown language %v", language))
 	}
 }
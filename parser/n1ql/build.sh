@@ -36,5 +36,7 @@ EOF
 fi
 echo goyacc n1ql.y
 goyacc n1ql.y
+echo go run keywords/gen.go
+go run keywords/gen.go > keywords/keywords.go
 echo go build
 go build
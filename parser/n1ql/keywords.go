@@ -0,0 +1,52 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package n1ql
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+var keywords []string
+var keywordsOnce sync.Once
+
+// Keywords returns the sorted list of N1QL keywords.
+// A grammar token is a keyword if the scanner recognizes its own name as that token.
+// The list is also generated into the keywords package, for clients that do not
+// want to depend on the parser.
+func Keywords() []string {
+	keywordsOnce.Do(func() {
+		for i, name := range yyToknames {
+			if name == "IDENT" || !isKeywordName(name) {
+				continue
+			}
+			nex := NewLexer(strings.NewReader(strings.ToLower(name)))
+			tok := nex.Lex(&yySymType{})
+			nex.Stop()
+			if tok == yyPrivate+i-1 {
+				keywords = append(keywords, name)
+			}
+		}
+		sort.Strings(keywords)
+	})
+	return keywords
+}
+
+func isKeywordName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		if (c < 'A' || c > 'Z') && c != '_' {
+			return false
+		}
+	}
+	return true
+}
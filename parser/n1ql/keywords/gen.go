@@ -0,0 +1,55 @@
+//go:build ignore
+// +build ignore
+
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+// gen writes keywords.go from the keywords the parser knows:
+//
+//	go run keywords/gen.go > keywords/keywords.go
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/couchbase/query/parser/n1ql"
+)
+
+const header = `//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+// Code generated by gen.go from the N1QL grammar. DO NOT EDIT.
+
+// Package keywords lists the N1QL keywords without depending on the parser,
+// for clients such as the shell.
+package keywords
+
+var keywords = []string{
+`
+
+const footer = `}
+
+// List returns the sorted list of N1QL keywords.
+func List() []string {
+	return keywords
+}
+`
+
+func main() {
+	fmt.Fprint(os.Stdout, header)
+	for _, k := range n1ql.Keywords() {
+		fmt.Fprintf(os.Stdout, "\t%q,\n", k)
+	}
+	fmt.Fprint(os.Stdout, footer)
+}
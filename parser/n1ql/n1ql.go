@@ -29,6 +29,17 @@ func ParseStatement(input string) (algebra.Statement, error) {
 }
 
 func ParseStatement2(input string, namespace string, queryContext string) (algebra.Statement, error) {
+	return ParseStatement3(input, namespace, queryContext, nil)
+}
+
+/*
+The resolver gives the full path of unqualified keyspace names that do not
+refer to the namespace and query context, such as the temporary collections
+of a session; it returns nil for all other names.
+*/
+type KeyspaceResolver func(name string) []string
+
+func ParseStatement3(input string, namespace string, queryContext string, resolver KeyspaceResolver) (algebra.Statement, error) {
 	input = strings.TrimSpace(input)
 	reader := strings.NewReader(input)
 	lex := newLexer(NewLexer(reader))
@@ -36,6 +47,7 @@ func ParseStatement2(input string, namespace string, queryContext string) (algeb
 	lex.text = input
 	lex.namespace = namespace
 	lex.queryContext = queryContext
+	lex.resolver = resolver
 	lex.nex.ResetOffset()
 	lex.nex.ReportError(lex.ScannerError)
 	doParse(lex)
@@ -106,10 +118,12 @@ type lexer struct {
 	namespace              string
 	createFuncQueryContext string
 	queryContext           string
+	resolver               KeyspaceResolver
 	hasSaved               bool
 	saved                  int
 	lval                   yySymType
 	stop                   bool
+	lastToken              int
 }
 
 func newLexer(nex *Lexer) *lexer {
@@ -133,7 +147,7 @@ func (this *lexer) Lex(lval *yySymType) int {
 		return rv
 	}
 
-	rv := this.nex.Lex(lval)
+	rv := this.nexLex(lval)
 
 	// we are going to treat identifiers specially to resolve
 	// shift reduce conflicts on namespaces
@@ -150,7 +164,7 @@ func (this *lexer) Lex(lval *yySymType) int {
 	// save the current token value and check the next
 	this.hasSaved = true
 	oldLval := *lval
-	this.saved = this.nex.Lex(lval)
+	this.saved = this.nexLex(lval)
 	this.lval = *lval
 	*lval = oldLval
 
@@ -162,6 +176,48 @@ func (this *lexer) Lex(lval *yySymType) int {
 	return NAMESPACE_ID
 }
 
+// hint comments only count right after SELECT, elsewhere they are
+// plain comments
+func (this *lexer) nexLex(lval *yySymType) int {
+	for {
+		rv := this.nex.Lex(lval)
+		if rv == '@' {
+			rv = this.sessionVariable(lval)
+		}
+		if rv != OPTIM_HINTS || this.lastToken == SELECT {
+			this.lastToken = rv
+			return rv
+		}
+	}
+}
+
+// session variables are an @ immediately followed by an identifier, which may be a keyword
+func (this *lexer) sessionVariable(lval *yySymType) int {
+	offset := this.nex.curOffset
+	rv := this.nex.Lex(lval)
+	text := this.nex.Text()
+	if this.nex.curOffset-len(text) == offset {
+		if rv == IDENT {
+			return SESSION_VAR
+		} else if isIdentifier(text) {
+			lval.s = text
+			return SESSION_VAR
+		}
+	}
+	this.ScannerError("@ must be immediately followed by a session variable name")
+	return '@'
+}
+
+// keywords are scanned as their own tokens, but have the shape of identifiers
+func isIdentifier(s string) bool {
+	for i, c := range s {
+		if c != '_' && (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (i == 0 || c < '0' || c > '9') {
+			return false
+		}
+	}
+	return s != ""
+}
+
 func (this *lexer) Remainder(offset int) string {
 	return strings.TrimLeft(this.text[offset:], " \t")
 }
@@ -276,6 +332,17 @@ func (this *lexer) Namespace() string {
 	return this.namespace
 }
 
+// unqualified keyspace names may refer to the temporary collections of a session,
+// except in function bodies, which outlive sessions
+func (this *lexer) KeyspacePath(keyspace string) *algebra.Path {
+	if this.resolver != nil && this.createFuncQueryContext == "" {
+		if elems := this.resolver(keyspace); elems != nil {
+			return algebra.NewPathFromElements(elems)
+		}
+	}
+	return algebra.NewPathWithContext(keyspace, this.Namespace(), this.QueryContext())
+}
+
 func (this *lexer) PushQueryContext(queryContext string) {
 	this.createFuncQueryContext = queryContext
 }
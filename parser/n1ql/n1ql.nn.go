@@ -38919,6 +38919,11 @@ OUTER0:
 			}
 		case 7:
 			{
+				if strings.HasPrefix(yylex.Text(), "/*+") {
+					lval.s = yylex.Text()
+					yylex.logToken(yylex.Text(), "OPTIM_HINTS (length=%d)", len(yylex.Text()))
+					return OPTIM_HINTS
+				}
 				yylex.logToken(yylex.Text(), "BLOCK_COMMENT (length=%d)", len(yylex.Text())) /* eat up block comment */
 			}
 		case 8:
@@ -58,6 +58,7 @@ group            *algebra.Group
 resultTerm       *algebra.ResultTerm
 resultTerms      algebra.ResultTerms
 projection       *algebra.Projection
+optimHints       *algebra.OptimHints
 order            *algebra.Order
 sortTerm         *algebra.SortTerm
 sortTerms        algebra.SortTerms
@@ -236,6 +237,7 @@ tokOffset    int
 %token ON
 %token OPTION
 %token OPTIONS
+%token OPTIM_HINTS
 %token OR
 %token ORDER
 %token OTHERS
@@ -319,7 +321,7 @@ tokOffset    int
 %token WORK
 %token XOR
 
-%token INT NUM STR IDENT IDENT_ICASE NAMED_PARAM POSITIONAL_PARAM NEXT_PARAM
+%token INT NUM STR IDENT IDENT_ICASE NAMED_PARAM POSITIONAL_PARAM NEXT_PARAM SESSION_VAR
 %token LPAREN RPAREN
 %token LBRACE RBRACE LBRACKET RBRACKET RBRACKET_ICASE
 %token COMMA COLON
@@ -355,11 +357,11 @@ tokOffset    int
 
 
 /* Types */
-%type <s>                STR
+%type <s>                STR OPTIM_HINTS
 %type <s>                IDENT IDENT_ICASE NAMESPACE_ID
 %type <identifier>       ident ident_icase
 %type <s>                REPLACE
-%type <s>                NAMED_PARAM
+%type <s>                NAMED_PARAM SESSION_VAR
 %type <f>                NUM
 %type <n>                INT
 %type <n>                POSITIONAL_PARAM NEXT_PARAM
@@ -389,7 +391,7 @@ tokOffset    int
 
 %type <functionName>     func_name long_func_name short_func_name
 %type <ss>               parm_list parameter_terms
-%type <functionBody>     func_body
+%type <functionBody>     func_body func_spec
 %type <b>                opt_replace
 
 %type <expr>             paren_expr
@@ -419,7 +421,8 @@ tokOffset    int
 %type <expr>             opt_having having
 %type <resultTerm>       project
 %type <resultTerms>      projects
-%type <projection>       projection select_clause
+%type <projection>       projection
+%type <optimHints>       opt_optim_hints
 %type <order>            order_by opt_order_by
 %type <sortTerm>         sort_term
 %type <sortTerms>        sort_terms
@@ -441,6 +444,7 @@ tokOffset    int
 %type <statement>        collection_stmt create_collection drop_collection flush_collection
 %type <statement>        role_stmt grant_role revoke_role
 %type <statement>        function_stmt create_function drop_function execute_function
+%type <statement>        session_stmt set_variable create_temporary_collection drop_temporary_collection
 
 %type <keyspaceRef>      keyspace_ref simple_keyspace_ref
 %type <pairs>            values values_list next_values
@@ -556,6 +560,8 @@ role_stmt
 function_stmt
 |
 transaction_stmt
+|
+session_stmt
 ;
 
 advise:
@@ -882,16 +888,18 @@ select_from
 ;
 
 from_select:
-opt_with from opt_let opt_where opt_group opt_window_clause select_clause
+opt_with from opt_let opt_where opt_group opt_window_clause SELECT opt_optim_hints projection
 {
-    $$ = algebra.NewSubselect($1, $2, $3, $4, $5, $6, $7)
+    $$ = algebra.NewSubselect($1, $2, $3, $4, $5, $6, $9)
+    $$.SetOptimHints($8)
 }
 ;
 
 select_from:
-opt_with select_clause opt_from opt_let opt_where opt_group opt_window_clause
+opt_with SELECT opt_optim_hints projection opt_from opt_let opt_where opt_group opt_window_clause
 {
-    $$ = algebra.NewSubselect($1, $3, $4, $5, $6, $7, $2)
+    $$ = algebra.NewSubselect($1, $5, $6, $7, $8, $9, $4)
+    $$.SetOptimHints($3)
 }
 ;
 
@@ -902,11 +910,15 @@ opt_with select_clause opt_from opt_let opt_where opt_group opt_window_clause
  *
  *************************************************/
 
-select_clause:
-SELECT
-projection
+opt_optim_hints:
+/* empty */
 {
-    $$ = $2
+    $$ = nil
+}
+|
+OPTIM_HINTS
+{
+    $$ = algebra.NewOptimHints($1)
 }
 ;
 
@@ -1160,8 +1172,7 @@ expr opt_as_alias opt_use
             }
             $$ = algebra.NewSubqueryTerm(other.Select(), $2, $3.JoinHint())
         case *expression.Identifier:
-            ksterm := algebra.NewKeyspaceTermFromPath(algebra.NewPathWithContext(other.Alias(), yylex.(*lexer).Namespace(),
-                yylex.(*lexer).QueryContext()), $2, $3.Keys(), $3.Indexes())
+            ksterm := algebra.NewKeyspaceTermFromPath(yylex.(*lexer).KeyspacePath(other.Alias()), $2, $3.Keys(), $3.Indexes())
             $$ = algebra.NewExpressionTerm(other, $2, ksterm, other.Parenthesis() == false, $3.JoinHint())
         case *algebra.NamedParameter, *algebra.PositionalParameter:
             if $3.Indexes() == nil {
@@ -1764,7 +1775,7 @@ INSERT INTO keyspace_ref LPAREN key_val_options_expr_header RPAREN fullselect op
 simple_keyspace_ref:
 keyspace_name opt_as_alias
 {
-    $$ = algebra.NewKeyspaceRefWithContext($1, $2, yylex.(*lexer).Namespace(), yylex.(*lexer).QueryContext())
+    $$ = algebra.NewKeyspaceRefFromPath(yylex.(*lexer).KeyspacePath($1), $2)
 }
 |
 keyspace_path opt_as_alias
@@ -2845,7 +2856,7 @@ CREATE opt_replace FUNCTION func_name
     // push function query context
     yylex.(*lexer).PushQueryContext($4.QueryContext())
 }
-LPAREN parm_list RPAREN func_body
+LPAREN parm_list RPAREN func_spec
 {
     yylex.(*lexer).PopQueryContext()
     if $9 != nil {
@@ -2933,6 +2944,39 @@ parameter_terms COMMA IDENT
 }
 ;
 
+func_spec:
+func_body
+|
+returns_table func_body
+{
+    if $2 != nil {
+        $2.SetReturnsTable(true)
+    }
+    $$ = $2
+}
+|
+returns_table AS fullselect
+{
+    body, err := inline.NewInlineBody(algebra.NewSubquery($3))
+    if err != nil {
+        yylex.Error(err.Error()+yylex.(*lexer).ErrorContext())
+    } else {
+        body.SetReturnsTable(true)
+        $$ = body
+    }
+}
+;
+
+/* RETURNS and TABLE are not reserved words */
+returns_table:
+IDENT IDENT
+{
+    if !strings.EqualFold($1, "returns") || !strings.EqualFold($2, "table") {
+        yylex.Error("syntax error"+yylex.(*lexer).ErrorContext())
+    }
+}
+;
+
 func_body:
 LBRACE expr RBRACE
 {
@@ -3819,6 +3863,13 @@ NAMED_PARAM
     $$.ExprBase().SetErrorContext(yylex.(*lexer).nex.Line()+1,yylex.(*lexer).nex.Column())
 }
 |
+SESSION_VAR
+{
+    $$ = algebra.NewNamedParameter(algebra.SESSION_VARIABLE_PREFIX + $1)
+    yylex.(*lexer).countParam()
+    $$.ExprBase().SetErrorContext(yylex.(*lexer).nex.Line()+1,yylex.(*lexer).nex.Column())
+}
+|
 POSITIONAL_PARAM
 {
     p := int($1)
@@ -4598,3 +4649,45 @@ SAVEPOINT savepoint_name
     $$ = algebra.NewSavepoint($2)
 }
 ;
+
+/*************************************************
+ *
+ * Session statements
+ *
+ *************************************************/
+
+session_stmt:
+set_variable
+|
+create_temporary_collection
+|
+drop_temporary_collection
+;
+
+set_variable:
+SET SESSION_VAR EQ expr
+{
+    $$ = algebra.NewSetVariable($2, $4)
+}
+;
+
+/* TEMPORARY is not a reserved word */
+create_temporary_collection:
+CREATE IDENT COLLECTION keyspace_name opt_if_not_exists
+{
+    if !strings.EqualFold($2, "temporary") {
+        yylex.Error("syntax error"+yylex.(*lexer).ErrorContext())
+    }
+    $$ = algebra.NewCreateTemporaryCollection($4, $5)
+}
+;
+
+drop_temporary_collection:
+DROP IDENT COLLECTION keyspace_name opt_if_exists
+{
+    if !strings.EqualFold($2, "temporary") {
+        yylex.Error("syntax error"+yylex.(*lexer).ErrorContext())
+    }
+    $$ = algebra.NewDropTemporaryCollection($4, $5)
+}
+;
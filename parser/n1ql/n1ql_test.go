@@ -0,0 +1,158 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package n1ql
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/couchbase/query/algebra"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/functions"
+	generated "github.com/couchbase/query/parser/n1ql/keywords"
+)
+
+func TestSessionVariables(t *testing.T) {
+
+	// keywords are valid session variable names
+	for _, name := range []string{"total", "value", "limit", "Select", "_x1"} {
+		stmt, err := ParseStatement2("SET @"+name+" = 1", "default", "")
+		if err != nil {
+			t.Errorf("Cannot parse SET @%v: %v", name, err)
+			continue
+		}
+		if set, ok := stmt.(*algebra.SetVariable); !ok || set.Name() != name {
+			t.Errorf("Expected variable %v, got %v", name, stmt)
+		}
+
+		stmt, err = ParseStatement2("SELECT @"+name+" + 1", "default", "")
+		if err != nil {
+			t.Errorf("Cannot parse SELECT @%v: %v", name, err)
+			continue
+		}
+		if sel, ok := stmt.(*algebra.Select); !ok || !strings.Contains(sel.String(), "@"+name) {
+			t.Errorf("Expected session variable @%v, got %v", name, stmt)
+		}
+	}
+
+	for _, text := range []string{"SET @ value = 1", "SET @1 = 1", "SELECT @'a'", "SELECT @"} {
+		if _, err := ParseStatement2(text, "default", ""); err == nil {
+			t.Errorf("Expected %q to fail", text)
+		}
+	}
+}
+
+// function names only need a path to be parsed
+type testFunction struct {
+	functions.FunctionName
+	path []string
+}
+
+func (this *testFunction) Path() []string {
+	return this.path
+}
+
+func (this *testFunction) Name() string {
+	return this.path[len(this.path)-1]
+}
+
+func (this *testFunction) QueryContext() string {
+	return ""
+}
+
+func TestCreateTableFunction(t *testing.T) {
+	constructor := functions.Constructor
+	defer func() { functions.Constructor = constructor }()
+	functions.Constructor = func(elem []string, namespace string, queryContext string) (functions.FunctionName, errors.Error) {
+		return &testFunction{path: append([]string{namespace}, elem...)}, nil
+	}
+
+	tests := []struct {
+		text  string
+		table bool
+	}{
+		{"CREATE FUNCTION f(a, b) RETURNS TABLE { [ {\"x\": a}, {\"x\": b} ] }", true},
+		{"CREATE FUNCTION f(a, b) returns table LANGUAGE INLINE AS [a, b]", true},
+		{"CREATE FUNCTION f(a, b) RETURNS TABLE AS SELECT v AS x FROM [a, b] AS v", true},
+		{"CREATE OR REPLACE FUNCTION f(a, b) RETURNS TABLE AS SELECT a AS x ORDER BY x LIMIT 1", true},
+		{"CREATE FUNCTION f(a, b) { [a, b] }", false},
+		{"CREATE FUNCTION f(a, b) LANGUAGE INLINE AS [a, b]", false},
+	}
+	for _, test := range tests {
+		stmt, err := ParseStatement2(test.text, "default", "")
+		if err != nil {
+			t.Errorf("Cannot parse %v: %v", test.text, err)
+			continue
+		}
+		create, ok := stmt.(*algebra.CreateFunction)
+		if !ok {
+			t.Errorf("Expected CREATE FUNCTION, got %v", stmt)
+			continue
+		}
+		if create.Body().ReturnsTable() != test.table {
+			t.Errorf("Expected returns table %v for %v", test.table, test.text)
+		}
+	}
+
+	for _, text := range []string{"CREATE FUNCTION f(a, b) RETURNS ARRAY { [a, b] }",
+		"CREATE FUNCTION f(a, b) RETURNS TABLE", "CREATE FUNCTION f(a, b) AS SELECT a"} {
+		if _, err := ParseStatement2(text, "default", ""); err == nil {
+			t.Errorf("Expected %q to fail", text)
+		}
+	}
+}
+
+func TestKeywords(t *testing.T) {
+	list := Keywords()
+	for _, k := range []string{"SELECT", "FROM", "WHERE", "AS", "FLATTEN_KEYS"} {
+		if i := sort.SearchStrings(list, k); i == len(list) || list[i] != k {
+			t.Errorf("Expected %v to be a keyword", k)
+		}
+	}
+	for _, k := range []string{"IDENT", "STR", "NUM", "LPAREN", "RETURNS"} {
+		if i := sort.SearchStrings(list, k); i < len(list) && list[i] == k {
+			t.Errorf("Unexpected keyword %v", k)
+		}
+	}
+
+	// the shell's copy is generated by keywords/gen.go
+	if !reflect.DeepEqual(list, generated.List()) {
+		t.Errorf("The keywords package is out of date, run: go run keywords/gen.go > keywords/keywords.go")
+	}
+}
+
+func TestOptimHintComments(t *testing.T) {
+	hints := func(text string) *algebra.OptimHints {
+		stmt, err := ParseStatement2(text, "default", "")
+		if err != nil {
+			t.Fatalf("Cannot parse %v: %v", text, err)
+		}
+		return stmt.(*algebra.Select).Subresult().(*algebra.Subselect).OptimHints()
+	}
+
+	// hint comments directly follow SELECT, other comments notwithstanding
+	for _, text := range []string{"SELECT /*+ INDEX(b idx1) */ name FROM b",
+		"select /* c */ /*+ INDEX(b idx1) */ name FROM b", "SELECT\n/*+INDEX(b idx1)*/ name FROM b"} {
+		h := hints(text)
+		if h == nil || len(h.Hints()) != 1 || h.Hints()[0].Type() != algebra.HINT_INDEX {
+			t.Errorf("Expected an index hint for %q, got %v", text, h)
+		}
+	}
+
+	// elsewhere they are ordinary comments
+	for _, text := range []string{"/*+ INDEX(b idx1) */ SELECT name FROM b",
+		"SELECT name /*+ INDEX(b idx1) */ FROM b", "SELECT name FROM b /*+ INDEX(b idx1) */",
+		"SELECT name FROM b WHERE /*+ INDEX(b idx1) */ a = 1", "SELECT /* INDEX(b idx1) */ name FROM b"} {
+		if h := hints(text); h != nil {
+			t.Errorf("Expected no hints for %q, got %v", text, h)
+		}
+	}
+}
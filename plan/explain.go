@@ -14,8 +14,9 @@ import (
 
 type Explain struct {
 	execution
-	op   Operator
-	text string
+	op         Operator
+	text       string
+	optimHints map[string]interface{}
 }
 
 func NewExplain(op Operator, text string) *Explain {
@@ -37,6 +38,17 @@ func (this *Explain) Operator() Operator {
 	return this.op
 }
 
+/*
+The optimizer hints of the statement, by whether they were followed.
+*/
+func (this *Explain) OptimHints() map[string]interface{} {
+	return this.optimHints
+}
+
+func (this *Explain) SetOptimHints(optimHints map[string]interface{}) {
+	this.optimHints = optimHints
+}
+
 func (this *Explain) MarshalJSON() ([]byte, error) {
 	return json.Marshal(this.MarshalBase(nil))
 }
@@ -44,6 +56,9 @@ func (this *Explain) MarshalJSON() ([]byte, error) {
 func (this *Explain) MarshalBase(f func(map[string]interface{})) map[string]interface{} {
 	r := make(map[string]interface{}, 2)
 	r["text"] = this.text
+	if len(this.optimHints) > 0 {
+		r["optimizer_hints"] = this.optimHints
+	}
 	if this.op != nil {
 		if this.op.Cost() > 0.0 {
 			r["cost"] = this.op.Cost()
@@ -62,10 +77,11 @@ func (this *Explain) MarshalBase(f func(map[string]interface{})) map[string]inte
 
 func (this *Explain) UnmarshalJSON(body []byte) error {
 	var _unmarshalled struct {
-		Op          json.RawMessage `json:"plan"`
-		Text        string          `json:"text"`
-		Cost        float64         `json:"cost"`
-		Cardinality float64         `json:"cardinality"`
+		Op          json.RawMessage        `json:"plan"`
+		Text        string                 `json:"text"`
+		Cost        float64                `json:"cost"`
+		Cardinality float64                `json:"cardinality"`
+		OptimHints  map[string]interface{} `json:"optimizer_hints"`
 	}
 
 	var op_type struct {
@@ -83,6 +99,7 @@ func (this *Explain) UnmarshalJSON(body []byte) error {
 	}
 
 	this.text = _unmarshalled.Text
+	this.optimHints = _unmarshalled.OptimHints
 
 	err = json.Unmarshal(_unmarshalled.Op, &op_type)
 	if err != nil {
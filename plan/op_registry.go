@@ -178,4 +178,9 @@ var _OPERATORS = map[string]Operator{
 	"RollbackTransaction":  &RollbackTransaction{},
 	"TransactionIsolation": &TransactionIsolation{},
 	"Savepoint":            &Savepoint{},
+
+	// Sessions
+	"SetVariable":               &SetVariable{},
+	"CreateTemporaryCollection": &CreateTemporaryCollection{},
+	"DropTemporaryCollection":   &DropTemporaryCollection{},
 }
@@ -0,0 +1,203 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package plan
+
+import (
+	"encoding/json"
+
+	"github.com/couchbase/query/algebra"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/expression/parser"
+)
+
+// Set session variable
+type SetVariable struct {
+	ddl
+	name string
+	expr expression.Expression
+}
+
+func NewSetVariable(stmt *algebra.SetVariable) *SetVariable {
+	return &SetVariable{
+		name: stmt.Name(),
+		expr: stmt.Expression(),
+	}
+}
+
+func (this *SetVariable) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitSetVariable(this)
+}
+
+func (this *SetVariable) New() Operator {
+	return &SetVariable{}
+}
+
+func (this *SetVariable) Name() string {
+	return this.name
+}
+
+func (this *SetVariable) Expression() expression.Expression {
+	return this.expr
+}
+
+func (this *SetVariable) MarshalJSON() ([]byte, error) {
+	return json.Marshal(this.MarshalBase(nil))
+}
+
+func (this *SetVariable) MarshalBase(f func(map[string]interface{})) map[string]interface{} {
+	r := map[string]interface{}{"#operator": "SetVariable"}
+	r["name"] = this.name
+	r["expr"] = expression.NewStringer().Visit(this.expr)
+
+	if f != nil {
+		f(r)
+	}
+	return r
+}
+
+func (this *SetVariable) UnmarshalJSON(body []byte) error {
+	var _unmarshalled struct {
+		_    string `json:"#operator"`
+		Name string `json:"name"`
+		Expr string `json:"expr"`
+	}
+
+	err := json.Unmarshal(body, &_unmarshalled)
+	if err != nil {
+		return err
+	}
+
+	this.name = _unmarshalled.Name
+	this.expr, err = parser.Parse(_unmarshalled.Expr)
+	return err
+}
+
+// Create temporary collection
+type CreateTemporaryCollection struct {
+	ddl
+	name         string
+	failIfExists bool
+}
+
+func NewCreateTemporaryCollection(stmt *algebra.CreateTemporaryCollection) *CreateTemporaryCollection {
+	return &CreateTemporaryCollection{
+		name:         stmt.Name(),
+		failIfExists: stmt.FailIfExists(),
+	}
+}
+
+func (this *CreateTemporaryCollection) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitCreateTemporaryCollection(this)
+}
+
+func (this *CreateTemporaryCollection) New() Operator {
+	return &CreateTemporaryCollection{}
+}
+
+func (this *CreateTemporaryCollection) Name() string {
+	return this.name
+}
+
+func (this *CreateTemporaryCollection) FailIfExists() bool {
+	return this.failIfExists
+}
+
+func (this *CreateTemporaryCollection) MarshalJSON() ([]byte, error) {
+	return json.Marshal(this.MarshalBase(nil))
+}
+
+func (this *CreateTemporaryCollection) MarshalBase(f func(map[string]interface{})) map[string]interface{} {
+	r := map[string]interface{}{"#operator": "CreateTemporaryCollection"}
+	r["name"] = this.name
+	r["failIfExists"] = this.failIfExists
+
+	if f != nil {
+		f(r)
+	}
+	return r
+}
+
+func (this *CreateTemporaryCollection) UnmarshalJSON(body []byte) error {
+	var _unmarshalled struct {
+		_            string `json:"#operator"`
+		Name         string `json:"name"`
+		FailIfExists bool   `json:"failIfExists"`
+	}
+
+	err := json.Unmarshal(body, &_unmarshalled)
+	if err != nil {
+		return err
+	}
+
+	this.name = _unmarshalled.Name
+	this.failIfExists = _unmarshalled.FailIfExists
+	return nil
+}
+
+// Drop temporary collection
+type DropTemporaryCollection struct {
+	ddl
+	name            string
+	failIfNotExists bool
+}
+
+func NewDropTemporaryCollection(stmt *algebra.DropTemporaryCollection) *DropTemporaryCollection {
+	return &DropTemporaryCollection{
+		name:            stmt.Name(),
+		failIfNotExists: stmt.FailIfNotExists(),
+	}
+}
+
+func (this *DropTemporaryCollection) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitDropTemporaryCollection(this)
+}
+
+func (this *DropTemporaryCollection) New() Operator {
+	return &DropTemporaryCollection{}
+}
+
+func (this *DropTemporaryCollection) Name() string {
+	return this.name
+}
+
+func (this *DropTemporaryCollection) FailIfNotExists() bool {
+	return this.failIfNotExists
+}
+
+func (this *DropTemporaryCollection) MarshalJSON() ([]byte, error) {
+	return json.Marshal(this.MarshalBase(nil))
+}
+
+func (this *DropTemporaryCollection) MarshalBase(f func(map[string]interface{})) map[string]interface{} {
+	r := map[string]interface{}{"#operator": "DropTemporaryCollection"}
+	r["name"] = this.name
+	r["failIfNotExists"] = this.failIfNotExists
+
+	if f != nil {
+		f(r)
+	}
+	return r
+}
+
+func (this *DropTemporaryCollection) UnmarshalJSON(body []byte) error {
+	var _unmarshalled struct {
+		_               string `json:"#operator"`
+		Name            string `json:"name"`
+		FailIfNotExists bool   `json:"failIfNotExists"`
+	}
+
+	err := json.Unmarshal(body, &_unmarshalled)
+	if err != nil {
+		return err
+	}
+
+	this.name = _unmarshalled.Name
+	this.failIfNotExists = _unmarshalled.FailIfNotExists
+	return nil
+}
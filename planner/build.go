@@ -117,7 +117,10 @@ type builder struct {
 	indexAdvisor       bool
 	useCBO             bool
 	hintIndexes        bool
-	lastOp             plan.Operator // last operator built, to get cost/cardinality info
+	lastOp             plan.Operator                // last operator built, to get cost/cardinality info
+	optimHints         *algebra.OptimHints          // optimizer hints of the current query block
+	allOptimHints      []*algebra.OptimHints        // optimizer hints of all query blocks, for EXPLAIN
+	hintScans          map[string][]datastore.Index // indexes scanned per keyspace, for index hints
 }
 
 func (this *builder) Copy() *builder {
@@ -145,6 +148,8 @@ func (this *builder) Copy() *builder {
 		indexAdvisor:      this.indexAdvisor,
 		useCBO:            this.useCBO,
 		hintIndexes:       this.hintIndexes,
+		optimHints:        this.optimHints,
+		hintScans:         this.hintScans,
 		// the following fields are setup during planning process and thus not copied:
 		// children, subChildren, coveringScan, coveredUnnests, countScan, orderScan, lastOp
 	}
@@ -19,5 +19,7 @@ func (this *builder) VisitExplain(stmt *algebra.Explain) (interface{}, error) {
 		return nil, err
 	}
 
-	return plan.NewExplain(op.(plan.Operator), stmt.Text()), nil
+	explain := plan.NewExplain(op.(plan.Operator), stmt.Text())
+	explain.SetOptimHints(algebra.OptimHintsSummary(this.allOptimHints))
+	return explain, nil
 }
@@ -0,0 +1,353 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package planner
+
+import (
+	"github.com/couchbase/query/algebra"
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/plan"
+)
+
+// a term of the FROM clause that hints can refer to
+type hintTerm struct {
+	term    algebra.SimpleFromTerm
+	join    algebra.JoinTerm // ANSI JOIN or ANSI NEST with the term on the right-hand side
+	first   bool
+	unnest  bool
+	nonAnsi bool
+}
+
+func collectHintTerms(from algebra.FromTerm, terms map[string]*hintTerm) {
+	switch from := from.(type) {
+	case *algebra.AnsiJoin:
+		collectHintTerms(from.Left(), terms)
+		terms[from.Right().Alias()] = &hintTerm{term: from.Right(), join: from}
+	case *algebra.AnsiNest:
+		collectHintTerms(from.Left(), terms)
+		terms[from.Right().Alias()] = &hintTerm{term: from.Right(), join: from}
+	case *algebra.Join:
+		collectHintTerms(from.Left(), terms)
+		terms[from.Right().Alias()] = &hintTerm{term: from.Right(), nonAnsi: true}
+	case *algebra.IndexJoin:
+		collectHintTerms(from.Left(), terms)
+		terms[from.Right().Alias()] = &hintTerm{term: from.Right(), nonAnsi: true}
+	case *algebra.Nest:
+		collectHintTerms(from.Left(), terms)
+		terms[from.Right().Alias()] = &hintTerm{term: from.Right(), nonAnsi: true}
+	case *algebra.IndexNest:
+		collectHintTerms(from.Left(), terms)
+		terms[from.Right().Alias()] = &hintTerm{term: from.Right(), nonAnsi: true}
+	case *algebra.Unnest:
+		collectHintTerms(from.Left(), terms)
+		terms[from.Alias()] = &hintTerm{unnest: true}
+	case algebra.SimpleFromTerm:
+		terms[from.Alias()] = &hintTerm{term: from, first: true}
+	}
+}
+
+/*
+Validate the optimizer hints of the query block against its FROM
+clause, and apply the join hints to the right-hand side terms. The
+returned function restores the join hints of the terms.
+*/
+func (this *builder) beginOptimHints(node *algebra.Subselect) func() {
+	this.optimHints = node.OptimHints()
+	this.hintScans = nil
+	if this.optimHints == nil {
+		return func() {}
+	}
+	this.hintScans = make(map[string][]datastore.Index, _MAP_KEYSPACE_CAP)
+
+	this.optimHints.ResetStates()
+	found := false
+	for _, hints := range this.allOptimHints {
+		if hints == this.optimHints {
+			found = true
+			break
+		}
+	}
+	if !found {
+		this.allOptimHints = append(this.allOptimHints, this.optimHints)
+	}
+
+	terms := make(map[string]*hintTerm, _MAP_KEYSPACE_CAP)
+	if node.From() != nil {
+		collectHintTerms(node.From(), terms)
+	}
+
+	ordered := false
+	indexHints := make(map[string]bool, len(terms))
+	joinHints := make(map[string]bool, len(terms))
+	for _, hint := range this.optimHints.Hints() {
+		if hint.State() == algebra.HINT_STATE_INVALID {
+			continue
+		}
+		switch hint.Type() {
+		case algebra.HINT_ORDERED:
+			if ordered {
+				hint.SetState(algebra.HINT_STATE_INVALID, algebra.HINT_DUPLICATE)
+			}
+			ordered = true
+			continue
+		}
+
+		term, ok := terms[hint.Keyspace()]
+		if !ok {
+			hint.SetState(algebra.HINT_STATE_INVALID, algebra.HINT_UNKNOWN_KEYSPACE)
+			continue
+		}
+
+		switch hint.Type() {
+		case algebra.HINT_INDEX, algebra.HINT_NO_INDEX, algebra.HINT_INDEX_FTS:
+			ksterm := algebra.GetKeyspaceTerm(term.term)
+			key := hint.Keyspace() + "/" + hint.String()
+			if ksterm == nil {
+				hint.SetState(algebra.HINT_STATE_INVALID, algebra.HINT_NOT_KEYSPACE)
+			} else if indexHints[key] {
+				hint.SetState(algebra.HINT_STATE_INVALID, algebra.HINT_DUPLICATE)
+			} else if ksterm.Keys() != nil ||
+				(hint.Type() != algebra.HINT_NO_INDEX && len(ksterm.Indexes()) > 0) {
+				hint.SetState(algebra.HINT_STATE_INVALID, algebra.HINT_USE_CLAUSE)
+			}
+			indexHints[key] = true
+		case algebra.HINT_USE_HASH, algebra.HINT_USE_NL:
+			if term.unnest {
+				hint.SetState(algebra.HINT_STATE_INVALID, algebra.HINT_NOT_KEYSPACE)
+			} else if term.first {
+				hint.SetState(algebra.HINT_STATE_INVALID, algebra.HINT_FIRST_KEYSPACE)
+			} else if term.nonAnsi {
+				hint.SetState(algebra.HINT_STATE_INVALID, algebra.HINT_NOT_ANSI_JOIN)
+			} else if joinHints[hint.Keyspace()] {
+				hint.SetState(algebra.HINT_STATE_INVALID, algebra.HINT_DUPLICATE)
+			} else if joinHint := term.term.JoinHint(); joinHint != algebra.JOIN_HINT_NONE &&
+				joinHint != hint.JoinHint() {
+				hint.SetState(algebra.HINT_STATE_INVALID, algebra.HINT_USE_CLAUSE)
+			}
+			joinHints[hint.Keyspace()] = true
+		}
+	}
+
+	// join hints are followed as if given with USE HASH or USE NL
+	var restore []func()
+	for _, hint := range this.optimHints.Hints() {
+		if hint.State() == algebra.HINT_STATE_INVALID ||
+			(hint.Type() != algebra.HINT_USE_HASH && hint.Type() != algebra.HINT_USE_NL) {
+			continue
+		}
+		right := terms[hint.Keyspace()].term
+		joinHint := right.JoinHint()
+		right.SetJoinHint(hint.JoinHint())
+		restore = append(restore, func() { right.SetJoinHint(joinHint) })
+	}
+
+	return func() {
+		for _, r := range restore {
+			r()
+		}
+	}
+}
+
+/*
+Set the state of the optimizer hints of the query block once it
+has been planned.
+*/
+func (this *builder) endOptimHints(node *algebra.Subselect) {
+	if this.optimHints == nil {
+		return
+	}
+
+	terms := make(map[string]*hintTerm, _MAP_KEYSPACE_CAP)
+	if node.From() != nil {
+		collectHintTerms(node.From(), terms)
+	}
+
+	for _, hint := range this.optimHints.Hints() {
+		if hint.State() != algebra.HINT_STATE_UNKNOWN {
+			continue
+		}
+
+		switch hint.Type() {
+		case algebra.HINT_ORDERED:
+			// joins are planned in the order of the FROM clause
+			hint.SetState(algebra.HINT_STATE_FOLLOWED, "")
+		case algebra.HINT_USE_HASH, algebra.HINT_USE_NL:
+			hintError := ""
+			switch join := terms[hint.Keyspace()].join.(type) {
+			case *algebra.AnsiJoin:
+				hintError = join.HintError()
+			case *algebra.AnsiNest:
+				hintError = join.HintError()
+			}
+			if hintError == "" {
+				hint.SetState(algebra.HINT_STATE_FOLLOWED, "")
+			} else {
+				hint.SetState(algebra.HINT_STATE_NOT_FOLLOWED, hintError)
+			}
+		default:
+			if followsIndexHint(hint, this.hintScans[hint.Keyspace()]) {
+				hint.SetState(algebra.HINT_STATE_FOLLOWED, "")
+			} else {
+				hint.SetState(algebra.HINT_STATE_NOT_FOLLOWED, algebra.HINT_INDEX_NOT_FOLLOWED)
+			}
+		}
+	}
+}
+
+func followsIndexHint(hint *algebra.OptimHint, used []datastore.Index) bool {
+	if used == nil {
+		return false
+	}
+
+	matches := 0
+	for _, index := range used {
+		if matchesIndexHint(hint, index) {
+			matches++
+		}
+	}
+
+	if hint.Type() == algebra.HINT_NO_INDEX {
+		return matches == 0
+	}
+	return matches > 0
+}
+
+func matchesIndexHint(hint *algebra.OptimHint, index datastore.Index) bool {
+	names := hint.Indexes()
+	if len(names) == 0 {
+		switch hint.Type() {
+		case algebra.HINT_INDEX_FTS:
+			return index.Type() == datastore.FTS
+		case algebra.HINT_NO_INDEX:
+			return !index.IsPrimary()
+		default:
+			return index.Type() == datastore.GSI
+		}
+	}
+	for _, name := range names {
+		if name == index.Name() {
+			return true
+		}
+	}
+	return false
+}
+
+func (this *builder) validOptimHints(alias string, hintTypes ...algebra.HintType) []*algebra.OptimHint {
+	if this.optimHints == nil {
+		return nil
+	}
+	var rv []*algebra.OptimHint
+	for _, hint := range this.optimHints.Hints() {
+		if hint.State() != algebra.HINT_STATE_UNKNOWN || hint.Keyspace() != alias {
+			continue
+		}
+		for _, hintType := range hintTypes {
+			if hint.Type() == hintType {
+				rv = append(rv, hint)
+			}
+		}
+	}
+	return rv
+}
+
+/*
+Index references equivalent to the INDEX and INDEX_FTS hints on the
+keyspace, as if given with USE INDEX.
+*/
+func (this *builder) optimHintIndexes(node *algebra.KeyspaceTerm) algebra.IndexRefs {
+	var rv algebra.IndexRefs
+	for _, hint := range this.validOptimHints(node.Alias(), algebra.HINT_INDEX, algebra.HINT_INDEX_FTS) {
+		using := datastore.DEFAULT
+		if hint.Type() == algebra.HINT_INDEX_FTS {
+			using = datastore.FTS
+		}
+		if len(hint.Indexes()) == 0 {
+			rv = append(rv, algebra.NewIndexRef("", using))
+		}
+		for _, name := range hint.Indexes() {
+			rv = append(rv, algebra.NewIndexRef(name, using))
+		}
+	}
+	return rv
+}
+
+/*
+Remove the indexes excluded by the NO_INDEX hints on the keyspace.
+The indexes are filtered in place.
+*/
+func (this *builder) skipOptimHintIndexes(alias string, indexes []datastore.Index) []datastore.Index {
+	hints := this.validOptimHints(alias, algebra.HINT_NO_INDEX)
+	if len(hints) == 0 {
+		return indexes
+	}
+
+	rv := indexes[:0]
+	for _, index := range indexes {
+		skip := false
+		for _, hint := range hints {
+			if matchesIndexHint(hint, index) {
+				skip = true
+				break
+			}
+		}
+		if !skip {
+			rv = append(rv, index)
+		}
+	}
+	return rv
+}
+
+/*
+True if ORDERED is given, in which case joins are not reordered.
+*/
+func (this *builder) orderedOptimHint() bool {
+	if this.optimHints == nil {
+		return false
+	}
+	for _, hint := range this.optimHints.Hints() {
+		if hint.Type() == algebra.HINT_ORDERED && hint.State() == algebra.HINT_STATE_UNKNOWN {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+Remember the indexes used by the scan of a keyspace, to tell whether
+its index hints were followed.
+*/
+func (this *builder) recordOptimHintScan(alias string, op plan.Operator) {
+	if this.hintScans == nil {
+		return
+	}
+	this.hintScans[alias] = scanIndexes(op, make([]datastore.Index, 0, 2))
+}
+
+func scanIndexes(op plan.Operator, indexes []datastore.Index) []datastore.Index {
+	switch op := op.(type) {
+	case *plan.IntersectScan:
+		for _, scan := range op.Scans() {
+			indexes = scanIndexes(scan, indexes)
+		}
+	case *plan.OrderedIntersectScan:
+		for _, scan := range op.Scans() {
+			indexes = scanIndexes(scan, indexes)
+		}
+	case *plan.UnionScan:
+		for _, scan := range op.Scans() {
+			indexes = scanIndexes(scan, indexes)
+		}
+	case *plan.DistinctScan:
+		indexes = scanIndexes(op.Scan(), indexes)
+	case interface{ GetIndex() datastore.Index }:
+		if index := op.GetIndex(); index != nil {
+			indexes = append(indexes, index)
+		}
+	}
+	return indexes
+}
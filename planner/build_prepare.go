@@ -63,6 +63,7 @@ func (this *builder) VisitPrepare(stmt *algebra.Prepare) (interface{}, error) {
 	if err != nil {
 		return nil, err
 	}
+	prep = planCache.ApplyBaseline(stmt.Statement(), text, prep, this.namespace, this.context, name)
 
 	prep.SetName(name)
 	prep.SetText(text)
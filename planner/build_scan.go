@@ -51,12 +51,14 @@ func (this *builder) selectScan(keyspace datastore.Keyspace, node *algebra.Keysp
 	}
 
 	if secondary != nil {
+		this.recordOptimHintScan(node.Alias(), secondary)
 		return secondary, nil
 	}
 	if node.IsInCorrSubq() {
 		return nil, errors.NewSubqueryMissingIndexError(node.Alias())
 	}
 	if primary != nil {
+		this.recordOptimHintScan(node.Alias(), primary)
 		return primary, nil
 	}
 
@@ -73,14 +75,19 @@ func (this *builder) buildScan(keyspace datastore.Keyspace, node *algebra.Keyspa
 	if this.indexAdvisor {
 		virtualIndexes = this.getIdxCandidates()
 	}
-	if len(node.Indexes()) > 0 || this.context.UseFts() {
-		hints, err = allHints(keyspace, node.Indexes(), virtualIndexes, this.context.IndexApiVersion(), this.context.UseFts())
+	indexes := node.Indexes()
+	if len(indexes) == 0 {
+		indexes = this.optimHintIndexes(node)
+	}
+	if len(indexes) > 0 || this.context.UseFts() {
+		hints, err = allHints(keyspace, indexes, virtualIndexes, this.context.IndexApiVersion(), this.context.UseFts())
 		if nil != hints {
 			defer _INDEX_POOL.Put(hints)
 		}
 		if err != nil {
 			return
 		}
+		hints = this.skipOptimHintIndexes(node.Alias(), hints)
 	}
 
 	baseKeyspace, ok := this.baseKeyspaces[node.Alias()]
@@ -220,6 +227,7 @@ func (this *builder) buildPredicateScan(keyspace datastore.Keyspace, node *algeb
 	if err != nil {
 		return
 	}
+	others = this.skipOptimHintIndexes(node.Alias(), others)
 
 	secondary, primary, err = this.buildSubsetScan(keyspace, node,
 		baseKeyspace, id, others, primaryKey, formalizer, false)
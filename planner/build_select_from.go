@@ -120,7 +120,7 @@ func (this *builder) visitFrom(node *algebra.Subselect, group *algebra.Group) er
 
 		var op plan.Operator
 
-		if this.useCBO && this.context.Optimizer() != nil {
+		if this.useCBO && this.context.Optimizer() != nil && !this.orderedOptimHint() {
 			optimizer := this.context.Optimizer()
 			optimizer.Initialize(this.Copy())
 			op, err = optimizer.OptimizeQueryBlock(node.From())
@@ -35,6 +35,8 @@ func (this *builder) VisitSubselect(node *algebra.Subselect) (interface{}, error
 	prevBuilderFlags := this.builderFlags
 	prevMaxParallelism := this.maxParallelism
 	prevLastOp := this.lastOp
+	prevOptimHints := this.optimHints
+	prevHintScans := this.hintScans
 
 	indexPushDowns := this.storeIndexPushDowns()
 
@@ -52,6 +54,8 @@ func (this *builder) VisitSubselect(node *algebra.Subselect) (interface{}, error
 		this.builderFlags = prevBuilderFlags
 		this.maxParallelism = prevMaxParallelism
 		this.lastOp = prevLastOp
+		this.optimHints = prevOptimHints
+		this.hintScans = prevHintScans
 		this.restoreIndexPushDowns(indexPushDowns, false)
 	}()
 
@@ -229,10 +233,13 @@ func (this *builder) VisitSubselect(node *algebra.Subselect) (interface{}, error
 	this.setIndexGroupAggs(group, aggs, node.Let())
 	this.extractLetGroupProjOrder(nil, group, nil, nil, aggs)
 
+	restoreHints := this.beginOptimHints(node)
 	err = this.visitFrom(node, group)
+	restoreHints()
 	if err != nil {
 		return nil, err
 	}
+	this.endOptimHints(node)
 
 	if len(this.coveringScans) > 0 {
 		err = this.coverExpressions()
@@ -0,0 +1,29 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package planner
+
+import (
+	"github.com/couchbase/query/algebra"
+	"github.com/couchbase/query/plan"
+)
+
+func (this *builder) VisitSetVariable(stmt *algebra.SetVariable) (interface{}, error) {
+	this.maxParallelism = 1
+	return plan.NewSequence(plan.NewSetVariable(stmt)), nil
+}
+
+func (this *builder) VisitCreateTemporaryCollection(stmt *algebra.CreateTemporaryCollection) (interface{}, error) {
+	this.maxParallelism = 1
+	return plan.NewSequence(plan.NewCreateTemporaryCollection(stmt)), nil
+}
+
+func (this *builder) VisitDropTemporaryCollection(stmt *algebra.DropTemporaryCollection) (interface{}, error) {
+	this.maxParallelism = 1
+	return plan.NewSequence(plan.NewDropTemporaryCollection(stmt)), nil
+}
@@ -9,6 +9,7 @@
 package planner
 
 import (
+	"github.com/couchbase/query/algebra"
 	"github.com/couchbase/query/errors"
 	"github.com/couchbase/query/plan"
 )
@@ -29,6 +30,10 @@ type PlanCache interface {
 
 	// Predefined prepare name
 	IsPredefinedPrepareName(name string) bool
+
+	// plan baselines may pin a different plan for the prepared statement text
+	ApplyBaseline(stmt algebra.Statement, text string, prepared *plan.Prepared, namespace string,
+		context *PrepareContext, name string) *plan.Prepared
 }
 
 var planCache PlanCache
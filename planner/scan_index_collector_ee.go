@@ -502,6 +502,19 @@ func (this *scanIdxCol) VisitSavepoint(op *plan.Savepoint) (interface{}, error)
 	return nil, nil
 }
 
+// Sessions
+func (this *scanIdxCol) VisitSetVariable(op *plan.SetVariable) (interface{}, error) {
+	return nil, nil
+}
+
+func (this *scanIdxCol) VisitCreateTemporaryCollection(op *plan.CreateTemporaryCollection) (interface{}, error) {
+	return nil, nil
+}
+
+func (this *scanIdxCol) VisitDropTemporaryCollection(op *plan.DropTemporaryCollection) (interface{}, error) {
+	return nil, nil
+}
+
 func formalizeIndexKeys(alias string, keys expression.Expressions) expression.Expressions {
 	formalizer := expression.NewSelfFormalizer(alias, nil)
 	keys = keys.Copy()
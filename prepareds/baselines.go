@@ -0,0 +1,515 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package prepareds
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	atomic "github.com/couchbase/go-couchbase/platform"
+	json "github.com/couchbase/go_json"
+	"github.com/couchbase/query/algebra"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/logging"
+	"github.com/couchbase/query/parser/n1ql"
+	"github.com/couchbase/query/plan"
+	"github.com/couchbase/query/planner"
+	"github.com/couchbase/query/util"
+)
+
+/*
+Plan baselines pin the plans of SELECT statements, so that plans do not
+change silently when indexes are added or statistics are refreshed.
+
+A baseline is keyed by the normalized statement text and holds the
+accepted plans for the statement, together with any newer plan that the
+planner has since generated, as an unaccepted candidate.
+When baselines are in use, an accepted plan is forced whenever a plan is
+built for the statement, be it ad hoc, prepared, auto prepared or prepared
+again, until a candidate is accepted or the accepted plan is rejected, at
+which point prepared statements are built again.
+In capture mode, statements that do not have a baseline get one with the
+current plan accepted.
+
+Plans hold the literals of the statement they are built for, so only
+statements without literals have baselines: parameterize statements to
+pin their plans. Plans built with the values of the request arguments
+can use the accepted plan, but are not recorded, and neither are the plans
+of transactions with pending mutations, which depend on those.
+*/
+
+// plan baseline modes
+const (
+	BASELINES_OFF = int32(iota)
+	BASELINES_USE
+	BASELINES_CAPTURE
+)
+
+var baselineModes = []string{"off", "use", "capture"}
+
+// baselines beyond the limit are evicted least recently used first, and
+// plans beyond the limit oldest unaccepted plan first
+const (
+	_BASELINES_LIMIT      = 16384
+	_BASELINE_PLANS_LIMIT = 16
+)
+
+// baseline plan states
+const (
+	BASELINE_PLAN_CANDIDATE = "candidate"
+	BASELINE_PLAN_ACCEPTED  = "accepted"
+	BASELINE_PLAN_REJECTED  = "rejected"
+)
+
+type Baseline struct {
+	sync.Mutex
+	name         string
+	statement    string
+	namespace    string
+	queryContext string
+	created      time.Time
+	plans        []*BaselinePlan
+	prepareds    map[string]bool // prepared plans to evict on change, auto prepared or not
+}
+
+type BaselinePlan struct {
+	id       string
+	state    string
+	plan     []byte
+	created  time.Time
+	accepted time.Time
+	lastUse  time.Time
+	uses     int32
+}
+
+type baselineCache struct {
+	cache *util.GenCache
+	mode  int32
+}
+
+var baselines = &baselineCache{cache: util.NewGenCache(_BASELINES_LIMIT)}
+
+func BaselinesMode() string {
+	return baselineModes[atomic.LoadInt32(&baselines.mode)]
+}
+
+func ValidBaselinesMode(mode string) bool {
+	for _, m := range baselineModes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+func BaselinesOn() bool {
+	return atomic.LoadInt32(&baselines.mode) != BASELINES_OFF
+}
+
+func BaselinesSetMode(mode string) errors.Error {
+	for i, m := range baselineModes {
+		if m == mode {
+			atomic.StoreInt32(&baselines.mode, int32(i))
+			return nil
+		}
+	}
+	return errors.NewPlanBaselineModeError(mode)
+}
+
+// different feature controls and index API version generate different plans,
+// hence different baselines
+func getBaselineName(text, namespace string, context *planner.PrepareContext) string {
+	var buf [_REALM_SIZE]byte
+	realm := buf[0:0:_REALM_SIZE]
+	realm = strconv.AppendInt(realm, int64(context.IndexApiVersion()), 16)
+	realm = append(realm, '_')
+	realm = strconv.AppendInt(realm, int64(context.FeatureControls()), 16)
+	realm = append(realm, '_')
+	realm = strconv.AppendBool(realm, context.UseFts())
+	realm = append(realm, '_')
+	realm = strconv.AppendBool(realm, context.UseCBO())
+	realm = append(realm, '_')
+	realm = append(realm, namespace...)
+	realm = append(realm, '_')
+	realm = append(realm, context.QueryContext()...)
+	name, err := util.UUIDV5(string(realm), text)
+
+	// this never happens
+	if err != nil {
+		return ""
+	}
+	return name
+}
+
+/*
+Returns the plan to execute for the statement: the newly built plan if
+baselines are off, the statement is not eligible or the plan is accepted,
+and the accepted plan otherwise.
+The text is the normalized statement, or empty if the statement has
+literals.
+Newly built plans that are not accepted are recorded as candidates.
+*/
+func ApplyBaseline(stmt algebra.Statement, text string, prepared *plan.Prepared, namespace string,
+	context *planner.PrepareContext, autoPrepareName string) *plan.Prepared {
+	return applyBaseline(stmt, text, prepared, namespace, context, autoPrepareName, true)
+}
+
+// preparedCache implements planner.PlanCache
+func (this *preparedCache) ApplyBaseline(stmt algebra.Statement, text string, prepared *plan.Prepared,
+	namespace string, context *planner.PrepareContext, name string) *plan.Prepared {
+	if atomic.LoadInt32(&baselines.mode) == BASELINES_OFF {
+		return prepared
+	}
+	return applyBaseline(stmt, preparedBaselineText(text, namespace, context.QueryContext()), prepared,
+		namespace, context, name, false)
+}
+
+// the normalized text of a PREPARE statement, as the server has it for ad hoc statements
+func preparedBaselineText(text, namespace, queryContext string) string {
+	stmt, err := n1ql.ParseStatement2(text, namespace, queryContext)
+	if err != nil {
+		return ""
+	}
+	if prepare, ok := stmt.(*algebra.Prepare); ok {
+		stmt = prepare.Statement()
+	}
+	normalized, literals, ok := algebra.NormalizeStatement(stmt, text)
+	if !ok || literals > 0 {
+		return ""
+	}
+	return normalized
+}
+
+func applyBaseline(stmt algebra.Statement, text string, prepared *plan.Prepared, namespace string,
+	context *planner.PrepareContext, preparedName string, auto bool) *plan.Prepared {
+	mode := atomic.LoadInt32(&baselines.mode)
+	if mode == BASELINES_OFF {
+		return prepared
+	}
+
+	// only queries without literals
+	_, ok := stmt.(*algebra.Select)
+	if !ok || text == "" || len(context.DeltaKeyspaces()) > 0 {
+		return prepared
+	}
+	name := getBaselineName(text, namespace, context)
+	if name == "" {
+		return prepared
+	}
+
+	// plans with argument values embedded only hold for those values
+	bound := len(context.NamedArgs()) > 0 || len(context.PositionalArgs()) > 0
+
+	// nothing to do for statements without a baseline, unless capturing
+	var baseline *Baseline
+	if mode != BASELINES_CAPTURE || bound {
+		entry := baselines.cache.Get(name, nil)
+		if entry == nil {
+			return prepared
+		}
+		baseline = entry.(*Baseline)
+	}
+
+	var id string
+	var bytes []byte
+	if !bound {
+		var err error
+
+		id, bytes, err = baselinePlanId(prepared)
+		if err != nil {
+			logging.Infof("Plan baseline encoding failed with %v", err)
+			return prepared
+		}
+	}
+
+	if baseline == nil {
+		now := time.Now()
+		newBaseline := &Baseline{
+			name:         name,
+			statement:    text,
+			namespace:    namespace,
+			queryContext: context.QueryContext(),
+			created:      now,
+			plans: []*BaselinePlan{&BaselinePlan{
+				id:       id,
+				state:    BASELINE_PLAN_ACCEPTED,
+				plan:     bytes,
+				created:  now,
+				accepted: now,
+			}},
+			prepareds: make(map[string]bool),
+		}
+		baseline = newBaseline
+		baselines.cache.Add(newBaseline, name, func(entry interface{}) util.Operation {
+			baseline = entry.(*Baseline)
+			return util.IGNORE
+		})
+	}
+
+	baseline.Lock()
+	defer baseline.Unlock()
+
+	if preparedName != "" {
+		baseline.prepareds[encodeName(preparedName, context.QueryContext())] = auto
+	}
+
+	if !bound {
+		current := baseline.find(id)
+		if current != nil && current.state == BASELINE_PLAN_ACCEPTED {
+			current.use()
+			return prepared
+		}
+		if current == nil {
+			baseline.addCandidate(id, bytes)
+		}
+	}
+
+	var forced *BaselinePlan
+	for _, p := range baseline.plans {
+		if p.state == BASELINE_PLAN_ACCEPTED && (forced == nil || p.accepted.After(forced.accepted)) {
+			forced = p
+		}
+	}
+	if forced == nil {
+		return prepared
+	}
+
+	// decode afresh, as the plan may be amended by the caller
+	pinned, err := unmarshalPrepared(forced.plan, nil, false)
+	if err != nil || !pinned.Verify() {
+		logging.Infof("Plan baseline %v: accepted plan %v is no longer valid", name, forced.id)
+		return prepared
+	}
+	forced.use()
+	return pinned
+}
+
+// plans differing only in optimizer estimates are the same plan
+func baselinePlanId(prepared *plan.Prepared) (string, []byte, error) {
+	bytes, err := prepared.MarshalJSON()
+	if err != nil {
+		return "", nil, err
+	}
+	op, err := json.Marshal(prepared.Operator)
+	if err != nil {
+		return "", nil, err
+	}
+	var ops interface{}
+	err = json.Unmarshal(op, &ops)
+	if err != nil {
+		return "", nil, err
+	}
+	op, err = json.Marshal(stripEstimates(ops))
+	if err != nil {
+		return "", nil, err
+	}
+	id, err := util.UUIDV5("plan_baseline", string(op))
+	return id, bytes, err
+}
+
+func stripEstimates(op interface{}) interface{} {
+	switch op := op.(type) {
+	case map[string]interface{}:
+		delete(op, "optimizer_estimates")
+		for k, v := range op {
+			op[k] = stripEstimates(v)
+		}
+	case []interface{}:
+		for i, v := range op {
+			op[i] = stripEstimates(v)
+		}
+	}
+	return op
+}
+
+func (this *BaselinePlan) use() {
+	atomic.AddInt32(&this.uses, 1)
+	this.lastUse = time.Now()
+}
+
+func (this *Baseline) find(id string) *BaselinePlan {
+	for _, p := range this.plans {
+		if p.id == id {
+			return p
+		}
+	}
+	return nil
+}
+
+// makes room for the candidate by dropping the oldest plan not accepted
+// if all plans are accepted, the candidate is not recorded
+func (this *Baseline) addCandidate(id string, bytes []byte) {
+	if len(this.plans) >= _BASELINE_PLANS_LIMIT {
+		oldest := -1
+		for i, p := range this.plans {
+			if p.state != BASELINE_PLAN_ACCEPTED && (oldest < 0 || p.created.Before(this.plans[oldest].created)) {
+				oldest = i
+			}
+		}
+		if oldest < 0 {
+			return
+		}
+		this.plans = append(this.plans[:oldest], this.plans[oldest+1:]...)
+	}
+	this.plans = append(this.plans, &BaselinePlan{
+		id:      id,
+		state:   BASELINE_PLAN_CANDIDATE,
+		plan:    bytes,
+		created: time.Now(),
+	})
+}
+
+// plans cached for the statement may no longer be the plans to use:
+// auto prepared plans are dropped, and the prepared statements to build
+// again are returned, as that cannot be done with the baseline locked
+func (this *Baseline) evict() []string {
+	var rebuild []string
+	for name, auto := range this.prepareds {
+		if auto {
+			DeletePrepared(name)
+		} else {
+			rebuild = append(rebuild, name)
+		}
+	}
+	this.prepareds = make(map[string]bool)
+	return rebuild
+}
+
+func rebuildPrepareds(names []string) {
+	for _, name := range names {
+		ce := prepareds.get(name, false)
+		if ce == nil {
+			continue
+		}
+		prepared, err := reprepare(ce.Prepared, nil, nil)
+		if err == nil {
+			err = AddPrepared(prepared)
+		}
+		if err != nil {
+			logging.Infof("Plan baseline: prepared statement %v could not be built again: %v", name, err)
+		}
+	}
+}
+
+// a snapshot of the baseline, for system keyspaces and REST endpoints
+func (this *Baseline) Format(withPlans bool) map[string]interface{} {
+	this.Lock()
+	defer this.Unlock()
+
+	rv := map[string]interface{}{
+		"name":      this.name,
+		"statement": this.statement,
+		"namespace": this.namespace,
+		"created":   this.created.String(),
+	}
+	if this.queryContext != "" {
+		rv["queryContext"] = this.queryContext
+	}
+	plans := make([]interface{}, len(this.plans))
+	for i, p := range this.plans {
+		item := map[string]interface{}{
+			"id":      p.id,
+			"state":   p.state,
+			"created": p.created.String(),
+			"uses":    atomic.LoadInt32(&p.uses),
+		}
+		if !p.accepted.IsZero() {
+			item["accepted"] = p.accepted.String()
+		}
+		if !p.lastUse.IsZero() {
+			item["lastUse"] = p.lastUse.String()
+		}
+		if withPlans {
+			op, err := json.FindKey(p.plan, "operator")
+			if op != nil && err == nil {
+				item["plan"] = json.RawMessage(op)
+			}
+		}
+		plans[i] = item
+	}
+	rv["plans"] = plans
+	return rv
+}
+
+// Plan baselines and system keyspaces
+func CountBaselines() int {
+	return baselines.cache.Size()
+}
+
+func NameBaselines() []string {
+	return baselines.cache.Names()
+}
+
+func BaselinesForeach(nonBlocking func(string, *Baseline) bool,
+	blocking func() bool) {
+	dummyF := func(id string, r interface{}) bool {
+		return nonBlocking(id, r.(*Baseline))
+	}
+	baselines.cache.ForEach(dummyF, blocking)
+}
+
+func BaselineDo(name string, f func(*Baseline)) {
+	var process func(interface{}) = nil
+
+	if f != nil {
+		process = func(entry interface{}) {
+			f(entry.(*Baseline))
+		}
+	}
+	_ = baselines.cache.Get(name, process)
+}
+
+func AcceptBaselinePlan(name, id string) errors.Error {
+	return setBaselinePlanState(name, id, BASELINE_PLAN_ACCEPTED)
+}
+
+func RejectBaselinePlan(name, id string) errors.Error {
+	return setBaselinePlanState(name, id, BASELINE_PLAN_REJECTED)
+}
+
+func setBaselinePlanState(name, id, state string) errors.Error {
+	entry := baselines.cache.Get(name, nil)
+	if entry == nil {
+		return errors.NewNoSuchPlanBaselineError(name)
+	}
+	baseline := entry.(*Baseline)
+	baseline.Lock()
+
+	p := baseline.find(id)
+	if p == nil {
+		baseline.Unlock()
+		return errors.NewNoSuchBaselinePlanError(name, id)
+	}
+	p.state = state
+	if state == BASELINE_PLAN_ACCEPTED {
+		p.accepted = time.Now()
+	} else {
+		p.accepted = time.Time{}
+	}
+	rebuild := baseline.evict()
+	baseline.Unlock()
+	rebuildPrepareds(rebuild)
+	return nil
+}
+
+func DeleteBaseline(name string) errors.Error {
+	var rebuild []string
+
+	if baselines.cache.Delete(name, func(entry interface{}) {
+		baseline := entry.(*Baseline)
+		baseline.Lock()
+		rebuild = baseline.evict()
+		baseline.Unlock()
+	}) {
+		rebuildPrepareds(rebuild)
+		return nil
+	}
+	return errors.NewNoSuchPlanBaselineError(name)
+}
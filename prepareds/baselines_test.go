@@ -0,0 +1,146 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package prepareds
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/couchbase/query/algebra"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/plan"
+	"github.com/couchbase/query/planner"
+	"github.com/couchbase/query/util"
+	"github.com/couchbase/query/value"
+)
+
+func testBaselineStatement(n int) *algebra.Select {
+	projection := algebra.NewProjection(false,
+		algebra.ResultTerms{algebra.NewResultTerm(expression.NewConstant(n), false, "")})
+	return algebra.NewSelect(algebra.NewSubselect(nil, nil, nil, nil, nil, nil, projection), nil, nil, nil)
+}
+
+func TestApplyBaseline(t *testing.T) {
+	baselines.cache = util.NewGenCache(_BASELINES_LIMIT)
+	defer BaselinesSetMode("off")
+
+	context := &planner.PrepareContext{}
+	stmt := testBaselineStatement(1)
+	text := "select $1"
+	prepared := plan.NewPrepared(plan.NewSequence(), nil, nil)
+	other := plan.NewPrepared(plan.NewSequence(plan.NewDiscard(0, 0, 0, 0)), nil, nil)
+
+	// statements without a baseline run as planned
+	BaselinesSetMode("use")
+	if ApplyBaseline(stmt, text, other, "default", context, "") != other || CountBaselines() != 0 {
+		t.Errorf("Expected the plan to run as is")
+	}
+
+	// as do statements with literals, which have no text
+	BaselinesSetMode("capture")
+	if ApplyBaseline(stmt, "", prepared, "default", context, "") != prepared || CountBaselines() != 0 {
+		t.Errorf("Expected no baseline for a statement with literals")
+	}
+
+	if ApplyBaseline(stmt, text, prepared, "default", context, "") != prepared || CountBaselines() != 1 {
+		t.Fatalf("Expected a baseline to be captured")
+	}
+
+	// the accepted plan is forced, the new plan is a candidate
+	BaselinesSetMode("use")
+	pinned := ApplyBaseline(stmt, text, other, "default", context, "")
+	if pinned == other || pinned == nil {
+		t.Errorf("Expected the accepted plan to be forced")
+	}
+	name := getBaselineName(text, "default", context)
+	BaselineDo(name, func(baseline *Baseline) {
+		if len(baseline.plans) != 2 || baseline.plans[1].state != BASELINE_PLAN_CANDIDATE {
+			t.Errorf("Expected the new plan to be recorded as a candidate")
+		}
+		if baseline.plans[0].uses != 1 {
+			t.Errorf("Expected the accepted plan to be used once, got %v", baseline.plans[0].uses)
+		}
+	})
+
+	// plans holding argument values use the accepted plan, but are not recorded
+	bound := &planner.PrepareContext{}
+	bound.SetPositionalArgs(value.Values{value.NewValue(1)})
+	discard := plan.NewPrepared(plan.NewSequence(plan.NewDiscard(0, 0, 0, 1)), nil, nil)
+	if pinned = ApplyBaseline(stmt, text, discard, "default", bound, ""); pinned == discard || pinned == nil {
+		t.Errorf("Expected the accepted plan to be forced with arguments")
+	}
+	BaselineDo(name, func(baseline *Baseline) {
+		if len(baseline.plans) != 2 {
+			t.Errorf("Expected no candidate for a plan with arguments, got %v plans", len(baseline.plans))
+		}
+	})
+
+	// plans of transactions with pending mutations are left alone
+	delta := &planner.PrepareContext{}
+	delta.SetDeltaKeyspaces(map[string]bool{"default:b": true})
+	if ApplyBaseline(stmt, text, other, "default", delta, "") != other {
+		t.Errorf("Expected the plan of a transaction with mutations to run as is")
+	}
+
+	if err := DeleteBaseline(name); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+}
+
+func TestPreparedBaselineText(t *testing.T) {
+	text := preparedBaselineText("PREPARE p1 FROM SELECT name FROM b WHERE city = $1", "default", "")
+	if text == "" || strings.Contains(strings.ToUpper(text), "PREPARE") {
+		t.Errorf("Expected the prepared statement to be normalized, got %q", text)
+	}
+	if other := preparedBaselineText("prepare p2 as select name from b where city = $1", "default", ""); other != text {
+		t.Errorf("Expected the same text for the same statement, got %q and %q", text, other)
+	}
+
+	// plans of statements with literals cannot be shared
+	if text = preparedBaselineText("PREPARE p1 FROM SELECT name FROM b WHERE city = 'Paris'", "default", ""); text != "" {
+		t.Errorf("Expected no text for a statement with literals, got %q", text)
+	}
+}
+
+func TestBaselineCandidates(t *testing.T) {
+	now := time.Now()
+	baseline := &Baseline{}
+	baseline.plans = append(baseline.plans, &BaselinePlan{id: "accepted", state: BASELINE_PLAN_ACCEPTED,
+		created: now.Add(-time.Hour)})
+	baseline.plans = append(baseline.plans, &BaselinePlan{id: "rejected", state: BASELINE_PLAN_REJECTED,
+		created: now.Add(-time.Minute)})
+	for i := 2; i < _BASELINE_PLANS_LIMIT; i++ {
+		baseline.plans = append(baseline.plans, &BaselinePlan{state: BASELINE_PLAN_CANDIDATE, created: now})
+	}
+
+	// the oldest plan that is not accepted goes first
+	baseline.addCandidate("new", nil)
+	if len(baseline.plans) != _BASELINE_PLANS_LIMIT {
+		t.Fatalf("Expected %v plans, got %v", _BASELINE_PLANS_LIMIT, len(baseline.plans))
+	}
+	if baseline.find("accepted") == nil || baseline.find("rejected") != nil || baseline.find("new") == nil {
+		t.Errorf("Expected the rejected plan to be dropped")
+	}
+
+	// accepted plans are never dropped
+	for _, p := range baseline.plans {
+		p.state = BASELINE_PLAN_ACCEPTED
+	}
+	baseline.addCandidate("newer", nil)
+	if len(baseline.plans) != _BASELINE_PLANS_LIMIT || baseline.find("newer") != nil {
+		t.Errorf("Expected the candidate not to be recorded")
+	}
+}
+
+func TestBaselinesLimit(t *testing.T) {
+	if baselines.cache.Limit() != _BASELINES_LIMIT {
+		t.Errorf("Expected baselines to be limited to %v, got %v", _BASELINES_LIMIT, baselines.cache.Limit())
+	}
+}
@@ -764,6 +764,8 @@ func reprepare(prepared *plan.Prepared, deltaKeyspaces map[string]bool, phaseTim
 	if err != nil {
 		return nil, errors.NewReprepareError(err)
 	}
+	pl = prepareds.ApplyBaseline(stmt.(*algebra.Prepare).Statement(), prepared.Text(), pl, prepared.Namespace(),
+		&prepContext, prepared.Name())
 
 	pl.SetName(prepared.Name())
 	pl.SetText(prepared.Text())
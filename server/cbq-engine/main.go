@@ -27,6 +27,7 @@ import (
 	"github.com/couchbase/query/audit"
 	config_resolver "github.com/couchbase/query/clustering/resolver"
 	datastore_package "github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/datastore/remote"
 	"github.com/couchbase/query/datastore/resolver"
 	"github.com/couchbase/query/datastore/system"
 	"github.com/couchbase/query/functions"
@@ -38,6 +39,8 @@ import (
 	server_package "github.com/couchbase/query/server"
 	control "github.com/couchbase/query/server/control/couchbase"
 	"github.com/couchbase/query/server/http"
+	"github.com/couchbase/query/sessions"
+	"github.com/couchbase/query/tracing"
 	"github.com/couchbase/query/util"
 )
 
@@ -54,15 +57,28 @@ const (
 	_DEF_FUNCTIONS_LIMIT        = 16384
 	_DEF_DICTIONARY_CACHE_LIMIT = 16384
 	_DEF_TASKS_LIMIT            = 16384
+	_DEF_STATEMENT_STATS_LIMIT  = server_package.STATEMENT_STATS_LIMIT_DEFAULT
 	_DEF_MEMORY_QUOTA           = 0
+	_SESSION_CLEANUP            = 30 * time.Second
 )
 
 var DATASTORE = flag.String("datastore", "", "Datastore address (http://URL or dir:PATH or mock:)")
+var REMOTES = flag.String("remotes", "", "Remote query services mounted as namespaces (name=http://URL,...)")
 var CONFIGSTORE = flag.String("configstore", "stub:", "Configuration store address (http://URL or stub:)")
 var ACCTSTORE = flag.String("acctstore", "gometrics:", "Accounting store address (http://URL or stub:)")
 var NAMESPACE = flag.String("namespace", "default", "Default namespace")
 var TIMEOUT = flag.Duration("timeout", 0*time.Second, "Server execution timeout, e.g. 500ms or 2s; use zero or negative value to disable")
 var TXTIMEOUT = flag.Duration("txtimeout", 0*time.Second, "Maximum Transaction timeout, e.g. 2m or 2s; use zero or negative to use request level value")
+var ASYNC_DIR = flag.String("async-dir", "", "Directory for the results of asynchronous requests; defaults to the temporary directory")
+var RESULT_CACHE_MEMORY = flag.Uint64("result-cache-memory", 0, "Memory available to the result cache in MB; zero disables the cache")
+var RESULT_CACHE_TTL = flag.Duration("result-cache-ttl", server_package.RESULT_CACHE_TTL_DEFAULT, "How long cached results are served for, e.g. 10s or 1m")
+var SESSION_MEMORY_QUOTA = flag.Uint64("session-memory-quota", sessions.DEFAULT_MEMORY_QUOTA, "Memory available to the temporary collections of each session in MB; zero means no limit")
+var TRACE_COLLECTOR = flag.String("trace-collector", "", "OTLP/HTTP collector for the spans of traced requests, e.g. http://localhost:4318")
+var ASYNC_RETENTION = flag.Duration("async-retention", server_package.ASYNC_RETENTION_DEFAULT, "How long the results of asynchronous requests are kept, e.g. 30m or 2h")
+var ASYNC_LIMIT = flag.Int("async-limit", server_package.ASYNC_LIMIT_DEFAULT, "Maximum number of asynchronous requests whose results are kept")
+var ASYNC_QUOTA = flag.Uint64("async-quota", server_package.ASYNC_QUOTA_DEFAULT, "Disk space available to the results of asynchronous requests in MB")
+var MAX_CURSOR_TIMEOUT = flag.Duration("max-cursor-timeout", server_package.MAX_CURSOR_TIMEOUT_DEFAULT, "Longest a paged request can be left idle between pages, e.g. 1m or 10m")
+var MAX_CURSORS = flag.Int("max-cursors", 0, "Maximum number of paged requests open at a time; zero means a quarter of the servicers")
 var READONLY = flag.Bool("readonly", false, "Read-only mode")
 var SIGNATURE = flag.Bool("signature", true, "Whether to provide signature")
 var METRICS = flag.Bool("metrics", true, "Whether to provide metrics")
@@ -101,9 +117,11 @@ var MEM_PROFILE = flag.String("memprofile", "", "write memory profile to this fi
 // Monitoring API
 var COMPLETED_THRESHOLD = flag.Int("completed-threshold", _DEF_COMPLETED_THRESHOLD, "cache completed query lasting longer than this many milliseconds")
 var COMPLETED_LIMIT = flag.Int("completed-limit", _DEF_COMPLETED_LIMIT, "maximum number of completed requests")
+var STATEMENT_STATS_LIMIT = flag.Int("statement-stats-limit", _DEF_STATEMENT_STATS_LIMIT, "maximum number of statements to keep statistics for")
 
 var PREPARED_LIMIT = flag.Int("prepared-limit", _DEF_PREPARED_LIMIT, "maximum number of prepared statements")
 var AUTO_PREPARE = flag.Bool("auto-prepare", false, "Silently prepare ad hoc statements if possible")
+var PLAN_BASELINES = flag.String("plan-baselines", "off", "Plan baselines mode: off, use or capture")
 
 var FUNCTIONS_LIMIT = flag.Int("functions-limit", _DEF_FUNCTIONS_LIMIT, "maximum number of cached functions")
 var TASKS_LIMIT = flag.Int("tasks-limit", _DEF_TASKS_LIMIT, "maximum number of cached tasks")
@@ -218,6 +236,18 @@ func main() {
 		logging.Errorf("Shutting down.")
 		os.Exit(1)
 	}
+
+	if *REMOTES != "" {
+		remotes, err := remote.ParseRemotes(*REMOTES)
+		if err == nil {
+			datastore, err = remote.NewDatastore(datastore, remotes)
+		}
+		if err != nil {
+			logging.Errorf("%v", err.Error())
+			logging.Errorf("Shutting down.")
+			os.Exit(1)
+		}
+	}
 	datastore_package.SetDatastore(datastore)
 
 	nullSecurityConfig := &datastore_package.ConnectionSecurityConfig{}
@@ -264,6 +294,7 @@ func main() {
 
 	// Start the completed requests log
 	server_package.RequestsInit(*COMPLETED_THRESHOLD, *COMPLETED_LIMIT)
+	server_package.StatementStatsSetLimit(*STATEMENT_STATS_LIMIT)
 
 	// Initialized the prepared statement cache
 	if *PREPARED_LIMIT <= 0 {
@@ -271,6 +302,9 @@ func main() {
 		*PREPARED_LIMIT = _DEF_PREPARED_LIMIT
 	}
 	prepareds.PreparedsInit(*PREPARED_LIMIT)
+	if err := prepareds.BaselinesSetMode(*PLAN_BASELINES); err != nil {
+		logging.Errorf("Ignoring invalid plan baselines mode: %v", *PLAN_BASELINES)
+	}
 	functions.FunctionsSetLimit(*FUNCTIONS_LIMIT)
 	scheduler.SchedulerSetLimit(*TASKS_LIMIT)
 
@@ -300,6 +334,7 @@ func main() {
 
 	datastore_package.SetSystemstore(server.Systemstore())
 	prepareds.PreparedsReprepareInit(datastore, sys)
+	sessions.SessionCacheInit(_SESSION_CLEANUP)
 
 	server.SetCpuProfile(*CPU_PROFILE)
 	server.SetKeepAlive(*KEEP_ALIVE_LENGTH)
@@ -312,6 +347,18 @@ func main() {
 	server.SetMaxIndexAPI(*MAX_INDEX_API)
 	server.SetAutoPrepare(*AUTO_PREPARE)
 	server.SetTxTimeout(*TXTIMEOUT)
+	server.SetAsyncDir(*ASYNC_DIR)
+	server.SetAsyncRetention(*ASYNC_RETENTION)
+	server.SetAsyncLimit(*ASYNC_LIMIT)
+	server.SetAsyncQuota(*ASYNC_QUOTA)
+	server.SetMaxCursorTimeout(*MAX_CURSOR_TIMEOUT)
+	server.SetMaxCursors(*MAX_CURSORS)
+	server_package.SetResultCacheMemory(*RESULT_CACHE_MEMORY)
+	server_package.SetResultCacheTTL(*RESULT_CACHE_TTL)
+	sessions.SetMemoryQuota(*SESSION_MEMORY_QUOTA)
+	if !tracing.SetCollector(*TRACE_COLLECTOR) {
+		logging.Errorf("Ignoring invalid trace collector: %v", *TRACE_COLLECTOR)
+	}
 	if *ENTERPRISE {
 		util.SetN1qlFeatureControl(*N1QL_FEAT_CTRL)
 		util.SetUseCBO(util.DEF_USE_CBO)
@@ -14,6 +14,8 @@ import (
 	"github.com/couchbase/query/algebra"
 	"github.com/couchbase/query/errors"
 	"github.com/couchbase/query/logging"
+	"github.com/couchbase/query/prepareds"
+	"github.com/couchbase/query/tracing"
 )
 
 const (
@@ -54,6 +56,20 @@ const (
 	CLEANUPCLIENTATTEMPTS = "cleanupclientattempts"
 	CLEANUPLOSTATTEMPTS   = "cleanuplostattempts"
 	GCPERCENT             = "gc-percent"
+	PLANBASELINES         = "plan-baselines"
+	WORKLOADGROUPS        = "workload-groups"
+	ASYNCDIR              = "async-dir"
+	ASYNCRETENTION        = "async-retention"
+	ASYNCLIMIT            = "async-limit"
+	ASYNCQUOTA            = "async-quota"
+	MAXCURSORTIMEOUT      = "max-cursor-timeout"
+	MAXCURSORS            = "max-cursors"
+	RESULTCACHEMEMORY     = "result-cache-memory"
+	RESULTCACHETTL        = "result-cache-ttl"
+	STMTSTATSLIMIT        = "statement-stats-limit"
+	TRACECOLLECTOR        = "trace-collector"
+	USERLIMITS            = "user-limits"
+	SESSIONMEMORYQUOTA    = "session-memory-quota"
 )
 
 type Checker func(interface{}) (bool, errors.Error)
@@ -86,19 +102,33 @@ var CHECKERS = map[string]Checker{
 	CLEANUPCLIENTATTEMPTS: checkBool,
 	CLEANUPLOSTATTEMPTS:   checkBool,
 	GCPERCENT:             checkNumber,
+	PLANBASELINES:         checkPlanBaselines,
+	WORKLOADGROUPS:        checkWorkloadGroups,
+	ASYNCDIR:              checkString,
+	ASYNCRETENTION:        checkDuration,
+	MAXCURSORTIMEOUT:      checkDuration,
+	RESULTCACHETTL:        checkDuration,
+	STMTSTATSLIMIT:        checkNumber,
+	TRACECOLLECTOR:        checkTraceCollector,
+	USERLIMITS:            checkUserLimits,
 }
 
 var CHECKERS_MIN = map[string]int{
-	KEEPALIVELENGTH: KEEP_ALIVE_MIN,
-	CMPPUSH:         2,
-	CMPPOP:          2,
-	SERVICERS:       1,
-	PLUSSERVICERS:   1,
-	PRPLIMIT:        2,
-	FUNCLIMIT:       2,
-	TASKLIMIT:       2,
-	MEMORYQUOTA:     0,
-	NUMATRS:         2,
+	KEEPALIVELENGTH:    KEEP_ALIVE_MIN,
+	CMPPUSH:            2,
+	CMPPOP:             2,
+	SERVICERS:          1,
+	PLUSSERVICERS:      1,
+	PRPLIMIT:           2,
+	FUNCLIMIT:          2,
+	TASKLIMIT:          2,
+	MEMORYQUOTA:        0,
+	NUMATRS:            2,
+	RESULTCACHEMEMORY:  0,
+	MAXCURSORS:         0,
+	ASYNCLIMIT:         0,
+	ASYNCQUOTA:         0,
+	SESSIONMEMORYQUOTA: 0,
 }
 
 func checkBool(val interface{}) (bool, errors.Error) {
@@ -202,6 +232,25 @@ func checkLogLevel(val interface{}) (bool, errors.Error) {
 	return ok, nil
 }
 
+func checkPlanBaselines(val interface{}) (bool, errors.Error) {
+	mode, ok := val.(string)
+	if !ok {
+		return false, nil
+	}
+	if !prepareds.ValidBaselinesMode(mode) {
+		return false, errors.NewPlanBaselineModeError(mode)
+	}
+	return true, nil
+}
+
+func checkTraceCollector(val interface{}) (bool, errors.Error) {
+	s, ok := val.(string)
+	if ok && s != "" && !tracing.ValidCollector(s) {
+		return false, errors.NewAdminSettingTypeError(TRACECOLLECTOR, val)
+	}
+	return ok, nil
+}
+
 func checkPath(val interface{}) (bool, errors.Error) {
 	s, ok := val.(string)
 	if ok && s != "" {
@@ -74,6 +74,7 @@ type RequestLogEntry struct {
 	Users                    string
 	RemoteAddr               string
 	UserAgent                string
+	AsyncHandle              string
 	Tag                      string
 }
 
@@ -611,6 +612,10 @@ func LogRequest(request_time, service_time, transactionElapsedTime time.Duration
 	if userAgent != "" {
 		re.UserAgent = userAgent
 	}
+	asyncHandle := request.AsyncHandle()
+	if asyncHandle != "" {
+		re.AsyncHandle = asyncHandle
+	}
 
 	clientId := request.ClientID().String()
 	if clientId != "" {
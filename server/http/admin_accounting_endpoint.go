@@ -47,6 +47,9 @@ const (
 	functionsPrefix       = adminPrefix + "/functions_cache"
 	dictionaryPrefix      = adminPrefix + "/dictionary_cache"
 	tasksPrefix           = adminPrefix + "/tasks_cache"
+	baselinesPrefix       = adminPrefix + "/plan_baselines"
+	resultCachePrefix     = adminPrefix + "/result_cache"
+	statementStatsPrefix  = adminPrefix + "/statement_stats"
 	indexesPrefix         = adminPrefix + "/indexes"
 	expvarsRoute          = "/debug/vars"
 	prometheusLow         = "/_prometheusMetrics"
@@ -86,6 +89,9 @@ func (this *HttpEndpoint) registerAccountingHandlers() {
 	requestHandler := func(w http.ResponseWriter, req *http.Request) {
 		this.wrapAPI(w, req, doActiveRequest)
 	}
+	requestProgressHandler := func(w http.ResponseWriter, req *http.Request) {
+		this.wrapAPI(w, req, doActiveRequestProgress)
+	}
 	completedsHandler := func(w http.ResponseWriter, req *http.Request) {
 		this.wrapAPI(w, req, doCompletedRequests)
 	}
@@ -130,6 +136,37 @@ func (this *HttpEndpoint) registerAccountingHandlers() {
 		this.wrapAPI(w, req, doTasks)
 	}
 
+	baselinesIndexHandler := func(w http.ResponseWriter, req *http.Request) {
+		this.wrapAPI(w, req, doBaselinesIndex)
+	}
+	baselineHandler := func(w http.ResponseWriter, req *http.Request) {
+		this.wrapAPI(w, req, doBaseline)
+	}
+	baselinePlanHandler := func(w http.ResponseWriter, req *http.Request) {
+		this.wrapAPI(w, req, doBaselinePlan)
+	}
+	baselinesHandler := func(w http.ResponseWriter, req *http.Request) {
+		this.wrapAPI(w, req, doBaselines)
+	}
+	resultCacheIndexHandler := func(w http.ResponseWriter, req *http.Request) {
+		this.wrapAPI(w, req, doResultCacheIndex)
+	}
+	resultCacheEntryHandler := func(w http.ResponseWriter, req *http.Request) {
+		this.wrapAPI(w, req, doResultCacheEntry)
+	}
+	resultCacheHandler := func(w http.ResponseWriter, req *http.Request) {
+		this.wrapAPI(w, req, doResultCache)
+	}
+	statementStatsIndexHandler := func(w http.ResponseWriter, req *http.Request) {
+		this.wrapAPI(w, req, doStatementStatsIndex)
+	}
+	statementStatsEntryHandler := func(w http.ResponseWriter, req *http.Request) {
+		this.wrapAPI(w, req, doStatementStatsEntry)
+	}
+	statementStatsHandler := func(w http.ResponseWriter, req *http.Request) {
+		this.wrapAPI(w, req, doStatementStats)
+	}
+
 	prometheusLowHandler := func(w http.ResponseWriter, req *http.Request) {
 		this.wrapAPI(w, req, doPrometheusLow)
 	}
@@ -162,6 +199,7 @@ func (this *HttpEndpoint) registerAccountingHandlers() {
 		preparedsPrefix + "/{name}":                       {handler: preparedHandler, methods: []string{"GET", "POST", "DELETE", "PUT"}},
 		requestsPrefix:                                    {handler: requestsHandler, methods: []string{"GET"}},
 		requestsPrefix + "/{request}":                     {handler: requestHandler, methods: []string{"GET", "POST", "DELETE"}},
+		requestsPrefix + "/{request}/progress":            {handler: requestProgressHandler, methods: []string{"GET"}},
 		completedsPrefix:                                  {handler: completedsHandler, methods: []string{"GET"}},
 		completedsPrefix + "/{request}":                   {handler: completedHandler, methods: []string{"GET", "POST", "DELETE"}},
 		functionsPrefix:                                   {handler: functionsHandler, methods: []string{"GET"}},
@@ -170,6 +208,13 @@ func (this *HttpEndpoint) registerAccountingHandlers() {
 		dictionaryPrefix + "/{name}":                      {handler: dictionaryEntryHandler, methods: []string{"GET", "POST", "DELETE"}},
 		tasksPrefix:                                       {handler: tasksHandler, methods: []string{"GET"}},
 		tasksPrefix + "/{name}":                           {handler: taskHandler, methods: []string{"GET", "POST", "DELETE"}},
+		baselinesPrefix:                                   {handler: baselinesHandler, methods: []string{"GET"}},
+		baselinesPrefix + "/{name}":                       {handler: baselineHandler, methods: []string{"GET", "POST", "DELETE"}},
+		baselinesPrefix + "/{name}/{action}/{plan}":       {handler: baselinePlanHandler, methods: []string{"POST", "PUT"}},
+		resultCachePrefix:                                 {handler: resultCacheHandler, methods: []string{"GET", "DELETE"}},
+		resultCachePrefix + "/{name}":                     {handler: resultCacheEntryHandler, methods: []string{"GET", "POST", "DELETE"}},
+		statementStatsPrefix:                              {handler: statementStatsHandler, methods: []string{"GET", "DELETE"}},
+		statementStatsPrefix + "/{name}":                  {handler: statementStatsEntryHandler, methods: []string{"GET", "POST", "DELETE"}},
 		transactionsPrefix:                                {handler: transactionsHandler, methods: []string{"GET"}},
 		transactionsPrefix + "/{txid}":                    {handler: transactionHandler, methods: []string{"GET", "POST", "DELETE"}},
 		indexesPrefix + "/prepareds":                      {handler: preparedIndexHandler, methods: []string{"GET"}},
@@ -178,6 +223,9 @@ func (this *HttpEndpoint) registerAccountingHandlers() {
 		indexesPrefix + "/function_cache":                 {handler: functionsIndexHandler, methods: []string{"GET"}},
 		indexesPrefix + "/dictionary_cache":               {handler: dictionaryIndexHandler, methods: []string{"GET"}},
 		indexesPrefix + "/tasks_cache":                    {handler: tasksIndexHandler, methods: []string{"GET"}},
+		indexesPrefix + "/plan_baselines":                 {handler: baselinesIndexHandler, methods: []string{"GET"}},
+		indexesPrefix + "/result_cache":                   {handler: resultCacheIndexHandler, methods: []string{"GET"}},
+		indexesPrefix + "/statement_stats":                {handler: statementStatsIndexHandler, methods: []string{"GET"}},
 		prometheusLow:                                     {handler: prometheusLowHandler, methods: []string{"GET"}},
 		prometheusHigh:                                    {handler: prometheusHighHandler, methods: []string{"GET"}},
 		indexesPrefix + "/transactions":                   {handler: transactionsIndexHandler, methods: []string{"GET"}},
@@ -816,6 +864,235 @@ func doTasks(endpoint *HttpEndpoint, w http.ResponseWriter, req *http.Request, a
 	}
 }
 
+func doBaseline(endpoint *HttpEndpoint, w http.ResponseWriter, req *http.Request, af *audit.ApiAuditFields) (interface{}, errors.Error) {
+	vars := mux.Vars(req)
+	name := vars["name"]
+
+	af.EventTypeId = audit.API_ADMIN_PLAN_BASELINES
+	af.Name = name
+
+	if req.Method == "DELETE" {
+		err, _ := endpoint.verifyCredentialsFromRequest("system:plan_baselines", auth.PRIV_SYSTEM_READ, req, af)
+		if err != nil {
+			return nil, err
+		}
+		err = prepareds.DeleteBaseline(name)
+		if err != nil {
+			return nil, err
+		}
+		return true, nil
+	} else if req.Method == "GET" || req.Method == "POST" {
+		err, isInternal := endpoint.verifyCredentialsFromRequest("system:plan_baselines", auth.PRIV_SYSTEM_READ, req, af)
+		if err != nil {
+			return nil, err
+		}
+		if isInternal {
+			// Do not audit internal requests. They are an internal API used
+			// only for queries to system:plan_baselines, and would cause too
+			// many log messages to be generated.
+			af.EventTypeId = audit.API_DO_NOT_AUDIT
+		}
+
+		var res interface{}
+
+		prepareds.BaselineDo(name, func(baseline *prepareds.Baseline) {
+			res = baseline.Format(req.Method == "POST")
+		})
+		return res, nil
+	} else {
+		return nil, errors.NewServiceErrorHttpMethod(req.Method)
+	}
+}
+
+func doBaselinePlan(endpoint *HttpEndpoint, w http.ResponseWriter, req *http.Request, af *audit.ApiAuditFields) (interface{}, errors.Error) {
+	vars := mux.Vars(req)
+	name := vars["name"]
+
+	af.EventTypeId = audit.API_ADMIN_PLAN_BASELINES
+	af.Name = name
+
+	if req.Method != "POST" && req.Method != "PUT" {
+		return nil, errors.NewServiceErrorHttpMethod(req.Method)
+	}
+	err, _ := endpoint.verifyCredentialsFromRequest("system:plan_baselines", auth.PRIV_SYSTEM_READ, req, af)
+	if err != nil {
+		return nil, err
+	}
+
+	switch vars["action"] {
+	case "accept":
+		err = prepareds.AcceptBaselinePlan(name, vars["plan"])
+	case "reject":
+		err = prepareds.RejectBaselinePlan(name, vars["plan"])
+	default:
+		return nil, errors.NewServiceErrorUnrecognizedValue("action", vars["action"])
+	}
+	if err != nil {
+		return nil, err
+	}
+	return true, nil
+}
+
+func doResultCacheEntry(endpoint *HttpEndpoint, w http.ResponseWriter, req *http.Request, af *audit.ApiAuditFields) (interface{}, errors.Error) {
+	vars := mux.Vars(req)
+	name := vars["name"]
+
+	af.EventTypeId = audit.API_ADMIN_RESULT_CACHE
+	af.Name = name
+
+	if req.Method == "DELETE" {
+		err, _ := endpoint.verifyCredentialsFromRequest("system:result_cache", auth.PRIV_SYSTEM_READ, req, af)
+		if err != nil {
+			return nil, err
+		}
+		if !server.ResultCacheDelete(name) {
+			return nil, errors.NewServiceErrorNoSuchResultCacheEntry(name)
+		}
+		return true, nil
+	} else if req.Method == "GET" || req.Method == "POST" {
+		err, isInternal := endpoint.verifyCredentialsFromRequest("system:result_cache", auth.PRIV_SYSTEM_READ, req, af)
+		if err != nil {
+			return nil, err
+		}
+		if isInternal {
+			// Do not audit internal requests. They are an internal API used
+			// only for queries to system:result_cache, and would cause too
+			// many log messages to be generated.
+			af.EventTypeId = audit.API_DO_NOT_AUDIT
+		}
+
+		var res interface{}
+
+		server.ResultCacheDo(name, func(entry *server.ResultCacheEntry) {
+			res = entry.Format()
+		})
+		return res, nil
+	} else {
+		return nil, errors.NewServiceErrorHttpMethod(req.Method)
+	}
+}
+
+func doResultCache(endpoint *HttpEndpoint, w http.ResponseWriter, req *http.Request, af *audit.ApiAuditFields) (interface{}, errors.Error) {
+	af.EventTypeId = audit.API_ADMIN_RESULT_CACHE
+	err, _ := endpoint.verifyCredentialsFromRequest("system:result_cache", auth.PRIV_SYSTEM_READ, req, af)
+	if err != nil {
+		return nil, err
+	}
+
+	switch req.Method {
+	case "GET":
+		data := make([]map[string]interface{}, 0, server.CountResultCache())
+
+		snapshot := func(name string, entry *server.ResultCacheEntry) bool {
+			data = append(data, entry.Format())
+			return true
+		}
+
+		server.ResultCacheForeach(snapshot, nil)
+		return data, nil
+
+	case "DELETE":
+		server.ResultCacheFlush()
+		return true, nil
+
+	default:
+		return nil, errors.NewServiceErrorHttpMethod(req.Method)
+	}
+}
+
+func doStatementStatsEntry(endpoint *HttpEndpoint, w http.ResponseWriter, req *http.Request, af *audit.ApiAuditFields) (interface{}, errors.Error) {
+	vars := mux.Vars(req)
+	name := vars["name"]
+
+	af.EventTypeId = audit.API_ADMIN_STATEMENT_STATS
+	af.Name = name
+
+	if req.Method == "DELETE" {
+		err, _ := endpoint.verifyCredentialsFromRequest("system:statement_stats", auth.PRIV_SYSTEM_READ, req, af)
+		if err != nil {
+			return nil, err
+		}
+		if !server.StatementStatsDelete(name) {
+			return nil, errors.NewServiceErrorNoSuchStatementStats(name)
+		}
+		return true, nil
+	} else if req.Method == "GET" || req.Method == "POST" {
+		err, isInternal := endpoint.verifyCredentialsFromRequest("system:statement_stats", auth.PRIV_SYSTEM_READ, req, af)
+		if err != nil {
+			return nil, err
+		}
+		if isInternal {
+			// Do not audit internal requests. They are an internal API used
+			// only for queries to system:statement_stats, and would cause too
+			// many log messages to be generated.
+			af.EventTypeId = audit.API_DO_NOT_AUDIT
+		}
+
+		var res interface{}
+
+		server.StatementStatsDo(name, func(stats *server.StatementStats) {
+			res = stats.Format()
+		})
+		return res, nil
+	} else {
+		return nil, errors.NewServiceErrorHttpMethod(req.Method)
+	}
+}
+
+// DELETE resets all statement statistics
+func doStatementStats(endpoint *HttpEndpoint, w http.ResponseWriter, req *http.Request, af *audit.ApiAuditFields) (interface{}, errors.Error) {
+	af.EventTypeId = audit.API_ADMIN_STATEMENT_STATS
+	err, _ := endpoint.verifyCredentialsFromRequest("system:statement_stats", auth.PRIV_SYSTEM_READ, req, af)
+	if err != nil {
+		return nil, err
+	}
+
+	switch req.Method {
+	case "GET":
+		data := make([]map[string]interface{}, 0, server.CountStatementStats())
+
+		snapshot := func(name string, stats *server.StatementStats) bool {
+			data = append(data, stats.Format())
+			return true
+		}
+
+		server.StatementStatsForeach(snapshot, nil)
+		return data, nil
+
+	case "DELETE":
+		server.StatementStatsReset()
+		return true, nil
+
+	default:
+		return nil, errors.NewServiceErrorHttpMethod(req.Method)
+	}
+}
+
+func doBaselines(endpoint *HttpEndpoint, w http.ResponseWriter, req *http.Request, af *audit.ApiAuditFields) (interface{}, errors.Error) {
+	af.EventTypeId = audit.API_ADMIN_PLAN_BASELINES
+	switch req.Method {
+	case "GET":
+		err, _ := endpoint.verifyCredentialsFromRequest("system:plan_baselines", auth.PRIV_SYSTEM_READ, req, af)
+		if err != nil {
+			return nil, err
+		}
+
+		numBaselines := prepareds.CountBaselines()
+		data := make([]map[string]interface{}, 0, numBaselines)
+
+		snapshot := func(name string, baseline *prepareds.Baseline) bool {
+			data = append(data, baseline.Format(false))
+			return true
+		}
+
+		prepareds.BaselinesForeach(snapshot, nil)
+		return data, nil
+
+	default:
+		return nil, errors.NewServiceErrorHttpMethod(req.Method)
+	}
+}
+
 func doFunctionsGlobalBackup(endpoint *HttpEndpoint, w http.ResponseWriter, req *http.Request, af *audit.ApiAuditFields) (interface{}, errors.Error) {
 	af.EventTypeId = audit.API_ADMIN_FUNCTIONS_BACKUP
 	switch req.Method {
@@ -1344,6 +1621,26 @@ func doActiveRequest(endpoint *HttpEndpoint, w http.ResponseWriter, req *http.Re
 	}
 }
 
+// progress estimates are cheap enough for progress bars to poll
+func doActiveRequestProgress(endpoint *HttpEndpoint, w http.ResponseWriter, req *http.Request, af *audit.ApiAuditFields) (interface{}, errors.Error) {
+	vars := mux.Vars(req)
+	requestId := vars["request"]
+
+	af.EventTypeId = audit.API_DO_NOT_AUDIT
+	err, _ := endpoint.verifyCredentialsFromRequest("system:active_requests", auth.PRIV_SYSTEM_READ, req, af)
+	if err != nil {
+		return nil, err
+	}
+
+	var res interface{}
+	_ = endpoint.actives.Get(requestId, func(request server.Request) {
+		progress := server.RequestProgress(request)
+		progress["requestId"] = requestId
+		res = progress
+	})
+	return res, nil
+}
+
 func activeRequestWorkHorse(endpoint *HttpEndpoint, requestId string, profiling bool) interface{} {
 	var res interface{}
 
@@ -1407,6 +1704,7 @@ func activeRequestWorkHorse(endpoint *HttpEndpoint, requestId string, profiling
 		if usedMemory != 0 {
 			reqMap["usedMemory"] = usedMemory
 		}
+		reqMap["progress"] = server.RequestProgress(request)
 		if profiling {
 
 			prof := request.Profile()
@@ -1459,6 +1757,12 @@ func activeRequestWorkHorse(endpoint *HttpEndpoint, requestId string, profiling
 		if userAgent != "" {
 			reqMap["userAgent"] = userAgent
 		}
+		if group := request.WorkloadGroup(); group != nil {
+			reqMap["workloadGroup"] = group.Name()
+		}
+		if handle := request.AsyncHandle(); handle != "" {
+			reqMap["asyncHandle"] = handle
+		}
 		res = reqMap
 	})
 	return res
@@ -1525,6 +1829,12 @@ func doActiveRequests(endpoint *HttpEndpoint, w http.ResponseWriter, req *http.R
 		if credsString != "" {
 			requests[i]["users"] = credsString
 		}
+		if group := request.WorkloadGroup(); group != nil {
+			requests[i]["workloadGroup"] = group.Name()
+		}
+		if handle := request.AsyncHandle(); handle != "" {
+			requests[i]["asyncHandle"] = handle
+		}
 
 		p := request.Output().FmtPhaseCounts()
 		if p != nil {
@@ -1678,6 +1988,9 @@ func completedRequestWorkHorse(requestId string, profiling bool) interface{} {
 		if request.UserAgent != "" {
 			reqMap["userAgent"] = request.UserAgent
 		}
+		if request.AsyncHandle != "" {
+			reqMap["asyncHandle"] = request.AsyncHandle
+		}
 		res = reqMap
 	})
 	return res
@@ -1821,6 +2134,21 @@ func doTasksIndex(endpoint *HttpEndpoint, w http.ResponseWriter, req *http.Reque
 	return scheduler.NameTasks(), nil
 }
 
+func doBaselinesIndex(endpoint *HttpEndpoint, w http.ResponseWriter, req *http.Request, af *audit.ApiAuditFields) (interface{}, errors.Error) {
+	af.EventTypeId = audit.API_DO_NOT_AUDIT
+	return prepareds.NameBaselines(), nil
+}
+
+func doResultCacheIndex(endpoint *HttpEndpoint, w http.ResponseWriter, req *http.Request, af *audit.ApiAuditFields) (interface{}, errors.Error) {
+	af.EventTypeId = audit.API_DO_NOT_AUDIT
+	return server.NameResultCache(), nil
+}
+
+func doStatementStatsIndex(endpoint *HttpEndpoint, w http.ResponseWriter, req *http.Request, af *audit.ApiAuditFields) (interface{}, errors.Error) {
+	af.EventTypeId = audit.API_DO_NOT_AUDIT
+	return server.NameStatementStats(), nil
+}
+
 func doTransactionsIndex(endpoint *HttpEndpoint, w http.ResponseWriter, req *http.Request,
 	af *audit.ApiAuditFields) (interface{}, errors.Error) {
 	af.EventTypeId = audit.API_DO_NOT_AUDIT
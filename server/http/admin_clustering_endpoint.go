@@ -28,6 +28,8 @@ import (
 	"github.com/couchbase/query/logging"
 	"github.com/couchbase/query/prepareds"
 	"github.com/couchbase/query/server"
+	"github.com/couchbase/query/sessions"
+	"github.com/couchbase/query/tracing"
 	"github.com/couchbase/query/util"
 	"github.com/gorilla/mux"
 )
@@ -489,6 +491,20 @@ func fillSettings(settings map[string]interface{}, srvr *server.Server) map[stri
 	settings[server.CLEANUPCLIENTATTEMPTS] = tranSettings.CleanupClientAttempts()
 	settings[server.CLEANUPLOSTATTEMPTS] = tranSettings.CleanupLostAttempts()
 	settings[server.GCPERCENT] = srvr.GCPercent()
+	settings[server.PLANBASELINES] = prepareds.BaselinesMode()
+	settings[server.WORKLOADGROUPS] = srvr.WorkloadGroups()
+	settings[server.ASYNCDIR] = srvr.AsyncDir()
+	settings[server.ASYNCRETENTION] = srvr.AsyncRetention().String()
+	settings[server.ASYNCLIMIT] = srvr.AsyncLimit()
+	settings[server.ASYNCQUOTA] = srvr.AsyncQuota()
+	settings[server.MAXCURSORTIMEOUT] = srvr.MaxCursorTimeout().String()
+	settings[server.MAXCURSORS] = srvr.MaxCursorsSetting()
+	settings[server.RESULTCACHEMEMORY] = server.ResultCacheMemory()
+	settings[server.RESULTCACHETTL] = server.ResultCacheTTL().String()
+	settings[server.SESSIONMEMORYQUOTA] = sessions.MemoryQuota()
+	settings[server.STMTSTATSLIMIT] = server.StatementStatsLimit()
+	settings[server.TRACECOLLECTOR] = tracing.Collector()
+	settings[server.USERLIMITS] = srvr.UserLimits()
 	return settings
 }
 
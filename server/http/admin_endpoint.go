@@ -100,8 +100,11 @@ func mapErrorToHttpStatus(err errors.Error) int {
 		return http.StatusNotFound
 	case errors.DS_AUTH_ERROR:
 		return http.StatusUnauthorized
-	case errors.ADMIN_CREDS_ERROR:
+	case errors.ADMIN_CREDS_ERROR, 1040, 1050, 3000: // bad request values, parse errors
 		return http.StatusBadRequest
+	case errors.SERVICE_NO_SUCH_ASYNC, errors.SERVICE_NO_SUCH_CURSOR, errors.SERVICE_NO_SUCH_RESULT_CACHE_ENTRY,
+		errors.SERVICE_NO_SUCH_STATEMENT_STATS:
+		return http.StatusNotFound
 	default:
 		return http.StatusInternalServerError
 	}
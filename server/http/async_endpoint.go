@@ -0,0 +1,652 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/couchbase/query/audit"
+	"github.com/couchbase/query/auth"
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/distributed"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/logging"
+	"github.com/couchbase/query/util"
+	"github.com/gorilla/mux"
+)
+
+// Asynchronous requests are submitted to the service endpoint with async=true.
+// The submission returns a handle straight away, and the response that would
+// have been sent to the client is spooled to a file as it is produced.
+//
+// GET    /query/async/{handle}                             status, and the response less its results
+// GET    /query/async/{handle}/results?offset=<n>&limit=<n> a page of the results spooled so far
+// DELETE /query/async/{handle}                             cancel the request, or discard its results
+//
+// Spooled results are discarded once the retention period has elapsed since
+// the request completed. Submissions are turned down while async-limit handles
+// are kept, or once the spooled results take up async-quota MB; a request whose
+// results go past the quota fails.
+//
+// Handles are only known to the node that ran the request, which is returned
+// with the handle in a cluster: clients must send their GET and DELETE requests
+// to that node, rather than through a load balancer.
+const (
+	asyncPrefix = "/query/async"
+
+	_ASYNC_FILE_PREFIX = "cbq-async-"
+	_ASYNC_FILE_SUFFIX = ".json"
+	_ASYNC_DEF_LIMIT   = 1000
+	_ASYNC_SWEEP       = time.Minute
+)
+
+const (
+	_ASYNC_RUNNING  = "running"
+	_ASYNC_REJECTED = "rejected"
+)
+
+// asyncSpool stands in for the client's http.ResponseWriter
+type asyncSpool struct {
+	sync.Mutex
+	file   *os.File
+	header http.Header
+	code   int
+	err    error
+	index  rowIndex
+	quota  uint64 // MB, shared by all the spools
+	size   int64
+}
+
+// the size of all the spools, for the quota
+var asyncSpooled int64
+
+func (this *asyncSpool) Header() http.Header {
+	return this.header
+}
+
+func (this *asyncSpool) WriteHeader(code int) {
+	this.Lock()
+	this.code = code
+	this.Unlock()
+}
+
+func (this *asyncSpool) Write(b []byte) (int, error) {
+	this.Lock()
+	defer this.Unlock()
+	if this.file == nil {
+		return 0, os.ErrClosed
+	}
+	if atomic.AddInt64(&asyncSpooled, int64(len(b))) > int64(this.quota)*(1024*1024) {
+		atomic.AddInt64(&asyncSpooled, -int64(len(b)))
+		if this.err == nil {
+			this.err = errors.NewServiceErrorAsyncQuota(this.quota)
+		}
+		return 0, this.err
+	}
+	n, err := this.file.Write(b)
+	atomic.AddInt64(&asyncSpooled, int64(n-len(b)))
+	this.size += int64(n)
+	this.index.write(b[:n])
+	if err != nil && this.err == nil {
+		this.err = err
+	}
+	return n, err
+}
+
+// writes go straight to the file, there is nothing to flush
+func (this *asyncSpool) Flush() {
+}
+
+func (this *asyncSpool) result() (int, error) {
+	this.Lock()
+	defer this.Unlock()
+	return this.code, this.err
+}
+
+func (this *asyncSpool) rows() spoolRows {
+	this.Lock()
+	defer this.Unlock()
+	return this.index.spoolRows
+}
+
+func (this *asyncSpool) close() {
+	this.Lock()
+	if this.file != nil {
+		this.file.Close()
+		this.file = nil
+	}
+	this.Unlock()
+}
+
+// the spool file is gone, and no longer counts against the quota
+func (this *asyncSpool) remove(path string) {
+	this.close()
+	this.Lock()
+	os.Remove(path)
+	atomic.AddInt64(&asyncSpooled, -this.size)
+	this.size = 0
+	this.Unlock()
+}
+
+// Where the results array and each of its rows start in the spool, so
+// that pages of results can be read back without decoding the spool
+// from the start. Offsets are only ever appended.
+type spoolRows struct {
+	start int64 // past the '[' opening the results, or -1
+	end   int64 // at the ']' closing the results, or -1
+	rows  []int64
+}
+
+func newSpoolRows() spoolRows {
+	return spoolRows{start: -1, end: -1}
+}
+
+// the rows that have been written in full
+func (this *spoolRows) count() int {
+	if this.end >= 0 || len(this.rows) == 0 {
+		return len(this.rows)
+	}
+	return len(this.rows) - 1
+}
+
+// rowIndex follows the JSON response as it is spooled, tracking no more
+// than nesting and the names of the top level fields
+type rowIndex struct {
+	spoolRows
+	pos       int64
+	depth     int
+	inString  bool
+	escape    bool
+	str       []byte
+	key       string
+	inResults bool
+	expectRow bool
+}
+
+func (this *rowIndex) write(b []byte) {
+	for _, c := range b {
+		pos := this.pos
+		this.pos++
+		if this.inString {
+			if this.escape {
+				this.escape = false
+			} else if c == '\\' {
+				this.escape = true
+			} else if c == '"' {
+				this.inString = false
+				continue
+			}
+			if this.depth == 1 {
+				this.str = append(this.str, c)
+			}
+			continue
+		}
+
+		switch c {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+		if this.expectRow {
+			this.expectRow = false
+			if c != ']' {
+				this.rows = append(this.rows, pos)
+			}
+		}
+		switch c {
+		case '"':
+			this.inString = true
+			if this.depth == 1 {
+				this.str = this.str[:0]
+			}
+		case ':':
+			if this.depth == 1 {
+				this.key = string(this.str)
+			}
+		case ',':
+			if this.inResults && this.depth == 2 {
+				this.expectRow = true
+			}
+		case '{', '[':
+			this.depth++
+			if c == '[' && this.depth == 2 && this.key == "results" && this.start < 0 {
+				this.inResults = true
+				this.expectRow = true
+				this.start = pos + 1
+			}
+		case '}', ']':
+			if this.inResults && this.depth == 2 {
+				this.inResults = false
+				this.end = pos
+			}
+			this.depth--
+		}
+	}
+}
+
+type asyncRequest struct {
+	sync.RWMutex
+	handle          string
+	clientContextID string
+	users           []string
+	path            string
+	spool           *asyncSpool
+	request         *httpRequest // only while running
+	state           string
+	submitted       time.Time
+	completed       time.Time
+}
+
+var asyncRequests = util.NewGenCache(-1)
+
+func (this *asyncRequest) remove() {
+	this.spool.remove(this.path)
+}
+
+func (this *HttpEndpoint) registerAsyncHandlers() {
+	asyncHandler := func(w http.ResponseWriter, req *http.Request) {
+		this.wrapAPI(w, req, doAsync)
+	}
+	asyncResultsHandler := func(w http.ResponseWriter, req *http.Request) {
+		this.wrapAPI(w, req, doAsyncResults)
+	}
+	this.mux.HandleFunc(asyncPrefix+"/{handle}", asyncHandler).Methods("GET", "DELETE")
+	this.mux.HandleFunc(asyncPrefix+"/{handle}/results", asyncResultsHandler).Methods("GET")
+
+	go this.asyncSweeper()
+}
+
+// Starts an asynchronous request and responds with its handle
+// The request is not pooled, as it outlives the http request.
+func (this *HttpEndpoint) serveAsync(request *httpRequest, resp http.ResponseWriter) {
+	handle := request.Id().String()
+	if limit := this.server.AsyncLimit(); asyncRequests.Size() >= limit {
+		request.Fail(errors.NewServiceErrorTooManyAsync(limit))
+		request.Failed(this.server)
+		this.doStats(request, this.server)
+		return
+	}
+	if quota := this.server.AsyncQuota(); atomic.LoadInt64(&asyncSpooled) >= int64(quota)*(1024*1024) {
+		request.Fail(errors.NewServiceErrorAsyncQuota(quota))
+		request.Failed(this.server)
+		this.doStats(request, this.server)
+		return
+	}
+	path := filepath.Join(this.server.AsyncDir(), _ASYNC_FILE_PREFIX+handle+_ASYNC_FILE_SUFFIX)
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		request.Fail(errors.NewServiceErrorAsyncSpool(err, handle))
+		request.Failed(this.server)
+		this.doStats(request, this.server)
+		return
+	}
+
+	var cancel context.CancelFunc
+
+	spool := &asyncSpool{file: file, header: make(http.Header), code: http.StatusOK, quota: this.server.AsyncQuota()}
+	spool.index.spoolRows = newSpoolRows()
+	request.resp = spool
+	request.req, cancel = detachRequest(request.req)
+	request.SetAsyncHandle(handle)
+
+	entry := &asyncRequest{
+		handle:          handle,
+		clientContextID: request.ClientID().String(),
+		path:            path,
+		spool:           spool,
+		users:           requestUsers(request),
+		request:         request,
+		state:           _ASYNC_RUNNING,
+		submitted:       time.Now(),
+	}
+	asyncRequests.Add(entry, handle, nil)
+	this.actives.Put(request)
+
+	go func() {
+		defer cancel()
+
+		var res bool
+		if request.ScanConsistency() == datastore.UNBOUNDED && request.TxId() == "" {
+			res = this.server.ServiceRequest(request)
+		} else {
+			res = this.server.PlusServiceRequest(request)
+		}
+		this.actives.Delete(handle, false)
+		spool.close()
+		this.doStats(request, this.server)
+
+		entry.Lock()
+		if res {
+			entry.state = request.State().StateName()
+		} else {
+			entry.state = _ASYNC_REJECTED
+			spool.WriteHeader(http.StatusServiceUnavailable)
+		}
+		entry.request = nil
+		entry.completed = time.Now()
+		entry.Unlock()
+	}()
+
+	rv := map[string]interface{}{
+		"requestID": handle,
+		"handle":    asyncPrefix + "/" + handle,
+		"status":    _ASYNC_RUNNING,
+	}
+	if entry.clientContextID != "" {
+		rv["clientContextID"] = entry.clientContextID
+	}
+	if node := distributed.RemoteAccess().WhoAmI(); node != "" {
+		rv["node"] = node
+	}
+	buf, _ := json.Marshal(rv)
+	resp.Header().Set("Content-Type", "application/json")
+	resp.WriteHeader(http.StatusAccepted)
+	resp.Write(buf)
+}
+
+func doAsync(endpoint *HttpEndpoint, w http.ResponseWriter, req *http.Request, af *audit.ApiAuditFields) (interface{}, errors.Error) {
+	handle := mux.Vars(req)["handle"]
+	af.EventTypeId = audit.API_DO_NOT_AUDIT
+
+	entry, err := endpoint.getAsync(handle, req, af)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Method == "DELETE" {
+		entry.RLock()
+		running := entry.request != nil
+		entry.RUnlock()
+
+		// a running request is stopped, and its results kept until they expire
+		if running {
+			endpoint.actives.Delete(handle, true)
+			return textPlain(""), nil
+		}
+		asyncRequests.Delete(handle, func(e interface{}) {
+			e.(*asyncRequest).remove()
+		})
+		return textPlain(""), nil
+	}
+	return endpoint.asyncStatus(entry, false, 0, 0)
+}
+
+func doAsyncResults(endpoint *HttpEndpoint, w http.ResponseWriter, req *http.Request, af *audit.ApiAuditFields) (interface{}, errors.Error) {
+	handle := mux.Vars(req)["handle"]
+	af.EventTypeId = audit.API_DO_NOT_AUDIT
+
+	entry, err := endpoint.getAsync(handle, req, af)
+	if err != nil {
+		return nil, err
+	}
+
+	var er error
+	offset := 0
+	limit := _ASYNC_DEF_LIMIT
+	query := req.URL.Query()
+	if s := query.Get("offset"); s != "" {
+		offset, er = strconv.Atoi(s)
+		if er != nil || offset < 0 {
+			return nil, errors.NewServiceErrorBadValue(er, "offset")
+		}
+	}
+	if s := query.Get("limit"); s != "" {
+		limit, er = strconv.Atoi(s)
+		if er != nil || limit <= 0 {
+			return nil, errors.NewServiceErrorBadValue(er, "limit")
+		}
+	}
+	return endpoint.asyncStatus(entry, true, offset, limit)
+}
+
+func (this *HttpEndpoint) getAsync(handle string, req *http.Request, af *audit.ApiAuditFields) (*asyncRequest, errors.Error) {
+	e := asyncRequests.Get(handle, nil)
+	if e == nil {
+		return nil, errors.NewServiceErrorNoSuchAsync(handle)
+	}
+	entry := e.(*asyncRequest)
+	err := this.verifyRequestOwner(entry.users, req, af)
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// Only the submitter of a request can access it: whoever can read active
+// requests sees statements, but must not get to read their results.
+// Requests submitted without credentials can only be accessed without them.
+func (this *HttpEndpoint) verifyRequestOwner(users []string, req *http.Request, af *audit.ApiAuditFields) errors.Error {
+	ds := datastore.GetDatastore()
+	creds, err, _ := this.getCredentialsFromRequest(ds, req)
+	if err != nil {
+		return err
+	}
+	if af != nil {
+		for user := range creds.Users {
+			af.Users = append(af.Users, user)
+		}
+	}
+
+	owner := len(users) == 0 && len(creds.Users) == 0
+	for _, user := range users {
+		if _, ok := creds.Users[user]; ok {
+			owner = true
+			break
+		}
+	}
+	if !owner {
+		return errors.NewDatastoreInsufficientCredentials("User does not have credentials to access the request.")
+	}
+
+	// check that the user is who they say they are
+	_, err = ds.Authorize(auth.NewPrivileges(), creds)
+	return err
+}
+
+// the execution of a detached request must survive the client disconnecting:
+// only keep what the audit records need from the original context
+func detachRequest(req *http.Request) (*http.Request, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(),
+		http.LocalAddrContextKey, req.Context().Value(http.LocalAddrContextKey)))
+	return req.WithContext(ctx), cancel
+}
+
+func requestUsers(request *httpRequest) []string {
+	var users []string
+
+	if creds := request.Credentials(); creds != nil {
+		for user := range creds.Users {
+			users = append(users, user)
+		}
+	}
+	return users
+}
+
+func (this *HttpEndpoint) asyncStatus(entry *asyncRequest, results bool, offset, limit int) (interface{}, errors.Error) {
+	entry.RLock()
+	state := entry.state
+	request := entry.request
+	completed := entry.completed
+	entry.RUnlock()
+
+	code, err := entry.spool.result()
+	if err != nil {
+		return nil, errors.NewServiceErrorAsyncSpool(err, entry.handle)
+	}
+	fields, rows, count, err := readAsyncSpool(entry.path, entry.spool.rows(), results, offset, limit)
+
+	// a running request has only produced part of its response
+	if err != nil && request == nil && state != _ASYNC_REJECTED {
+		return nil, errors.NewServiceErrorAsyncSpool(err, entry.handle)
+	}
+
+	rv := make(map[string]interface{}, len(fields)+8)
+	if !results {
+		for k, v := range fields {
+			rv[k] = v
+		}
+	}
+	rv["requestID"] = entry.handle
+	if entry.clientContextID != "" {
+		rv["clientContextID"] = entry.clientContextID
+	}
+	if request != nil {
+		rv["status"] = _ASYNC_RUNNING
+		rv["state"] = request.State().StateName()
+	} else {
+		if _, ok := fields["status"]; !ok {
+			rv["status"] = state
+		}
+		rv["state"] = state
+		rv["completed"] = completed.String()
+		rv["expires"] = completed.Add(this.server.AsyncRetention()).String()
+		rv["httpStatus"] = code
+	}
+	rv["submitted"] = entry.submitted.String()
+	rv["resultCount"] = count
+	if results {
+		rv["offset"] = offset
+		rv["results"] = rows
+		if offset+len(rows) < count || request != nil {
+			rv["next"] = asyncPrefix + "/" + entry.handle + "/results?offset=" +
+				strconv.Itoa(offset+len(rows)) + "&limit=" + strconv.Itoa(limit)
+		}
+	} else {
+		rv["results"] = asyncPrefix + "/" + entry.handle + "/results"
+	}
+	return rv, nil
+}
+
+// Reads back a spooled response, returning its fields other than the results,
+// the requested page of results and the number of results spooled.
+// While a request is running, the spool holds a truncated response and an
+// error is returned along with whatever could be read.
+func readAsyncSpool(path string, index spoolRows, results bool, offset, limit int) (map[string]json.RawMessage, []json.RawMessage, int, error) {
+	fields := make(map[string]json.RawMessage)
+	rows := []json.RawMessage{}
+	count := index.count()
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fields, rows, count, err
+	}
+	defer file.Close()
+
+	if results && offset < count {
+		last := offset + limit
+		if last > count {
+			last = count
+		}
+		stop := index.end
+		if last < len(index.rows) {
+			stop = index.rows[last]
+		}
+		buf := make([]byte, stop-index.rows[offset])
+		if _, err = file.ReadAt(buf, index.rows[offset]); err != nil {
+			return fields, rows, count, err
+		}
+		base := index.rows[offset]
+		for i := offset; i < last; i++ {
+			end := int64(len(buf))
+			if i+1 < last {
+				end = index.rows[i+1] - base
+			}
+			rows = append(rows, json.RawMessage(bytes.TrimRight(buf[index.rows[i]-base:end], " \t\r\n,")))
+		}
+	}
+
+	// the fields are read with the results skipped
+	var reader io.Reader = file
+	if index.start >= 0 {
+		readers := []io.Reader{io.NewSectionReader(file, 0, index.start), strings.NewReader("]")}
+		if index.end >= 0 {
+			readers = append(readers, io.NewSectionReader(file, index.end+1, 1<<62))
+		}
+		reader = io.MultiReader(readers...)
+	}
+	dec := json.NewDecoder(reader)
+	if _, err = dec.Token(); err != nil {
+		return fields, rows, count, err
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return fields, rows, count, err
+		}
+		key, _ := tok.(string)
+		if key != "results" {
+			var field json.RawMessage
+			if err = dec.Decode(&field); err != nil {
+				return fields, rows, count, err
+			}
+			fields[key] = field
+			continue
+		}
+
+		// the results have been left out of the reader
+		for i := 0; i < 2; i++ {
+			if _, err = dec.Token(); err != nil {
+				return fields, rows, count, err
+			}
+		}
+	}
+	_, err = dec.Token()
+	return fields, rows, count, err
+}
+
+// Discards the results of asynchronous requests once they have expired,
+// including those left behind by a previous run of the service
+func (this *HttpEndpoint) asyncSweeper() {
+	ticker := time.NewTicker(_ASYNC_SWEEP)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		retention := this.server.AsyncRetention()
+		now := time.Now()
+
+		expired := make([]string, 0)
+		asyncRequests.ForEach(func(handle string, e interface{}) bool {
+			entry := e.(*asyncRequest)
+			entry.RLock()
+			if entry.request == nil && now.Sub(entry.completed) > retention {
+				expired = append(expired, handle)
+			}
+			entry.RUnlock()
+			return true
+		}, nil)
+		for _, handle := range expired {
+			asyncRequests.Delete(handle, func(e interface{}) {
+				e.(*asyncRequest).remove()
+			})
+		}
+
+		files, _ := filepath.Glob(filepath.Join(this.server.AsyncDir(), _ASYNC_FILE_PREFIX+"*"+_ASYNC_FILE_SUFFIX))
+		for _, f := range files {
+			handle := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(f), _ASYNC_FILE_PREFIX), _ASYNC_FILE_SUFFIX)
+			if asyncRequests.Get(handle, nil) != nil {
+				continue
+			}
+			info, err := os.Stat(f)
+			if err == nil && now.Sub(info.ModTime()) > retention {
+				logging.Infof("Removing expired asynchronous request results %v", f)
+				os.Remove(f)
+			}
+		}
+	}
+}
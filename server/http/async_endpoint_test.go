@@ -0,0 +1,151 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package http
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/couchbase/query/errors"
+)
+
+const _TEST_SPOOL = `{
+"requestID": "abc",
+"signature": {"*":"*"},
+"results": [
+{"a":1},
+{"a":2},
+{"a":3}
+],
+"status": "success",
+"metrics": {"resultCount": 3}
+}`
+
+func TestAsyncSpool(t *testing.T) {
+	f, err := ioutil.TempFile("", "async_spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	// a complete response, spooled in pieces
+	index := rowIndex{spoolRows: newSpoolRows()}
+	for i := 0; i < len(_TEST_SPOOL); i += 7 {
+		end := i + 7
+		if end > len(_TEST_SPOOL) {
+			end = len(_TEST_SPOOL)
+		}
+		index.write([]byte(_TEST_SPOOL[i:end]))
+	}
+	f.WriteString(_TEST_SPOOL)
+	f.Close()
+	fields, rows, count, err := readAsyncSpool(f.Name(), index.spoolRows, true, 1, 1)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if count != 3 || len(rows) != 1 || string(rows[0]) != `{"a":2}` {
+		t.Errorf("Expected 3 results and page [{\"a\":2}], got %v %s", count, rows)
+	}
+	if string(fields["status"]) != `"success"` || string(fields["metrics"]) != `{"resultCount": 3}` || fields["results"] != nil {
+		t.Errorf("Unexpected fields %v", fields)
+	}
+	_, rows, _, err = readAsyncSpool(f.Name(), index.spoolRows, true, 1, 10)
+	if err != nil || len(rows) != 2 || string(rows[1]) != `{"a":3}` {
+		t.Errorf("Expected last page [{\"a\":2},{\"a\":3}], got %s %v", rows, err)
+	}
+
+	// a response that is still being written: the row being written is not counted
+	truncated := _TEST_SPOOL[:75]
+	index = rowIndex{spoolRows: newSpoolRows()}
+	index.write([]byte(truncated))
+	ioutil.WriteFile(f.Name(), []byte(truncated), 0600)
+	_, rows, count, err = readAsyncSpool(f.Name(), index.spoolRows, true, 0, 10)
+	if err == nil {
+		t.Errorf("Expected error on truncated spool")
+	}
+	if count != 1 || len(rows) != 1 || string(rows[0]) != `{"a":1}` {
+		t.Errorf("Expected 1 result from truncated spool, got %v %s", count, rows)
+	}
+}
+
+func TestRowIndex(t *testing.T) {
+	spool := `{"results": [1, "a,]\"", [2, {"b": "}"}], {}],"results2": [3]}`
+	index := rowIndex{spoolRows: newSpoolRows()}
+	index.write([]byte(spool))
+	if len(index.rows) != 4 || index.end < 0 {
+		t.Fatalf("Expected 4 complete rows, got %v", index.spoolRows)
+	}
+	expected := []string{"1", `"a,]\""`, `[2, {"b": "}"}]`, "{}"}
+	for i, row := range index.rows {
+		end := index.end
+		if i+1 < len(index.rows) {
+			end = index.rows[i+1]
+		}
+		got := strings.TrimRight(spool[row:end], " ,")
+		if got != expected[i] {
+			t.Errorf("Expected row %v to be %v, got %v", i, expected[i], got)
+		}
+	}
+
+	empty := rowIndex{spoolRows: newSpoolRows()}
+	empty.write([]byte(`{"results": [ ], "status": "success"}`))
+	if empty.count() != 0 || empty.start < 0 || empty.end < 0 {
+		t.Errorf("Expected no rows, got %v", empty.spoolRows)
+	}
+}
+
+func TestAsyncQuota(t *testing.T) {
+	newSpool := func() (*asyncSpool, string) {
+		f, err := ioutil.TempFile("", "async_quota")
+		if err != nil {
+			t.Fatal(err)
+		}
+		spool := &asyncSpool{file: f, header: make(http.Header), code: http.StatusOK, quota: 1}
+		spool.index.spoolRows = newSpoolRows()
+		return spool, f.Name()
+	}
+	chunk := bytes.Repeat([]byte(" "), 600*1024)
+
+	first, firstPath := newSpool()
+	defer os.Remove(firstPath)
+	if _, err := first.Write(chunk); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	// the quota is shared by all spools
+	second, secondPath := newSpool()
+	defer os.Remove(secondPath)
+	_, err := second.Write(chunk)
+	if e, ok := err.(errors.Error); !ok || e.Code() != errors.SERVICE_ASYNC_QUOTA {
+		t.Errorf("Expected the quota to be exceeded, got %v", err)
+	}
+	if _, err = second.result(); err == nil {
+		t.Errorf("Expected the spool to keep the error")
+	}
+
+	// discarded results free their space
+	first.remove(firstPath)
+	if _, err = os.Stat(firstPath); !os.IsNotExist(err) {
+		t.Errorf("Expected the spool file to be removed, got %v", err)
+	}
+	third, thirdPath := newSpool()
+	defer third.remove(thirdPath)
+	if _, err = third.Write(chunk); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+	second.remove(secondPath)
+	if spooled := atomic.LoadInt64(&asyncSpooled); spooled != int64(len(chunk)) {
+		t.Errorf("Expected %v bytes spooled, got %v", len(chunk), spooled)
+	}
+}
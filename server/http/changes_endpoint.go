@@ -0,0 +1,197 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	json "github.com/couchbase/go_json"
+	"github.com/couchbase/query/audit"
+	"github.com/couchbase/query/auth"
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/expression"
+	"github.com/gorilla/mux"
+)
+
+// The changes endpoint streams the mutations of a keyspace as newline
+// delimited JSON objects.
+//
+// GET /query/changes/{namespace}/{keyspace}?since=<seqno>&feed=normal|continuous&timeout=<duration>&limit=<n>
+//
+// Every line but the last describes a mutation. The last line, and heartbeats
+// in continuous mode, carry the checkpoint to resume from: {"checkpoint":<seqno>}
+// A normal feed returns the mutations available, waiting up to timeout if
+// there are none. A continuous feed keeps streaming until limit mutations have
+// been sent, or the client disconnects, sending a heartbeat every timeout.
+const (
+	changesPrefix = "/query/changes"
+
+	_CHANGES_DEF_TIMEOUT = 30 * time.Second
+	_CHANGES_BATCH       = 256
+)
+
+func (this *HttpEndpoint) registerChangesHandlers() {
+	changesHandler := func(w http.ResponseWriter, req *http.Request) {
+		this.wrapAPI(w, req, doChanges)
+	}
+	this.mux.HandleFunc(changesPrefix+"/{namespace}/{keyspace}", changesHandler).Methods("GET")
+}
+
+func doChanges(endpoint *HttpEndpoint, w http.ResponseWriter, req *http.Request, af *audit.ApiAuditFields) (interface{}, errors.Error) {
+	vars := mux.Vars(req)
+	namespaceName := vars["namespace"]
+	keyspaceName := vars["keyspace"]
+
+	af.EventTypeId = audit.API_DO_NOT_AUDIT
+	ds := datastore.GetDatastore()
+	creds, err, _ := endpoint.getCredentialsFromRequest(ds, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// continuous feeds check the privilege again for every batch, so
+	// that they stop once it has been revoked
+	privs := auth.NewPrivileges()
+	privs.Add(namespaceName+":"+keyspaceName, auth.PRIV_QUERY_SELECT, auth.PRIV_PROPS_NONE)
+	_, err = ds.Authorize(privs, creds)
+	if err != nil {
+		return nil, err
+	}
+
+	namespace, err := ds.NamespaceByName(namespaceName)
+	if err != nil {
+		return nil, err
+	}
+	keyspace, err := namespace.KeyspaceByName(keyspaceName)
+	if err != nil {
+		return nil, err
+	}
+	feed, ok := keyspace.(datastore.MutationFeed)
+	if !ok {
+		return nil, errors.NewMutationFeedNotSupportedError(keyspace.QualifiedName())
+	}
+
+	var since uint64
+	var limit int
+	var er error
+
+	continuous := false
+	timeout := _CHANGES_DEF_TIMEOUT
+	query := req.URL.Query()
+	if s := query.Get("since"); s != "" {
+		since, er = strconv.ParseUint(s, 10, 64)
+		if er != nil {
+			return nil, errors.NewServiceErrorBadValue(er, "since")
+		}
+	}
+	if s := query.Get("limit"); s != "" {
+		limit, er = strconv.Atoi(s)
+		if er != nil || limit < 0 {
+			return nil, errors.NewServiceErrorBadValue(er, "limit")
+		}
+	}
+	if s := query.Get("timeout"); s != "" {
+		timeout, er = time.ParseDuration(s)
+		if er != nil || timeout < 0 {
+			return nil, errors.NewServiceErrorBadValue(er, "timeout")
+		}
+	}
+	switch query.Get("feed") {
+	case "", "normal":
+	case "continuous":
+		continuous = true
+	default:
+		return nil, errors.NewServiceErrorUnrecognizedValue("feed", query.Get("feed"))
+	}
+
+	// validate the checkpoint before committing to a response
+	_, err = feed.Mutations(since, 1, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+
+	sent := 0
+	for {
+		batch := _CHANGES_BATCH
+		if limit > 0 && limit-sent < batch {
+			batch = limit - sent
+		}
+		mutations, err := feed.Mutations(since, batch, timeout)
+		if err != nil {
+			writeChangesLine(w, map[string]interface{}{"error": err})
+			break
+		}
+
+		for _, m := range mutations {
+			if !writeChangesLine(w, mutationLine(m)) {
+				return textPlain(""), nil
+			}
+			since = m.Seqno
+		}
+		sent += len(mutations)
+
+		if !continuous || (limit > 0 && sent >= limit) {
+			break
+		}
+
+		// heartbeat, which also tells us if the client has gone away
+		if len(mutations) == 0 && !writeChangesLine(w, map[string]interface{}{"checkpoint": since}) {
+			return textPlain(""), nil
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		select {
+		case <-req.Context().Done():
+			return textPlain(""), nil
+		default:
+		}
+
+		_, err = ds.Authorize(privs, creds)
+		if err != nil {
+			writeChangesLine(w, map[string]interface{}{"error": err})
+			break
+		}
+	}
+
+	writeChangesLine(w, map[string]interface{}{"checkpoint": since})
+	return textPlain(""), nil
+}
+
+func mutationLine(m *datastore.Mutation) map[string]interface{} {
+	line := map[string]interface{}{
+		"seqno": m.Seqno,
+		"type":  m.Type,
+		"key":   m.Key,
+		"time":  m.Time.Format(expression.DEFAULT_FORMAT),
+	}
+	if m.Value != nil {
+		line["value"] = m.Value
+	}
+	return line
+}
+
+func writeChangesLine(w http.ResponseWriter, line map[string]interface{}) bool {
+	buf, err := json.Marshal(line)
+	if err != nil {
+		return false
+	}
+	buf = append(buf, '\n')
+	_, err = w.Write(buf)
+	return err == nil
+}
@@ -0,0 +1,231 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package http
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/couchbase/query/audit"
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/server"
+	"github.com/couchbase/query/util"
+	"github.com/gorilla/mux"
+)
+
+// Requests submitted with page_size=<n> return the first n results, and leave
+// the execution pipeline paused for the next page to be fetched, rather than
+// having to execute the statement again with a larger OFFSET.
+// Every page but the last carries a continuation token:
+//
+// GET    /query/cursor/{token}    the next page of results
+// DELETE /query/cursor/{token}    stop the request
+//
+// A paused request stays active, holding on to its servicer and to the memory
+// it accounts for against memory_quota, until it is resumed to completion,
+// deleted, or has been left idle for longer than cursor_timeout.
+// So cursor_timeout is capped by the max-cursor-timeout setting, and paged
+// requests are turned down while max-cursors of them are open.
+const (
+	cursorPrefix = "/query/cursor"
+
+	_CURSOR_DEF_IDLE = time.Minute
+)
+
+type cursor struct {
+	token    string
+	users    []string
+	pageSize int
+	pageRows int
+	idle     time.Duration
+	bufpool  BufferPool
+	next     chan http.ResponseWriter // the writer for the next page
+	pageDone chan bool                // the current page has been written
+	stop     chan bool                // the request has been stopped
+	stopOnce sync.Once
+}
+
+var cursors = util.NewGenCache(-1)
+
+func (this *HttpEndpoint) registerCursorHandlers() {
+	cursorHandler := func(w http.ResponseWriter, req *http.Request) {
+		this.wrapAPI(w, req, doCursor)
+	}
+	this.mux.HandleFunc(cursorPrefix+"/{token}", cursorHandler).Methods("GET", "DELETE")
+}
+
+// Runs a paged request detached from the http request, and returns once the
+// first page has been written
+// Like asynchronous requests, paged requests are not pooled.
+func (this *HttpEndpoint) serveCursor(request *httpRequest) {
+	var cancel context.CancelFunc
+
+	if max := this.server.MaxCursors(); cursors.Size() >= max {
+		request.Fail(errors.NewServiceErrorTooManyCursors(max))
+		request.Failed(this.server)
+		this.doStats(request, this.server)
+		return
+	}
+
+	c := &cursor{
+		token:    request.Id().String(),
+		users:    requestUsers(request),
+		pageSize: request.pageSize,
+		idle:     cursorIdle(request.cursorIdle, this.server.MaxCursorTimeout()),
+		bufpool:  this.bufpool,
+		next:     make(chan http.ResponseWriter),
+		pageDone: make(chan bool, 1),
+		stop:     make(chan bool),
+	}
+	request.cursor = c
+	request.req, cancel = detachRequest(request.req)
+	resp := request.resp
+
+	cursors.Add(c, c.token, nil)
+	this.actives.Put(request)
+
+	go func() {
+		defer cancel()
+
+		var res bool
+		if request.ScanConsistency() == datastore.UNBOUNDED && request.TxId() == "" {
+			res = this.server.ServiceRequest(request)
+		} else {
+			res = this.server.PlusServiceRequest(request)
+		}
+
+		// only possible before the first page
+		if !res {
+			resp.WriteHeader(http.StatusServiceUnavailable)
+		}
+		this.actives.Delete(c.token, false)
+		c.close()
+		cursors.Delete(c.token, nil)
+		this.doStats(request, this.server)
+		c.pageDone <- true
+	}()
+
+	<-c.pageDone
+}
+
+func doCursor(endpoint *HttpEndpoint, w http.ResponseWriter, req *http.Request, af *audit.ApiAuditFields) (interface{}, errors.Error) {
+	token := mux.Vars(req)["token"]
+	af.EventTypeId = audit.API_DO_NOT_AUDIT
+
+	e := cursors.Get(token, nil)
+	if e == nil {
+		return nil, errors.NewServiceErrorNoSuchCursor(token)
+	}
+	c := e.(*cursor)
+	err := endpoint.verifyRequestOwner(c.users, req, af)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Method == "DELETE" {
+		endpoint.actives.Delete(token, true)
+		return textPlain(""), nil
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	select {
+	case c.next <- w:
+	case <-c.stop:
+		return nil, errors.NewServiceErrorNoSuchCursor(token)
+	case <-req.Context().Done():
+		return textPlain(""), nil
+	}
+	<-c.pageDone
+	return textPlain(""), nil
+}
+
+// the idle time asked for by the request, within the server maximum
+func cursorIdle(requested, max time.Duration) time.Duration {
+	if requested <= 0 {
+		requested = _CURSOR_DEF_IDLE
+	}
+	if max > 0 && requested > max {
+		requested = max
+	}
+	return requested
+}
+
+func (this *cursor) close() {
+	this.stopOnce.Do(func() {
+		close(this.stop)
+	})
+}
+
+// Stopping a paused request has to wake the pipeline up
+func (this *httpRequest) Stop(state server.State) {
+	this.BaseRequest.Stop(state)
+	if this.cursor != nil {
+		this.cursor.close()
+	}
+	if this.ws != nil {
+		this.ws.close()
+	}
+}
+
+// Called for every result of a paged request: once the page is full, the
+// response is completed with a continuation token, and the pipeline is held
+// until the next page is requested
+func (this *httpRequest) pageResult() bool {
+	c := this.cursor
+	c.pageRows++
+	if c.pageRows < c.pageSize {
+		return true
+	}
+
+	this.writeString("\n")
+	this.writeString(this.prefix)
+	this.writeString("],\n")
+	this.writeString(this.prefix)
+	this.writeString("\"continuation\": \"")
+	this.writeString(c.token)
+	this.writeString("\",\n")
+	this.writeString(this.prefix)
+	this.writeString("\"status\": \"")
+	this.writeString(server.RUNNING.StateName())
+	this.writeString("\",\n")
+	this.writeString(this.prefix)
+	this.writeString("\"metrics\": {\"resultCount\": ")
+	this.writeString(strconv.Itoa(c.pageRows))
+	if usedMemory := this.UsedMemory(); usedMemory > 0 {
+		this.writeString(", \"usedMemory\": ")
+		this.writeString(strconv.FormatUint(usedMemory, 10))
+	}
+	this.writeString("}\n}\n")
+	this.writer.noMoreData()
+	c.pageDone <- true
+
+	timer := time.NewTimer(c.idle)
+	defer timer.Stop()
+
+	select {
+	case w := <-c.next:
+		this.resp = w
+		NewBufferedWriter(&this.writer, this, c.bufpool)
+		c.pageRows = 0
+		return this.writeString("{\n") &&
+			this.writeRequestID(this.prefix) &&
+			this.writeString(",\n") &&
+			this.writeString(this.prefix) &&
+			this.writeString("\"results\": [")
+	case <-timer.C:
+		this.Error(errors.NewServiceErrorCursorTimeout(c.idle))
+		this.SetState(server.TIMEOUT)
+	case <-c.stop:
+	}
+	return false
+}
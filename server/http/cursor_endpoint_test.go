@@ -0,0 +1,65 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package http
+
+import (
+	"testing"
+	"time"
+
+	"github.com/couchbase/query/server"
+)
+
+func TestCursorIdle(t *testing.T) {
+	tests := []struct {
+		requested, max, expected time.Duration
+	}{
+		{0, time.Minute, _CURSOR_DEF_IDLE},
+		{30 * time.Second, time.Minute, 30 * time.Second},
+		{24 * time.Hour, 10 * time.Minute, 10 * time.Minute},
+		{24 * time.Hour, 0, 24 * time.Hour},
+	}
+	for _, test := range tests {
+		if idle := cursorIdle(test.requested, test.max); idle != test.expected {
+			t.Errorf("Expected idle %v for %v capped at %v, got %v", test.expected, test.requested, test.max, idle)
+		}
+	}
+}
+
+func TestCursorTimeoutParam(t *testing.T) {
+	_, err := doJsonEncodedPost(map[string]interface{}{
+		"statement":      "select 1",
+		"cursor_timeout": "24h",
+	})
+	if err != nil {
+		t.Errorf("Unexpected error in HTTP request: %v", err)
+	}
+	if test_server.request().cursorIdle != 24*time.Hour {
+		t.Errorf("Expected cursor timeout 24h, got %v", test_server.request().cursorIdle)
+	}
+}
+
+func TestMaxCursors(t *testing.T) {
+	srvr := makeMockServer()
+	if srvr.MaxCursorTimeout() != server.MAX_CURSOR_TIMEOUT_DEFAULT {
+		t.Errorf("Expected default max cursor timeout, got %v", srvr.MaxCursorTimeout())
+	}
+
+	// a quarter of the 4 servicers of the mock server
+	if srvr.MaxCursors() != 1 {
+		t.Errorf("Expected 1 cursor by default, got %v", srvr.MaxCursors())
+	}
+	srvr.SetMaxCursors(3)
+	if srvr.MaxCursors() != 3 || srvr.MaxCursorsSetting() != 3 {
+		t.Errorf("Expected 3 cursors, got %v", srvr.MaxCursors())
+	}
+	srvr.SetMaxCursorTimeout(0)
+	if srvr.MaxCursorTimeout() != server.MAX_CURSOR_TIMEOUT_DEFAULT {
+		t.Errorf("Expected default max cursor timeout, got %v", srvr.MaxCursorTimeout())
+	}
+}
@@ -0,0 +1,97 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package http
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/couchbase/query/audit"
+	"github.com/couchbase/query/auth"
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/formatter"
+	"github.com/couchbase/query/parser/n1ql"
+	"github.com/couchbase/query/server"
+)
+
+// Statements are formatted and linted, but not executed:
+//
+// GET|POST /query/format?statement=...&query_context=...
+//
+// The response holds the statement in canonical form, and lint warnings.
+// Warnings that depend on keyspace sizes and indexes are only given for the
+// keyspaces the caller is allowed to query.
+const formatPrefix = "/query/format"
+
+func (this *HttpEndpoint) registerFormatHandlers() {
+	formatHandler := func(w http.ResponseWriter, req *http.Request) {
+		this.wrapAPI(w, req, doFormat)
+	}
+	this.mux.HandleFunc(formatPrefix, formatHandler).Methods("GET", "POST")
+}
+
+func doFormat(endpoint *HttpEndpoint, w http.ResponseWriter, req *http.Request, af *audit.ApiAuditFields) (interface{}, errors.Error) {
+	af.EventTypeId = audit.API_DO_NOT_AUDIT
+
+	// Limit body size in case of denial-of-service attack
+	req.Body = http.MaxBytesReader(w, req.Body, int64(endpoint.server.RequestSizeCap()))
+
+	// http.BasicAuth eats the body, so verify credentials after getting the parameters
+	statement, queryContext, err := formatParams(req)
+	if err != nil {
+		return nil, err
+	}
+	ds := datastore.GetDatastore()
+	creds, err, _ := endpoint.getCredentialsFromRequest(ds, req)
+	if err != nil {
+		return nil, err
+	}
+	_, err = ds.Authorize(auth.NewPrivileges(), creds)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt, perr := n1ql.ParseStatement2(statement, endpoint.server.Namespace(), queryContext)
+	if perr != nil {
+		return nil, errors.NewParseSyntaxError(perr, "")
+	}
+	return map[string]interface{}{
+		"statement": formatter.Format(stmt, statement),
+		"warnings":  formatter.Lint(stmt, statement, server.NewLintCatalog(creds)),
+	}, nil
+}
+
+// parameters come as a form, or as a JSON object
+func formatParams(req *http.Request) (string, string, errors.Error) {
+	var params struct {
+		Statement    string `json:"statement"`
+		QueryContext string `json:"query_context"`
+	}
+
+	if req.Method == "POST" && strings.HasPrefix(req.Header.Get("Content-Type"), "application/json") {
+		body, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return "", "", errors.NewAdminBodyError(err)
+		}
+		if err = json.Unmarshal(body, &params); err != nil {
+			return "", "", errors.NewServiceErrorBadValue(err, "request body")
+		}
+	} else {
+		params.Statement = req.FormValue("statement")
+		params.QueryContext = req.FormValue("query_context")
+	}
+	if strings.TrimSpace(params.Statement) == "" {
+		return "", "", errors.NewServiceErrorMissingValue("statement")
+	}
+	return params.Statement, params.QueryContext, nil
+}
@@ -260,6 +260,14 @@ func (this *HttpEndpoint) ServeHTTP(resp http.ResponseWriter, req *http.Request)
 	request := requestPool.Get().(*httpRequest)
 	*request = httpRequest{}
 	newHttpRequest(request, resp, req, this.bufpool, this.server.RequestSizeCap(), this.server.Namespace())
+	if request.async && request.State() != server.FATAL && !this.server.ShuttingDown() {
+		this.serveAsync(request, resp)
+		return
+	}
+	if request.pageSize > 0 && request.State() != server.FATAL && !this.server.ShuttingDown() {
+		this.serveCursor(request)
+		return
+	}
 	defer func() {
 		requestPool.Put(request)
 	}()
@@ -372,6 +380,12 @@ func (this *HttpEndpoint) registerHandlers(staticPath string) {
 
 	this.registerClusterHandlers()
 	this.registerAccountingHandlers()
+	this.registerChangesHandlers()
+	this.registerAsyncHandlers()
+	this.registerCursorHandlers()
+	this.registerFormatHandlers()
+	this.registerSessionHandlers()
+	this.registerWebSocketHandlers()
 	this.registerStaticHandlers(staticPath)
 }
 
@@ -32,6 +32,7 @@ import (
 	"github.com/couchbase/query/prepareds"
 	"github.com/couchbase/query/server"
 	"github.com/couchbase/query/timestamp"
+	"github.com/couchbase/query/tracing"
 	"github.com/couchbase/query/util"
 	"github.com/couchbase/query/value"
 )
@@ -57,10 +58,15 @@ type httpRequest struct {
 	executionTime          time.Duration
 	transactionElapsedTime time.Duration
 
-	stmtCnt  int
-	consCnt  int
-	jsonArgs jsonArgs // ESCAPE analysis workaround
-	urlArgs  urlArgs  // ESCAPE analysis workaround
+	async      bool
+	pageSize   int
+	cursorIdle time.Duration
+	cursor     *cursor
+	ws         *wsRequest
+	stmtCnt    int
+	consCnt    int
+	jsonArgs   jsonArgs // ESCAPE analysis workaround
+	urlArgs    urlArgs  // ESCAPE analysis workaround
 }
 
 var zeroScanVectorSource = &ZeroScanVectorSource{}
@@ -119,6 +125,9 @@ func newHttpRequest(rv *httpRequest, resp http.ResponseWriter, req *http.Request
 	}
 	rv.SetUserAgent(userAgent)
 	rv.SetRemoteAddr(req.RemoteAddr)
+	if trace := tracing.Parse(req.Header.Get("traceparent"), req.Header.Get("tracestate")); trace != nil {
+		rv.SetTrace(trace)
+	}
 
 	if err == nil {
 		err = httpArgs.processParameters(rv)
@@ -129,6 +138,8 @@ func newHttpRequest(rv *httpRequest, resp http.ResponseWriter, req *http.Request
 			err = errors.NewServiceErrorMissingValue("statement or prepared")
 		} else if rv.stmtCnt > 1 {
 			err = errors.NewServiceErrorMultipleValues("statement and prepared")
+		} else if rv.async && rv.pageSize > 0 {
+			err = errors.NewServiceErrorMultipleValues("async and page_size")
 		}
 	}
 
@@ -395,6 +406,51 @@ func handlePretty(rv *httpRequest, httpArgs httpRequestArgs, parm string, val in
 	return err
 }
 
+func handleAsync(rv *httpRequest, httpArgs httpRequestArgs, parm string, val interface{}) errors.Error {
+	async, err := httpArgs.getTristateVal(parm, val)
+	if err == nil {
+		rv.async = (async == value.TRUE)
+	}
+	return err
+}
+
+func handlePageSize(rv *httpRequest, httpArgs httpRequestArgs, parm string, val interface{}) errors.Error {
+	pageSize, err := httpArgs.getIntVal(parm, val)
+	if err == nil {
+		rv.pageSize = pageSize
+	}
+	return err
+}
+
+func handleCursorTimeout(rv *httpRequest, httpArgs httpRequestArgs, parm string, val interface{}) errors.Error {
+	var timeout time.Duration
+
+	t, err := httpArgs.getStringVal(parm, val)
+	if err == nil && t != "" {
+		timeout, err = newDuration(t)
+		if err == nil {
+			rv.cursorIdle = timeout
+		}
+	}
+	return err
+}
+
+func handleResultCache(rv *httpRequest, httpArgs httpRequestArgs, parm string, val interface{}) errors.Error {
+	resultCache, err := httpArgs.getTristateVal(parm, val)
+	if err == nil {
+		rv.SetResultCache(resultCache == value.TRUE)
+	}
+	return err
+}
+
+func handleSessionId(rv *httpRequest, httpArgs httpRequestArgs, parm string, val interface{}) errors.Error {
+	sessionId, err := httpArgs.getStringVal(parm, val)
+	if err == nil {
+		rv.SetSessionId(sessionId)
+	}
+	return err
+}
+
 func handleAutoPrepare(rv *httpRequest, httpArgs httpRequestArgs, parm string, val interface{}) errors.Error {
 	autoPrepare, err := httpArgs.getTristateVal(parm, val)
 	if err == nil {
@@ -771,6 +827,11 @@ const ( // Request argument names
 	ATRCOLLECTION      = "atrcollection"
 	NUMATRS            = "numatrs"
 	PRESERVE_EXPIRY    = "preserve_expiry"
+	ASYNC              = "async"
+	PAGE_SIZE          = "page_size"
+	CURSOR_TIMEOUT     = "cursor_timeout"
+	RESULT_CACHE       = "result_cache"
+	SESSION_ID         = "session_id"
 )
 
 type argHandler struct {
@@ -824,6 +885,11 @@ var _PARAMETERS = map[string]*argHandler{
 	ATRCOLLECTION: {handleAtrCollection, false},
 	//	NUMATRS:            {handleNumAtrs, false},
 	PRESERVE_EXPIRY: {handlePreserveExpiry, false},
+	ASYNC:           {handleAsync, false},
+	PAGE_SIZE:       {handlePageSize, false},
+	CURSOR_TIMEOUT:  {handleCursorTimeout, false},
+	RESULT_CACHE:    {handleResultCache, false},
+	SESSION_ID:      {handleSessionId, false},
 }
 
 // common storage for the httpArgs implementations
@@ -46,6 +46,9 @@ func (this *httpRequest) Fail(err errors.Error) {
 	// Determine the appropriate http response code based on the error
 	httpRespCode := mapErrorToHttpResponse(err, http.StatusInternalServerError)
 	this.setHttpCode(httpRespCode)
+	if httpRespCode == http.StatusTooManyRequests {
+		this.setRetryAfter(err)
+	}
 	// Add error to the request
 	this.Error(err)
 }
@@ -76,10 +79,14 @@ func mapErrorToHttpResponse(err errors.Error, def int) int {
 		return http.StatusServiceUnavailable
 	case 1181:
 		return http.StatusServiceUnavailable
+	case errors.SERVICE_USER_RATE_LIMIT, errors.SERVICE_USER_REQUEST_LIMIT, errors.SERVICE_USER_MEMORY_LIMIT:
+		return http.StatusTooManyRequests
 	case 13014:
 		return http.StatusUnauthorized
 	case 3000: // parse error range
 		return http.StatusBadRequest
+	case errors.SERVICE_NO_SUCH_SESSION:
+		return http.StatusNotFound
 	case 4000, errors.NO_SUCH_PREPARED: // plan error range
 		return http.StatusNotFound
 	case 4300:
@@ -95,6 +102,17 @@ func mapErrorToHttpResponse(err errors.Error, def int) int {
 	}
 }
 
+// user limit errors say how long to wait before retrying
+func (this *httpRequest) setRetryAfter(err errors.Error) {
+	cause, ok := err.Cause().(map[string]interface{})
+	if !ok {
+		return
+	}
+	if retryAfter, ok := cause["retry_after"].(int64); ok && retryAfter > 0 {
+		this.resp.Header().Set("Retry-After", strconv.FormatInt(retryAfter, 10))
+	}
+}
+
 func (this *httpRequest) httpCode() int {
 	this.RLock()
 	rv := this.httpRespCode
@@ -254,7 +272,7 @@ func (this *httpRequest) Result(item value.AnnotatedValue) bool {
 	this.writer.timeFlush()
 	beforeWrites := this.writer.mark()
 
-	if this.resultCount == 0 {
+	if this.resultCount == 0 || (this.cursor != nil && this.cursor.pageRows == 0) {
 		success = this.writer.write("\n")
 	} else {
 		success = this.writer.write(",\n")
@@ -282,6 +300,8 @@ func (this *httpRequest) Result(item value.AnnotatedValue) bool {
 	// did not work out: remove last writes so that we have a well formed document
 	if !success {
 		this.writer.truncate(beforeWrites)
+	} else if this.cursor != nil {
+		success = this.pageResult()
 	}
 	return success
 }
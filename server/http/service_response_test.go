@@ -0,0 +1,48 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/couchbase/query/errors"
+)
+
+func TestUserLimitRetryAfter(t *testing.T) {
+	tests := []struct {
+		err        errors.Error
+		retryAfter string
+	}{
+		{errors.NewServiceErrorUserRateLimit("l", "u", 2, 1500*time.Millisecond), "2"},
+		{errors.NewServiceErrorUserRequestLimit("l", "u", 1, time.Second), "1"},
+		{errors.NewServiceErrorUserMemoryLimit("l", "u", 100, time.Second), "1"},
+	}
+	for _, test := range tests {
+		if code := mapErrorToHttpResponse(test.err, http.StatusOK); code != http.StatusTooManyRequests {
+			t.Errorf("Expected %v for %v, got %v", http.StatusTooManyRequests, test.err, code)
+		}
+		resp := httptest.NewRecorder()
+		request := &httpRequest{resp: resp}
+		request.setRetryAfter(test.err)
+		if header := resp.Header().Get("Retry-After"); header != test.retryAfter {
+			t.Errorf("Expected Retry-After %v for %v, got %v", test.retryAfter, test.err, header)
+		}
+	}
+
+	// other errors carry no hint
+	resp := httptest.NewRecorder()
+	request := &httpRequest{resp: resp}
+	request.setRetryAfter(errors.NewServiceErrorTooManyCursors(1))
+	if header := resp.Header().Get("Retry-After"); header != "" {
+		t.Errorf("Unexpected Retry-After %v", header)
+	}
+}
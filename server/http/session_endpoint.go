@@ -0,0 +1,175 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package http
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/couchbase/query/audit"
+	"github.com/couchbase/query/auth"
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/server"
+	"github.com/couchbase/query/util"
+	"github.com/couchbase/query/value"
+	"github.com/gorilla/mux"
+)
+
+// Sessions hold variables, default parameters and temporary collections
+// for the requests that name them with the session_id parameter:
+//
+// POST   /query/session?timeout=<duration>&query_context=...&$name=<value>  start a session
+// GET    /query/session/{id}                                               the state of the session
+// DELETE /query/session/{id}                                               end the session
+//
+// A session ends when it has been idle for longer than its timeout, and can
+// only be used by the user who started it.
+const sessionPrefix = "/query/session"
+
+func (this *HttpEndpoint) registerSessionHandlers() {
+	startHandler := func(w http.ResponseWriter, req *http.Request) {
+		this.wrapAPI(w, req, doStartSession)
+	}
+	sessionHandler := func(w http.ResponseWriter, req *http.Request) {
+		this.wrapAPI(w, req, doSession)
+	}
+	this.mux.HandleFunc(sessionPrefix, startHandler).Methods("POST")
+	this.mux.HandleFunc(sessionPrefix+"/{id}", sessionHandler).Methods("GET", "DELETE")
+}
+
+func doStartSession(endpoint *HttpEndpoint, w http.ResponseWriter, req *http.Request, af *audit.ApiAuditFields) (interface{}, errors.Error) {
+	af.EventTypeId = audit.API_DO_NOT_AUDIT
+
+	// http.BasicAuth eats the body, so verify credentials after getting the parameters
+	timeout, queryContext, defaults, err := sessionParams(req)
+	if err != nil {
+		return nil, err
+	}
+	creds, err := endpoint.sessionCredentials(req)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := server.StartSession(creds, timeout, defaults, queryContext)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"session_id": session.Id(),
+		"timeout":    session.Timeout().String(),
+	}, nil
+}
+
+func doSession(endpoint *HttpEndpoint, w http.ResponseWriter, req *http.Request, af *audit.ApiAuditFields) (interface{}, errors.Error) {
+	id := mux.Vars(req)["id"]
+	af.EventTypeId = audit.API_DO_NOT_AUDIT
+
+	creds, err := endpoint.sessionCredentials(req)
+	if err != nil {
+		return nil, err
+	}
+	if req.Method == "DELETE" {
+		return true, server.EndSession(id, creds)
+	}
+
+	session, err := server.GetSession(id, creds)
+	if err != nil {
+		return nil, err
+	}
+	defer session.Release()
+	return session.Format(), nil
+}
+
+func (this *HttpEndpoint) sessionCredentials(req *http.Request) (*auth.Credentials, errors.Error) {
+	ds := datastore.GetDatastore()
+	creds, err, _ := this.getCredentialsFromRequest(ds, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// check that the user is who they say they are
+	_, err = ds.Authorize(auth.NewPrivileges(), creds)
+	if err != nil {
+		return nil, err
+	}
+	return creds, nil
+}
+
+// parameters come as a form, or as a JSON object; default named parameters start with $
+func sessionParams(req *http.Request) (time.Duration, string, map[string]value.Value, errors.Error) {
+	var timeout time.Duration
+	var queryContext string
+
+	defaults := make(map[string]value.Value)
+	if strings.HasPrefix(req.Header.Get("Content-Type"), "application/json") {
+		var params map[string]interface{}
+
+		body, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return 0, "", nil, errors.NewAdminBodyError(err)
+		}
+		if len(body) > 0 {
+			if err = json.Unmarshal(body, &params); err != nil {
+				return 0, "", nil, errors.NewServiceErrorBadValue(err, "request body")
+			}
+		}
+		for n, v := range params {
+			switch n {
+			case TIMEOUT:
+				t, ok := v.(string)
+				if !ok {
+					return 0, "", nil, errors.NewServiceErrorTypeMismatch(TIMEOUT, "string")
+				}
+				timeout, err = time.ParseDuration(t)
+				if err != nil {
+					return 0, "", nil, errors.NewServiceErrorBadValue(err, TIMEOUT)
+				}
+			case QUERY_CONTEXT:
+				s, ok := v.(string)
+				if !ok {
+					return 0, "", nil, errors.NewServiceErrorTypeMismatch(QUERY_CONTEXT, "string")
+				}
+				queryContext = s
+			default:
+				if !strings.HasPrefix(n, "$") {
+					return 0, "", nil, errors.NewServiceErrorUnrecognizedParameter(n)
+				}
+				defaults[n[1:]] = value.NewValue(v)
+			}
+		}
+	} else {
+		req.ParseForm()
+		for n, v := range req.Form {
+			if len(v) == 0 {
+				continue
+			}
+			switch n {
+			case TIMEOUT:
+				var err error
+				timeout, err = time.ParseDuration(v[0])
+				if err != nil {
+					return 0, "", nil, errors.NewServiceErrorBadValue(err, TIMEOUT)
+				}
+			case QUERY_CONTEXT:
+				queryContext = v[0]
+			default:
+				if !strings.HasPrefix(n, "$") {
+					return 0, "", nil, errors.NewServiceErrorUnrecognizedParameter(n)
+				}
+				defaults[n[1:]] = value.NewValue([]byte(util.TrimSpace(v[0])))
+			}
+		}
+	}
+	return timeout, queryContext, defaults, nil
+}
@@ -0,0 +1,439 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/couchbase/query/auth"
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/logging"
+	"github.com/couchbase/query/server"
+	"golang.org/x/net/websocket"
+)
+
+// A websocket connection carries many concurrent requests for the user who
+// opened it:
+//
+// GET /query/ws
+//
+// Messages are JSON text frames, and name the request they refer to with an
+// id chosen by the client:
+//
+// {"type": "query", "id": "q1", "params": {"statement": "..."}}  start a request, params as for /query/service
+// {"type": "credit", "id": "q1", "frames": <n>}                   allow n more data frames for the request
+// {"type": "progress", "id": "q1"}                                 ask how far the request has got
+// {"type": "cancel", "id": "q1"}                                   stop the request
+//
+// The server answers with:
+//
+// {"type": "data", "id": "q1", "data": "..."}          the next part of the response, as /query/service would send it
+// {"type": "end", "id": "q1", "status": <http status>} the response is complete
+// {"type": "progress", "id": "q1", "progress": {...}}
+// {"type": "error", "id": "q1", "errors": [...]}       the message could not be processed
+//
+// A query message with "progress_interval": "1s" also gets progress frames every second.
+// Each request can have a limited number of data frames in flight: once they
+// are used up, the request pipeline is held until the client sends credit.
+const (
+	wsPrefix = "/query/ws"
+
+	_WS_WINDOW       = 16 // data frames a request can send before it needs credit
+	_WS_MAX_CREDIT   = 1024
+	_WS_MAX_REQUESTS = 64
+
+	_WS_MIN_PROGRESS_INTERVAL = 100 * time.Millisecond
+)
+
+const (
+	_WS_QUERY    = "query"
+	_WS_CREDIT   = "credit"
+	_WS_PROGRESS = "progress"
+	_WS_CANCEL   = "cancel"
+	_WS_DATA     = "data"
+	_WS_END      = "end"
+	_WS_ERROR    = "error"
+)
+
+type wsMessage struct {
+	Type     string                 `json:"type"`
+	Id       string                 `json:"id,omitempty"`
+	Params   json.RawMessage        `json:"params,omitempty"`
+	Interval string                 `json:"progress_interval,omitempty"`
+	Frames   int                    `json:"frames,omitempty"`
+	Data     string                 `json:"data,omitempty"`
+	Status   int                    `json:"status,omitempty"`
+	Progress map[string]interface{} `json:"progress,omitempty"`
+	Errors   []errors.Error         `json:"errors,omitempty"`
+}
+
+type wsConn struct {
+	sync.Mutex
+	endpoint *HttpEndpoint
+	ws       *websocket.Conn
+	ctx      context.Context
+	requests map[string]*wsRequest
+	wg       sync.WaitGroup
+}
+
+// wsRequest stands in for the client's http.ResponseWriter
+type wsRequest struct {
+	id       string
+	request  *httpRequest
+	header   http.Header
+	code     int
+	credit   chan bool
+	stop     chan bool
+	stopOnce sync.Once
+	send     func(*wsMessage) error
+}
+
+func (this *HttpEndpoint) registerWebSocketHandlers() {
+	wsServer := websocket.Server{
+		Handshake: checkWsOrigin,
+		Handler:   this.serveWebSocket,
+	}
+
+	// check credentials before upgrading, so that failures get an http status
+	wsHandler := func(w http.ResponseWriter, req *http.Request) {
+		ds := datastore.GetDatastore()
+		creds, err, _ := this.getCredentialsFromRequest(ds, req)
+		if err == nil {
+			_, err = ds.Authorize(auth.NewPrivileges(), creds)
+		}
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		wsServer.ServeHTTP(w, req)
+	}
+	this.mux.HandleFunc(wsPrefix, wsHandler).Methods("GET")
+}
+
+// browsers send credentials on cross site connections, so only same site origins are accepted
+func checkWsOrigin(config *websocket.Config, req *http.Request) error {
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(u.Host, req.Host) {
+		return fmt.Errorf("cross origin websocket connection from %v", origin)
+	}
+	return nil
+}
+
+func (this *HttpEndpoint) serveWebSocket(ws *websocket.Conn) {
+	var cancel context.CancelFunc
+
+	conn := &wsConn{
+		endpoint: this,
+		ws:       ws,
+		requests: make(map[string]*wsRequest),
+	}
+	conn.ctx, cancel = context.WithCancel(ws.Request().Context())
+	ws.MaxPayloadBytes = this.server.RequestSizeCap()
+
+	// requests still running when the client goes are stopped
+	defer func() {
+		cancel()
+		conn.stopAll()
+		conn.wg.Wait()
+		ws.Close()
+	}()
+
+	for {
+		var data []byte
+
+		err := websocket.Message.Receive(ws, &data)
+		if err == websocket.ErrFrameTooLarge {
+			conn.sendError("", errors.NewServiceErrorWsMessage(err))
+			continue
+		} else if err != nil {
+			return
+		}
+
+		var msg wsMessage
+		err = json.Unmarshal(data, &msg)
+		if err != nil {
+			conn.sendError("", errors.NewServiceErrorWsMessage(err))
+			continue
+		}
+		conn.process(&msg)
+	}
+}
+
+func (this *wsConn) process(msg *wsMessage) {
+	if msg.Id == "" {
+		this.sendError("", errors.NewServiceErrorMissingValue("id"))
+		return
+	}
+	if msg.Type == _WS_QUERY {
+		var interval time.Duration
+
+		if msg.Interval != "" {
+			var err error
+
+			interval, err = time.ParseDuration(msg.Interval)
+			if err != nil {
+				this.sendError(msg.Id, errors.NewServiceErrorBadValue(err, "progress_interval"))
+				return
+			} else if interval < _WS_MIN_PROGRESS_INTERVAL {
+				interval = _WS_MIN_PROGRESS_INTERVAL
+			}
+		}
+		this.start(msg.Id, msg.Params, interval)
+		return
+	}
+
+	this.Lock()
+	r := this.requests[msg.Id]
+	this.Unlock()
+	if r == nil {
+		this.sendError(msg.Id, errors.NewServiceErrorWsNoSuchRequest(msg.Id))
+		return
+	}
+
+	switch msg.Type {
+	case _WS_CREDIT:
+		r.addCredit(msg.Frames)
+	case _WS_PROGRESS:
+		this.send(&wsMessage{Type: _WS_PROGRESS, Id: msg.Id, Progress: wsProgress(r.request)})
+	case _WS_CANCEL:
+		this.endpoint.actives.Delete(r.request.Id().String(), true)
+	default:
+		this.sendError(msg.Id, errors.NewServiceErrorUnrecognizedParameter(msg.Type))
+	}
+}
+
+// Runs a request detached from the connection's reader, the way asynchronous requests run
+// Like those, requests are not pooled.
+func (this *wsConn) start(id string, params json.RawMessage, interval time.Duration) {
+	req, err := this.newHttpRequest(params)
+	if err != nil {
+		this.sendError(id, errors.NewServiceErrorWsMessage(err))
+		return
+	}
+
+	r := &wsRequest{
+		id:     id,
+		header: make(http.Header),
+		code:   http.StatusOK,
+		credit: make(chan bool, _WS_MAX_CREDIT),
+		stop:   make(chan bool),
+		send:   this.send,
+	}
+	r.addCredit(_WS_WINDOW)
+
+	this.Lock()
+	if this.requests[id] != nil {
+		this.Unlock()
+		this.sendError(id, errors.NewServiceErrorWsDuplicateRequest(id))
+		return
+	} else if len(this.requests) >= _WS_MAX_REQUESTS {
+		this.Unlock()
+		this.sendError(id, errors.NewServiceErrorWsTooManyRequests(_WS_MAX_REQUESTS))
+		return
+	}
+	this.requests[id] = r
+	this.wg.Add(1)
+	this.Unlock()
+
+	endpoint := this.endpoint
+	request := &httpRequest{}
+	newHttpRequest(request, r, req, endpoint.bufpool, endpoint.server.RequestSizeCap(), endpoint.server.Namespace())
+	request.ws = r
+	r.request = request
+
+	// progress frames stop before the end frame goes
+	var ticker sync.WaitGroup
+	done := make(chan bool)
+	if interval > 0 {
+		ticker.Add(1)
+		go func() {
+			defer ticker.Done()
+			r.sendProgress(interval, done)
+		}()
+	}
+
+	go func() {
+		defer func() {
+			this.Lock()
+			delete(this.requests, id)
+			this.Unlock()
+			this.wg.Done()
+		}()
+
+		res := true
+		if endpoint.server.ShuttingDown() && request.TxId() == "" {
+			logging.Infof("Incoming request from '%v' rejected during service shutdown.", req.RemoteAddr)
+			if endpoint.server.ShutDown() {
+				request.Fail(errors.NewServiceShutDownError())
+			} else {
+				request.Fail(errors.NewServiceShuttingDownError())
+			}
+			request.Failed(endpoint.server)
+		} else if request.State() == server.FATAL {
+			request.Failed(endpoint.server)
+		} else {
+			endpoint.actives.Put(request)
+			if request.ScanConsistency() == datastore.UNBOUNDED && request.TxId() == "" {
+				res = endpoint.server.ServiceRequest(request)
+			} else {
+				res = endpoint.server.PlusServiceRequest(request)
+			}
+			endpoint.actives.Delete(request.Id().String(), false)
+		}
+		endpoint.doStats(request, endpoint.server)
+
+		close(done)
+		ticker.Wait()
+		code := r.code
+		if !res {
+			code = http.StatusServiceUnavailable
+		}
+		this.send(&wsMessage{Type: _WS_END, Id: id, Status: code})
+	}()
+}
+
+// requests are made as if they had been posted to the service endpoint on the connection
+func (this *wsConn) newHttpRequest(params json.RawMessage) (*http.Request, error) {
+	upgrade := this.ws.Request()
+	req, err := http.NewRequest("POST", servicePrefix, bytes.NewReader(params))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range upgrade.Header {
+		switch k {
+		case "Upgrade", "Connection", "Content-Length", "Content-Type", "Origin":
+			continue
+		}
+		if strings.HasPrefix(k, "Sec-Websocket-") {
+			continue
+		}
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Host = upgrade.Host
+	req.RemoteAddr = upgrade.RemoteAddr
+	req.TLS = upgrade.TLS
+	return req.WithContext(this.ctx), nil
+}
+
+func (this *wsConn) send(msg *wsMessage) error {
+	err := websocket.JSON.Send(this.ws, msg)
+	if err != nil {
+		logging.Debugf("websocket send to %v failed: %v", this.ws.Request().RemoteAddr, err)
+	}
+	return err
+}
+
+func (this *wsConn) sendError(id string, err errors.Error) {
+	this.send(&wsMessage{Type: _WS_ERROR, Id: id, Errors: []errors.Error{err}})
+}
+
+func (this *wsConn) stopAll() {
+	this.Lock()
+	requests := make([]*wsRequest, 0, len(this.requests))
+	for _, r := range this.requests {
+		requests = append(requests, r)
+	}
+	this.Unlock()
+	for _, r := range requests {
+		if r.request != nil {
+			this.endpoint.actives.Delete(r.request.Id().String(), true)
+		}
+		r.close()
+	}
+}
+
+func (this *wsRequest) Header() http.Header {
+	return this.header
+}
+
+func (this *wsRequest) WriteHeader(code int) {
+	this.code = code
+}
+
+// each write is one data frame, and waits for credit
+// Stopped requests are not held, so that they can complete their response.
+func (this *wsRequest) Write(b []byte) (int, error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+	select {
+	case <-this.credit:
+	case <-this.stop:
+	}
+	err := this.send(&wsMessage{Type: _WS_DATA, Id: this.id, Data: string(b)})
+	if err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// frames go out as they are written, there is nothing to flush
+func (this *wsRequest) Flush() {
+}
+
+func (this *wsRequest) addCredit(frames int) {
+	for ; frames > 0; frames-- {
+		select {
+		case this.credit <- true:
+		default:
+			return
+		}
+	}
+}
+
+func (this *wsRequest) close() {
+	this.stopOnce.Do(func() {
+		close(this.stop)
+	})
+}
+
+func (this *wsRequest) sendProgress(interval time.Duration, done chan bool) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			this.send(&wsMessage{Type: _WS_PROGRESS, Id: this.id, Progress: wsProgress(this.request)})
+		case <-done:
+			return
+		}
+	}
+}
+
+func wsProgress(request *httpRequest) map[string]interface{} {
+	rv := server.RequestProgress(request)
+	rv["requestID"] = request.Id().String()
+	rv["state"] = request.State().StateName()
+	rv["elapsedTime"] = time.Since(request.RequestTime()).String()
+	if p := request.Output().FmtPhaseCounts(); p != nil {
+		rv["phaseCounts"] = p
+	}
+	if usedMemory := request.UsedMemory(); usedMemory != 0 {
+		rv["usedMemory"] = usedMemory
+	}
+	return rv
+}
@@ -0,0 +1,67 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package http
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWsCredit(t *testing.T) {
+	frames := make(chan *wsMessage, 10)
+	r := &wsRequest{
+		id:     "q1",
+		credit: make(chan bool, 4),
+		stop:   make(chan bool),
+		send: func(msg *wsMessage) error {
+			frames <- msg
+			return nil
+		},
+	}
+	r.addCredit(10)
+	if len(r.credit) != 4 {
+		t.Errorf("Expected credit capped at 4, got %v", len(r.credit))
+	}
+
+	for i := 0; i < 4; i++ {
+		r.Write([]byte("[1]"))
+	}
+	if len(frames) != 4 {
+		t.Fatalf("Expected 4 frames, got %v", len(frames))
+	}
+
+	// out of credit, the writer is held
+	written := make(chan bool)
+	go func() {
+		r.Write([]byte("[2]"))
+		written <- true
+	}()
+	select {
+	case <-written:
+		t.Fatalf("Write did not wait for credit")
+	case <-time.After(50 * time.Millisecond):
+	}
+	r.addCredit(1)
+	<-written
+
+	// stopped requests are not held
+	r.close()
+	r.close()
+	r.Write([]byte("[3]"))
+	if len(frames) != 6 {
+		t.Fatalf("Expected 6 frames, got %v", len(frames))
+	}
+	for i := 0; i < 5; i++ {
+		<-frames
+	}
+	msg := <-frames
+	if msg.Type != _WS_DATA || msg.Id != "q1" || msg.Data != "[3]" {
+		t.Errorf("Unexpected frame %v", msg)
+	}
+}
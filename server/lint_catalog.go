@@ -0,0 +1,153 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package server
+
+import (
+	"github.com/couchbase/query/algebra"
+	"github.com/couchbase/query/auth"
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/formatter"
+	"github.com/couchbase/query/planner"
+	base "github.com/couchbase/query/plannerbase"
+)
+
+/*
+The lint catalog answers the linter's questions about keyspaces from the
+datastore, for the keyspaces the caller is allowed to query.
+Predicates are checked against the online secondary indexes of the keyspace
+with the same sargability analysis the planner uses to choose index scans.
+*/
+type lintCatalog struct {
+	creds     *auth.Credentials
+	keyspaces map[string]*lintKeyspace
+}
+
+type lintKeyspace struct {
+	keyspace datastore.Keyspace
+	indexes  []datastore.Index
+	keys     map[string][]expression.Expressions // formalized index keys, by alias
+}
+
+func NewLintCatalog(creds *auth.Credentials) formatter.Catalog {
+	return &lintCatalog{
+		creds:     creds,
+		keyspaces: make(map[string]*lintKeyspace),
+	}
+}
+
+func (this *lintCatalog) keyspace(path *algebra.Path) *lintKeyspace {
+	name := path.FullName()
+	if ks, ok := this.keyspaces[name]; ok {
+		return ks
+	}
+
+	var rv *lintKeyspace
+	ds := datastore.GetDatastore()
+	privs, err := algebra.PrivilegesFromPath(auth.PRIV_QUERY_SELECT, path)
+	if ds != nil && err == nil && !path.IsSystem() {
+		if _, err = ds.Authorize(privs, this.creds); err == nil {
+			keyspace, err := datastore.GetKeyspace(path.Parts()...)
+			if err == nil {
+				rv = &lintKeyspace{keyspace: keyspace}
+			}
+		}
+	}
+	this.keyspaces[name] = rv
+	return rv
+}
+
+func (this *lintCatalog) Count(path *algebra.Path) (int64, bool) {
+	ks := this.keyspace(path)
+	if ks == nil {
+		return 0, false
+	}
+	count, err := ks.keyspace.Count(datastore.NULL_QUERY_CONTEXT)
+	return count, err == nil
+}
+
+func (this *lintCatalog) Sargable(path *algebra.Path, alias string, pred expression.Expression) (bool, bool) {
+	ks := this.keyspace(path)
+	if ks == nil || !ks.loadIndexes() {
+		return false, false
+	}
+
+	pred = pred.Copy()
+	pred, err := base.NewDNF(pred, true, true).Map(pred)
+	if err != nil {
+		return false, false
+	}
+	for _, keys := range ks.indexKeys(alias) {
+		if min, _, _, _ := planner.SargableFor(pred, keys, false, false); min > 0 {
+			return true, true
+		}
+	}
+	return false, true
+}
+
+func (this *lintKeyspace) loadIndexes() bool {
+	if this.indexes != nil {
+		return true
+	}
+
+	indexers, err := this.keyspace.Indexers()
+	if err != nil {
+		return false
+	}
+	indexes := []datastore.Index{}
+	for _, indexer := range indexers {
+		if indexer.Name() == datastore.FTS {
+			continue
+		}
+		idxes, err := indexer.Indexes()
+		if err != nil {
+			return false
+		}
+		for _, idx := range idxes {
+			state, _, err := idx.State()
+			if err != nil || state != datastore.ONLINE || idx.IsPrimary() || len(idx.RangeKey()) == 0 {
+				continue
+			}
+			indexes = append(indexes, idx)
+		}
+	}
+	this.indexes = indexes
+	this.keys = make(map[string][]expression.Expressions)
+	return true
+}
+
+// index keys are formalized against the alias the keyspace is queried as
+func (this *lintKeyspace) indexKeys(alias string) []expression.Expressions {
+	if rv, ok := this.keys[alias]; ok {
+		return rv
+	}
+
+	rv := make([]expression.Expressions, 0, len(this.indexes))
+	formalizer := expression.NewSelfFormalizer(alias, nil)
+	for _, index := range this.indexes {
+		keys := make(expression.Expressions, 0, len(index.RangeKey()))
+		for _, key := range index.RangeKey() {
+			formalizer.SetIndexScope()
+			key, err := formalizer.Map(key.Copy())
+			formalizer.ClearIndexScope()
+			if err == nil {
+				key, err = base.NewDNF(key, true, true).Map(key)
+			}
+			if err != nil {
+				break
+			}
+			keys = append(keys, key)
+		}
+		if len(keys) > 0 {
+			rv = append(rv, keys)
+		}
+	}
+	this.keys[alias] = rv
+	return rv
+}
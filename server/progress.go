@@ -0,0 +1,106 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package server
+
+import (
+	"github.com/couchbase/query/execution"
+)
+
+// execution phases, from the last a document goes through to the first
+var _PROGRESS_PHASES = []execution.Phases{
+	execution.INSERT,
+	execution.UPSERT,
+	execution.UPDATE,
+	execution.DELETE,
+	execution.MERGE,
+	execution.SORT,
+	execution.FILTER,
+	execution.HASH_NEST,
+	execution.NL_NEST,
+	execution.INDEX_NEST,
+	execution.NEST,
+	execution.HASH_JOIN,
+	execution.NL_JOIN,
+	execution.INDEX_JOIN,
+	execution.JOIN,
+	execution.FETCH,
+	execution.FTS_SEARCH,
+	execution.PRIMARY_SCAN,
+	execution.INDEX_SCAN,
+	execution.INDEX_COUNT,
+	execution.COUNT,
+}
+
+// requests that count the results they have sent
+type resultCounter interface {
+	EventResultCount() int
+}
+
+// Estimates how far a request has got: the phase it is in, the documents its
+// scans have returned against the documents they are expected to return, and
+// the results sent so far.
+func RequestProgress(request Request) map[string]interface{} {
+	state := request.State()
+	counts := request.Output().FmtPhaseCounts()
+	rv := map[string]interface{}{
+		"phase": requestPhase(request, state, counts),
+	}
+
+	scanned := phaseCount(counts, execution.INDEX_SCAN) + phaseCount(counts, execution.PRIMARY_SCAN)
+	rv["documentsScanned"] = scanned
+	if context := request.ExecutionContext(); context != nil {
+		if estimate, ok := context.ScanEstimate(); ok {
+			rv["documentsEstimated"] = estimate
+			rv["percentComplete"] = percentComplete(scanned, estimate, state)
+		}
+	}
+	if counter, ok := request.(resultCounter); ok {
+		rv["resultCount"] = counter.EventResultCount()
+	}
+	return rv
+}
+
+// estimates are not exact, so a running request is never done
+func percentComplete(scanned uint64, estimate int64, state State) uint64 {
+	percent := uint64(100)
+	if estimate > 0 && scanned < uint64(estimate) {
+		percent = scanned * 100 / uint64(estimate)
+	}
+	if state == RUNNING && percent > 99 {
+		percent = 99
+	}
+	return percent
+}
+
+// a request is in the last phase documents have reached, or else in the first phase that has started
+func requestPhase(request Request, state State, counts map[string]interface{}) string {
+	if state != RUNNING {
+		return state.StateName()
+	}
+	for _, p := range _PROGRESS_PHASES {
+		if phaseCount(counts, p) > 0 {
+			return p.String()
+		}
+	}
+	operators := request.Output().FmtPhaseOperators()
+	for i := len(_PROGRESS_PHASES) - 1; i >= 0; i-- {
+		if _, ok := operators[_PROGRESS_PHASES[i].String()]; ok {
+			return _PROGRESS_PHASES[i].String()
+		}
+	}
+	if len(operators) > 0 {
+		return execution.RUN.String()
+	}
+	return execution.PLAN.String()
+}
+
+func phaseCount(counts map[string]interface{}, phase execution.Phases) uint64 {
+	count, _ := counts[phase.String()].(uint64)
+	return count
+}
@@ -0,0 +1,123 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package server
+
+import (
+	"testing"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/execution"
+	"github.com/couchbase/query/value"
+)
+
+// requests whose output is themselves, and which count results
+type progressRequest struct {
+	testRequest
+	results int
+}
+
+func newProgressRequest() *progressRequest {
+	rv := &progressRequest{}
+	NewBaseRequest(&rv.BaseRequest)
+	rv.SetScanConfiguration(&testScanConfig{datastore.UNBOUNDED})
+	rv.SetState(RUNNING)
+	return rv
+}
+
+func (this *progressRequest) Output() execution.Output {
+	return this
+}
+
+func (this *progressRequest) SetUp() {
+}
+
+func (this *progressRequest) Result(item value.AnnotatedValue) bool {
+	this.results++
+	return true
+}
+
+func (this *progressRequest) EventResultCount() int {
+	return this.results
+}
+
+func TestRequestPhase(t *testing.T) {
+	request := newProgressRequest()
+	phase := func() string {
+		return requestPhase(request, request.State(), request.FmtPhaseCounts())
+	}
+
+	if p := phase(); p != execution.PLAN.String() {
+		t.Errorf("Expected %v before operators start, got %v", execution.PLAN, p)
+	}
+	request.AddPhaseOperator(execution.AUTHORIZE)
+	if p := phase(); p != execution.RUN.String() {
+		t.Errorf("Expected %v once operators start, got %v", execution.RUN, p)
+	}
+
+	// the first phase started, until documents come through
+	request.AddPhaseOperator(execution.FETCH)
+	request.AddPhaseOperator(execution.PRIMARY_SCAN)
+	if p := phase(); p != execution.PRIMARY_SCAN.String() {
+		t.Errorf("Expected %v, got %v", execution.PRIMARY_SCAN, p)
+	}
+
+	// then the last phase documents have reached
+	request.AddPhaseCount(execution.PRIMARY_SCAN, 10)
+	request.AddPhaseCount(execution.FETCH, 5)
+	if p := phase(); p != execution.FETCH.String() {
+		t.Errorf("Expected %v, got %v", execution.FETCH, p)
+	}
+
+	request.SetState(COMPLETED)
+	if p := phase(); p != COMPLETED.StateName() {
+		t.Errorf("Expected %v once done, got %v", COMPLETED.StateName(), p)
+	}
+}
+
+func TestRequestProgress(t *testing.T) {
+	request := newProgressRequest()
+	request.AddPhaseOperator(execution.INDEX_SCAN)
+	request.AddPhaseCount(execution.INDEX_SCAN, 30)
+	request.AddPhaseCount(execution.PRIMARY_SCAN, 12)
+	request.Result(nil)
+	request.Result(nil)
+
+	progress := RequestProgress(request)
+	if progress["phase"] != execution.PRIMARY_SCAN.String() || progress["documentsScanned"] != uint64(42) {
+		t.Errorf("Unexpected progress %v", progress)
+	}
+	if progress["resultCount"] != 2 {
+		t.Errorf("Expected 2 results, got %v", progress["resultCount"])
+	}
+
+	// no estimate without an execution context
+	if _, ok := progress["percentComplete"]; ok {
+		t.Errorf("Unexpected estimate %v", progress)
+	}
+
+	tests := []struct {
+		scanned  uint64
+		estimate int64
+		state    State
+		percent  uint64
+	}{
+		{0, 100, RUNNING, 0},
+		{42, 100, RUNNING, 42},
+		{100, 100, RUNNING, 99},
+		{150, 100, RUNNING, 99},
+		{10, 0, RUNNING, 99},
+		{100, 100, COMPLETED, 100},
+		{150, 100, COMPLETED, 100},
+	}
+	for _, test := range tests {
+		if percent := percentComplete(test.scanned, test.estimate, test.state); percent != test.percent {
+			t.Errorf("Expected %v%% for %v of %v, got %v%%", test.percent, test.scanned, test.estimate, percent)
+		}
+	}
+}
@@ -20,7 +20,9 @@ import (
 	"github.com/couchbase/query/errors"
 	"github.com/couchbase/query/execution"
 	"github.com/couchbase/query/plan"
+	"github.com/couchbase/query/sessions"
 	"github.com/couchbase/query/timestamp"
+	"github.com/couchbase/query/tracing"
 	"github.com/couchbase/query/util"
 	"github.com/couchbase/query/value"
 )
@@ -149,6 +151,7 @@ type Request interface {
 	Servicing()
 	Fail(err errors.Error)
 	Error(err errors.Error)
+	Errors() []errors.Error
 	Execute(server *Server, context *execution.Context, reqType string, signature value.Value)
 	NotifyStop(stop execution.Operator)
 	Failed(server *Server)
@@ -162,6 +165,19 @@ type Request interface {
 	SetRemoteAddr(remoteAddr string)
 	UserAgent() string
 	SetUserAgent(userAgent string)
+	WorkloadGroup() *WorkloadGroup
+	SetWorkloadGroup(group *WorkloadGroup)
+	AsyncHandle() string
+	SetAsyncHandle(handle string)
+	ResultCache() bool
+	SetResultCache(resultCache bool)
+	SessionId() string
+	SetSessionId(id string)
+	Session() *sessions.Session
+	SetSession(session *sessions.Session)
+	Trace() *tracing.Trace
+	SetTrace(trace *tracing.Trace)
+	StartSpan(name string) *tracing.Span
 	SetTimings(o execution.Operator)
 	GetTimings() execution.Operator
 	IsAdHoc() bool
@@ -318,6 +334,13 @@ type BaseRequest struct {
 	numAtrs              int
 	preserveExpiry       bool
 	executionContext     *execution.Context
+	workloadGroup        *WorkloadGroup
+	asyncHandle          string
+	resultCache          bool
+	sessionId            string
+	session              *sessions.Session
+	trace                *tracing.Trace
+	traceSpan            *tracing.Span
 }
 
 type requestIDImpl struct {
@@ -647,6 +670,96 @@ func (this *BaseRequest) SetUserAgent(userAgent string) {
 	this.userAgent = userAgent
 }
 
+func (this *BaseRequest) WorkloadGroup() *WorkloadGroup {
+	return this.workloadGroup
+}
+
+func (this *BaseRequest) SetWorkloadGroup(group *WorkloadGroup) {
+	this.workloadGroup = group
+}
+
+func (this *BaseRequest) AsyncHandle() string {
+	return this.asyncHandle
+}
+
+func (this *BaseRequest) SetAsyncHandle(handle string) {
+	this.asyncHandle = handle
+}
+
+func (this *BaseRequest) ResultCache() bool {
+	return this.resultCache
+}
+
+func (this *BaseRequest) SetResultCache(resultCache bool) {
+	this.resultCache = resultCache
+}
+
+func (this *BaseRequest) SessionId() string {
+	return this.sessionId
+}
+
+func (this *BaseRequest) SetSessionId(id string) {
+	this.sessionId = id
+}
+
+func (this *BaseRequest) Session() *sessions.Session {
+	return this.session
+}
+
+func (this *BaseRequest) SetSession(session *sessions.Session) {
+	this.session = session
+}
+
+func (this *BaseRequest) Trace() *tracing.Trace {
+	return this.trace
+}
+
+// a traced request has a span covering its whole life
+func (this *BaseRequest) SetTrace(trace *tracing.Trace) {
+	this.trace = trace
+	this.traceSpan = trace.StartSpan("query", tracing.SERVER, nil)
+	if this.traceSpan != nil {
+		this.traceSpan.SetAttribute("db.system", "couchbase")
+		this.traceSpan.SetAttribute("db.couchbase.request_id", this.id.String())
+	}
+}
+
+func (this *BaseRequest) StartSpan(name string) *tracing.Span {
+	return this.traceSpan.StartChild(name, tracing.INTERNAL)
+}
+
+// phase timings are recorded as events of the request span
+func (this *BaseRequest) finishTrace(resultCount int, errorCount int) {
+	span := this.traceSpan
+	if span == nil {
+		return
+	}
+	this.traceSpan = nil
+
+	// collectors are external: literals are not exported, as they may be sensitive
+	span.SetAttribute("db.statement", this.normalizedStatement())
+	span.SetAttribute("db.operation", this.Type())
+	span.SetAttribute("db.couchbase.state", this.State().StateName())
+	span.SetAttribute("db.couchbase.result_count", resultCount)
+	for i := range this.phaseStats {
+		duration := atomic.LoadUint64(&this.phaseStats[i].duration)
+		if duration > 0 {
+			span.AddEvent(execution.Phases(i).String(), map[string]interface{}{
+				"duration":  time.Duration(duration),
+				"count":     atomic.LoadUint64(&this.phaseStats[i].count),
+				"operators": atomic.LoadUint64(&this.phaseStats[i].operators),
+			})
+		}
+	}
+	if errs := this.Errors(); errorCount > 0 && len(errs) > 0 {
+		span.SetError(errs[0].Error())
+	} else {
+		span.SetOk()
+	}
+	span.End()
+	this.trace.Finish()
+}
+
 func (this *BaseRequest) Servicing() {
 	this.serviceTime = time.Now()
 	this.state = RUNNING
@@ -1067,6 +1180,8 @@ func (this *BaseRequest) CompleteRequest(requestTime, serviceTime, transaction_t
 	}
 	LogRequest(requestTime, serviceTime, transaction_time, resultCount,
 		resultSize, errorCount, req, this, server)
+	statementStats.record(requestTime, serviceTime, resultCount, resultSize, errorCount, this)
+	this.finishTrace(resultCount, errorCount)
 
 	// Request Profiling - signal that request has completed and
 	// resources can be pooled / released as necessary
@@ -0,0 +1,580 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package server
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	atomic "github.com/couchbase/go-couchbase/platform"
+	"github.com/couchbase/query/algebra"
+	"github.com/couchbase/query/auth"
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/execution"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/logging"
+	"github.com/couchbase/query/parser/n1ql"
+	"github.com/couchbase/query/plan"
+	"github.com/couchbase/query/util"
+	"github.com/couchbase/query/value"
+)
+
+/*
+The result cache keeps the results of read-only, deterministic SELECT
+statements, for requests that ask for it, so that the same statement fired
+over and over, as dashboards do, is answered without being executed again.
+
+Entries are keyed by the normalized statement text, the named and positional
+arguments, the query context and the requesting users, and every hit is
+authorized against the privileges the statement requires. Only requests that
+do not ask for their own writes to be visible, ie with not_bounded scan
+consistency, use the cache.
+Entries expire after the cache TTL, are evicted least recently used first
+when the cache goes over its memory limit, and are invalidated when DML
+statements run by this engine modify any of the buckets they read from.
+Statements using volatile functions (NOW_*, RANDOM, UUID, CURL...) or user
+defined functions, reading system keyspaces or keyspaces named at run time,
+are not cached.
+Results captured while a DML statement modified one of the buckets they read
+from are dropped rather than cached, since they may predate the change.
+*/
+
+const (
+	RESULT_CACHE_TTL_DEFAULT = time.Minute
+
+	_RESULT_CACHE_ENTRY_SHARE = 10 // no entry may take more than a tenth of the cache
+)
+
+type ResultCacheEntry struct {
+	hits         atomic.AlignedUint64
+	key          string
+	statement    string
+	queryContext string
+	users        string
+	buckets      []string
+	privileges   *auth.Privileges
+	results      []value.Value
+	size         uint64
+	created      time.Time
+	lastUse      atomic.AlignedInt64
+}
+
+type resultCacheStats struct {
+	hits          atomic.AlignedUint64
+	misses        atomic.AlignedUint64
+	evictions     atomic.AlignedUint64
+	invalidations atomic.AlignedUint64
+}
+
+type resultCache struct {
+	stats   resultCacheStats
+	memory  atomic.AlignedUint64
+	limit   atomic.AlignedUint64 // bytes, 0 disables the cache
+	ttl     atomic.AlignedInt64
+	evictMu sync.Mutex
+	cache   *util.GenCache
+
+	// invalidation generations, by bucket, and for the whole cache
+	genMu       sync.Mutex
+	generation  uint64
+	generations map[string]uint64
+}
+
+var resultsCache = newResultCache()
+
+func newResultCache() *resultCache {
+	return &resultCache{
+		cache:       util.NewGenCache(-1),
+		ttl:         atomic.AlignedInt64(RESULT_CACHE_TTL_DEFAULT),
+		generations: make(map[string]uint64),
+	}
+}
+
+// Result cache settings, the memory limit in MB
+
+func ResultCacheMemory() uint64 {
+	return atomic.LoadUint64(&resultsCache.limit) / (1024 * 1024)
+}
+
+func SetResultCacheMemory(memory uint64) {
+	atomic.StoreUint64(&resultsCache.limit, memory*1024*1024)
+	if memory == 0 {
+		ResultCacheFlush()
+	} else {
+		resultsCache.evict(0)
+	}
+}
+
+func ResultCacheTTL() time.Duration {
+	return time.Duration(atomic.LoadInt64(&resultsCache.ttl))
+}
+
+func SetResultCacheTTL(ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = RESULT_CACHE_TTL_DEFAULT
+	}
+	atomic.StoreInt64(&resultsCache.ttl, int64(ttl))
+}
+
+// Result cache lookup: either a live entry, or a capture to record the
+// results of the request, if the statement can be cached at all
+func (this *resultCache) lookup(request Request, prepared *plan.Prepared,
+	context *execution.Context) (*ResultCacheEntry, *resultCapture) {
+
+	limit := atomic.LoadUint64(&this.limit)
+	if limit == 0 || prepared == nil || !prepared.Readonly() || prepared.Type() != "SELECT" ||
+		request.ScanConsistency() != datastore.UNBOUNDED {
+		return nil, nil
+	}
+
+	text := prepared.Text()
+	if text == "" {
+		text = request.Statement()
+	}
+	stmt, err := n1ql.ParseStatement2(text, context.Namespace(), request.QueryContext())
+	if err != nil {
+		return nil, nil
+	}
+	if prepare, ok := stmt.(*algebra.Prepare); ok {
+		stmt = prepare.Statement()
+	}
+	sel, ok := stmt.(*algebra.Select)
+	if !ok || !deterministic(sel.Expressions()) {
+		return nil, nil
+	}
+
+	privileges, err1 := sel.Privileges()
+	if err1 != nil {
+		return nil, nil
+	}
+	buckets := make([]string, 0, len(privileges.List))
+	for _, p := range privileges.List {
+		if p.Priv != auth.PRIV_QUERY_SELECT || p.Props != auth.PRIV_PROPS_NONE {
+			return nil, nil
+		}
+		buckets = append(buckets, targetBucket(p.Target))
+	}
+
+	// system keyspaces that filter on user privileges require none
+	normalized := sel.String()
+	if strings.Contains(normalized, "system`:") || strings.Contains(normalized, "system:") {
+		return nil, nil
+	}
+
+	users := datastore.CredsString(request.Credentials())
+	key := resultCacheKey(normalized, request, users)
+	if key == "" {
+		return nil, nil
+	}
+
+	entry := this.cache.Get(key, nil)
+	if entry != nil {
+		rv := entry.(*ResultCacheEntry)
+		if time.Since(rv.created) < ResultCacheTTL() {
+			atomic.AddUint64(&rv.hits, 1)
+			atomic.StoreInt64(&rv.lastUse, time.Now().UnixNano())
+			atomic.AddUint64(&this.stats.hits, 1)
+			return rv, nil
+		}
+		this.remove(key)
+	}
+	atomic.AddUint64(&this.stats.misses, 1)
+	return nil, &resultCapture{
+		Output: context.Output(),
+		cache:  this,
+		entry: &ResultCacheEntry{
+			key:          key,
+			statement:    normalized,
+			queryContext: request.QueryContext(),
+			users:        users,
+			buckets:      buckets,
+			privileges:   privileges,
+		},
+		generations: this.snapshot(buckets),
+		limit:       limit / _RESULT_CACHE_ENTRY_SHARE,
+	}
+}
+
+func resultCacheKey(normalized string, request Request, users string) string {
+	var buf strings.Builder
+
+	buf.WriteString(request.QueryContext())
+	buf.WriteByte('_')
+	buf.WriteString(users)
+	namedArgs := request.NamedArgs()
+	names := make([]string, 0, len(namedArgs))
+	for n, _ := range namedArgs {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		bytes, err := namedArgs[n].MarshalJSON()
+		if err != nil {
+			return ""
+		}
+		buf.WriteString("_$")
+		buf.WriteString(n)
+		buf.WriteByte('=')
+		buf.Write(bytes)
+	}
+	for _, a := range request.PositionalArgs() {
+		bytes, err := a.MarshalJSON()
+		if err != nil {
+			return ""
+		}
+		buf.WriteByte('_')
+		buf.Write(bytes)
+	}
+	key, err := util.UUIDV5(buf.String(), normalized)
+	if err != nil {
+		return ""
+	}
+	return key
+}
+
+func deterministic(exprs expression.Expressions) bool {
+	for _, expr := range exprs {
+		if expr == nil {
+			continue
+		}
+		if expr.HasExprFlag(expression.EXPR_IS_VOLATILE) {
+			return false
+		}
+		if _, ok := expr.(*expression.UserDefinedFunction); ok {
+			return false
+		}
+		if !deterministic(expr.Children()) {
+			return false
+		}
+	}
+	return true
+}
+
+// DML is tracked at the bucket level: "namespace:bucket.scope.collection"
+func targetBucket(target string) string {
+	if i := strings.IndexByte(target, '.'); i >= 0 {
+		return target[:i]
+	}
+	return target
+}
+
+// Serve a request from the cache
+// The request still goes through the Execute lifecycle, with the cached
+// results standing in for the operators.
+func (this *Server) serveCachedResults(request Request, prepared *plan.Prepared,
+	context *execution.Context, entry *ResultCacheEntry) {
+
+	_, err := this.datastore.Authorize(entry.privileges, request.Credentials())
+	if err != nil {
+		request.Fail(err)
+		request.Failed(this)
+		return
+	}
+
+	request.SetExecTime(time.Now())
+	go func() {
+		for _, v := range entry.results {
+			if !context.Result(value.NewAnnotatedValue(v)) {
+				break
+			}
+		}
+		context.CloseResults()
+	}()
+	request.Execute(this, context, request.Type(), prepared.Signature())
+}
+
+// Invalidate the entries that read from the buckets a DML statement modifies,
+// and the results being captured from them
+func (this *resultCache) invalidate(request Request, prepared *plan.Prepared, context *execution.Context) {
+	if atomic.LoadUint64(&this.limit) == 0 {
+		return
+	}
+
+	// whatever the transaction modified is only visible now
+	if request.Type() == "COMMIT" {
+		this.invalidateAll()
+		return
+	}
+	if prepared == nil || prepared.Readonly() {
+		return
+	}
+
+	text := prepared.Text()
+	if text == "" {
+		text = request.Statement()
+	}
+	stmt, err := n1ql.ParseStatement2(text, context.Namespace(), request.QueryContext())
+	if err == nil {
+		if prepare, ok := stmt.(*algebra.Prepare); ok {
+			stmt = prepare.Statement()
+		}
+	}
+	var privileges *auth.Privileges
+	var err1 errors.Error
+	if err == nil {
+		privileges, err1 = stmt.Privileges()
+	}
+	if err != nil || err1 != nil {
+		this.invalidateAll()
+		return
+	}
+
+	buckets := make(map[string]bool, len(privileges.List))
+	for _, p := range privileges.List {
+		if p.Props&auth.PRIV_PROPS_DYNAMIC_TARGET != 0 {
+			this.invalidateAll()
+			return
+		}
+		if p.Target != "" {
+			buckets[targetBucket(p.Target)] = true
+		}
+	}
+	this.invalidateBuckets(buckets)
+}
+
+func (this *resultCache) invalidateBuckets(buckets map[string]bool) {
+	this.genMu.Lock()
+	for b, _ := range buckets {
+		this.generations[b]++
+	}
+	this.genMu.Unlock()
+
+	keys := make([]string, 0)
+	this.cache.ForEach(func(key string, e interface{}) bool {
+		for _, b := range e.(*ResultCacheEntry).buckets {
+			if buckets[b] {
+				keys = append(keys, key)
+				break
+			}
+		}
+		return true
+	}, nil)
+	for _, key := range keys {
+		if this.remove(key) {
+			atomic.AddUint64(&this.stats.invalidations, 1)
+		}
+	}
+}
+
+func (this *resultCache) invalidateAll() {
+	this.genMu.Lock()
+	this.generation++
+	this.genMu.Unlock()
+	this.flush(true)
+}
+
+// the invalidation generations of the buckets, followed by the cache's own
+func (this *resultCache) snapshot(buckets []string) []uint64 {
+	rv := make([]uint64, len(buckets)+1)
+	this.genMu.Lock()
+	for i, b := range buckets {
+		rv[i] = this.generations[b]
+	}
+	rv[len(buckets)] = this.generation
+	this.genMu.Unlock()
+	return rv
+}
+
+// Add an entry, unless its buckets have been invalidated since the snapshot.
+// Invalidations move generations on before removing entries, so an entry
+// added under the lock is either seen as invalidated, or removed.
+func (this *resultCache) addCurrent(entry *ResultCacheEntry, generations []uint64) bool {
+	this.genMu.Lock()
+	defer this.genMu.Unlock()
+	for i, b := range entry.buckets {
+		if this.generations[b] != generations[i] {
+			return false
+		}
+	}
+	if this.generation != generations[len(entry.buckets)] {
+		return false
+	}
+	this.add(entry)
+	return true
+}
+
+func (this *resultCache) add(entry *ResultCacheEntry) {
+	limit := atomic.LoadUint64(&this.limit)
+	if limit == 0 || entry.size > limit/_RESULT_CACHE_ENTRY_SHARE {
+		return
+	}
+	entry.created = time.Now()
+	atomic.StoreInt64(&entry.lastUse, entry.created.UnixNano())
+
+	this.evict(entry.size)
+	this.cache.Add(entry, entry.key, func(e interface{}) util.Operation {
+		old := e.(*ResultCacheEntry)
+		atomic.AddUint64(&this.memory, ^(old.size - 1))
+		return util.REPLACE
+	})
+	atomic.AddUint64(&this.memory, entry.size)
+}
+
+func (this *resultCache) remove(key string) bool {
+	return this.cache.Delete(key, func(e interface{}) {
+		atomic.AddUint64(&this.memory, ^(e.(*ResultCacheEntry).size - 1))
+	})
+}
+
+// make room for size bytes, dropping expired entries first, and then the least
+// recently used
+func (this *resultCache) evict(size uint64) {
+	limit := atomic.LoadUint64(&this.limit)
+	if atomic.LoadUint64(&this.memory)+size <= limit {
+		return
+	}
+
+	this.evictMu.Lock()
+	defer this.evictMu.Unlock()
+
+	type candidate struct {
+		key     string
+		lastUse int64
+	}
+	candidates := make([]candidate, 0, this.cache.Size())
+	ttl := ResultCacheTTL()
+	this.cache.ForEach(func(key string, e interface{}) bool {
+		entry := e.(*ResultCacheEntry)
+		lastUse := atomic.LoadInt64(&entry.lastUse)
+		if time.Since(entry.created) >= ttl {
+			lastUse = 0
+		}
+		candidates = append(candidates, candidate{key, lastUse})
+		return true
+	}, nil)
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].lastUse < candidates[j].lastUse })
+
+	for _, c := range candidates {
+		if atomic.LoadUint64(&this.memory)+size <= limit {
+			break
+		}
+		if this.remove(c.key) {
+			atomic.AddUint64(&this.stats.evictions, 1)
+		}
+	}
+}
+
+func (this *resultCache) flush(invalidation bool) {
+	for _, key := range this.cache.Names() {
+		if this.remove(key) && invalidation {
+			atomic.AddUint64(&this.stats.invalidations, 1)
+		}
+	}
+}
+
+// resultCapture records the results of a request as they are sent
+type resultCapture struct {
+	execution.Output
+	cache       *resultCache
+	entry       *ResultCacheEntry
+	generations []uint64
+	limit       uint64
+	overflow    bool
+}
+
+func (this *resultCapture) Result(item value.AnnotatedValue) bool {
+	if !this.overflow {
+
+		// items are recycled once sent
+		bytes, err := json.Marshal(item)
+		if err != nil || this.entry.size+uint64(len(bytes)) > this.limit {
+			this.overflow = true
+			this.entry.results = nil
+		} else {
+			this.entry.results = append(this.entry.results, value.NewValue(bytes))
+			this.entry.size += uint64(len(bytes))
+		}
+	}
+	return this.Output.Result(item)
+}
+
+// only complete, successful results are cached
+func (this *resultCapture) complete(request Request) {
+	if this.overflow || request.State() != COMPLETED || len(request.Errors()) > 0 {
+		return
+	}
+	if !this.cache.addCurrent(this.entry, this.generations) {
+		logging.Debugf("Result cache: dropped invalidated results for %v", this.entry.key)
+		return
+	}
+	logging.Debugf("Result cache: added %v results for %v", len(this.entry.results), this.entry.key)
+}
+
+// Result cache and system keyspaces
+
+func ResultCacheFlush() {
+	resultsCache.flush(false)
+}
+
+func CountResultCache() int {
+	return resultsCache.cache.Size()
+}
+
+func NameResultCache() []string {
+	return resultsCache.cache.Names()
+}
+
+func ResultCacheForeach(nonBlocking func(string, *ResultCacheEntry) bool,
+	blocking func() bool) {
+	dummyF := func(id string, r interface{}) bool {
+		return nonBlocking(id, r.(*ResultCacheEntry))
+	}
+	resultsCache.cache.ForEach(dummyF, blocking)
+}
+
+func ResultCacheDo(key string, f func(*ResultCacheEntry)) {
+	var process func(interface{}) = nil
+
+	if f != nil {
+		process = func(entry interface{}) {
+			f(entry.(*ResultCacheEntry))
+		}
+	}
+	_ = resultsCache.cache.Get(key, process)
+}
+
+func ResultCacheDelete(key string) bool {
+	return resultsCache.remove(key)
+}
+
+func (this *ResultCacheEntry) Format() map[string]interface{} {
+	rv := map[string]interface{}{
+		"key":         this.key,
+		"statement":   this.statement,
+		"hits":        atomic.LoadUint64(&this.hits),
+		"resultCount": len(this.results),
+		"size":        this.size,
+		"created":     this.created.String(),
+		"lastUse":     time.Unix(0, atomic.LoadInt64(&this.lastUse)).String(),
+		"expires":     this.created.Add(ResultCacheTTL()).String(),
+		"keyspaces":   this.buckets,
+	}
+	if this.queryContext != "" {
+		rv["queryContext"] = this.queryContext
+	}
+	if this.users != "" {
+		rv["users"] = this.users
+	}
+	return rv
+}
+
+func ResultCacheStats() map[string]interface{} {
+	return map[string]interface{}{
+		"entries":       resultsCache.cache.Size(),
+		"memory":        atomic.LoadUint64(&resultsCache.memory),
+		"hits":          atomic.LoadUint64(&resultsCache.stats.hits),
+		"misses":        atomic.LoadUint64(&resultsCache.stats.misses),
+		"evictions":     atomic.LoadUint64(&resultsCache.stats.evictions),
+		"invalidations": atomic.LoadUint64(&resultsCache.stats.invalidations),
+	}
+}
@@ -0,0 +1,212 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package server
+
+import (
+	"testing"
+	"time"
+
+	atomic "github.com/couchbase/go-couchbase/platform"
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/execution"
+	"github.com/couchbase/query/plan"
+	"github.com/couchbase/query/timestamp"
+	"github.com/couchbase/query/value"
+)
+
+type testRequest struct {
+	BaseRequest
+}
+
+func newTestRequest(statement string, consistency datastore.ScanConsistency) *testRequest {
+	rv := &testRequest{}
+	NewBaseRequest(&rv.BaseRequest)
+	rv.SetStatement(statement)
+	rv.SetScanConfiguration(&testScanConfig{consistency})
+	return rv
+}
+
+func (this *testRequest) Output() execution.Output {
+	return nil
+}
+
+func (this *testRequest) Fail(err errors.Error) {
+	this.SetState(FATAL)
+}
+
+func (this *testRequest) Execute(server *Server, context *execution.Context, reqType string, signature value.Value) {
+}
+
+func (this *testRequest) Failed(server *Server) {
+}
+
+func (this *testRequest) Expire(state State, timeout time.Duration) {
+}
+
+type testScanConfig struct {
+	consistency datastore.ScanConsistency
+}
+
+func (this *testScanConfig) ScanConsistency() datastore.ScanConsistency {
+	return this.consistency
+}
+
+func (this *testScanConfig) ScanWait() time.Duration {
+	return 0
+}
+
+func (this *testScanConfig) ScanVectorSource() timestamp.ScanVectorSource {
+	return nil
+}
+
+func (this *testScanConfig) SetScanConsistency(consistency datastore.ScanConsistency) interface{} {
+	this.consistency = consistency
+	return this
+}
+
+func testPrepared(statement string) *plan.Prepared {
+	rv := plan.NewPrepared(plan.NewSequence(), nil, nil)
+	rv.SetType("SELECT")
+	rv.SetText(statement)
+	return rv
+}
+
+func TestResultCacheLookup(t *testing.T) {
+	cache := newResultCache()
+	cache.limit = 1024 * 1024
+	context := &execution.Context{}
+	stmt := "SELECT a FROM default:b WHERE c = 1"
+
+	// request_plus and at_plus requests must see their own writes
+	for _, consistency := range []datastore.ScanConsistency{datastore.SCAN_PLUS, datastore.AT_PLUS} {
+		entry, capture := cache.lookup(newTestRequest(stmt, consistency), testPrepared(stmt), context)
+		if entry != nil || capture != nil {
+			t.Errorf("Expected no caching for scan consistency %v", consistency)
+		}
+	}
+
+	// volatile functions
+	volatile := "SELECT NOW_STR() FROM default:b"
+	entry, capture := cache.lookup(newTestRequest(volatile, datastore.UNBOUNDED), testPrepared(volatile), context)
+	if entry != nil || capture != nil {
+		t.Errorf("Expected no caching for volatile functions")
+	}
+
+	request := newTestRequest(stmt, datastore.UNBOUNDED)
+	entry, capture = cache.lookup(request, testPrepared(stmt), context)
+	if entry != nil || capture == nil {
+		t.Fatalf("Expected a capture on miss, got %v %v", entry, capture)
+	}
+	capture.entry.results = []value.Value{value.NewValue(1)}
+	capture.entry.size = 1
+	request.SetState(COMPLETED)
+	capture.complete(request)
+
+	entry, capture = cache.lookup(newTestRequest(stmt, datastore.UNBOUNDED), testPrepared(stmt), context)
+	if entry == nil || capture != nil || len(entry.results) != 1 {
+		t.Fatalf("Expected a hit, got %v %v", entry, capture)
+	}
+	if entry.buckets[0] != "default:b" {
+		t.Errorf("Unexpected buckets %v", entry.buckets)
+	}
+
+	// other arguments, other entry
+	request = newTestRequest("SELECT a FROM default:b WHERE c = $1", datastore.UNBOUNDED)
+	request.SetPositionalArgs(value.Values{value.NewValue(1)})
+	entry, capture = cache.lookup(request, testPrepared(request.Statement()), context)
+	if entry != nil || capture == nil {
+		t.Errorf("Expected a miss for a different statement")
+	}
+}
+
+func TestResultCacheInvalidation(t *testing.T) {
+	cache := newResultCache()
+	cache.limit = 1024 * 1024
+
+	cache.add(&ResultCacheEntry{key: "k1", buckets: []string{"default:b1"}, size: 10})
+	cache.add(&ResultCacheEntry{key: "k2", buckets: []string{"default:b2", "default:b3"}, size: 20})
+	cache.invalidateBuckets(map[string]bool{"default:b3": true})
+	if cache.cache.Get("k1", nil) == nil || cache.cache.Get("k2", nil) != nil {
+		t.Errorf("Expected only k2 to be invalidated, have %v", cache.cache.Names())
+	}
+	if cache.memory != 10 || cache.stats.invalidations != 1 {
+		t.Errorf("Unexpected memory %v or invalidations %v", cache.memory, cache.stats.invalidations)
+	}
+
+	// results captured across a modification of their buckets are dropped
+	entry := &ResultCacheEntry{key: "k3", buckets: []string{"default:b1"}, size: 10}
+	generations := cache.snapshot(entry.buckets)
+	cache.invalidateBuckets(map[string]bool{"default:b1": true})
+	if cache.addCurrent(entry, generations) || cache.cache.Get("k3", nil) != nil {
+		t.Errorf("Expected stale results to be dropped")
+	}
+	if cache.cache.Get("k1", nil) != nil {
+		t.Errorf("Expected k1 to be invalidated")
+	}
+
+	// but not those captured across the modification of other buckets
+	generations = cache.snapshot(entry.buckets)
+	cache.invalidateBuckets(map[string]bool{"default:b2": true})
+	if !cache.addCurrent(entry, generations) || cache.cache.Get("k3", nil) == nil {
+		t.Errorf("Expected results to be added")
+	}
+
+	// a commit invalidates everything
+	entry = &ResultCacheEntry{key: "k4", buckets: []string{"default:b4"}, size: 10}
+	generations = cache.snapshot(entry.buckets)
+	cache.invalidateAll()
+	if cache.cache.Size() != 0 || cache.addCurrent(entry, generations) {
+		t.Errorf("Expected everything to be invalidated")
+	}
+}
+
+func TestResultCacheEviction(t *testing.T) {
+	cache := newResultCache()
+	cache.limit = 100
+
+	// entries over a tenth of the cache are not kept
+	cache.add(&ResultCacheEntry{key: "big", size: 11})
+	if cache.cache.Size() != 0 {
+		t.Errorf("Expected oversized entry to be rejected")
+	}
+
+	for i, key := range []string{"k1", "k2", "k3", "k4", "k5", "k6", "k7", "k8", "k9", "k10"} {
+		cache.add(&ResultCacheEntry{key: key, size: 10})
+		cache.cache.Get(key, func(e interface{}) {
+			e.(*ResultCacheEntry).lastUse = atomic.AlignedInt64(i + 1)
+		})
+	}
+	if cache.cache.Size() != 10 || cache.memory != 100 {
+		t.Fatalf("Expected a full cache, got %v entries and %v bytes", cache.cache.Size(), cache.memory)
+	}
+
+	// expired entries go first, then the least recently used
+	cache.cache.Get("k5", func(e interface{}) {
+		e.(*ResultCacheEntry).created = time.Now().Add(-2 * RESULT_CACHE_TTL_DEFAULT)
+	})
+	cache.add(&ResultCacheEntry{key: "k11", size: 10})
+	cache.add(&ResultCacheEntry{key: "k12", size: 10})
+	if cache.cache.Get("k5", nil) != nil || cache.cache.Get("k1", nil) != nil {
+		t.Errorf("Expected k5 and k1 to be evicted, have %v", cache.cache.Names())
+	}
+	if cache.cache.Get("k2", nil) == nil || cache.cache.Get("k12", nil) == nil {
+		t.Errorf("Expected k2 and k12 to be kept, have %v", cache.cache.Names())
+	}
+	if cache.memory != 100 || cache.stats.evictions != 2 {
+		t.Errorf("Unexpected memory %v or evictions %v", cache.memory, cache.stats.evictions)
+	}
+
+	// replacing an entry accounts for the old one
+	cache.remove("k2")
+	cache.add(&ResultCacheEntry{key: "k12", size: 5})
+	if cache.memory != 85 || cache.cache.Size() != 9 {
+		t.Errorf("Expected 85 bytes in 9 entries, got %v in %v", cache.memory, cache.cache.Size())
+	}
+}
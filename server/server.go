@@ -111,6 +111,7 @@ type runQueue struct {
 	tail      int32
 	queue     []waitEntry
 	mutex     sync.RWMutex
+	retired   int32 // no longer in use, once emptied
 }
 
 type txRunQueues struct {
@@ -145,6 +146,12 @@ type Server struct {
 	readonly          bool
 	timeout           time.Duration
 	txTimeout         time.Duration
+	asyncDir          string
+	asyncRetention    time.Duration
+	asyncLimit        int
+	asyncQuota        uint64
+	maxCursorTimeout  time.Duration
+	maxCursors        int
 	signature         bool
 	metrics           bool
 	memprofile        string
@@ -187,6 +194,10 @@ func NewServer(store datastore.Datastore, sys datastore.Systemstore, config clus
 		pretty:           pretty,
 		srvcontrols:      srvcontrols,
 		srvprofile:       srvprofile,
+		asyncRetention:   ASYNC_RETENTION_DEFAULT,
+		asyncLimit:       ASYNC_LIMIT_DEFAULT,
+		asyncQuota:       ASYNC_QUOTA_DEFAULT,
+		maxCursorTimeout: MAX_CURSOR_TIMEOUT_DEFAULT,
 		settingsCallback: func(s string, v interface{}) {},
 	}
 
@@ -195,6 +206,7 @@ func NewServer(store datastore.Datastore, sys datastore.Systemstore, config clus
 	newRunQueue(&rv.unboundQueue, requestsCap, false)
 	newRunQueue(&rv.plusQueue, plusRequestsCap, false)
 	newTxRunQueues(&rv.transactionQueues, plusRequestsCap, _TX_QUEUE_SIZE)
+	workload.server = rv
 	store.SetLogLevel(logging.LogLevel())
 	rv.SetMaxParallelism(maxParallelism)
 	rv.SetNumAtrs(datastore.DEF_NUMATRS)
@@ -466,6 +478,11 @@ func (this *Server) SetServicers(servicers int) {
 	this.Lock()
 	this.unboundQueue.servicers = servicers
 	this.Unlock()
+
+	// group shares are relative to the servicers
+	if defs := this.WorkloadGroups(); len(defs) > 0 {
+		this.SetWorkloadGroups(defs)
+	}
 }
 
 func (this *Server) PlusServicers() int {
@@ -498,6 +515,97 @@ func (this *Server) SetTxTimeout(timeout time.Duration) {
 	datastore.GetTransactionSettings().SetTxTimeout(timeout)
 }
 
+// Default retention of the results of asynchronous requests
+const ASYNC_RETENTION_DEFAULT = time.Hour
+
+// Directory where the results of asynchronous requests are spooled
+func (this *Server) AsyncDir() string {
+	if this.asyncDir == "" {
+		return os.TempDir()
+	}
+	return this.asyncDir
+}
+
+func (this *Server) SetAsyncDir(dir string) {
+	this.asyncDir = dir
+}
+
+func (this *Server) AsyncRetention() time.Duration {
+	return this.asyncRetention
+}
+
+func (this *Server) SetAsyncRetention(retention time.Duration) {
+	if retention <= 0 {
+		retention = ASYNC_RETENTION_DEFAULT
+	}
+	this.asyncRetention = retention
+}
+
+// Asynchronous requests are turned down once there are too many handles,
+// or their results take up too much disk space
+const (
+	ASYNC_LIMIT_DEFAULT = 1000
+	ASYNC_QUOTA_DEFAULT = 1024 // MB
+)
+
+func (this *Server) AsyncLimit() int {
+	return this.asyncLimit
+}
+
+func (this *Server) SetAsyncLimit(limit int) {
+	if limit <= 0 {
+		limit = ASYNC_LIMIT_DEFAULT
+	}
+	this.asyncLimit = limit
+}
+
+// The space available to the results of asynchronous requests, in MB
+func (this *Server) AsyncQuota() uint64 {
+	return this.asyncQuota
+}
+
+func (this *Server) SetAsyncQuota(quota uint64) {
+	if quota == 0 {
+		quota = ASYNC_QUOTA_DEFAULT
+	}
+	this.asyncQuota = quota
+}
+
+// Paged requests hold on to their servicer while paused, so how long they
+// can be left idle, and how many can be open at a time, are limited
+const MAX_CURSOR_TIMEOUT_DEFAULT = 10 * time.Minute
+
+func (this *Server) MaxCursorTimeout() time.Duration {
+	return this.maxCursorTimeout
+}
+
+func (this *Server) SetMaxCursorTimeout(timeout time.Duration) {
+	if timeout <= 0 {
+		timeout = MAX_CURSOR_TIMEOUT_DEFAULT
+	}
+	this.maxCursorTimeout = timeout
+}
+
+// Zero limits open cursors to a quarter of the servicers
+func (this *Server) MaxCursors() int {
+	if this.maxCursors > 0 {
+		return this.maxCursors
+	}
+	rv := this.Servicers() / 4
+	if rv < 1 {
+		rv = 1
+	}
+	return rv
+}
+
+func (this *Server) MaxCursorsSetting() int {
+	return this.maxCursors
+}
+
+func (this *Server) SetMaxCursors(max int) {
+	this.maxCursors = max
+}
+
 func (this *Server) Profile() Profile {
 	return this.srvprofile
 }
@@ -563,19 +671,55 @@ func (this *Server) SetUseCBO(useCBO bool) {
 }
 
 func (this *Server) ServiceRequest(request Request) bool {
+	leave, err := joinSession(request)
+	if err != nil {
+		request.Fail(err)
+		request.Failed(this)
+		return true
+	}
+	if leave != nil {
+		defer leave()
+	}
 	if !this.setupRequestContext(request) {
 		request.Failed(this)
 		return true // so that StatusServiceUnavailable will not return
 	}
+	release, err := this.admitUserLimits(request)
+	if err != nil {
+		request.Fail(err)
+		request.Failed(this)
+		return true
+	}
+	if release != nil {
+		defer release()
+	}
 
 	return this.handleRequest(request, &this.unboundQueue)
 }
 
 func (this *Server) PlusServiceRequest(request Request) bool {
+	leave, err := joinSession(request)
+	if err != nil {
+		request.Fail(err)
+		request.Failed(this)
+		return true
+	}
+	if leave != nil {
+		defer leave()
+	}
 	if !this.setupRequestContext(request) {
 		request.Failed(this)
 		return true // so that StatusServiceUnavailable will not return
 	}
+	release, err := this.admitUserLimits(request)
+	if err != nil {
+		request.Fail(err)
+		request.Failed(this)
+		return true
+	}
+	if release != nil {
+		defer release()
+	}
 
 	return this.handlePlusRequest(request, &this.plusQueue, &this.transactionQueues)
 }
@@ -601,6 +745,7 @@ func (this *Server) setupRequestContext(request Request) bool {
 	context.SetDurability(request.DurabilityLevel(), request.DurabilityTimeout())
 	context.SetScanConsistency(request.ScanConsistency(), request.OriginalScanConsistency())
 	context.SetPreserveExpiry(request.PreserveExpiry())
+	context.SetSession(request.Session())
 
 	if request.TxId() != "" {
 		err := context.SetTransactionInfo(request.TxId(), request.TxStmtNum())
@@ -634,6 +779,13 @@ func (this *Server) setupRequestContext(request Request) bool {
 }
 
 func (this *Server) handleRequest(request Request, queue *runQueue) bool {
+	group, ok := this.workloadEnqueue(request)
+	if !ok {
+		return false
+	}
+	if group != nil {
+		defer group.queue.dequeue()
+	}
 	if !queue.enqueue(request) {
 		return false
 	}
@@ -646,6 +798,13 @@ func (this *Server) handleRequest(request Request, queue *runQueue) bool {
 }
 
 func (this *Server) handlePlusRequest(request Request, queue *runQueue, transactionQueues *txRunQueues) bool {
+	group, ok := this.workloadEnqueue(request)
+	if !ok {
+		return false
+	}
+	if group != nil {
+		defer group.queue.dequeue()
+	}
 	if !queue.enqueue(request) {
 		return false
 	}
@@ -874,6 +1033,11 @@ func (this *runQueue) checkWaiters() {
 		time.Sleep(100 * time.Millisecond)
 		runCnt := atomic.LoadInt32(&this.runCnt)
 		queueCnt := atomic.LoadInt32(&this.queueCnt)
+
+		// workload group queues are replaced when the groups change
+		if atomic.LoadInt32(&this.retired) != 0 && runCnt <= 0 && queueCnt <= 0 {
+			return
+		}
 		for {
 
 			// no left behind requests
@@ -1003,6 +1167,19 @@ func (this *Server) serviceRequest(request Request) {
 		}
 	}
 
+	var capture *resultCapture
+	if request.ResultCache() && request.TxId() == "" && request.Session() == nil && !request.IsPrepare() {
+		var entry *ResultCacheEntry
+
+		entry, capture = resultsCache.lookup(request, prepared, context)
+		if entry != nil {
+			this.serveCachedResults(request, prepared, context, entry)
+			return
+		} else if capture != nil {
+			context.SetOutput(capture)
+		}
+	}
+
 	memoryQuota := request.MemoryQuota()
 
 	// never allow request side quota to be higher than
@@ -1010,6 +1187,12 @@ func (this *Server) serviceRequest(request Request) {
 	if this.memoryQuota > 0 && (this.memoryQuota < memoryQuota || memoryQuota == 0) {
 		memoryQuota = this.memoryQuota
 	}
+
+	// nor than the workload group quota
+	group := request.WorkloadGroup()
+	if group != nil && group.MemoryQuota() > 0 && (group.MemoryQuota() < memoryQuota || memoryQuota == 0) {
+		memoryQuota = group.MemoryQuota()
+	}
 	context.SetMemoryQuota(memoryQuota)
 
 	context.SetIsPrepared(request.Prepared() != nil)
@@ -1034,6 +1217,9 @@ func (this *Server) serviceRequest(request Request) {
 	}
 
 	timeout := request.Timeout()
+	if timeout <= 0 && group != nil {
+		timeout = group.Timeout()
+	}
 
 	// never allow request side timeout to be higher than
 	// server side timeout
@@ -1055,9 +1241,17 @@ func (this *Server) serviceRequest(request Request) {
 
 	request.NotifyStop(operator)
 	request.SetExecTime(time.Now())
+	span := request.StartSpan("execute")
+	context.SetTraceSpan(span)
 	operator.RunOnce(context, nil)
 
 	request.Execute(this, context, request.Type(), prepared.Signature())
+	span.End()
+
+	if capture != nil {
+		capture.complete(request)
+	}
+	resultsCache.invalidate(request, prepared, context)
 }
 
 func (this *Server) getPrepared(request Request, context *execution.Context) (*plan.Prepared, errors.Error) {
@@ -1081,6 +1275,20 @@ func (this *Server) getPrepared(request Request, context *execution.Context) (*p
 		autoPrepare = false
 	}
 
+	// plans referring to temporary collections only make sense within the session
+	var resolver n1ql.KeyspaceResolver
+	tempKeyspace := ""
+	if session := request.Session(); session != nil {
+		autoPrepare = false
+		resolver = func(name string) []string {
+			rv := session.KeyspacePath(name)
+			if rv != nil && tempKeyspace == "" {
+				tempKeyspace = name
+			}
+			return rv
+		}
+	}
+
 	if prepared == nil && autoPrepare {
 
 		// no datastore context for autoprepare
@@ -1101,7 +1309,9 @@ func (this *Server) getPrepared(request Request, context *execution.Context) (*p
 
 	if prepared == nil {
 		parse := time.Now()
-		stmt, err := n1ql.ParseStatement2(request.Statement(), context.Namespace(), request.QueryContext())
+		span := request.StartSpan("parse")
+		stmt, err := n1ql.ParseStatement3(request.Statement(), context.Namespace(), request.QueryContext(), resolver)
+		span.End()
 		request.Output().AddPhaseTime(execution.PARSE, time.Since(parse))
 		if err != nil {
 			return nil, errors.NewParseSyntaxError(err, "")
@@ -1114,6 +1324,9 @@ func (this *Server) getPrepared(request Request, context *execution.Context) (*p
 			autoExecute = false
 			request.SetAutoExecute(value.FALSE)
 		}
+		if isPrepare && tempKeyspace != "" {
+			return nil, errors.NewTempCollectionPrepareError(tempKeyspace)
+		}
 
 		var stype string
 		var allow bool
@@ -1161,8 +1374,10 @@ func (this *Server) getPrepared(request Request, context *execution.Context) (*p
 			stmt.SetContext(context)
 		}
 
+		span = request.StartSpan("plan")
 		prepared, err = planner.BuildPrepared(stmt, this.datastore, this.systemstore, context.Namespace(),
 			autoExecute, !autoExecute, &prepContext)
+		span.End()
 		request.Output().AddPhaseTime(execution.PLAN, time.Since(prep))
 		if err != nil {
 			return nil, errors.NewPlanError(err, "")
@@ -1193,6 +1408,14 @@ func (this *Server) getPrepared(request Request, context *execution.Context) (*p
 				request.SetType(stmt.Type())
 			} else {
 
+				// plan baselines may pin a different plan for the statement
+				// (prepared statements have theirs applied as they are built)
+				// names in sessions may refer to temporary collections
+				if !isPrepare && request.Session() == nil && prepareds.BaselinesOn() {
+					prepared = prepareds.ApplyBaseline(stmt, baselineText(request), prepared,
+						request.Namespace(), &prepContext, name)
+				}
+
 				// even though this is not a prepared statement, add the
 				// text for the benefit of context.Recover(): we can
 				// output the text in case of crashes
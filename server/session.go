@@ -0,0 +1,74 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package server
+
+import (
+	"time"
+
+	"github.com/couchbase/query/algebra"
+	"github.com/couchbase/query/auth"
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/sessions"
+	"github.com/couchbase/query/value"
+)
+
+// the session belongs to the first credential
+func StartSession(creds *auth.Credentials, timeout time.Duration, defaults map[string]value.Value,
+	queryContext string) (*sessions.Session, errors.Error) {
+
+	user := ""
+	if users := datastore.CredsArray(creds); len(users) > 0 {
+		user = users[0]
+	}
+	return sessions.Start(user, timeout, defaults, queryContext)
+}
+
+// the caller must release the session
+func GetSession(id string, creds *auth.Credentials) (*sessions.Session, errors.Error) {
+	return sessions.Get(id, datastore.CredsArray(creds))
+}
+
+func EndSession(id string, creds *auth.Credentials) errors.Error {
+	return sessions.End(id, datastore.CredsArray(creds))
+}
+
+// requests running in a session see the session defaults, unless they override them,
+// and the session variables
+func joinSession(request Request) (func(), errors.Error) {
+	if request.SessionId() == "" {
+		return nil, nil
+	}
+	session, err := GetSession(request.SessionId(), request.Credentials())
+	if err != nil {
+		return nil, err
+	}
+
+	if request.QueryContext() == "" {
+		request.SetQueryContext(session.QueryContext())
+	}
+	defaults := session.Defaults()
+	namedArgs := request.NamedArgs()
+	variables := session.Variables()
+	if len(defaults) > 0 || len(variables) > 0 {
+		args := make(map[string]value.Value, len(defaults)+len(namedArgs)+len(variables))
+		for n, v := range defaults {
+			args[n] = v
+		}
+		for n, v := range namedArgs {
+			args[n] = v
+		}
+		for n, v := range variables {
+			args[algebra.SESSION_VARIABLE_PREFIX+n] = v
+		}
+		request.SetNamedArgs(args)
+	}
+	request.SetSession(session)
+	return session.Release, nil
+}
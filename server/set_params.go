@@ -22,6 +22,8 @@ import (
 	"github.com/couchbase/query/prepareds"
 	"github.com/couchbase/query/scheduler"
 	queryMetakv "github.com/couchbase/query/server/settings/couchbase"
+	"github.com/couchbase/query/sessions"
+	"github.com/couchbase/query/tracing"
 	"github.com/couchbase/query/util"
 )
 
@@ -155,6 +157,11 @@ var _SETTERS = map[string]Setter{
 		functions.FunctionsSetLimit(int(value))
 		return nil
 	},
+	STMTSTATSLIMIT: func(s *Server, o interface{}) errors.Error {
+		value := getNumber(o)
+		StatementStatsSetLimit(int(value))
+		return nil
+	},
 	TASKLIMIT: func(s *Server, o interface{}) errors.Error {
 		value := getNumber(o)
 		scheduler.SchedulerSetLimit(int(value))
@@ -170,6 +177,65 @@ var _SETTERS = map[string]Setter{
 		s.SetUseCBO(value)
 		return nil
 	},
+	WORKLOADGROUPS: func(s *Server, o interface{}) errors.Error {
+		value, _ := o.([]interface{})
+		return s.SetWorkloadGroups(value)
+	},
+	TRACECOLLECTOR: func(s *Server, o interface{}) errors.Error {
+		value, _ := o.(string)
+		tracing.SetCollector(value)
+		return nil
+	},
+	USERLIMITS: func(s *Server, o interface{}) errors.Error {
+		value, _ := o.([]interface{})
+		return s.SetUserLimits(value)
+	},
+	ASYNCDIR: func(s *Server, o interface{}) errors.Error {
+		value, _ := o.(string)
+		s.SetAsyncDir(value)
+		return nil
+	},
+	ASYNCRETENTION: func(s *Server, o interface{}) errors.Error {
+		s.SetAsyncRetention(getDuration(o))
+		return nil
+	},
+	ASYNCLIMIT: func(s *Server, o interface{}) errors.Error {
+		value := getNumber(o)
+		s.SetAsyncLimit(int(value))
+		return nil
+	},
+	ASYNCQUOTA: func(s *Server, o interface{}) errors.Error {
+		value := getNumber(o)
+		s.SetAsyncQuota(uint64(value))
+		return nil
+	},
+	MAXCURSORTIMEOUT: func(s *Server, o interface{}) errors.Error {
+		s.SetMaxCursorTimeout(getDuration(o))
+		return nil
+	},
+	MAXCURSORS: func(s *Server, o interface{}) errors.Error {
+		value := getNumber(o)
+		s.SetMaxCursors(int(value))
+		return nil
+	},
+	RESULTCACHEMEMORY: func(s *Server, o interface{}) errors.Error {
+		value := getNumber(o)
+		SetResultCacheMemory(uint64(value))
+		return nil
+	},
+	SESSIONMEMORYQUOTA: func(s *Server, o interface{}) errors.Error {
+		value := getNumber(o)
+		sessions.SetMemoryQuota(uint64(value))
+		return nil
+	},
+	RESULTCACHETTL: func(s *Server, o interface{}) errors.Error {
+		SetResultCacheTTL(getDuration(o))
+		return nil
+	},
+	PLANBASELINES: func(s *Server, o interface{}) errors.Error {
+		value, _ := o.(string)
+		return prepareds.BaselinesSetMode(value)
+	},
 	TXTIMEOUT: func(s *Server, o interface{}) errors.Error {
 		s.SetTxTimeout(getDuration(o))
 		return nil
@@ -0,0 +1,331 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package server
+
+import (
+	"math"
+	"time"
+
+	atomic "github.com/couchbase/go-couchbase/platform"
+	"github.com/couchbase/query/algebra"
+	"github.com/couchbase/query/execution"
+	"github.com/couchbase/query/parser/n1ql"
+	"github.com/couchbase/query/util"
+)
+
+/*
+Statement statistics aggregate completed requests by statement shape, as
+opposed to completed_requests, which keeps individual requests.
+
+Statements are normalized by replacing every literal with a positional
+parameter, numbered after any the statement already uses, so that
+"SELECT * FROM b WHERE a = 1" and "SELECT * FROM b WHERE a = 2" both count
+against "select * from b where a = $1".
+Shapes are fingerprinted together with the query context, and the
+statistics for each fingerprint are kept in an LRU cache of configurable
+size: a limit of 0 turns collection off, a negative limit sets no bound.
+Only the normalized text is kept, as the literals of the statements may be
+sensitive.
+*/
+
+const (
+	STATEMENT_STATS_LIMIT_DEFAULT = 4000
+
+	_STATEMENT_SHAPES_LIMIT = 16384
+)
+
+type StatementStats struct {
+	fingerprint  string
+	normalized   string
+	stmtType     string
+	queryContext string
+	firstSeen    time.Time
+	lastSeen     time.Time
+	calls        uint64
+	errors       uint64
+	resultCount  uint64
+	resultSize   uint64
+	mutations    uint64
+	elapsedTime  timeStats
+	serviceTime  timeStats
+	phaseTimes   [execution.PHASES]time.Duration
+	usedMemory   uint64
+	maxMemory    uint64
+}
+
+// running totals, with mean and variance computed incrementally (Welford)
+type timeStats struct {
+	total time.Duration
+	min   time.Duration
+	max   time.Duration
+	mean  float64
+	m2    float64
+}
+
+// the result of normalizing a statement, cached by statement text
+type statementShape struct {
+	fingerprint string
+	normalized  string
+	literals    int
+	parsed      bool // whether the literals could be replaced
+}
+
+type statementStatsCache struct {
+	limit  atomic.AlignedInt64
+	cache  *util.GenCache
+	shapes *util.GenCache
+}
+
+var statementStats = &statementStatsCache{
+	limit:  STATEMENT_STATS_LIMIT_DEFAULT,
+	cache:  util.NewGenCache(STATEMENT_STATS_LIMIT_DEFAULT),
+	shapes: util.NewGenCache(_STATEMENT_SHAPES_LIMIT),
+}
+
+func StatementStatsLimit() int {
+	return int(atomic.LoadInt64(&statementStats.limit))
+}
+
+func StatementStatsSetLimit(limit int) {
+	atomic.StoreInt64(&statementStats.limit, int64(limit))
+	if limit == 0 {
+		StatementStatsReset()
+	} else {
+		statementStats.cache.SetLimit(limit)
+	}
+}
+
+// Aggregate a completed request into the statistics for its statement shape
+func (this *statementStatsCache) record(requestTime, serviceTime time.Duration,
+	resultCount int, resultSize int, errorCount int, request *BaseRequest) {
+
+	if atomic.LoadInt64(&this.limit) == 0 {
+		return
+	}
+
+	shape := this.requestShape(request)
+	if shape == nil {
+		return
+	}
+
+	now := time.Now()
+	entry := &StatementStats{
+		fingerprint:  shape.fingerprint,
+		normalized:   shape.normalized,
+		stmtType:     request.Type(),
+		queryContext: request.QueryContext(),
+		firstSeen:    now,
+	}
+	update := func(e *StatementStats) {
+		e.lastSeen = now
+		e.calls++
+		if errorCount > 0 {
+			e.errors += uint64(errorCount)
+		}
+		if resultCount > 0 {
+			e.resultCount += uint64(resultCount)
+		}
+		if resultSize > 0 {
+			e.resultSize += uint64(resultSize)
+		}
+		e.mutations += request.MutationCount()
+		e.elapsedTime.add(requestTime, e.calls)
+		e.serviceTime.add(serviceTime, e.calls)
+		for i := range request.phaseStats {
+			e.phaseTimes[i] += time.Duration(atomic.LoadUint64(&request.phaseStats[i].duration))
+		}
+		usedMemory := request.UsedMemory()
+		e.usedMemory += usedMemory
+		if usedMemory > e.maxMemory {
+			e.maxMemory = usedMemory
+		}
+	}
+	update(entry)
+
+	// updates happen under the cache's exclusive lock
+	this.cache.Add(entry, shape.fingerprint, func(ce interface{}) util.Operation {
+		update(ce.(*StatementStats))
+		return util.AMEND
+	})
+}
+
+func (this *timeStats) add(d time.Duration, calls uint64) {
+	this.total += d
+	if calls == 1 || d < this.min {
+		this.min = d
+	}
+	if d > this.max {
+		this.max = d
+	}
+	delta := float64(d) - this.mean
+	this.mean += delta / float64(calls)
+	this.m2 += delta * (float64(d) - this.mean)
+}
+
+func (this *timeStats) format(calls uint64) map[string]interface{} {
+	var stddev time.Duration
+
+	if calls > 1 {
+		stddev = time.Duration(math.Sqrt(this.m2 / float64(calls)))
+	}
+	return map[string]interface{}{
+		"total":  this.total.String(),
+		"min":    this.min.String(),
+		"max":    this.max.String(),
+		"mean":   time.Duration(this.mean).String(),
+		"stddev": stddev.String(),
+	}
+}
+
+// The shape of the statement, or prepared statement, a request runs
+func (this *statementStatsCache) requestShape(request *BaseRequest) *statementShape {
+	text := request.Statement()
+	prepared := request.Prepared()
+	if prepared != nil && prepared.Text() != "" {
+		text = prepared.Text()
+	}
+	if text == "" {
+		return nil
+	}
+	return this.shape(text, request.Namespace(), request.QueryContext())
+}
+
+// The statement a request runs, with its literals replaced by parameters
+// Statements that do not parse cannot be normalized, and have no text.
+func (this *BaseRequest) normalizedStatement() string {
+	shape := statementStats.requestShape(this)
+	if shape == nil || !shape.parsed {
+		return ""
+	}
+	return shape.normalized
+}
+
+// The text plan baselines know a statement by
+// Plans hold the literals of the statement they are built for, and cannot
+// be shared by statements differing in literals: those have no text.
+func baselineText(request Request) string {
+	shape := statementStats.shape(request.Statement(), request.Namespace(), request.QueryContext())
+	if shape == nil || !shape.parsed || shape.literals > 0 {
+		return ""
+	}
+	return shape.normalized
+}
+
+// Normalize and fingerprint a statement
+// Statements that do not parse are fingerprinted as they are.
+func (this *statementStatsCache) shape(text, namespace, queryContext string) *statementShape {
+	key := queryContext + " " + text
+	if entry := this.shapes.Get(key, nil); entry != nil {
+		return entry.(*statementShape)
+	}
+
+	normalized := text
+	literals := 0
+	parsed := false
+	stmt, err := n1ql.ParseStatement2(text, namespace, queryContext)
+	if err == nil {
+		if prepare, ok := stmt.(*algebra.Prepare); ok {
+			stmt = prepare.Statement()
+		}
+		normalized, literals, parsed = algebra.NormalizeStatement(stmt, text)
+	}
+	fingerprint, err := util.UUIDV5(queryContext, normalized)
+	if err != nil {
+		return nil
+	}
+	rv := &statementShape{
+		fingerprint: fingerprint,
+		normalized:  normalized,
+		literals:    literals,
+		parsed:      parsed,
+	}
+	this.shapes.Add(rv, key, nil)
+	return rv
+}
+
+// Statement statistics and system keyspaces
+
+func StatementStatsReset() {
+	for _, id := range statementStats.cache.Names() {
+		statementStats.cache.Delete(id, nil)
+	}
+}
+
+func CountStatementStats() int {
+	return statementStats.cache.Size()
+}
+
+func NameStatementStats() []string {
+	return statementStats.cache.Names()
+}
+
+func StatementStatsForeach(nonBlocking func(string, *StatementStats) bool,
+	blocking func() bool) {
+	dummyF := func(id string, s interface{}) bool {
+		return nonBlocking(id, s.(*StatementStats))
+	}
+	statementStats.cache.ForEach(dummyF, blocking)
+}
+
+func StatementStatsDo(fingerprint string, f func(*StatementStats)) {
+	var process func(interface{}) = nil
+
+	if f != nil {
+		process = func(entry interface{}) {
+			f(entry.(*StatementStats))
+		}
+	}
+	_ = statementStats.cache.Get(fingerprint, process)
+}
+
+func StatementStatsDelete(fingerprint string) bool {
+	return statementStats.cache.Delete(fingerprint, nil)
+}
+
+func (this *StatementStats) Format() map[string]interface{} {
+	rv := map[string]interface{}{
+		"fingerprint":   this.fingerprint,
+		"normalized":    this.normalized,
+		"statementType": this.stmtType,
+		"calls":         this.calls,
+		"errorCount":    this.errors,
+		"resultCount":   this.resultCount,
+		"resultSize":    this.resultSize,
+		"elapsedTime":   this.elapsedTime.format(this.calls),
+		"serviceTime":   this.serviceTime.format(this.calls),
+		"firstSeen":     this.firstSeen.String(),
+		"lastSeen":      this.lastSeen.String(),
+	}
+	if this.queryContext != "" {
+		rv["queryContext"] = this.queryContext
+	}
+	if this.mutations > 0 {
+		rv["mutations"] = this.mutations
+	}
+	var phaseTimes map[string]interface{}
+	for i, d := range this.phaseTimes {
+		if d > 0 {
+			if phaseTimes == nil {
+				phaseTimes = make(map[string]interface{}, execution.PHASES)
+			}
+			phaseTimes[execution.Phases(i).String()] = d.String()
+		}
+	}
+	if phaseTimes != nil {
+		rv["phaseTimes"] = phaseTimes
+	}
+	if this.usedMemory > 0 {
+		rv["usedMemory"] = map[string]interface{}{
+			"total": this.usedMemory,
+			"mean":  this.usedMemory / this.calls,
+			"max":   this.maxMemory,
+		}
+	}
+	return rv
+}
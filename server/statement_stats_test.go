@@ -0,0 +1,166 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package server
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/util"
+)
+
+func newTestStatementStats() *statementStatsCache {
+	return &statementStatsCache{
+		limit:  STATEMENT_STATS_LIMIT_DEFAULT,
+		cache:  util.NewGenCache(STATEMENT_STATS_LIMIT_DEFAULT),
+		shapes: util.NewGenCache(_STATEMENT_SHAPES_LIMIT),
+	}
+}
+
+func TestNormalizeStatement(t *testing.T) {
+	cache := newTestStatementStats()
+
+	shape := cache.shape("SELECT name FROM b WHERE city = 'Paris' AND age > 30", "default", "")
+	if shape == nil {
+		t.Fatalf("Expected a shape")
+	}
+	if strings.Contains(shape.normalized, "Paris") || strings.Contains(shape.normalized, "30") {
+		t.Errorf("Expected literals to be replaced, got %v", shape.normalized)
+	}
+	if !strings.Contains(shape.normalized, "$1") || !strings.Contains(shape.normalized, "$2") {
+		t.Errorf("Expected positional parameters, got %v", shape.normalized)
+	}
+	if shape.literals != 2 {
+		t.Errorf("Expected 2 literals, got %v", shape.literals)
+	}
+
+	// parameters follow those the statement already has
+	shape = cache.shape("SELECT name FROM b WHERE city = $2 AND age > 30", "default", "")
+	if !strings.Contains(shape.normalized, "$3") || strings.Contains(shape.normalized, "30") {
+		t.Errorf("Expected the literal to be numbered after the statement's parameters, got %v", shape.normalized)
+	}
+
+	// statements that cannot be rendered as text
+	shape = cache.shape("UPDATE b SET secret = 'swordfish' WHERE id = 7", "default", "")
+	if !strings.HasPrefix(shape.normalized, "update") || strings.Contains(shape.normalized, "swordfish") {
+		t.Errorf("Unexpected normalized DML %v", shape.normalized)
+	}
+
+	// or parsed are kept as they are
+	shape = cache.shape("SELEKT 1", "default", "")
+	if shape.normalized != "SELEKT 1" {
+		t.Errorf("Expected an unparsable statement to be kept, got %v", shape.normalized)
+	}
+}
+
+func TestStatementFingerprint(t *testing.T) {
+	cache := newTestStatementStats()
+	text := "SELECT name FROM b WHERE city = 'Paris'"
+
+	shape := cache.shape(text, "default", "")
+	if other := newTestStatementStats().shape(text, "default", ""); other.fingerprint != shape.fingerprint {
+		t.Errorf("Expected fingerprints to be stable, got %v and %v", shape.fingerprint, other.fingerprint)
+	}
+	if other := cache.shape("SELECT name FROM b WHERE city = 'Rome'", "default", ""); other.fingerprint != shape.fingerprint {
+		t.Errorf("Expected statements differing in literals to share a fingerprint")
+	}
+	if other := cache.shape("SELECT name FROM b WHERE country = 'Italy'", "default", ""); other.fingerprint == shape.fingerprint {
+		t.Errorf("Expected different statements to have different fingerprints")
+	}
+	if other := cache.shape(text, "default", "default:travel"); other.fingerprint == shape.fingerprint {
+		t.Errorf("Expected different query contexts to have different fingerprints")
+	}
+
+	// only the normalized statement is kept
+	request := newTestRequest(text, datastore.UNBOUNDED)
+	request.SetNamespace("default")
+	cache.record(time.Second, time.Second, 1, 10, 0, &request.BaseRequest)
+	request = newTestRequest("SELECT name FROM b WHERE city = 'Rome'", datastore.UNBOUNDED)
+	request.SetNamespace("default")
+	cache.record(time.Second, time.Second, 1, 10, 0, &request.BaseRequest)
+	if cache.cache.Size() != 1 {
+		t.Fatalf("Expected one entry, got %v", cache.cache.Size())
+	}
+	cache.cache.Get(shape.fingerprint, func(e interface{}) {
+		stats := e.(*StatementStats).Format()
+		if stats["calls"] != uint64(2) || stats["resultCount"] != uint64(2) {
+			t.Errorf("Unexpected statistics %v", stats)
+		}
+		if _, ok := stats["statement"]; ok {
+			t.Errorf("Expected no statement text, got %v", stats["statement"])
+		}
+	})
+}
+
+func TestTimeStats(t *testing.T) {
+	var stats timeStats
+
+	durations := []time.Duration{2 * time.Second, 4 * time.Second, 4 * time.Second, 4 * time.Second,
+		5 * time.Second, 5 * time.Second, 7 * time.Second, 9 * time.Second}
+	for i, d := range durations {
+		stats.add(d, uint64(i+1))
+	}
+	if stats.total != 40*time.Second || stats.min != 2*time.Second || stats.max != 9*time.Second {
+		t.Errorf("Unexpected total %v, min %v or max %v", stats.total, stats.min, stats.max)
+	}
+	if math.Abs(stats.mean-float64(5*time.Second)) > 1 {
+		t.Errorf("Expected a mean of 5s, got %v", time.Duration(stats.mean))
+	}
+	if stddev := math.Sqrt(stats.m2 / float64(len(durations))); math.Abs(stddev-float64(2*time.Second)) > 1 {
+		t.Errorf("Expected a standard deviation of 2s, got %v", time.Duration(stddev))
+	}
+
+	// a single call has no deviation
+	stats = timeStats{}
+	stats.add(time.Second, 1)
+	if formatted := stats.format(1); formatted["stddev"] != "0s" || formatted["mean"] != "1s" {
+		t.Errorf("Unexpected formatting %v", formatted)
+	}
+}
+
+func TestNormalizedStatement(t *testing.T) {
+	request := newTestRequest("SELECT name FROM b WHERE password = 'swordfish'", datastore.UNBOUNDED)
+	request.SetNamespace("default")
+	if text := request.normalizedStatement(); text == "" || strings.Contains(text, "swordfish") {
+		t.Errorf("Expected the literal to be replaced, got %q", text)
+	}
+
+	// prepared statements are normalized from their text
+	request = newTestRequest("EXECUTE p1", datastore.UNBOUNDED)
+	request.SetNamespace("default")
+	request.SetPrepared(testPrepared("SELECT name FROM b WHERE password = 'swordfish'"))
+	if text := request.normalizedStatement(); !strings.Contains(text, "$1") || strings.Contains(text, "swordfish") {
+		t.Errorf("Expected the prepared statement to be normalized, got %q", text)
+	}
+
+	// statements that cannot be normalized have no text
+	request = newTestRequest("SELEKT name FROM b WHERE password = 'swordfish'", datastore.UNBOUNDED)
+	request.SetNamespace("default")
+	if text := request.normalizedStatement(); text != "" {
+		t.Errorf("Expected no text for an unparsable statement, got %q", text)
+	}
+}
+
+func TestBaselineText(t *testing.T) {
+	request := newTestRequest("SELECT name FROM b WHERE city = $1", datastore.UNBOUNDED)
+	request.SetNamespace("default")
+	if text := baselineText(request); text == "" || !strings.Contains(text, "$1") {
+		t.Errorf("Expected the normalized statement, got %q", text)
+	}
+
+	// plans holding literals cannot be shared by statements of the same shape
+	request = newTestRequest("SELECT name FROM b WHERE city = 'Paris'", datastore.UNBOUNDED)
+	request.SetNamespace("default")
+	if text := baselineText(request); text != "" {
+		t.Errorf("Expected no text for a statement with literals, got %q", text)
+	}
+}
@@ -0,0 +1,428 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package server
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	atomic "github.com/couchbase/go-couchbase/platform"
+	"github.com/couchbase/query/errors"
+)
+
+/*
+User limits cap how much of the service each user can take, regardless of
+the workload group their requests run in.
+
+A limit selects users by name or role, as workload groups do, and a limit
+with no selectors applies to every user. All the limits that match a
+request apply to it.
+Each limit can bound the request rate, as a token bucket refilled at rate
+requests per second and holding up to burst requests, the number of
+concurrent requests, and the memory quota, in MB, that the user's requests
+can reserve between them: each request reserves its own memory quota, which
+is lowered to what is left of the user's. Requests without a memory quota
+reserve a share of the user's: the quota divided by maxRequests, or else a
+quarter of it.
+Usage is tracked per user, unless perUser is false, in which case all the
+users matching the limit share it.
+Requests over a limit fail straight away, with a hint as to when to retry.
+*/
+
+const (
+	_USER_LIMITS_RETRY        = time.Second
+	_USER_LIMITS_SWEEP        = time.Minute
+	_USER_LIMITS_MEMORY_SHARE = 4
+)
+
+type UserLimit struct {
+	admitted atomic.AlignedUint64
+	rejected atomic.AlignedUint64
+
+	name        string
+	users       map[string]bool
+	roles       map[string]bool
+	perUser     bool
+	rate        float64
+	burst       float64
+	maxRequests int
+	memoryQuota uint64
+
+	sync.Mutex
+	usage map[string]*userUsage
+	swept time.Time
+}
+
+type userUsage struct {
+	tokens   float64
+	refilled time.Time
+	active   int
+	memory   uint64
+}
+
+type userLimits struct {
+	sync.RWMutex
+	limits     []*UserLimit
+	definition []interface{}
+}
+
+var limits userLimits
+
+// a request admitted by a limit, and what it holds
+type userAdmission struct {
+	limit    *UserLimit
+	key      string
+	reserved uint64
+}
+
+func newUserLimit(def interface{}) (*UserLimit, errors.Error) {
+	fields, ok := def.(map[string]interface{})
+	if !ok {
+		return nil, errors.NewAdminUserLimitError(fmt.Sprintf("%v", def), fmt.Errorf("not an object"))
+	}
+	name, ok := fields["name"].(string)
+	if !ok || name == "" {
+		return nil, errors.NewAdminUserLimitError(fmt.Sprintf("%v", def), fmt.Errorf("missing name"))
+	}
+
+	rv := &UserLimit{
+		name:    name,
+		perUser: true,
+		usage:   make(map[string]*userUsage),
+	}
+	burst := 0
+	for k, v := range fields {
+		var err error
+		switch k {
+		case "name":
+		case "users":
+			rv.users, err = workloadStrings(v, false)
+		case "roles":
+			rv.roles, err = workloadStrings(v, false)
+		case "perUser":
+			rv.perUser, ok = v.(bool)
+			if !ok {
+				err = fmt.Errorf("%v is not a boolean", k)
+			}
+		case "rate":
+			rv.rate, ok = v.(float64)
+			if !ok {
+				if i, ok := v.(int); ok {
+					rv.rate = float64(i)
+				} else {
+					err = fmt.Errorf("%v is not a number", v)
+				}
+			}
+			if err == nil && rv.rate < 0 {
+				err = fmt.Errorf("%v is less than 0", v)
+			}
+		case "burst":
+			burst, err = workloadNumber(v, 1)
+		case "maxRequests":
+			rv.maxRequests, err = workloadNumber(v, 0)
+		case "memoryQuota":
+			var q int
+			q, err = workloadNumber(v, 0)
+			rv.memoryQuota = uint64(q)
+		default:
+			err = fmt.Errorf("unknown field %v", k)
+		}
+		if err != nil {
+			return nil, errors.NewAdminUserLimitError(name, err)
+		}
+	}
+
+	// by default, allow up to a second's worth of requests at once
+	if burst > 0 {
+		rv.burst = float64(burst)
+	} else {
+		rv.burst = math.Max(1, math.Ceil(rv.rate))
+	}
+	return rv, nil
+}
+
+func checkUserLimits(val interface{}) (bool, errors.Error) {
+	defs, ok := val.([]interface{})
+	if !ok {
+		return false, nil
+	}
+	names := make(map[string]bool, len(defs))
+	for _, def := range defs {
+		limit, err := newUserLimit(def)
+		if err != nil {
+			return false, err
+		}
+		if names[limit.name] {
+			return false, errors.NewAdminUserLimitError(limit.name, fmt.Errorf("duplicate name"))
+		}
+		names[limit.name] = true
+	}
+	return true, nil
+}
+
+func (this *Server) UserLimits() []interface{} {
+	limits.RLock()
+	defer limits.RUnlock()
+	if limits.definition == nil {
+		return []interface{}{}
+	}
+	return limits.definition
+}
+
+// Usage starts afresh when the limits change
+// Requests admitted by the old limits still release them.
+func (this *Server) SetUserLimits(defs []interface{}) errors.Error {
+	list := make([]*UserLimit, 0, len(defs))
+	for _, def := range defs {
+		limit, err := newUserLimit(def)
+		if err != nil {
+			return err
+		}
+		list = append(list, limit)
+	}
+
+	limits.Lock()
+	limits.limits = list
+	limits.definition = defs
+	limits.Unlock()
+	return nil
+}
+
+func (this *UserLimit) matches(users []string, roles func() map[string]bool) bool {
+	if len(this.users) > 0 && !matchesAny(this.users, users) {
+		return false
+	}
+	if len(this.roles) > 0 && !matchesAny(this.roles, roles()) {
+		return false
+	}
+	return true
+}
+
+/*
+Admit the request to all the limits that apply to it.
+On success, the caller must call the returned function, if any, once the
+request has completed.
+*/
+func (this *Server) admitUserLimits(request Request) (func(), errors.Error) {
+	limits.RLock()
+	list := limits.limits
+	limits.RUnlock()
+	if len(list) == 0 {
+		return nil, nil
+	}
+
+	// usage is accounted against the first credential
+	users := requestUsers(request)
+	user := ""
+	if len(users) > 0 {
+		user = users[0]
+	}
+	var roles map[string]bool
+	rolesOf := func() map[string]bool {
+		if roles == nil {
+			roles = workload.rolesOf(users)
+		}
+		return roles
+	}
+
+	memoryQuota := request.MemoryQuota()
+	if this.memoryQuota > 0 && (this.memoryQuota < memoryQuota || memoryQuota == 0) {
+		memoryQuota = this.memoryQuota
+	}
+	capped := false
+
+	var admitted []userAdmission
+	for _, limit := range list {
+		if !limit.matches(users, rolesOf) {
+			continue
+		}
+		key := user
+		if !limit.perUser {
+			key = ""
+		}
+		reserved, err := limit.admit(key, user, memoryQuota)
+		if err != nil {
+			for _, a := range admitted {
+				a.limit.release(a.key, a.reserved, true)
+			}
+			return nil, err
+		}
+		if reserved > 0 && (reserved < memoryQuota || memoryQuota == 0) {
+			memoryQuota = reserved
+			capped = true
+		}
+		admitted = append(admitted, userAdmission{limit: limit, key: key, reserved: reserved})
+	}
+	if len(admitted) == 0 {
+		return nil, nil
+	}
+
+	// limits admitted earlier give back what later limits capped
+	if capped {
+		request.SetMemoryQuota(memoryQuota)
+		for i := range admitted {
+			a := &admitted[i]
+			if a.reserved > memoryQuota {
+				a.limit.shrink(a.key, a.reserved-memoryQuota)
+				a.reserved = memoryQuota
+			}
+		}
+	}
+	return func() {
+		for _, a := range admitted {
+			a.limit.release(a.key, a.reserved, false)
+		}
+	}, nil
+}
+
+// returns the memory reserved, if the limit has a memory quota
+func (this *UserLimit) admit(key, user string, memoryQuota uint64) (uint64, errors.Error) {
+	now := time.Now()
+
+	this.Lock()
+	defer this.Unlock()
+
+	if now.Sub(this.swept) > _USER_LIMITS_SWEEP {
+		this.sweep(now)
+	}
+	usage := this.usage[key]
+	if usage == nil {
+		usage = &userUsage{tokens: this.burst, refilled: now}
+		this.usage[key] = usage
+	}
+
+	if this.rate > 0 {
+		usage.refill(now, this.rate, this.burst)
+		if usage.tokens < 1 {
+			atomic.AddUint64(&this.rejected, 1)
+			wait := time.Duration((1 - usage.tokens) / this.rate * float64(time.Second))
+			return 0, errors.NewServiceErrorUserRateLimit(this.name, user, this.rate, wait)
+		}
+	}
+	if this.maxRequests > 0 && usage.active >= this.maxRequests {
+		atomic.AddUint64(&this.rejected, 1)
+		return 0, errors.NewServiceErrorUserRequestLimit(this.name, user, this.maxRequests, _USER_LIMITS_RETRY)
+	}
+	reserved := uint64(0)
+	if this.memoryQuota > 0 {
+		if usage.memory >= this.memoryQuota {
+			atomic.AddUint64(&this.rejected, 1)
+			return 0, errors.NewServiceErrorUserMemoryLimit(this.name, user, this.memoryQuota, _USER_LIMITS_RETRY)
+		}
+		if memoryQuota == 0 {
+			memoryQuota = this.memoryShare()
+		}
+		reserved = this.memoryQuota - usage.memory
+		if memoryQuota < reserved {
+			reserved = memoryQuota
+		}
+		usage.memory += reserved
+	}
+	if this.rate > 0 {
+		usage.tokens--
+	}
+	usage.active++
+	atomic.AddUint64(&this.admitted, 1)
+	return reserved, nil
+}
+
+// what a request without a memory quota of its own reserves
+func (this *UserLimit) memoryShare() uint64 {
+	share := this.memoryQuota / _USER_LIMITS_MEMORY_SHARE
+	if this.maxRequests > 0 {
+		share = this.memoryQuota / uint64(this.maxRequests)
+	}
+	if share == 0 {
+		share = 1
+	}
+	return share
+}
+
+func (this *UserLimit) shrink(key string, memory uint64) {
+	this.Lock()
+	if usage := this.usage[key]; usage != nil {
+		usage.memory -= memory
+	}
+	this.Unlock()
+}
+
+// requests that were rejected by a later limit get their token back
+func (this *UserLimit) release(key string, reserved uint64, refund bool) {
+	this.Lock()
+	defer this.Unlock()
+
+	usage := this.usage[key]
+	if usage == nil {
+		return
+	}
+	usage.active--
+	usage.memory -= reserved
+	if refund {
+		atomic.AddUint64(&this.admitted, ^uint64(0))
+		if this.rate > 0 {
+			usage.tokens++
+		}
+	}
+}
+
+// drop users that are back to where they started
+func (this *UserLimit) sweep(now time.Time) {
+	this.swept = now
+	for key, usage := range this.usage {
+		if usage.active > 0 || usage.memory > 0 {
+			continue
+		}
+		if this.rate > 0 {
+			usage.refill(now, this.rate, this.burst)
+			if usage.tokens < this.burst {
+				continue
+			}
+		}
+		delete(this.usage, key)
+	}
+}
+
+func (this *userUsage) refill(now time.Time, rate, burst float64) {
+	this.tokens = math.Min(burst, this.tokens+now.Sub(this.refilled).Seconds()*rate)
+	this.refilled = now
+}
+
+// User limits for vitals
+func UserLimitsStats() []interface{} {
+	limits.RLock()
+	list := limits.limits
+	limits.RUnlock()
+	if len(list) == 0 {
+		return nil
+	}
+
+	rv := make([]interface{}, 0, len(list))
+	for _, limit := range list {
+		limit.Lock()
+		users := len(limit.usage)
+		active := 0
+		memory := uint64(0)
+		for _, usage := range limit.usage {
+			active += usage.active
+			memory += usage.memory
+		}
+		limit.Unlock()
+		rv = append(rv, map[string]interface{}{
+			"name":     limit.name,
+			"users":    users,
+			"active":   active,
+			"memory":   memory,
+			"admitted": atomic.LoadUint64(&limit.admitted),
+			"rejected": atomic.LoadUint64(&limit.rejected),
+		})
+	}
+	return rv
+}
@@ -0,0 +1,142 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/couchbase/query/auth"
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+)
+
+func testUserLimit(t *testing.T, def map[string]interface{}) *UserLimit {
+	rv, err := newUserLimit(def)
+	if err != nil {
+		t.Fatalf("Cannot create limit %v: %v", def, err)
+	}
+	return rv
+}
+
+func TestUserLimitRate(t *testing.T) {
+	limit := testUserLimit(t, map[string]interface{}{"name": "l", "rate": 2})
+	if limit.burst != 2 {
+		t.Errorf("Expected a burst of a second's worth of requests, got %v", limit.burst)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := limit.admit("u", "u", 0); err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+		limit.release("u", 0, false)
+	}
+	_, err := limit.admit("u", "u", 0)
+	if err == nil || err.Code() != errors.SERVICE_USER_RATE_LIMIT {
+		t.Fatalf("Expected rate limit error, got %v", err)
+	}
+	cause, _ := err.Cause().(map[string]interface{})
+	if cause["retry_after"] != int64(1) {
+		t.Errorf("Expected to retry after 1 second, got %v", cause)
+	}
+
+	// other users have their own bucket
+	if _, err = limit.admit("v", "v", 0); err != nil {
+		t.Errorf("Unexpected error for another user %v", err)
+	}
+
+	// half a second later, a token is back
+	limit.usage["u"].refilled = limit.usage["u"].refilled.Add(-500 * time.Millisecond)
+	if _, err = limit.admit("u", "u", 0); err != nil {
+		t.Errorf("Expected token to be refilled, got %v", err)
+	}
+	if limit.admitted != 4 || limit.rejected != 1 {
+		t.Errorf("Unexpected admitted %v or rejected %v", limit.admitted, limit.rejected)
+	}
+}
+
+func TestUserLimitMemory(t *testing.T) {
+	limit := testUserLimit(t, map[string]interface{}{"name": "l", "memoryQuota": 100})
+
+	// requests without a quota of their own take a quarter each
+	for i := 0; i < 4; i++ {
+		reserved, err := limit.admit("u", "u", 0)
+		if err != nil || reserved != 25 {
+			t.Fatalf("Expected 25MB reserved, got %v %v", reserved, err)
+		}
+	}
+	_, err := limit.admit("u", "u", 0)
+	if err == nil || err.Code() != errors.SERVICE_USER_MEMORY_LIMIT {
+		t.Errorf("Expected memory limit error, got %v", err)
+	}
+	limit.release("u", 25, false)
+	if reserved, _ := limit.admit("u", "u", 10); reserved != 10 {
+		t.Errorf("Expected the request's own quota reserved, got %v", reserved)
+	}
+	if reserved, _ := limit.admit("u", "u", 50); reserved != 15 {
+		t.Errorf("Expected what is left reserved, got %v", reserved)
+	}
+
+	// or what maxRequests leaves them
+	limit = testUserLimit(t, map[string]interface{}{"name": "l", "memoryQuota": 100, "maxRequests": 2})
+	if reserved, _ := limit.admit("u", "u", 0); reserved != 50 {
+		t.Errorf("Expected 50MB reserved, got %v", reserved)
+	}
+}
+
+func TestAdmitUserLimits(t *testing.T) {
+	srvr := &Server{}
+	defer srvr.SetUserLimits(nil)
+
+	err := srvr.SetUserLimits([]interface{}{
+		map[string]interface{}{"name": "all", "memoryQuota": 100, "rate": 1},
+		map[string]interface{}{"name": "alice", "users": []interface{}{"alice"}, "memoryQuota": 10, "maxRequests": 1},
+	})
+	if err != nil {
+		t.Fatalf("Cannot set limits: %v", err)
+	}
+	all := limits.limits[0]
+	alice := limits.limits[1]
+
+	request := newTestRequest("SELECT 1", datastore.UNBOUNDED)
+	creds := auth.NewCredentials()
+	creds.Users["alice"] = "pw"
+	request.SetCredentials(creds)
+
+	// the first limit gives back what the second capped
+	done, err := srvr.admitUserLimits(request)
+	if err != nil || done == nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if request.MemoryQuota() != 10 {
+		t.Errorf("Expected request quota capped at 10MB, got %v", request.MemoryQuota())
+	}
+	if all.usage["alice"].memory != 10 || alice.usage["alice"].memory != 10 {
+		t.Errorf("Expected 10MB reserved by each limit, got %v and %v",
+			all.usage["alice"].memory, alice.usage["alice"].memory)
+	}
+
+	// rejected by the second limit, the first gets its token back
+	all.usage["alice"].tokens = 1
+	request = newTestRequest("SELECT 1", datastore.UNBOUNDED)
+	request.SetCredentials(creds)
+	_, err = srvr.admitUserLimits(request)
+	if err == nil || err.Code() != errors.SERVICE_USER_REQUEST_LIMIT {
+		t.Fatalf("Expected request limit error, got %v", err)
+	}
+	if all.usage["alice"].tokens != 1 || all.usage["alice"].active != 1 || all.admitted != 1 {
+		t.Errorf("Expected refund, got %v tokens, %v active, %v admitted",
+			all.usage["alice"].tokens, all.usage["alice"].active, all.admitted)
+	}
+
+	done()
+	if all.usage["alice"].memory != 0 || alice.usage["alice"].memory != 0 || alice.usage["alice"].active != 0 {
+		t.Errorf("Expected everything released")
+	}
+}
@@ -0,0 +1,477 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package server
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	atomic "github.com/couchbase/go-couchbase/platform"
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/logging"
+)
+
+/*
+Workload groups partition the servicers amongst classes of requests, so that
+one class cannot starve the others.
+
+A request is assigned to the first group, in order of descending priority,
+whose selectors all match: users, roles, a client_context_id pattern and
+statement types. A group with no selectors matches every request.
+Each group has its own run queue, which admits up to its share of the
+servicers (capped by the maximum number of concurrent requests), and
+queues up to the group queue length before rejecting requests.
+Requests that have been admitted by the group then go through the server
+queues as usual.
+The group timeout applies to requests that do not specify one, and the
+group memory quota caps the memory quota of each of its requests.
+*/
+
+const (
+	_WORKLOAD_DEF_SERVICERS = 100
+	_WORKLOAD_ROLES_REFRESH = 10 * time.Second
+)
+
+type WorkloadGroup struct {
+	admitted atomic.AlignedUint64
+	rejected atomic.AlignedUint64
+
+	name            string
+	priority        int
+	users           map[string]bool
+	roles           map[string]bool
+	clientContextID *regexp.Regexp
+	statements      map[string]bool
+	servicers       int
+	maxRequests     int
+	queueSize       int
+	timeout         time.Duration
+	memoryQuota     uint64
+	queue           runQueue
+}
+
+type workloadGroups struct {
+	sync.RWMutex
+	groups     []*WorkloadGroup
+	definition []interface{}
+	server     *Server
+
+	// users to roles, refreshed lazily for groups that select by role
+	rolesMutex     sync.Mutex
+	rolesRefreshed time.Time
+	userRoles      map[string][]string
+}
+
+var workload workloadGroups
+
+func (this *WorkloadGroup) Name() string {
+	return this.name
+}
+
+func (this *WorkloadGroup) Timeout() time.Duration {
+	return this.timeout
+}
+
+func (this *WorkloadGroup) MemoryQuota() uint64 {
+	return this.memoryQuota
+}
+
+func newWorkloadGroup(def interface{}, servicers, queueSize int) (*WorkloadGroup, errors.Error) {
+	fields, ok := def.(map[string]interface{})
+	if !ok {
+		return nil, errors.NewAdminWorkloadGroupError(fmt.Sprintf("%v", def), fmt.Errorf("not an object"))
+	}
+	name, ok := fields["name"].(string)
+	if !ok || name == "" {
+		return nil, errors.NewAdminWorkloadGroupError(fmt.Sprintf("%v", def), fmt.Errorf("missing name"))
+	}
+
+	rv := &WorkloadGroup{
+		name:      name,
+		servicers: _WORKLOAD_DEF_SERVICERS,
+		queueSize: queueSize,
+	}
+	for k, v := range fields {
+		var err error
+		switch k {
+		case "name":
+		case "priority":
+			rv.priority, err = workloadNumber(v, 0)
+		case "users":
+			rv.users, err = workloadStrings(v, false)
+		case "roles":
+			rv.roles, err = workloadStrings(v, false)
+		case "statements":
+			rv.statements, err = workloadStrings(v, true)
+		case "clientContextId":
+			s, ok := v.(string)
+			if !ok {
+				err = fmt.Errorf("%v is not a string", k)
+			} else {
+				rv.clientContextID, err = regexp.Compile(s)
+			}
+		case "servicers":
+			rv.servicers, err = workloadNumber(v, 1)
+			if err == nil && rv.servicers > 100 {
+				err = fmt.Errorf("servicers is a percentage")
+			}
+		case "maxRequests":
+			rv.maxRequests, err = workloadNumber(v, 0)
+		case "queue":
+			rv.queueSize, err = workloadNumber(v, 1)
+		case "timeout":
+			s, ok := v.(string)
+			if !ok {
+				err = fmt.Errorf("%v is not a duration", k)
+			} else {
+				rv.timeout, err = time.ParseDuration(s)
+			}
+		case "memoryQuota":
+			var q int
+			q, err = workloadNumber(v, 0)
+			rv.memoryQuota = uint64(q)
+		default:
+			err = fmt.Errorf("unknown field %v", k)
+		}
+		if err != nil {
+			return nil, errors.NewAdminWorkloadGroupError(name, err)
+		}
+	}
+
+	rv.queue.servicers = (servicers*rv.servicers + 99) / 100
+	if rv.maxRequests > 0 && rv.maxRequests < rv.queue.servicers {
+		rv.queue.servicers = rv.maxRequests
+	}
+	return rv, nil
+}
+
+func workloadNumber(v interface{}, min int) (int, error) {
+	f, ok := v.(float64)
+	if !ok {
+		if i, ok := v.(int); ok {
+			f = float64(i)
+		} else {
+			return 0, fmt.Errorf("%v is not a number", v)
+		}
+	}
+	if int(f) < min {
+		return 0, fmt.Errorf("%v is less than %v", v, min)
+	}
+	return int(f), nil
+}
+
+func workloadStrings(v interface{}, upper bool) (map[string]bool, error) {
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%v is not an array", v)
+	}
+	rv := make(map[string]bool, len(list))
+	for _, e := range list {
+		s, ok := e.(string)
+		if !ok {
+			return nil, fmt.Errorf("%v is not a string", e)
+		}
+		if upper {
+			s = strings.ToUpper(s)
+		}
+		rv[s] = true
+	}
+	return rv, nil
+}
+
+func checkWorkloadGroups(val interface{}) (bool, errors.Error) {
+	defs, ok := val.([]interface{})
+	if !ok {
+		return false, nil
+	}
+	names := make(map[string]bool, len(defs))
+	for _, def := range defs {
+		group, err := newWorkloadGroup(def, 1, 1)
+		if err != nil {
+			return false, err
+		}
+		if names[group.name] {
+			return false, errors.NewAdminWorkloadGroupError(group.name, fmt.Errorf("duplicate name"))
+		}
+		names[group.name] = true
+	}
+	return true, nil
+}
+
+func (this *Server) WorkloadGroups() []interface{} {
+	workload.RLock()
+	defer workload.RUnlock()
+	if workload.definition == nil {
+		return []interface{}{}
+	}
+	return workload.definition
+}
+
+func (this *Server) SetWorkloadGroups(defs []interface{}) errors.Error {
+	groups := make([]*WorkloadGroup, 0, len(defs))
+	for _, def := range defs {
+		group, err := newWorkloadGroup(def, this.Servicers(), int(this.unboundQueue.size))
+		if err != nil {
+			return err
+		}
+		groups = append(groups, group)
+	}
+
+	// ties go to the group defined first
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groups[i].priority > groups[j].priority
+	})
+	for _, group := range groups {
+		newRunQueue(&group.queue, group.queueSize, false)
+	}
+
+	workload.Lock()
+	old := workload.groups
+	workload.groups = groups
+	workload.definition = defs
+	workload.Unlock()
+
+	// requests admitted by the old groups still release them
+	for _, group := range old {
+		atomic.StoreInt32(&group.queue.retired, 1)
+	}
+	return nil
+}
+
+// find the group for the request, if any
+func (this *Server) workloadGroup(request Request) *WorkloadGroup {
+	workload.RLock()
+	groups := workload.groups
+	workload.RUnlock()
+	if len(groups) == 0 {
+		return nil
+	}
+
+	var users []string
+	var roles map[string]bool
+	var stmtType string
+	for _, group := range groups {
+		if group.clientContextID != nil && !group.clientContextID.MatchString(request.ClientID().String()) {
+			continue
+		}
+		if len(group.statements) > 0 {
+			if stmtType == "" {
+				stmtType = requestStatementType(request)
+			}
+			if !group.statements[stmtType] {
+				continue
+			}
+		}
+		if len(group.users) > 0 || len(group.roles) > 0 {
+			if users == nil {
+				users = requestUsers(request)
+			}
+			if len(group.users) > 0 && !matchesAny(group.users, users) {
+				continue
+			}
+			if len(group.roles) > 0 {
+				if roles == nil {
+					roles = workload.rolesOf(users)
+				}
+				if !matchesAny(group.roles, roles) {
+					continue
+				}
+			}
+		}
+		return group
+	}
+	return nil
+}
+
+func matchesAny(set map[string]bool, names interface{}) bool {
+	switch names := names.(type) {
+	case []string:
+		for _, n := range names {
+			if set[n] {
+				return true
+			}
+		}
+	case map[string]bool:
+		for n, _ := range names {
+			if set[n] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// user names, with and without domain
+func requestUsers(request Request) []string {
+	creds := datastore.CredsArray(request.Credentials())
+	rv := make([]string, 0, 2*len(creds))
+	for _, c := range creds {
+		rv = append(rv, c)
+		if i := strings.IndexByte(c, ':'); i >= 0 {
+			rv = append(rv, c[i+1:])
+		}
+	}
+	return rv
+}
+
+// roles are matched by name, or by name and target, as in "select[travel-sample]"
+func (this *workloadGroups) rolesOf(users []string) map[string]bool {
+	this.rolesMutex.Lock()
+	defer this.rolesMutex.Unlock()
+
+	if this.userRoles == nil || time.Since(this.rolesRefreshed) > _WORKLOAD_ROLES_REFRESH {
+		this.rolesRefreshed = time.Now()
+		ds := datastore.GetDatastore()
+		if ds != nil {
+			all, err := ds.GetUserInfoAll()
+			if err != nil {
+				logging.Infof("Workload groups could not load user roles: %v", err)
+			} else {
+				this.userRoles = make(map[string][]string, len(all))
+				for _, u := range all {
+					roles := make([]string, 0, 2*len(u.Roles))
+					for _, r := range u.Roles {
+						roles = append(roles, r.Name)
+						if r.Target != "" {
+							roles = append(roles, r.Name+"["+r.Target+"]")
+						}
+					}
+					this.userRoles[u.Id] = roles
+					this.userRoles[u.Domain+":"+u.Id] = roles
+				}
+			}
+		}
+	}
+
+	rv := make(map[string]bool)
+	for _, u := range users {
+		for _, r := range this.userRoles[u] {
+			rv[r] = true
+		}
+	}
+	return rv
+}
+
+/*
+The statement type is needed before the statement is parsed: use the
+type of the prepared statement, or the first keyword of the text, skipping
+comments and parentheses.
+*/
+func requestStatementType(request Request) string {
+	if prepared := request.Prepared(); prepared != nil {
+		return prepared.Type()
+	}
+
+	s := request.Statement()
+	for {
+		s = strings.TrimLeftFunc(s, func(r rune) bool { return unicode.IsSpace(r) || r == '(' })
+		if strings.HasPrefix(s, "/*") {
+			i := strings.Index(s, "*/")
+			if i < 0 {
+				return ""
+			}
+			s = s[i+2:]
+		} else if strings.HasPrefix(s, "--") {
+			i := strings.IndexByte(s, '\n')
+			if i < 0 {
+				return ""
+			}
+			s = s[i+1:]
+		} else {
+			break
+		}
+	}
+	i := strings.IndexFunc(s, func(r rune) bool { return !unicode.IsLetter(r) })
+	if i >= 0 {
+		s = s[:i]
+	}
+	s = strings.ToUpper(s)
+
+	// common table expressions are used by queries
+	if s == "WITH" {
+		s = "SELECT"
+	}
+	return s
+}
+
+// admit the request to its group, if any
+func (this *Server) workloadEnqueue(request Request) (*WorkloadGroup, bool) {
+	group := this.workloadGroup(request)
+	if group == nil {
+		return nil, true
+	}
+	request.SetWorkloadGroup(group)
+	if !group.queue.enqueue(request) {
+		atomic.AddUint64(&group.rejected, 1)
+		return group, false
+	}
+	atomic.AddUint64(&group.admitted, 1)
+	return group, true
+}
+
+// Workload groups and server queues for vitals
+func WorkloadStats() map[string]interface{} {
+	server := workload.server
+	if server == nil {
+		return nil
+	}
+	queues := map[string]interface{}{
+		"unbound": server.unboundQueue.stats(),
+		"plus":    server.plusQueue.stats(),
+	}
+	server.transactionQueues.mutex.RLock()
+	queues["transactions"] = map[string]interface{}{
+		"queues":   len(server.transactionQueues.txQueues),
+		"queued":   server.transactionQueues.queueCnt,
+		"capacity": server.transactionQueues.size,
+	}
+	server.transactionQueues.mutex.RUnlock()
+
+	workload.RLock()
+	groups := workload.groups
+	workload.RUnlock()
+	rv := make([]interface{}, 0, len(groups))
+	for _, group := range groups {
+		stats := group.queue.stats()
+		stats["name"] = group.name
+		stats["priority"] = group.priority
+		stats["admitted"] = atomic.LoadUint64(&group.admitted)
+		stats["rejected"] = atomic.LoadUint64(&group.rejected)
+		rv = append(rv, stats)
+	}
+	return map[string]interface{}{
+		"queues": queues,
+		"groups": rv,
+	}
+}
+
+func (this *runQueue) stats() map[string]interface{} {
+	runCnt := atomic.LoadInt32(&this.runCnt)
+	queueCnt := atomic.LoadInt32(&this.queueCnt)
+
+	// counters are corrected after out of bounds increments
+	if runCnt < 0 {
+		runCnt = 0
+	}
+	if queueCnt < 0 {
+		queueCnt = 0
+	}
+	return map[string]interface{}{
+		"servicers": this.servicers,
+		"active":    runCnt,
+		"queued":    queueCnt,
+		"capacity":  this.size,
+	}
+}
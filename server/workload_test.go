@@ -0,0 +1,170 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/couchbase/query/auth"
+	"github.com/couchbase/query/datastore"
+)
+
+func TestNewWorkloadGroup(t *testing.T) {
+	group, err := newWorkloadGroup(map[string]interface{}{
+		"name":            "reports",
+		"priority":        2,
+		"statements":      []interface{}{"select", "Infer"},
+		"clientContextId": "^report-",
+		"servicers":       float64(25),
+		"maxRequests":     1,
+		"timeout":         "1m",
+		"memoryQuota":     100,
+	}, 8, 16)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if !group.statements["SELECT"] || !group.statements["INFER"] {
+		t.Errorf("Expected statement types upper cased, got %v", group.statements)
+	}
+	if group.timeout != time.Minute || group.memoryQuota != 100 || group.queueSize != 16 {
+		t.Errorf("Unexpected timeout %v, memory quota %v or queue %v", group.timeout, group.memoryQuota, group.queueSize)
+	}
+
+	// a quarter of 8 servicers, capped by maxRequests
+	if group.queue.servicers != 1 {
+		t.Errorf("Expected 1 servicer, got %v", group.queue.servicers)
+	}
+	group, _ = newWorkloadGroup(map[string]interface{}{"name": "small", "servicers": 10}, 8, 16)
+	if group.queue.servicers != 1 {
+		t.Errorf("Expected servicers to be rounded up, got %v", group.queue.servicers)
+	}
+
+	invalid := []interface{}{
+		"reports",
+		map[string]interface{}{"priority": 1},
+		map[string]interface{}{"name": ""},
+		map[string]interface{}{"name": "g", "priority": -1},
+		map[string]interface{}{"name": "g", "users": "alice"},
+		map[string]interface{}{"name": "g", "roles": []interface{}{1}},
+		map[string]interface{}{"name": "g", "clientContextId": "("},
+		map[string]interface{}{"name": "g", "servicers": 0},
+		map[string]interface{}{"name": "g", "servicers": 101},
+		map[string]interface{}{"name": "g", "queue": 0},
+		map[string]interface{}{"name": "g", "timeout": "soon"},
+		map[string]interface{}{"name": "g", "timeout": 10},
+		map[string]interface{}{"name": "g", "memoryQuota": "1GB"},
+		map[string]interface{}{"name": "g", "unknown": true},
+	}
+	for _, def := range invalid {
+		if _, err := newWorkloadGroup(def, 8, 16); err == nil || err.Code() != 2230 {
+			t.Errorf("Expected %v to be rejected, got %v", def, err)
+		}
+	}
+
+	_, err = checkWorkloadGroups([]interface{}{
+		map[string]interface{}{"name": "g"},
+		map[string]interface{}{"name": "g"},
+	})
+	if err == nil {
+		t.Errorf("Expected duplicate names to be rejected")
+	}
+}
+
+func TestWorkloadGroupSelection(t *testing.T) {
+	srvr := &Server{}
+	srvr.unboundQueue.servicers = 8
+	srvr.unboundQueue.size = 16
+	defer srvr.SetWorkloadGroups(nil)
+
+	err := srvr.SetWorkloadGroups([]interface{}{
+		map[string]interface{}{"name": "catchall"},
+		map[string]interface{}{"name": "reports", "priority": 1, "clientContextId": "^report-"},
+		map[string]interface{}{"name": "writers", "priority": 1, "statements": []interface{}{"insert", "update"}},
+		map[string]interface{}{"name": "alice", "priority": 2, "users": []interface{}{"alice"}},
+		map[string]interface{}{"name": "admins", "priority": 2, "roles": []interface{}{"admin", "select[travel]"}},
+	})
+	if err != nil {
+		t.Fatalf("Cannot set groups: %v", err)
+	}
+
+	// roles are only refreshed once stale
+	workload.rolesMutex.Lock()
+	workload.userRoles = map[string][]string{"bob": []string{"admin"}, "carol": []string{"select", "select[travel]"}}
+	workload.rolesRefreshed = time.Now()
+	workload.rolesMutex.Unlock()
+	defer func() {
+		workload.rolesMutex.Lock()
+		workload.userRoles = nil
+		workload.rolesMutex.Unlock()
+	}()
+
+	tests := []struct {
+		statement string
+		clientID  string
+		user      string
+		group     string
+	}{
+		{"SELECT 1", "", "", "catchall"},
+		{"SELECT 1", "report-1", "", "reports"},
+		{"SELECT 1", "my-report-1", "", "catchall"},
+		{"UPDATE b SET a = 1", "", "", "writers"},
+		{"DELETE FROM b", "", "", "catchall"},
+		{"SELECT 1", "report-1", "alice", "alice"},
+		{"SELECT 1", "", "local:alice", "alice"},
+		{"SELECT 1", "", "bob", "admins"},
+		{"SELECT 1", "", "carol", "admins"},
+		{"SELECT 1", "", "dave", "catchall"},
+	}
+	for _, test := range tests {
+		request := newTestRequest(test.statement, datastore.UNBOUNDED)
+		request.SetClientID(test.clientID)
+		if test.user != "" {
+			creds := auth.NewCredentials()
+			creds.Users[test.user] = "pw"
+			request.SetCredentials(creds)
+		}
+		group := srvr.workloadGroup(request)
+		if group == nil || group.Name() != test.group {
+			t.Errorf("Expected %v for %v, got %v", test.group, test, group)
+		}
+	}
+}
+
+func TestRequestStatementType(t *testing.T) {
+	tests := []struct {
+		statement string
+		stmtType  string
+	}{
+		{"select 1", "SELECT"},
+		{"  (SELECT 1) UNION (SELECT 2)", "SELECT"},
+		{"/* report */ UPDATE b SET a = 1", "UPDATE"},
+		{"/* a */ /* b */\n\tdelete FROM b", "DELETE"},
+		{"-- count\nINSERT INTO b VALUES ('k', {})", "INSERT"},
+		{"-- count", ""},
+		{"/* never closed SELECT 1", ""},
+		{"WITH a AS (SELECT 1) SELECT * FROM a", "SELECT"},
+		{"with\na AS (SELECT 1) SELECT * FROM a", "SELECT"},
+		{"EXECUTE p1", "EXECUTE"},
+		{"", ""},
+	}
+	for _, test := range tests {
+		request := newTestRequest(test.statement, datastore.UNBOUNDED)
+		if stmtType := requestStatementType(request); stmtType != test.stmtType {
+			t.Errorf("Expected %q for %q, got %q", test.stmtType, test.statement, stmtType)
+		}
+	}
+
+	// prepared statements have their own type
+	request := newTestRequest("EXECUTE p1", datastore.UNBOUNDED)
+	request.SetPrepared(testPrepared("SELECT 1"))
+	if stmtType := requestStatementType(request); stmtType != "SELECT" {
+		t.Errorf("Expected the type of the prepared statement, got %q", stmtType)
+	}
+}
@@ -0,0 +1,619 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package sessions
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/logging"
+	"github.com/couchbase/query/timestamp"
+	"github.com/couchbase/query/value"
+)
+
+/*
+Temporary collections are found in the session namespace, where each session
+is a bucket with a single scope, as #session:<session id>._default.<name>.
+Sessions are not listed: they can only be found by id, and statements only
+refer to temporary collections by name, through the session they run in.
+Statements naming the collections of another session explicitly fail
+authorization, as if the session did not exist.
+*/
+const _SCOPE_NAME = "_default"
+
+type namespace struct {
+}
+
+func (this *namespace) DatastoreId() string {
+	return datastore.SESSION_NAMESPACE
+}
+
+func (this *namespace) Id() string {
+	return datastore.SESSION_NAMESPACE
+}
+
+func (this *namespace) Name() string {
+	return datastore.SESSION_NAMESPACE
+}
+
+func (this *namespace) KeyspaceIds() ([]string, errors.Error) {
+	return nil, nil
+}
+
+func (this *namespace) KeyspaceNames() ([]string, errors.Error) {
+	return nil, nil
+}
+
+func (this *namespace) KeyspaceById(id string) (datastore.Keyspace, errors.Error) {
+	return this.KeyspaceByName(id)
+}
+
+func (this *namespace) KeyspaceByName(name string) (datastore.Keyspace, errors.Error) {
+	return nil, errors.NewOtherKeyspaceNotFoundError(nil, name+" for sessions")
+}
+
+func (this *namespace) BucketIds() ([]string, errors.Error) {
+	return nil, nil
+}
+
+func (this *namespace) BucketNames() ([]string, errors.Error) {
+	return nil, nil
+}
+
+func (this *namespace) BucketById(id string) (datastore.Bucket, errors.Error) {
+	return this.BucketByName(id)
+}
+
+func (this *namespace) BucketByName(name string) (datastore.Bucket, errors.Error) {
+	rv, _ := sessions.cache.Get(name, nil).(*Session)
+	if rv == nil {
+		return nil, errors.NewServiceErrorNoSuchSession(name)
+	}
+	return rv, nil
+}
+
+func (this *namespace) Objects(preload bool) ([]datastore.Object, errors.Error) {
+	return nil, nil
+}
+
+// A session is the bucket of its temporary collections
+
+func (this *Session) Name() string {
+	return this.id
+}
+
+func (this *Session) AuthKey() string {
+	return this.id
+}
+
+func (this *Session) Uid() string {
+	return this.id
+}
+
+func (this *Session) NamespaceId() string {
+	return datastore.SESSION_NAMESPACE
+}
+
+func (this *Session) Namespace() datastore.Namespace {
+	return &namespace{}
+}
+
+func (this *Session) DefaultKeyspace() (datastore.Keyspace, errors.Error) {
+	return nil, nil
+}
+
+func (this *Session) ScopeIds() ([]string, errors.Error) {
+	return this.ScopeNames()
+}
+
+func (this *Session) ScopeNames() ([]string, errors.Error) {
+	return []string{_SCOPE_NAME}, nil
+}
+
+func (this *Session) ScopeById(id string) (datastore.Scope, errors.Error) {
+	return this.ScopeByName(id)
+}
+
+func (this *Session) ScopeByName(name string) (datastore.Scope, errors.Error) {
+	if name != _SCOPE_NAME {
+		return nil, errors.NewOtherNotSupportedError(nil, "scope "+name+" for sessions")
+	}
+	return this.scope, nil
+}
+
+func (this *Session) CreateScope(name string) errors.Error {
+	return errors.NewScopesNotSupportedError(this.id)
+}
+
+func (this *Session) DropScope(name string) errors.Error {
+	return errors.NewScopesNotSupportedError(this.id)
+}
+
+type scope struct {
+	sync.RWMutex
+	session   *Session
+	keyspaces map[string]*keyspace
+}
+
+func newScope(session *Session) *scope {
+	return &scope{
+		session:   session,
+		keyspaces: make(map[string]*keyspace),
+	}
+}
+
+func (this *scope) Id() string {
+	return _SCOPE_NAME
+}
+
+func (this *scope) Name() string {
+	return _SCOPE_NAME
+}
+
+func (this *scope) AuthKey() string {
+	return this.session.id + ":" + _SCOPE_NAME
+}
+
+func (this *scope) BucketId() string {
+	return this.session.id
+}
+
+func (this *scope) Bucket() datastore.Bucket {
+	return this.session
+}
+
+func (this *scope) KeyspaceIds() ([]string, errors.Error) {
+	return this.KeyspaceNames()
+}
+
+func (this *scope) KeyspaceNames() ([]string, errors.Error) {
+	this.RLock()
+	defer this.RUnlock()
+	return sortedNames(this.keyspaces), nil
+}
+
+func (this *scope) KeyspaceById(id string) (datastore.Keyspace, errors.Error) {
+	return this.KeyspaceByName(id)
+}
+
+func (this *scope) KeyspaceByName(name string) (datastore.Keyspace, errors.Error) {
+	ks := this.keyspace(name)
+	if ks == nil {
+		return nil, errors.NewTempCollectionNotFoundError(name)
+	}
+	return ks, nil
+}
+
+func (this *scope) keyspace(name string) *keyspace {
+	this.RLock()
+	defer this.RUnlock()
+	return this.keyspaces[name]
+}
+
+func (this *scope) CreateCollection(name string) errors.Error {
+	this.Lock()
+	defer this.Unlock()
+	if _, ok := this.keyspaces[name]; ok {
+		return errors.NewTempCollectionExistsError(name)
+	}
+	ks := &keyspace{
+		scope: this,
+		name:  name,
+		docs:  make(map[string]value.Value),
+		sizes: make(map[string]int64),
+	}
+	ks.indexer = &indexer{keyspace: ks}
+	ks.indexer.primary = &primaryIndex{indexer: ks.indexer}
+	this.keyspaces[name] = ks
+	return nil
+}
+
+func (this *scope) DropCollection(name string) errors.Error {
+	this.Lock()
+	ks, ok := this.keyspaces[name]
+	delete(this.keyspaces, name)
+	this.Unlock()
+	if !ok {
+		return errors.NewTempCollectionNotFoundError(name)
+	}
+	ks.Flush()
+	return nil
+}
+
+func (this *scope) dropAll() {
+	this.Lock()
+	keyspaces := this.keyspaces
+	this.keyspaces = make(map[string]*keyspace)
+	this.Unlock()
+	for _, ks := range keyspaces {
+		ks.Flush()
+	}
+}
+
+// keyspace is a temporary collection, which holds its documents in memory
+type keyspace struct {
+	sync.RWMutex
+	scope   *scope
+	name    string
+	docs    map[string]value.Value
+	sizes   map[string]int64
+	indexer *indexer
+}
+
+func (this *keyspace) Id() string {
+	return this.name
+}
+
+func (this *keyspace) Name() string {
+	return this.name
+}
+
+func (this *keyspace) QualifiedName() string {
+	return datastore.SESSION_NAMESPACE + ":" + this.scope.session.id + "." + _SCOPE_NAME + "." + this.name
+}
+
+func (this *keyspace) AuthKey() string {
+	return this.scope.session.id + ":" + _SCOPE_NAME + ":" + this.name
+}
+
+func (this *keyspace) Uid() string {
+	return this.QualifiedName()
+}
+
+func (this *keyspace) NamespaceId() string {
+	return ""
+}
+
+func (this *keyspace) Namespace() datastore.Namespace {
+	return nil
+}
+
+func (this *keyspace) ScopeId() string {
+	return _SCOPE_NAME
+}
+
+func (this *keyspace) Scope() datastore.Scope {
+	return this.scope
+}
+
+func (this *keyspace) MetadataVersion() uint64 {
+	return 0
+}
+
+func (this *keyspace) Stats(context datastore.QueryContext, which []datastore.KeyspaceStats) ([]int64, errors.Error) {
+	res := make([]int64, len(which))
+	for i, f := range which {
+		switch f {
+		case datastore.KEYSPACE_COUNT:
+			res[i], _ = this.Count(context)
+		case datastore.KEYSPACE_SIZE:
+			res[i], _ = this.Size(context)
+		}
+	}
+	return res, nil
+}
+
+func (this *keyspace) Count(context datastore.QueryContext) (int64, errors.Error) {
+	this.RLock()
+	defer this.RUnlock()
+	return int64(len(this.docs)), nil
+}
+
+func (this *keyspace) Size(context datastore.QueryContext) (int64, errors.Error) {
+	this.RLock()
+	defer this.RUnlock()
+	size := int64(0)
+	for _, s := range this.sizes {
+		size += s
+	}
+	return size, nil
+}
+
+func (this *keyspace) Indexer(name datastore.IndexType) (datastore.Indexer, errors.Error) {
+	return this.indexer, nil
+}
+
+func (this *keyspace) Indexers() ([]datastore.Indexer, errors.Error) {
+	return []datastore.Indexer{this.indexer}, nil
+}
+
+// keys that are not found are left out of keysMap
+func (this *keyspace) Fetch(keys []string, keysMap map[string]value.AnnotatedValue,
+	context datastore.QueryContext, subPaths []string) []errors.Error {
+
+	this.RLock()
+	defer this.RUnlock()
+	for _, k := range keys {
+		doc, ok := this.docs[k]
+		if !ok {
+			continue
+		}
+		item := value.NewAnnotatedValue(doc.CopyForUpdate())
+		item.SetId(k)
+		keysMap[k] = item
+	}
+	return nil
+}
+
+func (this *keyspace) performOp(op datastore.MutationType, upsert bool, pairs []value.Pair) ([]value.Pair, errors.Error) {
+	this.Lock()
+	defer this.Unlock()
+
+	done := make([]value.Pair, 0, len(pairs))
+	for _, pair := range pairs {
+		_, exists := this.docs[pair.Name]
+		if !upsert && exists == (op == datastore.MUTATION_INSERT) {
+			if op == datastore.MUTATION_INSERT {
+				return done, errors.NewOtherDatastoreError(nil, "duplicate key: "+pair.Name)
+			}
+			continue
+		}
+
+		if op == datastore.MUTATION_DELETE {
+			this.scope.session.account(-this.sizes[pair.Name])
+			delete(this.docs, pair.Name)
+			delete(this.sizes, pair.Name)
+		} else {
+			size := int64(len(pair.Name)) + int64(pair.Value.Size())
+			if err := this.scope.session.account(size - this.sizes[pair.Name]); err != nil {
+				return done, err
+			}
+			this.docs[pair.Name] = pair.Value.CopyForUpdate()
+			this.sizes[pair.Name] = size
+		}
+		done = append(done, pair)
+	}
+	return done, nil
+}
+
+func (this *keyspace) Insert(inserts []value.Pair, context datastore.QueryContext) ([]value.Pair, errors.Error) {
+	return this.performOp(datastore.MUTATION_INSERT, false, inserts)
+}
+
+func (this *keyspace) Update(updates []value.Pair, context datastore.QueryContext) ([]value.Pair, errors.Error) {
+	return this.performOp(datastore.MUTATION_UPDATE, false, updates)
+}
+
+func (this *keyspace) Upsert(upserts []value.Pair, context datastore.QueryContext) ([]value.Pair, errors.Error) {
+	return this.performOp(datastore.MUTATION_UPDATE, true, upserts)
+}
+
+func (this *keyspace) Delete(deletes []value.Pair, context datastore.QueryContext) ([]value.Pair, errors.Error) {
+	return this.performOp(datastore.MUTATION_DELETE, false, deletes)
+}
+
+// the document keys, in key order
+func (this *keyspace) keys() []string {
+	this.RLock()
+	defer this.RUnlock()
+	rv := make([]string, 0, len(this.docs))
+	for k, _ := range this.docs {
+		rv = append(rv, k)
+	}
+	sort.Strings(rv)
+	return rv
+}
+
+func (this *keyspace) Flush() errors.Error {
+	this.Lock()
+	size := int64(0)
+	for _, s := range this.sizes {
+		size += s
+	}
+	this.docs = make(map[string]value.Value)
+	this.sizes = make(map[string]int64)
+	this.Unlock()
+	this.scope.session.account(-size)
+	return nil
+}
+
+func (this *keyspace) IsBucket() bool {
+	return false
+}
+
+func (this *keyspace) Release(close bool) {
+}
+
+// temporary collections only have a primary index
+type indexer struct {
+	keyspace *keyspace
+	primary  *primaryIndex
+}
+
+func (this *indexer) BucketId() string {
+	return this.keyspace.scope.session.id
+}
+
+func (this *indexer) ScopeId() string {
+	return _SCOPE_NAME
+}
+
+func (this *indexer) KeyspaceId() string {
+	return this.keyspace.name
+}
+
+func (this *indexer) Name() datastore.IndexType {
+	return datastore.DEFAULT
+}
+
+func (this *indexer) IndexIds() ([]string, errors.Error) {
+	return this.IndexNames()
+}
+
+func (this *indexer) IndexNames() ([]string, errors.Error) {
+	return []string{this.primary.Name()}, nil
+}
+
+func (this *indexer) IndexById(id string) (datastore.Index, errors.Error) {
+	return this.IndexByName(id)
+}
+
+func (this *indexer) IndexByName(name string) (datastore.Index, errors.Error) {
+	if name != this.primary.Name() {
+		return nil, errors.NewOtherIdxNotFoundError(nil, name+" for temporary collection "+this.keyspace.name)
+	}
+	return this.primary, nil
+}
+
+func (this *indexer) PrimaryIndexes() ([]datastore.PrimaryIndex, errors.Error) {
+	return []datastore.PrimaryIndex{this.primary}, nil
+}
+
+func (this *indexer) Indexes() ([]datastore.Index, errors.Error) {
+	return []datastore.Index{this.primary}, nil
+}
+
+func (this *indexer) CreatePrimaryIndex(requestId, name string, with value.Value) (datastore.PrimaryIndex, errors.Error) {
+	return this.primary, nil
+}
+
+func (this *indexer) CreateIndex(requestId, name string, seekKey, rangeKey expression.Expressions,
+	where expression.Expression, with value.Value) (datastore.Index, errors.Error) {
+	return nil, errors.NewOtherNotSupportedError(nil, "CREATE INDEX is not supported for temporary collections.")
+}
+
+func (this *indexer) BuildIndexes(requestId string, names ...string) errors.Error {
+	return errors.NewOtherNotSupportedError(nil, "BUILD INDEXES is not supported for temporary collections.")
+}
+
+func (this *indexer) Refresh() errors.Error {
+	return nil
+}
+
+func (this *indexer) MetadataVersion() uint64 {
+	return 0
+}
+
+func (this *indexer) SetLogLevel(level logging.Level) {
+	// No-op, uses query engine logger
+}
+
+func (this *indexer) SetConnectionSecurityConfig(conSecConfig *datastore.ConnectionSecurityConfig) {
+	// Do nothing.
+}
+
+type primaryIndex struct {
+	indexer *indexer
+}
+
+func (this *primaryIndex) BucketId() string {
+	return this.indexer.BucketId()
+}
+
+func (this *primaryIndex) ScopeId() string {
+	return _SCOPE_NAME
+}
+
+func (this *primaryIndex) KeyspaceId() string {
+	return this.indexer.KeyspaceId()
+}
+
+func (this *primaryIndex) Id() string {
+	return this.Name()
+}
+
+func (this *primaryIndex) Name() string {
+	return "#primary"
+}
+
+func (this *primaryIndex) Type() datastore.IndexType {
+	return datastore.DEFAULT
+}
+
+func (this *primaryIndex) Indexer() datastore.Indexer {
+	return this.indexer
+}
+
+func (this *primaryIndex) SeekKey() expression.Expressions {
+	return nil
+}
+
+func (this *primaryIndex) RangeKey() expression.Expressions {
+	return nil
+}
+
+func (this *primaryIndex) Condition() expression.Expression {
+	return nil
+}
+
+func (this *primaryIndex) IsPrimary() bool {
+	return true
+}
+
+func (this *primaryIndex) State() (state datastore.IndexState, msg string, err errors.Error) {
+	return datastore.ONLINE, "", nil
+}
+
+func (this *primaryIndex) Statistics(requestId string, span *datastore.Span) (
+	datastore.Statistics, errors.Error) {
+	return nil, nil
+}
+
+func (this *primaryIndex) Drop(requestId string) errors.Error {
+	return errors.NewOtherIdxNoDrop(nil, "The primary index of a temporary collection cannot be dropped.")
+}
+
+func (this *primaryIndex) Scan(requestId string, span *datastore.Span, distinct bool, limit int64,
+	cons datastore.ScanConsistency, vector timestamp.Vector, conn *datastore.IndexConnection) {
+	defer conn.Sender().Close()
+
+	// For primary indexes, bounds must always be strings
+	low, high := "", ""
+	if len(span.Range.Low) > 0 {
+		a, ok := span.Range.Low[0].Actual().(string)
+		if !ok {
+			conn.Error(errors.NewOtherDatastoreError(nil, fmt.Sprintf("Invalid lower bound %v.", span.Range.Low[0])))
+			return
+		}
+		low = a
+	}
+	if len(span.Range.High) > 0 {
+		a, ok := span.Range.High[0].Actual().(string)
+		if !ok {
+			conn.Error(errors.NewOtherDatastoreError(nil, fmt.Sprintf("Invalid upper bound %v.", span.Range.High[0])))
+			return
+		}
+		high = a
+	}
+
+	n := int64(0)
+	for _, id := range this.indexer.keyspace.keys() {
+		if limit > 0 && n >= limit {
+			break
+		}
+		if low != "" && (id < low || (id == low && (span.Range.Inclusion&datastore.LOW == 0))) {
+			continue
+		}
+		if high != "" && (id > high || (id == high && (span.Range.Inclusion&datastore.HIGH == 0))) {
+			break
+		}
+		if !conn.Sender().SendEntry(&datastore.IndexEntry{PrimaryKey: id}) {
+			return
+		}
+		n++
+	}
+}
+
+func (this *primaryIndex) ScanEntries(requestId string, limit int64, cons datastore.ScanConsistency,
+	vector timestamp.Vector, conn *datastore.IndexConnection) {
+	defer conn.Sender().Close()
+
+	for i, id := range this.indexer.keyspace.keys() {
+		if limit > 0 && int64(i) >= limit {
+			break
+		}
+		if !conn.Sender().SendEntry(&datastore.IndexEntry{PrimaryKey: id}) {
+			return
+		}
+	}
+}
@@ -0,0 +1,330 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+/*
+Package sessions holds the state requests share when they run in the same
+session: session variables, default named parameters and query context, and
+temporary collections, which live in engine memory.
+
+A session is started by a user, and can only be used by the same user.
+It ends when it is explicitly ended, or when it has been idle for longer
+than its timeout.
+Temporary collections are not transactional: mutations are applied
+immediately, and are not undone when a transaction rolls back.
+*/
+package sessions
+
+import (
+	"sort"
+	"sync"
+	go_atomic "sync/atomic"
+	"time"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/util"
+	"github.com/couchbase/query/value"
+)
+
+const (
+	DEFAULT_TIMEOUT      = 30 * time.Minute
+	MAX_TIMEOUT          = 24 * time.Hour
+	DEFAULT_MEMORY_QUOTA = 256 // MB
+)
+
+type Session struct {
+	sync.RWMutex
+	id           string
+	user         string
+	created      time.Time
+	lastUse      time.Time
+	timeout      time.Duration
+	uses         int32
+	variables    map[string]value.Value
+	defaults     map[string]value.Value
+	queryContext string
+	scope        *scope
+	memory       int64
+	ended        bool
+}
+
+type sessionCache struct {
+	cache         *util.GenCache
+	cleanupIntrvl time.Duration
+	memoryQuota   uint64
+}
+
+var sessions = &sessionCache{
+	cache:       util.NewGenCache(-1),
+	memoryQuota: DEFAULT_MEMORY_QUOTA,
+}
+
+func SessionCacheInit(intrvl time.Duration) {
+	sessions.cleanupIntrvl = intrvl
+	datastore.SetSessionNamespace(&namespace{})
+	go sessions.doSessionCleanup()
+}
+
+// memory available to the temporary collections of each session, in MB; zero means no limit
+func SetMemoryQuota(quota uint64) {
+	go_atomic.StoreUint64(&sessions.memoryQuota, quota)
+}
+
+func MemoryQuota() uint64 {
+	return go_atomic.LoadUint64(&sessions.memoryQuota)
+}
+
+func Start(user string, timeout time.Duration, defaults map[string]value.Value, queryContext string) (*Session, errors.Error) {
+	if timeout <= 0 {
+		timeout = DEFAULT_TIMEOUT
+	} else if timeout > MAX_TIMEOUT {
+		timeout = MAX_TIMEOUT
+	}
+	id, err := util.UUIDV3()
+	if err != nil {
+		return nil, errors.NewServiceErrorBadValue(err, "session id")
+	}
+	if defaults == nil {
+		defaults = make(map[string]value.Value)
+	}
+
+	now := time.Now()
+	rv := &Session{
+		id:           id,
+		user:         user,
+		created:      now,
+		lastUse:      now,
+		timeout:      timeout,
+		variables:    make(map[string]value.Value),
+		defaults:     defaults,
+		queryContext: queryContext,
+	}
+	rv.scope = newScope(rv)
+	sessions.cache.FastAdd(rv, id)
+	return rv, nil
+}
+
+// Get marks the session in use: each successful call needs a matching Release
+func Get(id string, users []string) (*Session, errors.Error) {
+	rv, _ := sessions.cache.Use(id, nil).(*Session)
+	if rv == nil || !rv.ownedBy(users) {
+		return nil, errors.NewServiceErrorNoSuchSession(id)
+	}
+	rv.Lock()
+	defer rv.Unlock()
+	if rv.ended || rv.expired(time.Now()) {
+		return nil, errors.NewServiceErrorNoSuchSession(id)
+	}
+	rv.uses++
+	rv.lastUse = time.Now()
+	return rv, nil
+}
+
+func End(id string, users []string) errors.Error {
+	rv, _ := sessions.cache.Get(id, nil).(*Session)
+	if rv == nil || !rv.ownedBy(users) {
+		return errors.NewServiceErrorNoSuchSession(id)
+	}
+	sessions.cache.Delete(id, nil)
+	rv.end()
+	return nil
+}
+
+func Count() int {
+	return sessions.cache.Size()
+}
+
+func (this *Session) Release() {
+	this.Lock()
+	this.uses--
+	this.lastUse = time.Now()
+	this.Unlock()
+}
+
+func (this *Session) Id() string {
+	return this.id
+}
+
+func (this *Session) User() string {
+	return this.user
+}
+
+func (this *Session) Timeout() time.Duration {
+	return this.timeout
+}
+
+func (this *Session) QueryContext() string {
+	return this.queryContext
+}
+
+// the default named parameters, which requests can override
+func (this *Session) Defaults() map[string]value.Value {
+	return this.defaults
+}
+
+func (this *Session) SetVariable(name string, val value.Value) {
+	this.Lock()
+	this.variables[name] = val
+	this.Unlock()
+}
+
+// a snapshot of the session variables
+func (this *Session) Variables() map[string]value.Value {
+	this.RLock()
+	defer this.RUnlock()
+	rv := make(map[string]value.Value, len(this.variables))
+	for n, v := range this.variables {
+		rv[n] = v
+	}
+	return rv
+}
+
+// the full path of a temporary collection, if the session has one by that name
+func (this *Session) KeyspacePath(name string) []string {
+	if this.scope.keyspace(name) == nil {
+		return nil
+	}
+	return []string{datastore.SESSION_NAMESPACE, this.id, _SCOPE_NAME, name}
+}
+
+func (this *Session) CreateKeyspace(name string, failIfExists bool) errors.Error {
+	err := this.scope.CreateCollection(name)
+	if err != nil && !failIfExists && err.Code() == errors.EXE_TEMP_COLLECTION_EXISTS {
+		return nil
+	}
+	return err
+}
+
+func (this *Session) DropKeyspace(name string, failIfNotExists bool) errors.Error {
+	err := this.scope.DropCollection(name)
+	if err != nil && !failIfNotExists && err.Code() == errors.EXE_TEMP_COLLECTION_NOT_FOUND {
+		return nil
+	}
+	return err
+}
+
+// the state of the session, as shown by the session endpoint
+func (this *Session) Format() map[string]interface{} {
+	this.RLock()
+	rv := map[string]interface{}{
+		"id":       this.id,
+		"user":     this.user,
+		"created":  this.created.Format(expression.DEFAULT_FORMAT),
+		"lastUse":  this.lastUse.Format(expression.DEFAULT_FORMAT),
+		"timeout":  this.timeout.String(),
+		"requests": this.uses,
+	}
+	if this.queryContext != "" {
+		rv["query_context"] = this.queryContext
+	}
+	if len(this.defaults) > 0 {
+		rv["defaults"] = this.defaults
+	}
+	if len(this.variables) > 0 {
+		rv["variables"] = this.variables
+	}
+	this.RUnlock()
+
+	names, _ := this.scope.KeyspaceNames()
+	if len(names) > 0 {
+		collections := make(map[string]interface{}, len(names))
+		for _, name := range names {
+			if ks := this.scope.keyspace(name); ks != nil {
+				count, _ := ks.Count(datastore.NULL_QUERY_CONTEXT)
+				collections[name] = map[string]interface{}{"count": count}
+			}
+		}
+		rv["collections"] = collections
+		rv["memory"] = go_atomic.LoadInt64(&this.memory)
+	}
+	return rv
+}
+
+// reserve, or give back, memory for temporary collection documents
+func (this *Session) account(delta int64) errors.Error {
+	if delta > 0 {
+		quota := MemoryQuota()
+		if quota > 0 && go_atomic.LoadInt64(&this.memory)+delta > int64(quota*1024*1024) {
+			return errors.NewSessionMemoryQuotaExceededError(quota)
+		}
+	}
+	go_atomic.AddInt64(&this.memory, delta)
+	return nil
+}
+
+// sessions started without credentials can only be used without credentials
+func (this *Session) ownedBy(users []string) bool {
+	if len(users) == 0 {
+		return this.user == ""
+	}
+	for _, u := range users {
+		if u == this.user {
+			return true
+		}
+	}
+	return false
+}
+
+// must be called with the session lock held
+func (this *Session) expired(now time.Time) bool {
+	return this.uses <= 0 && now.Sub(this.lastUse) > this.timeout
+}
+
+func (this *Session) end() {
+	this.Lock()
+	this.ended = true
+	this.variables = make(map[string]value.Value)
+	this.Unlock()
+	this.scope.dropAll()
+	go_atomic.StoreInt64(&this.memory, 0)
+}
+
+func (this *sessionCache) doSessionCleanup() {
+	defer func() {
+		recover()
+		go this.doSessionCleanup()
+	}()
+
+	data := make([]*Session, 0, this.cache.Size())
+	for {
+		data = data[0:0]
+		time.Sleep(this.cleanupIntrvl)
+
+		now := time.Now()
+		snapshot := func(name string, d interface{}) bool {
+			session := d.(*Session)
+			session.RLock()
+			if session.expired(now) {
+				data = append(data, session)
+			}
+			session.RUnlock()
+			return true
+		}
+
+		this.cache.ForEach(snapshot, nil)
+		for _, session := range data {
+			session.RLock()
+			expired := session.expired(now)
+			session.RUnlock()
+			if expired {
+				this.cache.Delete(session.id, nil)
+				session.end()
+			}
+		}
+	}
+}
+
+func sortedNames(m map[string]*keyspace) []string {
+	rv := make([]string, 0, len(m))
+	for n, _ := range m {
+		rv = append(rv, n)
+	}
+	sort.Strings(rv)
+	return rv
+}
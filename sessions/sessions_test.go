@@ -0,0 +1,131 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package sessions
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/value"
+)
+
+func TestSession(t *testing.T) {
+	session, err := Start("local:alice", 0, map[string]value.Value{"limit": value.NewValue(10)}, "default:travel")
+	if err != nil {
+		t.Fatalf("Cannot start session: %v", err)
+	}
+	if session.Timeout() != DEFAULT_TIMEOUT {
+		t.Errorf("Expected default timeout, got %v", session.Timeout())
+	}
+
+	// only the owner can use the session
+	if _, err = Get(session.Id(), []string{"local:bob"}); err == nil || err.Code() != errors.SERVICE_NO_SUCH_SESSION {
+		t.Errorf("Expected no such session for another user, got %v", err)
+	}
+	if _, err = Get(session.Id(), nil); err == nil {
+		t.Errorf("Expected no such session without credentials")
+	}
+	s, err := Get(session.Id(), []string{"local:bob", "local:alice"})
+	if err != nil || s != session {
+		t.Fatalf("Cannot get session: %v", err)
+	}
+
+	// variables are snapshots
+	s.SetVariable("total", value.NewValue(5))
+	vars := s.Variables()
+	s.SetVariable("total", value.NewValue(6))
+	if vars["total"].Actual() != int64(5) || s.Variables()["total"].Actual() != int64(6) {
+		t.Errorf("Unexpected variables %v %v", vars, s.Variables())
+	}
+	s.Release()
+
+	if err = End(session.Id(), []string{"local:alice"}); err != nil {
+		t.Errorf("Cannot end session: %v", err)
+	}
+	if _, err = Get(session.Id(), []string{"local:alice"}); err == nil {
+		t.Errorf("Expected no such session after end")
+	}
+	if len(session.Variables()) != 0 {
+		t.Errorf("Expected no variables after end, got %v", session.Variables())
+	}
+}
+
+func TestTemporaryCollection(t *testing.T) {
+	session, _ := Start("", 0, nil, "")
+	defer End(session.Id(), nil)
+
+	if session.KeyspacePath("staging") != nil {
+		t.Errorf("Unexpected path for missing collection")
+	}
+	if err := session.CreateKeyspace("staging", true); err != nil {
+		t.Fatalf("Cannot create collection: %v", err)
+	}
+	if err := session.CreateKeyspace("staging", true); err == nil || err.Code() != errors.EXE_TEMP_COLLECTION_EXISTS {
+		t.Errorf("Expected collection exists, got %v", err)
+	}
+	if err := session.CreateKeyspace("staging", false); err != nil {
+		t.Errorf("Unexpected error for IF NOT EXISTS: %v", err)
+	}
+	path := session.KeyspacePath("staging")
+	if strings.Join(path, ":") != datastore.SESSION_NAMESPACE+":"+session.Id()+":_default:staging" {
+		t.Errorf("Unexpected path %v", path)
+	}
+
+	ks := session.scope.keyspace("staging")
+	pairs := []value.Pair{
+		{Name: "k2", Value: value.NewValue(map[string]interface{}{"a": 2})},
+		{Name: "k1", Value: value.NewValue(map[string]interface{}{"a": 1})},
+	}
+	if _, err := ks.Insert(pairs, datastore.NULL_QUERY_CONTEXT); err != nil {
+		t.Fatalf("Cannot insert: %v", err)
+	}
+	if _, err := ks.Insert(pairs[:1], datastore.NULL_QUERY_CONTEXT); err == nil {
+		t.Errorf("Expected duplicate key error")
+	}
+	if keys := ks.keys(); len(keys) != 2 || keys[0] != "k1" {
+		t.Errorf("Unexpected keys %v", keys)
+	}
+	fetched := make(map[string]value.AnnotatedValue)
+	ks.Fetch([]string{"k1", "k3"}, fetched, datastore.NULL_QUERY_CONTEXT, nil)
+	if len(fetched) != 1 || fetched["k1"] == nil {
+		t.Errorf("Unexpected fetch %v", fetched)
+	}
+	if session.memory <= 0 {
+		t.Errorf("Expected memory in use")
+	}
+
+	if err := session.DropKeyspace("staging", true); err != nil {
+		t.Errorf("Cannot drop collection: %v", err)
+	}
+	if err := session.DropKeyspace("staging", true); err == nil || err.Code() != errors.EXE_TEMP_COLLECTION_NOT_FOUND {
+		t.Errorf("Expected collection not found, got %v", err)
+	}
+	if session.memory != 0 {
+		t.Errorf("Expected no memory in use, got %v", session.memory)
+	}
+}
+
+func TestMemoryQuota(t *testing.T) {
+	quota := MemoryQuota()
+	defer SetMemoryQuota(quota)
+	SetMemoryQuota(1)
+
+	session, _ := Start("", 0, nil, "")
+	defer End(session.Id(), nil)
+	session.CreateKeyspace("staging", true)
+	ks := session.scope.keyspace("staging")
+
+	big := value.NewValue(strings.Repeat("x", 2*1024*1024))
+	_, err := ks.Upsert([]value.Pair{{Name: "k", Value: big}}, datastore.NULL_QUERY_CONTEXT)
+	if err == nil || err.Code() != errors.EXE_SESSION_MEMORY_QUOTA {
+		t.Errorf("Expected quota exceeded, got %v", err)
+	}
+}
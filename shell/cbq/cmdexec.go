@@ -10,9 +10,12 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"io"
 	"os"
+	"os/signal"
 	"strings"
+	"time"
 	"unicode"
 
 	"github.com/couchbase/godbc/n1ql"
@@ -223,12 +226,28 @@ func ExecN1QLStmt(line string, dBn1ql n1ql.N1qlDB, w io.Writer) (int, string) {
 	if rows != nil {
 		// We have output. That is what we want.
 
-		_, werr := io.Copy(w, rows)
+		if format := command.OutputFormat(); command.PLAN_VIEW {
+			// Plans are displayed as operator trees
+			err_code, err_str := command.WritePlans(rows, w, format)
+			if err_code != 0 {
+				return err_code, err_str
+			}
+		} else if format != command.JSON_FORMAT {
+			// Tabular formats need the whole response
+			err_code, err_str := command.WriteResults(rows, w, format)
+			if err_code != 0 {
+				return err_code, err_str
+			}
+		} else {
+			_, werr := io.Copy(w, rows)
 
-		// For any captured write error
-		if werr != nil {
-			return errors.WRITER_OUTPUT, werr.Error()
-		} else if err != nil {
+			// For any captured write error
+			if werr != nil {
+				return errors.WRITER_OUTPUT, werr.Error()
+			}
+		}
+
+		if err != nil {
 			// Return error from godbc if there is one. This is for N1QL errors.
 			return errors.DRIVER_QUERY, err.Error()
 		}
@@ -323,6 +342,9 @@ func ExecShellCmd(line string, liner *liner.State) (int, string) {
 
 	EXIT = command.EXIT
 
+	// Run the startup commands of a profile connected to with \CONNECT
+	runStartup(command.TakeProfileStartup(), liner)
+
 	// File based input. Run all the commands as seen in the file
 	// given by FILE_INPUT and then return the prompt.
 	if strings.HasPrefix(line, "\\source") && command.FILE_RD_MODE == true {
@@ -333,9 +355,62 @@ func ExecShellCmd(line string, liner *liner.State) (int, string) {
 
 	} // ends main if loop for
 
+	// Statement to run repeatedly, given by WATCH_STMT.
+	if command.WATCH_STMT != "" {
+		watchAndExec(liner)
+	}
+
 	return 0, ""
 }
 
+// Helper function to run the statement given by WATCH_STMT every
+// WATCH_INTERVAL until interrupted. Errors are displayed with the
+// output of the run, and do not stop the following runs.
+func watchAndExec(liner *liner.State) {
+	stmt := command.WATCH_STMT
+	command.WATCH_STMT = ""
+
+	w := command.W
+	redraw := command.WatchRedraw()
+
+	// Ctrl-C stops watching instead of exiting the shell
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	ticker := time.NewTicker(command.WATCH_INTERVAL)
+	defer ticker.Stop()
+
+	prev := ""
+	for {
+		var b bytes.Buffer
+		start := time.Now()
+		errCode, errStr := dispatch_command(stmt, &b, true, liner)
+		if errCode != 0 {
+			s_err := command.HandleError(errCode, errStr)
+			command.PrintError(s_err)
+		}
+		command.W = w
+
+		output := b.String()
+		if command.WATCH_DIFF && redraw {
+			output = command.WatchDiff(prev, output)
+		}
+		prev = b.String()
+		io.WriteString(w, command.WatchHeader(stmt, command.WATCH_INTERVAL, start, redraw)+output)
+		if !redraw {
+			io.WriteString(w, "\n")
+		}
+
+		select {
+		case <-ticker.C:
+		case <-interrupt:
+			io.WriteString(w, "\n")
+			return
+		}
+	}
+}
+
 // Helper function to read file based input. Run all the commands as
 // seen in the file given by FILE_INPUT and then return the prompt.
 func readAndExec(liner *liner.State) (int, string) {
@@ -0,0 +1,359 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/couchbase/query/errors"
+)
+
+const (
+	_BENCH_RUNS        = 100
+	_BENCH_CONCURRENCY = 1
+)
+
+var _BENCH_PERCENTILES = []float64{50, 90, 99, 100}
+
+/* Bench Command */
+type Bench struct {
+	ShellCommand
+}
+
+func (this *Bench) Name() string {
+	return "BENCH"
+}
+
+func (this *Bench) CommandCompletion() bool {
+	return true
+}
+
+func (this *Bench) MinArgs() int {
+	return ONE_ARG
+}
+
+func (this *Bench) MaxArgs() int {
+	return MAX_ARGS
+}
+
+type benchOptions struct {
+	runs        int
+	concurrency int
+	warmup      int
+	prepared    bool
+	params      string
+	statement   string
+}
+
+/* Timings of one execution of the statement */
+type benchRun struct {
+	latency   time.Duration
+	elapsed   time.Duration
+	execution time.Duration
+	err       string
+}
+
+func (this *Bench) ExecCommand(args []string) (int, string) {
+	/* Command to run a statement repeatedly and report
+	   its throughput and latency percentiles.
+	*/
+	if len(args) < this.MinArgs() {
+		return errors.TOO_FEW_ARGS, ""
+	}
+
+	options, ok := benchArgs(args)
+	if !ok {
+		return errors.BENCH_ARGS, HBENCH
+	}
+
+	if !connected() {
+		return errors.NO_CONNECTION, ""
+	}
+
+	// Parameters are passed in the USING clause of EXECUTE, since
+	// request parameters are shared by all the statements of the shell
+	var params []string
+	if options.params != "" {
+		var err_code int
+		var err_str string
+		params, err_code, err_str = benchParams(options.params)
+		if err_code != 0 {
+			return err_code, err_str
+		}
+		options.prepared = true
+	}
+
+	stmt := options.statement
+	if options.prepared {
+		name := "cbq_bench_" + strconv.FormatInt(time.Now().UnixNano(), 36)
+		run := benchStatement("PREPARE `" + name + "` FROM " + stmt)
+		if run.err != "" {
+			return errors.DRIVER_QUERY, run.err
+		}
+		defer benchStatement("DELETE FROM system:prepareds WHERE name = \"" + name + "\"")
+		stmt = "EXECUTE `" + name + "`"
+	}
+
+	statement := func() string {
+		if len(params) == 0 {
+			return stmt
+		}
+		return stmt + " USING " + params[rand.Intn(len(params))]
+	}
+
+	if options.warmup > 0 {
+		benchRuns(statement, options.warmup, options.concurrency, "Warmup")
+	}
+	start := time.Now()
+	runs := benchRuns(statement, options.runs, options.concurrency, "Run")
+	duration := time.Since(start)
+	if showProgress() {
+		io.WriteString(W, "\n")
+	}
+
+	writeBench(W, &options, runs, duration)
+	return 0, ""
+}
+
+/*
+	Options come first, followed by the statement:
+	-n runs, -c concurrency, -warmup runs, -prepared and -params file.
+*/
+func benchArgs(args []string) (benchOptions, bool) {
+	options := benchOptions{runs: _BENCH_RUNS, concurrency: _BENCH_CONCURRENCY}
+	i := 0
+	for ; i < len(args) && strings.HasPrefix(args[i], "-"); i++ {
+		opt := strings.ToLower(args[i])
+		if opt == "-prepared" {
+			options.prepared = true
+			continue
+		}
+
+		i++
+		if i == len(args) {
+			return options, false
+		}
+		var err error
+		switch opt {
+		case "-n":
+			options.runs, err = strconv.Atoi(args[i])
+		case "-c":
+			options.concurrency, err = strconv.Atoi(args[i])
+		case "-warmup":
+			options.warmup, err = strconv.Atoi(args[i])
+		case "-params":
+			options.params = args[i]
+		default:
+			return options, false
+		}
+		if err != nil {
+			return options, false
+		}
+	}
+
+	options.statement = strings.TrimSuffix(strings.TrimSpace(strings.Join(args[i:], " ")), ";")
+	return options, options.statement != "" && options.runs > 0 && options.concurrency > 0 && options.warmup >= 0
+}
+
+/*
+	Named parameter sets, as USING clauses, from a JSON, JSON lines or CSV
+	file. Parameter names may have the $ prefix of request parameters.
+*/
+func benchParams(file string) ([]string, int, string) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, errors.FILE_OPEN, err.Error()
+	}
+	defer f.Close()
+
+	format, _ := bulkFormat(file, "")
+	reader, err := newDocReader(f, format)
+	if err != nil {
+		return nil, errors.READ_FILE, err.Error()
+	}
+
+	var params []string
+	for {
+		doc, err := reader.next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, errors.READ_FILE, err.Error()
+		}
+
+		var named map[string]json.RawMessage
+		err = json.Unmarshal(doc, &named)
+		if err != nil {
+			return nil, errors.READ_FILE, fmt.Sprintf("%s: parameters must be objects", file)
+		}
+		using := make(map[string]json.RawMessage, len(named))
+		for name, v := range named {
+			using[strings.TrimPrefix(name, "$")] = v
+		}
+		b, _ := json.Marshal(using)
+		params = append(params, string(b))
+	}
+	if len(params) == 0 {
+		return nil, errors.READ_FILE, fmt.Sprintf("%s: no parameters", file)
+	}
+	return params, 0, ""
+}
+
+func benchRuns(statement func() string, count, concurrency int, label string) []benchRun {
+	runs := make([]benchRun, count)
+	next := make(chan int, concurrency)
+	done := 0
+
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := range next {
+				runs[n] = benchStatement(statement())
+
+				lock.Lock()
+				done++
+				progress("%s %d of %d", label, done, count)
+				lock.Unlock()
+			}
+		}()
+	}
+	for n := 0; n < count; n++ {
+		next <- n
+	}
+	close(next)
+	wg.Wait()
+	return runs
+}
+
+func benchStatement(stmt string) benchRun {
+	var run benchRun
+
+	start := time.Now()
+	rows, err := DbN1ql.QueryRaw(stmt)
+	if rows == nil {
+		run.latency = time.Since(start)
+		if err != nil {
+			run.err = err.Error()
+		}
+		return run
+	}
+
+	// the results themselves are not kept
+	var response struct {
+		Errors  []queryMessage `json:"errors"`
+		Metrics struct {
+			ElapsedTime   string `json:"elapsedTime"`
+			ExecutionTime string `json:"executionTime"`
+		} `json:"metrics"`
+	}
+	derr := json.NewDecoder(rows).Decode(&response)
+	rows.Close()
+	run.latency = time.Since(start)
+
+	if len(response.Errors) > 0 {
+		run.err = fmt.Sprintf("ERROR %v : %v", response.Errors[0].Code, response.Errors[0].Msg)
+	} else if err != nil {
+		run.err = err.Error()
+	} else if derr != nil {
+		run.err = derr.Error()
+	}
+	run.elapsed, _ = time.ParseDuration(response.Metrics.ElapsedTime)
+	run.execution, _ = time.ParseDuration(response.Metrics.ExecutionTime)
+	return run
+}
+
+/* Nearest rank percentile of sorted durations */
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func writeBench(w io.Writer, options *benchOptions, runs []benchRun, duration time.Duration) {
+	var latency, elapsed, execution []time.Duration
+	failures := make(map[string]int)
+	for _, r := range runs {
+		if r.err != "" {
+			failures[r.err]++
+			continue
+		}
+		latency = append(latency, r.latency)
+		elapsed = append(elapsed, r.elapsed)
+		execution = append(execution, r.execution)
+	}
+
+	fmt.Fprintf(w, "Runs : %d, concurrency : %d, warmup : %d, errors : %d\n",
+		options.runs, options.concurrency, options.warmup, len(runs)-len(latency))
+	if duration > 0 {
+		fmt.Fprintf(w, "Throughput : %.2f statements/s\n\n", float64(len(latency))/duration.Seconds())
+	}
+
+	fmt.Fprintf(w, "%-15s", "")
+	for _, p := range _BENCH_PERCENTILES {
+		if p == 100 {
+			fmt.Fprintf(w, "%12s", "max")
+		} else {
+			fmt.Fprintf(w, "%12s", "p"+strconv.FormatFloat(p, 'f', -1, 64))
+		}
+	}
+	io.WriteString(w, "\n")
+	for _, row := range []struct {
+		name  string
+		times []time.Duration
+	}{{"latency", latency}, {"elapsedTime", elapsed}, {"executionTime", execution}} {
+		sort.Slice(row.times, func(i, j int) bool { return row.times[i] < row.times[j] })
+		fmt.Fprintf(w, "%-15s", row.name)
+		for _, p := range _BENCH_PERCENTILES {
+			fmt.Fprintf(w, "%12v", percentile(row.times, p).Round(time.Microsecond))
+		}
+		io.WriteString(w, "\n")
+	}
+
+	messages := make([]string, 0, len(failures))
+	for m, _ := range failures {
+		messages = append(messages, m)
+	}
+	sort.Strings(messages)
+	for _, m := range messages {
+		fmt.Fprintf(w, "%s (%d times)\n", m, failures[m])
+	}
+}
+
+func (this *Bench) PrintHelp(desc bool) (int, string) {
+	_, werr := io.WriteString(W, HBENCH)
+	if desc {
+		err_code, err_str := printDesc(this.Name())
+		if err_code != 0 {
+			return err_code, err_str
+		}
+	}
+	_, werr = io.WriteString(W, "\n")
+	if werr != nil {
+		return errors.WRITER_OUTPUT, werr.Error()
+	}
+	return 0, ""
+}
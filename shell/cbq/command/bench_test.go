@@ -0,0 +1,57 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package command
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBenchArgs(t *testing.T) {
+	options, ok := benchArgs(strings.Fields("-n 50 -c 4 -warmup 5 -prepared select * from b where a = $a;"))
+	if !ok || options.runs != 50 || options.concurrency != 4 || options.warmup != 5 || !options.prepared ||
+		options.statement != "select * from b where a = $a" {
+		t.Errorf("Unexpected options %+v", options)
+	}
+
+	options, ok = benchArgs(strings.Fields("select 1"))
+	if !ok || options.runs != _BENCH_RUNS || options.concurrency != _BENCH_CONCURRENCY || options.prepared {
+		t.Errorf("Unexpected default options %+v", options)
+	}
+
+	for _, args := range []string{"-n 0 select 1", "-c x select 1", "-n 10", "-x 1 select 1", "-warmup"} {
+		if _, ok = benchArgs(strings.Fields(args)); ok {
+			t.Errorf("Expected error for %s", args)
+		}
+	}
+}
+
+func TestWriteBench(t *testing.T) {
+	runs := make([]benchRun, 0, 101)
+	for i := 1; i <= 100; i++ {
+		d := time.Duration(i) * time.Millisecond
+		runs = append(runs, benchRun{latency: d, elapsed: d / 2, execution: d / 4})
+	}
+	runs = append(runs, benchRun{err: "ERROR 5000 : boom"})
+
+	var b bytes.Buffer
+	writeBench(&b, &benchOptions{runs: 101, concurrency: 2}, runs, 2*time.Second)
+	expected := "Runs : 101, concurrency : 2, warmup : 0, errors : 1\n" +
+		"Throughput : 50.00 statements/s\n\n" +
+		"                        p50         p90         p99         max\n" +
+		"latency                50ms        90ms        99ms       100ms\n" +
+		"elapsedTime            25ms        45ms      49.5ms        50ms\n" +
+		"executionTime        12.5ms      22.5ms     24.75ms        25ms\n" +
+		"ERROR 5000 : boom (1 times)\n"
+	if b.String() != expected {
+		t.Errorf("Unexpected output:\n%s\nexpected:\n%s", b.String(), expected)
+	}
+}
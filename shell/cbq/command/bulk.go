@@ -0,0 +1,305 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package command
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+/* JSON lines, one document per line, for \IMPORT and \EXPORT */
+const JSONL_FORMAT = "jsonl"
+
+var _BULK_FORMATS = map[string]bool{
+	JSON_FORMAT:  true,
+	JSONL_FORMAT: true,
+	CSV_FORMAT:   true,
+}
+
+/*
+	Splits the arguments of a bulk command into clauses, introduced
+	by one of the given keywords. Arguments before the first keyword
+	are returned under "". False if a clause is repeated.
+*/
+func bulkClauses(args []string, keywords map[string]bool) (map[string]string, bool) {
+	clauses := make(map[string]string, len(keywords)+1)
+	clause := ""
+	for _, a := range args {
+		if k := strings.ToUpper(a); keywords[k] {
+			if _, ok := clauses[k]; ok {
+				return nil, false
+			}
+			clause = k
+			clauses[clause] = ""
+		} else if clauses[clause] == "" {
+			clauses[clause] = a
+		} else {
+			clauses[clause] += " " + a
+		}
+	}
+	return clauses, true
+}
+
+/* The format named, or else the one implied by the file extension */
+func bulkFormat(file, format string) (string, bool) {
+	if format != "" {
+		format = strings.ToLower(format)
+		return format, _BULK_FORMATS[format]
+	}
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".csv":
+		return CSV_FORMAT, true
+	case ".jsonl", ".ndjson":
+		return JSONL_FORMAT, true
+	}
+	return JSON_FORMAT, true
+}
+
+func bulkNumber(s string, def int) (int, bool) {
+	if s == "" {
+		return def, true
+	}
+	n, err := strconv.Atoi(s)
+	return n, err == nil && n > 0
+}
+
+/* Progress is only displayed when the output is a terminal */
+func showProgress() bool {
+	f, ok := W.(*os.File)
+	return ok && f == os.Stdout && terminalHeight(f) > 0
+}
+
+func progress(format string, args ...interface{}) {
+	if showProgress() {
+		fmt.Fprintf(W, "\r"+format, args...)
+	}
+}
+
+/*
+	Reads the documents of an import file one at a time. JSON files
+	hold an array of documents or a sequence of documents, like JSON
+	lines. CSV files have a header row with the field names.
+*/
+type docReader interface {
+	next() (json.RawMessage, error)
+}
+
+type jsonReader struct {
+	dec     *json.Decoder
+	inArray bool
+}
+
+func newDocReader(r io.Reader, format string) (docReader, error) {
+	br := bufio.NewReader(r)
+	if format == CSV_FORMAT {
+		cr := csv.NewReader(br)
+		header, err := cr.Read()
+		if err != nil {
+			return nil, err
+		}
+		return &csvReader{reader: cr, header: header}, nil
+	}
+
+	rv := &jsonReader{dec: json.NewDecoder(br)}
+	for {
+		c, _, err := br.ReadRune()
+		if err == io.EOF {
+			return rv, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if !unicode.IsSpace(c) {
+			br.UnreadRune()
+			rv.inArray = c == '['
+			break
+		}
+	}
+	if rv.inArray {
+		_, err := rv.dec.Token()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return rv, nil
+}
+
+func (this *jsonReader) next() (json.RawMessage, error) {
+	if this.inArray && !this.dec.More() {
+		return nil, io.EOF
+	}
+	var doc json.RawMessage
+	err := this.dec.Decode(&doc)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	err = json.Compact(&b, doc)
+	return b.Bytes(), err
+}
+
+type csvReader struct {
+	reader *csv.Reader
+	header []string
+}
+
+/*
+	Numbers and booleans are imported as such, other values are
+	strings. Empty values are left out of the document.
+*/
+func (this *csvReader) next() (json.RawMessage, error) {
+	record, err := this.reader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	b.WriteString("{")
+	for i, s := range record {
+		if s == "" || i >= len(this.header) {
+			continue
+		}
+		if b.Len() > 1 {
+			b.WriteString(",")
+		}
+		name, _ := json.Marshal(this.header[i])
+		b.Write(name)
+		b.WriteString(":")
+		if s == "true" || s == "false" || ((s[0] == '-' || (s[0] >= '0' && s[0] <= '9')) && json.Valid([]byte(s))) {
+			b.WriteString(s)
+		} else {
+			v, _ := json.Marshal(s)
+			b.Write(v)
+		}
+	}
+	b.WriteString("}")
+	return b.Bytes(), nil
+}
+
+/*
+	Writes the results of an export as they are received. CSV columns
+	come from the projection, or else from the first result.
+*/
+type docWriter interface {
+	signature(raw json.RawMessage)
+	write(raw json.RawMessage) error
+	close() error
+}
+
+func newDocWriter(w *bufio.Writer, format string) docWriter {
+	switch format {
+	case CSV_FORMAT:
+		return &csvWriter{writer: csv.NewWriter(w)}
+	case JSONL_FORMAT:
+		return &jsonWriter{writer: w, lines: true}
+	}
+	return &jsonWriter{writer: w}
+}
+
+type jsonWriter struct {
+	writer *bufio.Writer
+	lines  bool
+	count  int
+}
+
+func (this *jsonWriter) signature(raw json.RawMessage) {
+}
+
+func (this *jsonWriter) write(raw json.RawMessage) error {
+	if !this.lines {
+		if this.count == 0 {
+			this.writer.WriteString("[\n")
+		} else {
+			this.writer.WriteString(",\n")
+		}
+	}
+	this.count++
+	var b bytes.Buffer
+	err := json.Compact(&b, raw)
+	if err != nil {
+		return err
+	}
+	if this.lines {
+		b.WriteString("\n")
+	}
+	_, err = this.writer.Write(b.Bytes())
+	return err
+}
+
+func (this *jsonWriter) close() error {
+	if !this.lines {
+		if this.count == 0 {
+			this.writer.WriteString("[")
+		}
+		this.writer.WriteString("\n]\n")
+	}
+	return this.writer.Flush()
+}
+
+type csvWriter struct {
+	writer  *csv.Writer
+	columns []string
+	header  bool
+}
+
+func (this *csvWriter) signature(raw json.RawMessage) {
+	if names, _, ok := objectFields(raw); ok {
+		for _, name := range names {
+			if name == "*" {
+				this.columns = nil
+				return
+			}
+			this.columns = append(this.columns, name)
+		}
+	}
+}
+
+func (this *csvWriter) write(raw json.RawMessage) error {
+	names, fields, ok := objectFields(raw)
+	if !this.header {
+		if !ok {
+			this.columns = []string{_VALUE_COLUMN}
+		} else if len(this.columns) == 0 {
+			this.columns = names
+		}
+		this.header = true
+		err := this.writer.Write(this.columns)
+		if err != nil {
+			return err
+		}
+	}
+
+	record := make([]string, len(this.columns))
+	if !ok {
+		if len(record) > 0 {
+			record[0] = delimitedText(raw)
+		}
+	} else {
+		for i, name := range this.columns {
+			record[i] = delimitedText(fields[name])
+		}
+	}
+	return this.writer.Write(record)
+}
+
+func (this *csvWriter) close() error {
+	if !this.header && len(this.columns) > 0 {
+		this.writer.Write(this.columns)
+	}
+	this.writer.Flush()
+	return this.writer.Error()
+}
@@ -0,0 +1,102 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package command
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestBulkClauses(t *testing.T) {
+	clauses, ok := bulkClauses(strings.Fields("INTO default key name || '-' || id format CSV"), _IMPORT_CLAUSES)
+	if !ok || clauses["INTO"] != "default" || clauses["KEY"] != "name || '-' || id" || clauses["FORMAT"] != "CSV" {
+		t.Errorf("Unexpected clauses %v", clauses)
+	}
+
+	_, ok = bulkClauses(strings.Fields("INTO a INTO b"), _IMPORT_CLAUSES)
+	if ok {
+		t.Errorf("Expected error for repeated clause")
+	}
+
+	for file, expected := range map[string]string{"a.json": JSON_FORMAT, "a.CSV": CSV_FORMAT, "a.ndjson": JSONL_FORMAT, "a": JSON_FORMAT} {
+		if format, ok := bulkFormat(file, ""); !ok || format != expected {
+			t.Errorf("Unexpected format %s for %s", format, file)
+		}
+	}
+	if _, ok = bulkFormat("a.json", "xml"); ok {
+		t.Errorf("Expected error for invalid format")
+	}
+}
+
+func TestDocReader(t *testing.T) {
+	inputs := map[string]string{
+		"[ {\"a\": 1},\n {\"a\": \"x\"} ]":        JSON_FORMAT,
+		"{\"a\": 1}\n{\"a\": \"x\"}\n":            JSONL_FORMAT,
+		"a,b\n1,\n\"x\",\n":                       CSV_FORMAT,
+		"  [{\"a\":1}, {\"a\" : \"x\"}]\n":        JSON_FORMAT,
+		"a\n1\nx\n":                               CSV_FORMAT,
+		"{\"a\": 1}\n\n   {\"a\":     \"x\"}\n\n": JSON_FORMAT,
+	}
+
+	for input, format := range inputs {
+		reader, err := newDocReader(strings.NewReader(input), format)
+		if err != nil {
+			t.Errorf("Error reading %q: %v", input, err)
+			continue
+		}
+		var docs []string
+		for {
+			doc, err := reader.next()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				t.Errorf("Error reading %q: %v", input, err)
+				break
+			}
+			docs = append(docs, string(doc))
+		}
+		if strings.Join(docs, " ") != "{\"a\":1} {\"a\":\"x\"}" {
+			t.Errorf("Unexpected documents for %q: %v", input, docs)
+		}
+	}
+}
+
+const _EXPORT_RESPONSE = `{
+"requestID": "1",
+"signature": {"name": "json", "abv": "json"},
+"results": [
+	{"name": "ipa", "abv": 6.5},
+	{"name": "stout, dry", "abv": null}
+],
+"warnings": [{"code": 1, "msg": "careful"}],
+"status": "success"
+}`
+
+func TestExportResults(t *testing.T) {
+	expected := map[string]string{
+		CSV_FORMAT:   "name,abv\nipa,6.5\n\"stout, dry\",\n",
+		JSON_FORMAT:  "[\n{\"name\":\"ipa\",\"abv\":6.5},\n{\"name\":\"stout, dry\",\"abv\":null}\n]\n",
+		JSONL_FORMAT: "{\"name\":\"ipa\",\"abv\":6.5}\n{\"name\":\"stout, dry\",\"abv\":null}\n",
+	}
+
+	for format, exp := range expected {
+		var b bytes.Buffer
+		count, msgs, errCode, errStr := exportResults(strings.NewReader(_EXPORT_RESPONSE), newDocWriter(bufio.NewWriter(&b), format))
+		if errCode != 0 {
+			t.Errorf("%s", HandleError(errCode, errStr))
+		} else if count != 2 || len(msgs) != 1 || msgs[0] != "WARNING 1 : careful" {
+			t.Errorf("Unexpected %s export of %d rows, messages %v", format, count, msgs)
+		} else if b.String() != exp {
+			t.Errorf("Unexpected %s export:\n%s\nexpected:\n%s", format, b.String(), exp)
+		}
+	}
+}
@@ -31,6 +31,12 @@ const (
 	UNALIAS_CMD             = "UNALIAS"
 	SOURCE_CMD              = "SOURCE"
 	REDIRECT_CMD            = "REDIRECT"
+	PLAN_CMD                = "PLAN"
+	IMPORT_CMD              = "IMPORT"
+	EXPORT_CMD              = "EXPORT"
+	BENCH_CMD               = "BENCH"
+	WATCH_CMD               = "WATCH"
+	FORMAT_CMD              = "FORMAT"
 	REFRESH_CLUSTER_MAP_CMD = "REFRESH_CLUSTER_MAP"
 )
 
@@ -72,6 +78,8 @@ var (
 	BATCH = "off"
 	//Output File open in append mode
 	FILE_APPEND_MODE = false
+	//True if plans and profiles are displayed as operator trees
+	PLAN_VIEW = false
 )
 
 /* Value to store sorted list of keys for shell commands */
@@ -127,6 +135,12 @@ var COMMAND_LIST = map[string]ShellCommand{
 	/* Scripting Management */
 	"\\source":   &Source{},
 	"\\redirect": &Redirect{},
+	"\\plan":     &Plan{},
+	"\\import":   &Import{},
+	"\\export":   &Export{},
+	"\\bench":    &Bench{},
+	"\\watch":    &Watch{},
+	"\\format":   &Format{},
 
 	"\\refresh_cluster_map": &Refresh_cluster_map{},
 }
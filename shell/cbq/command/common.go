@@ -391,6 +391,12 @@ func PushOrSet(args []string, pushvalue bool) (int, string) {
 
 		args_str := strings.Join(args[1:], " ")
 
+		if vble == OUTPUT_FORMAT_PARAM {
+			if _, ok := _OUTPUT_FORMATS[strings.ToLower(handleStrings(args_str))]; !ok {
+				return errors.OUTPUT_FORMAT, args_str
+			}
+		}
+
 		err_code, err_str := PushValue_Helper(pushvalue, QueryParam, vble, args_str)
 
 		if err_code != 0 {
@@ -419,7 +425,7 @@ func PushOrSet(args []string, pushvalue bool) (int, string) {
 			}
 
 			n1ql.SetQueryParams("creds", string(ac))
-			n1ql.SetUsernamePassword(creds[0]["user"], creds[0]["pass"])
+			SetUsernamePassword(creds[0]["user"], creds[0]["pass"])
 
 		} else {
 
@@ -438,7 +444,7 @@ func PushOrSet(args []string, pushvalue bool) (int, string) {
 				val = ValToStr(v)
 			}
 
-			n1ql.SetQueryParams(vble, val)
+			setQueryParam(vble, val)
 
 		}
 
@@ -573,6 +579,22 @@ func printDesc(cmdname string) (int, string) {
 	case REDIRECT_CMD:
 		return PrintStr(W, DREDIRECT)
 
+	case PLAN_CMD:
+		return PrintStr(W, DPLAN)
+
+	case IMPORT_CMD:
+		return PrintStr(W, DIMPORT)
+
+	case EXPORT_CMD:
+		return PrintStr(W, DEXPORT)
+
+	case BENCH_CMD:
+		return PrintStr(W, DBENCH)
+	case WATCH_CMD:
+		return PrintStr(W, DWATCH)
+	case FORMAT_CMD:
+		return PrintStr(W, DFORMAT)
+
 	case REFRESH_CLUSTER_MAP_CMD:
 		return PrintStr(W, DREFRESH_CLUSTERMAP)
 
@@ -584,6 +606,57 @@ func printDesc(cmdname string) (int, string) {
 
 }
 
+/*
+	Query parameters are passed on to the query service, except for
+	those that only affect the shell, like the output format.
+*/
+func setQueryParam(name, val string) {
+	if name != OUTPUT_FORMAT_PARAM {
+		n1ql.SetQueryParams(name, val)
+	}
+}
+
+func unsetQueryParam(name string) {
+	if name != OUTPUT_FORMAT_PARAM {
+		n1ql.UnsetQueryParams(name)
+	}
+}
+
+/*
+	The connection settings handed to the driver are also kept
+	for the commands that call the REST API of the query service
+	themselves.
+*/
+var restConfig struct {
+	engine   string
+	user     string
+	pass     string
+	rootFile string
+	certFile string
+	keyFile  string
+}
+
+func SetUsernamePassword(user, pass string) {
+	n1ql.SetUsernamePassword(user, pass)
+	restConfig.user = user
+	restConfig.pass = pass
+}
+
+func SetRootFile(rootFile string) {
+	n1ql.SetRootFile(rootFile)
+	restConfig.rootFile = rootFile
+}
+
+func SetCertFile(certFile string) {
+	n1ql.SetCertFile(certFile)
+	restConfig.certFile = certFile
+}
+
+func SetKeyFile(keyFile string) {
+	n1ql.SetKeyFile(keyFile)
+	restConfig.keyFile = keyFile
+}
+
 func Ping(server string) error {
 	var err error
 	oldDbN1ql := DbN1ql
@@ -594,9 +667,20 @@ func Ping(server string) error {
 	}
 
 	err = DbN1ql.Ping()
+	if err == nil {
+		restConfig.engine = server
+	}
 	return err
 }
 
+/*
+	True if statements can be sent to a query service. The
+	connection string itself is only kept by the main package.
+*/
+func connected() bool {
+	return DbN1ql != nil && !DISCONNECT
+}
+
 /* Find the HOME environment variable. If it isnt set then
    try USERPROFILE for windows. If neither is found then
    the cli cant find the history file to read from.
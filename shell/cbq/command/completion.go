@@ -0,0 +1,440 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package command
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/couchbase/query/parser/n1ql/keywords"
+)
+
+/*
+	Well known query parameters offered by \SET -<TAB> in addition
+	to the ones already set in the session.
+*/
+var _QUERY_PARAMS = []string{
+	"args", "atrcollection", "client_context_id", "controls", "creds",
+	"durability_level", "kvtimeout", "max_parallelism", "memory_quota",
+	"metrics", "numatrs", "output_format", "pipeline_batch", "pipeline_cap",
+	"preserve_expiry", "pretty", "profile", "query_context", "readonly", "scan_cap",
+	"scan_consistency", "scan_wait", "signature", "timeout", "txtimeout",
+	"tximplicit", "use_cbo", "use_fts",
+}
+
+/* Keywords after which a keyspace name is expected */
+var _KEYSPACE_KEYWORDS = map[string]bool{
+	"FROM": true, "JOIN": true, "NEST": true, "INTO": true,
+	"UPDATE": true, "KEYSPACE": true, "INFER": true,
+}
+
+/* Shell commands taking a parameter name as first argument */
+var _PARAM_COMMANDS = map[string]bool{
+	"\\set": true, "\\push": true, "\\pop": true, "\\unset": true, "\\echo": true,
+}
+
+/* The keyspace, and optional alias, of the FROM clause of a statement */
+var _FROM_TERM = regexp.MustCompile("(?i)\\bFROM\\s+((?:`[^`]*`|[^\\s,;()`])+)(?:\\s+(?:AS\\s+)?([A-Za-z_][A-Za-z_0-9]*))?")
+
+/*
+	Completion state: keyspace names fetched on connect and
+	field names inferred per keyspace, loaded on demand in the
+	background. The generation changes on every refresh, so that
+	inferences started before it are dropped.
+*/
+var completion struct {
+	sync.Mutex
+	keyspaces  []string
+	fields     map[string]map[string][]string
+	inferring  map[string]bool
+	generation int
+}
+
+/* INFER must not keep the server busy for long on behalf of TAB */
+const _INFER_TIMEOUT = 2
+
+/*
+	RefreshCompletion reloads the keyspace names used for completion
+	from system:keyspaces. It is called on connection to a query service.
+*/
+func RefreshCompletion() {
+	var keyspaces []string
+
+	rows, _ := completionQuery("SELECT k.`bucket`, k.`scope`, k.name FROM system:keyspaces AS k")
+	for _, r := range rows {
+		var ks struct {
+			Bucket string `json:"bucket"`
+			Scope  string `json:"scope"`
+			Name   string `json:"name"`
+		}
+		if json.Unmarshal(r, &ks) != nil || ks.Name == "" {
+			continue
+		}
+		if ks.Bucket != "" {
+			keyspaces = append(keyspaces, quoteName(ks.Bucket)+"."+quoteName(ks.Scope)+"."+quoteName(ks.Name))
+		} else {
+			keyspaces = append(keyspaces, quoteName(ks.Name))
+		}
+	}
+	sort.Strings(keyspaces)
+
+	completion.Lock()
+	completion.keyspaces = keyspaces
+	completion.fields = nil
+	completion.inferring = nil
+	completion.generation++
+	completion.Unlock()
+}
+
+/*
+	CompleteWord is the word completer for the cbq prompt.
+	It returns the text before the word under the cursor, the
+	candidate words and the text after the cursor.
+*/
+func CompleteWord(line string, pos int) (head string, completions []string, tail string) {
+	runes := []rune(line)
+	if pos > len(runes) {
+		pos = len(runes)
+	}
+	before := string(runes[:pos])
+	tail = string(runes[pos:])
+
+	start := wordStart(before)
+	head = before[:start]
+	word := before[start:]
+
+	fields := strings.Fields(head)
+	first := ""
+	prev := ""
+	if len(fields) > 0 {
+		first = strings.ToLower(fields[0])
+		prev = fields[len(fields)-1]
+	}
+
+	switch {
+
+	// shell commands
+	case strings.HasPrefix(word, "\\") && len(fields) == 0:
+		for _, name := range _SORTED_CMD_LIST {
+			if COMMAND_LIST[name].CommandCompletion() {
+				completions = append(completions, matchCase(name, word))
+			}
+		}
+		return head, filterPrefix(completions, word), tail
+
+	// file names
+	case first == "\\source" || first == "\\redirect" || (first == "\\import" && len(fields) == 1) ||
+		(first == "\\export" && strings.EqualFold(prev, "TO")):
+		matches, _ := filepath.Glob(word + "*")
+		return head, matches, tail
+
+	// session, query and named parameters
+	case _PARAM_COMMANDS[first] && len(fields) == 1:
+		return head, filterPrefix(paramNames(), word), tail
+
+	// named parameters in statements
+	case strings.HasPrefix(word, "$"):
+		completions = make([]string, 0, len(NamedParam))
+		for name, _ := range NamedParam {
+			completions = append(completions, "$"+name)
+		}
+		sort.Strings(completions)
+		return head, filterPrefix(completions, word), tail
+
+	// connection profiles
+	case first == "\\connect" && len(fields) == 1 && strings.HasPrefix(word, "@"):
+		names := profileNames()
+		for i, name := range names {
+			names[i] = "@" + name
+		}
+		return head, filterPrefix(names, word), tail
+
+	// keyspace to import into
+	case first == "\\import" && strings.EqualFold(prev, "INTO"):
+		return head, filterPrefix(keyspaceNames(), word), tail
+
+	case strings.HasPrefix(first, "\\"):
+		return head, nil, tail
+	}
+
+	// keyspace names
+	if _KEYSPACE_KEYWORDS[strings.ToUpper(prev)] {
+		return head, filterPrefix(keyspaceNames(), word), tail
+	}
+
+	// field paths: alias.field.subfield
+	if dot := strings.LastIndex(word, "."); dot > 0 {
+		path := splitPath(word[:dot])
+		keyspace, alias := fromTerm(line)
+		if keyspace != "" && (strings.EqualFold(path[0], alias) || strings.EqualFold(path[0], lastElem(keyspace))) {
+			subfields := keyspaceFields(keyspace)[strings.Join(path[1:], ".")]
+			head += word[:dot+1]
+			return head, filterPrefix(quoteNames(subfields), word[dot+1:]), tail
+		}
+		return head, nil, tail
+	}
+
+	// keywords and top level fields of the keyspace being queried
+	for _, k := range keywords.List() {
+		completions = append(completions, matchCase(k, word))
+	}
+	if keyspace, _ := fromTerm(line); keyspace != "" {
+		completions = append(completions, quoteNames(keyspaceFields(keyspace)[""])...)
+	}
+	return head, filterPrefix(completions, word), tail
+}
+
+/* Parameters that can be set, pushed or displayed from the shell */
+func paramNames() []string {
+	names := make([]string, 0, len(_QUERY_PARAMS)+len(QueryParam)+len(NamedParam)+len(PreDefSV)+len(UserDefSV))
+	seen := make(map[string]bool, cap(names))
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	for _, name := range _QUERY_PARAMS {
+		add("-" + name)
+	}
+	for name, _ := range QueryParam {
+		add("-" + name)
+	}
+	for name, _ := range NamedParam {
+		add("-$" + name)
+	}
+	for name, _ := range PreDefSV {
+		add(name)
+	}
+	for name, _ := range UserDefSV {
+		add("$" + name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func keyspaceNames() []string {
+	completion.Lock()
+	defer completion.Unlock()
+	return completion.keyspaces
+}
+
+/*
+	Field names of a keyspace, as inferred by INFER, indexed by
+	the dotted path of the containing object ("" for the top level).
+	Completion must not wait on INFER, so there are no field names
+	until the inference started by the first request completes.
+*/
+func keyspaceFields(keyspace string) map[string][]string {
+	completion.Lock()
+	defer completion.Unlock()
+	if fields, ok := completion.fields[keyspace]; ok {
+		return fields
+	}
+	if !completion.inferring[keyspace] {
+		if completion.inferring == nil {
+			completion.inferring = make(map[string]bool)
+		}
+		completion.inferring[keyspace] = true
+		go inferFields(keyspace, completion.generation)
+	}
+	return nil
+}
+
+/* Failed inferences are cached too, and only retried after a refresh */
+func inferFields(keyspace string, generation int) {
+	fields := make(map[string][]string)
+	rows, _ := completionQuery("INFER " + keyspace + " WITH {\"sample_size\": 100, \"num_sample_values\": 0, " +
+		"\"infer_timeout\": " + strconv.Itoa(_INFER_TIMEOUT) + "}")
+	for _, r := range rows {
+		var flavors []struct {
+			Properties map[string]interface{} `json:"properties"`
+		}
+		if json.Unmarshal(r, &flavors) != nil {
+			continue
+		}
+		for _, f := range flavors {
+			addFields(fields, "", f.Properties)
+		}
+	}
+	for p, names := range fields {
+		sort.Strings(names)
+		fields[p] = dedup(names)
+	}
+
+	completion.Lock()
+	defer completion.Unlock()
+	if completion.generation != generation {
+		return
+	}
+	if completion.fields == nil {
+		completion.fields = make(map[string]map[string][]string)
+	}
+	completion.fields[keyspace] = fields
+	delete(completion.inferring, keyspace)
+}
+
+func addFields(fields map[string][]string, path string, properties map[string]interface{}) {
+	for name, p := range properties {
+		fields[path] = append(fields[path], name)
+		if prop, ok := p.(map[string]interface{}); ok {
+			if sub, ok := prop["properties"].(map[string]interface{}); ok {
+				subPath := name
+				if path != "" {
+					subPath = path + "." + name
+				}
+				addFields(fields, subPath, sub)
+			}
+		}
+	}
+}
+
+/* Runs a statement for completion purposes, returning the raw result rows */
+func completionQuery(stmt string) ([]json.RawMessage, bool) {
+	if !connected() {
+		return nil, false
+	}
+	rows, err := DbN1ql.QueryRaw(stmt)
+	if rows == nil || err != nil {
+		if rows != nil {
+			rows.Close()
+		}
+		return nil, false
+	}
+	defer rows.Close()
+
+	var response struct {
+		Results []json.RawMessage `json:"results"`
+	}
+	if json.NewDecoder(rows).Decode(&response) != nil {
+		return nil, false
+	}
+	return response.Results, true
+}
+
+/* The first keyspace of the FROM clause and its alias, if any */
+func fromTerm(line string) (string, string) {
+	m := _FROM_TERM.FindStringSubmatch(line)
+	if m == nil {
+		return "", ""
+	}
+	alias := m[2]
+	if _KEYSPACE_KEYWORDS[strings.ToUpper(alias)] || n1qlKeyword(alias) {
+		alias = ""
+	}
+	return m[1], alias
+}
+
+func n1qlKeyword(word string) bool {
+	list := keywords.List()
+	i := sort.SearchStrings(list, strings.ToUpper(word))
+	return i < len(list) && list[i] == strings.ToUpper(word)
+}
+
+/* Start of the word under the cursor, taking backticks into account */
+func wordStart(before string) int {
+	quoted := false
+	start := 0
+	for i, c := range before {
+		switch {
+		case c == '`':
+			if !quoted {
+				start = i
+			}
+			quoted = !quoted
+		case quoted:
+		case unicode.IsSpace(c) || strings.ContainsRune("(),;=<>+*/%[]{}\"'|!", c):
+			start = i + 1
+		}
+	}
+	return start
+}
+
+func splitPath(path string) []string {
+	var elems []string
+	quoted := false
+	elem := []rune{}
+	for _, c := range path {
+		switch {
+		case c == '`':
+			quoted = !quoted
+		case c == '.' && !quoted:
+			elems = append(elems, string(elem))
+			elem = elem[:0]
+		default:
+			elem = append(elem, c)
+		}
+	}
+	return append(elems, string(elem))
+}
+
+func lastElem(keyspace string) string {
+	if i := strings.Index(keyspace, ":"); i >= 0 && !strings.Contains(keyspace[:i], "`") {
+		keyspace = keyspace[i+1:]
+	}
+	elems := splitPath(keyspace)
+	return elems[len(elems)-1]
+}
+
+func quoteName(name string) string {
+	for i, c := range name {
+		if !(c == '_' || unicode.IsLetter(c) || (i > 0 && unicode.IsDigit(c))) {
+			return "`" + name + "`"
+		}
+	}
+	return name
+}
+
+func quoteNames(names []string) []string {
+	rv := make([]string, len(names))
+	for i, name := range names {
+		rv[i] = quoteName(name)
+	}
+	return rv
+}
+
+/* Candidates matching the word typed so far, ignoring case and quotes */
+func filterPrefix(candidates []string, word string) []string {
+	rv := make([]string, 0, len(candidates))
+	lword := strings.ToLower(strings.TrimPrefix(word, "`"))
+	for _, c := range candidates {
+		if strings.HasPrefix(strings.ToLower(strings.TrimPrefix(c, "`")), lword) {
+			rv = append(rv, c)
+		}
+	}
+	return rv
+}
+
+/* Keywords and commands follow the case of the word being completed */
+func matchCase(candidate, word string) string {
+	for _, c := range word {
+		if unicode.IsLower(c) {
+			return strings.ToLower(candidate)
+		} else if unicode.IsUpper(c) {
+			return strings.ToUpper(candidate)
+		}
+	}
+	return candidate
+}
+
+func dedup(sorted []string) []string {
+	rv := sorted[:0]
+	for i, s := range sorted {
+		if i == 0 || s != sorted[i-1] {
+			rv = append(rv, s)
+		}
+	}
+	return rv
+}
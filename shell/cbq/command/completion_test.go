@@ -0,0 +1,124 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package command
+
+import (
+	"testing"
+	"time"
+)
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+func TestCompleteWord(t *testing.T) {
+	line := "\\SE"
+	head, completions, tail := CompleteWord(line, len(line))
+	if head != "" || tail != "" || !contains(completions, "\\SET") || contains(completions, "\\ECHO") {
+		t.Errorf("Unexpected shell command completion for %s: %q %v %q", line, head, completions, tail)
+	}
+
+	errCode, errStr := PushValue_Helper(false, NamedParam, "rate", "1")
+	if errCode != 0 {
+		t.Fatalf("%s", HandleError(errCode, errStr))
+	}
+	defer PopValue_Helper(true, NamedParam, "rate")
+
+	line = "\\set -"
+	head, completions, _ = CompleteWord(line, len(line))
+	if head != "\\set " || !contains(completions, "-timeout") || !contains(completions, "-$rate") || contains(completions, "histfile") {
+		t.Errorf("Unexpected parameter completion for %s: %q %v", line, head, completions)
+	}
+
+	line = "select * from b where a = $r"
+	_, completions, _ = CompleteWord(line, len(line))
+	if len(completions) != 1 || completions[0] != "$rate" {
+		t.Errorf("Unexpected named parameter completion for %s: %v", line, completions)
+	}
+
+	line = "sel * from b"
+	head, completions, tail = CompleteWord(line, 3)
+	if head != "" || tail != " * from b" || !contains(completions, "select") {
+		t.Errorf("Unexpected keyword completion for %s: %q %v %q", line, head, completions, tail)
+	}
+
+	completion.Lock()
+	completion.keyspaces = []string{"`beer-sample`", "default", "travel.inventory.airline"}
+	completion.Unlock()
+	defer RefreshCompletion()
+
+	line = "SELECT * FROM tr"
+	head, completions, _ = CompleteWord(line, len(line))
+	if head != "SELECT * FROM " || len(completions) != 1 || completions[0] != "travel.inventory.airline" {
+		t.Errorf("Unexpected keyspace completion for %s: %q %v", line, head, completions)
+	}
+
+	line = "SELECT * FROM `be"
+	head, completions, _ = CompleteWord(line, len(line))
+	if head != "SELECT * FROM " || len(completions) != 1 || completions[0] != "`beer-sample`" {
+		t.Errorf("Unexpected quoted keyspace completion for %s: %q %v", line, head, completions)
+	}
+}
+
+func TestFieldCompletion(t *testing.T) {
+	RefreshCompletion()
+	defer RefreshCompletion()
+
+	completion.Lock()
+	completion.fields = map[string]map[string][]string{
+		"b": map[string][]string{"": []string{"address", "name"}, "address": []string{"city", "zip"}},
+	}
+	completion.Unlock()
+
+	line := "SELECT * FROM b WHERE na"
+	_, completions, _ := CompleteWord(line, len(line))
+	if !contains(completions, "name") {
+		t.Errorf("Unexpected field completion for %s: %v", line, completions)
+	}
+	line = "SELECT * FROM b AS x WHERE x.address.c"
+	head, completions, _ := CompleteWord(line, len(line))
+	if head != "SELECT * FROM b AS x WHERE x.address." || len(completions) != 1 || completions[0] != "city" {
+		t.Errorf("Unexpected field path completion for %s: %q %v", line, head, completions)
+	}
+
+	// keyspaces not inferred yet have no fields, until the inference completes
+	if fields := keyspaceFields("c"); fields != nil {
+		t.Errorf("Expected no fields before inference, got %v", fields)
+	}
+	for start := time.Now(); ; time.Sleep(time.Millisecond) {
+		completion.Lock()
+		_, done := completion.fields["c"]
+		inferring := completion.inferring["c"]
+		completion.Unlock()
+		if done && !inferring {
+			break
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("Inference did not complete")
+		}
+	}
+
+	// inferences started before a refresh are dropped
+	completion.Lock()
+	generation := completion.generation
+	completion.Unlock()
+	RefreshCompletion()
+	inferFields("d", generation)
+	completion.Lock()
+	_, found := completion.fields["d"]
+	completion.Unlock()
+	if found {
+		t.Errorf("Expected a stale inference to be dropped")
+	}
+}
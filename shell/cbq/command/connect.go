@@ -12,7 +12,6 @@ import (
 	"io"
 	"strings"
 
-	"github.com/couchbase/godbc/n1ql"
 	"github.com/couchbase/query/errors"
 )
 
@@ -26,7 +25,7 @@ func (this *Connect) Name() string {
 }
 
 func (this *Connect) CommandCompletion() bool {
-	return false
+	return true
 }
 
 func (this *Connect) MinArgs() int {
@@ -41,7 +40,9 @@ func (this *Connect) ExecCommand(args []string) (int, string) {
 	/* Command to connect to the input query service or cluster
 	   endpoint. Use the Server flag and set it to the value
 	   of service_url. If the command contains no input argument
-	   or more than 1 argument then throw an error.
+	   or more than 1 argument then throw an error. An argument
+	   of the form @name connects using the named profile of the
+	   .cbqrc file.
 	*/
 	if len(args) > this.MaxArgs() {
 		return errors.TOO_MANY_ARGS, ""
@@ -51,6 +52,25 @@ func (this *Connect) ExecCommand(args []string) (int, string) {
 	} else {
 		SERVICE_URL = args[0]
 
+		// \CONNECT @profile connects with the settings of a profile
+		var profile *Profile
+		if strings.HasPrefix(SERVICE_URL, "@") {
+			var errCode int
+			var errStr string
+
+			profile, errCode, errStr = LoadProfile(SERVICE_URL[1:])
+			if errCode != 0 {
+				return errCode, errStr
+			} else if profile == nil {
+				return errors.PROFILE, "no default profile"
+			}
+			errCode, errStr = profile.SetConnection()
+			if errCode != 0 {
+				return errCode, errStr
+			}
+			SERVICE_URL = profile.Engine
+		}
+
 		// Support couchbase couchbases when using the connect command as well.
 		// call command.ParseURL()
 		var errCode int
@@ -74,7 +94,7 @@ func (this *Connect) ExecCommand(args []string) (int, string) {
 			}
 		}
 		if pURL.Username != "" && pURL.Password != "" {
-			n1ql.SetUsernamePassword(pURL.Username, pURL.Password)
+			SetUsernamePassword(pURL.Username, pURL.Password)
 		}
 
 		// Do the check for different values here as well.
@@ -82,7 +102,15 @@ func (this *Connect) ExecCommand(args []string) (int, string) {
 		if err != nil {
 			return errors.CONNECTION_REFUSED, err.Error()
 		}
+		if profile != nil {
+			errCode, errStr = profile.SetParams()
+			if errCode != 0 {
+				return errCode, errStr
+			}
+			profileStartup = profile.Startup
+		}
 		io.WriteString(W, NewMessage(STARTUP, SERVICE_URL)+EXITMSG)
+		go RefreshCompletion()
 	}
 	return 0, ""
 }
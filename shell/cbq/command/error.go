@@ -103,6 +103,20 @@ func HandleError(err int, msg string) errors.Error {
 		return errors.NewShellErrorNoSuchAlias(msg)
 	case errors.BATCH_MODE:
 		return errors.NewShellErrorBatchMode("")
+	case errors.OUTPUT_FORMAT:
+		return errors.NewShellErrorOutputFormat(msg)
+	case errors.PLAN_MODE:
+		return errors.NewShellErrorPlanMode(msg)
+	case errors.BULK_SYNTAX:
+		return errors.NewShellErrorBulkSyntax(msg)
+	case errors.BENCH_ARGS:
+		return errors.NewShellErrorBenchArgs(msg)
+	case errors.PROFILE:
+		return errors.NewShellErrorProfile(msg)
+	case errors.WATCH_ARGS:
+		return errors.NewShellErrorWatchArgs(msg)
+	case errors.FORMAT_STMT:
+		return errors.NewShellErrorFormatStmt(msg)
 
 	//Generic Errors
 	case errors.OPERATION_TIMEOUT:
@@ -0,0 +1,208 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package command
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/couchbase/query/errors"
+)
+
+/* Progress is reported every so many rows */
+const _EXPORT_PROGRESS_ROWS = 1000
+
+var _EXPORT_CLAUSES = map[string]bool{
+	"FORMAT": true,
+}
+
+/* Export Command */
+type Export struct {
+	ShellCommand
+}
+
+func (this *Export) Name() string {
+	return "EXPORT"
+}
+
+func (this *Export) CommandCompletion() bool {
+	return true
+}
+
+func (this *Export) MinArgs() int {
+	return 3
+}
+
+func (this *Export) MaxArgs() int {
+	return MAX_ARGS
+}
+
+func (this *Export) ExecCommand(args []string) (int, string) {
+	/* Command to write the results of a statement to a file.
+	   Results are written as they are received.
+	*/
+	if len(args) < this.MinArgs() {
+		return errors.TOO_FEW_ARGS, ""
+	}
+
+	// The statement is quoted, and may contain quotes itself
+	line := strings.Join(args, " ")
+	end := strings.LastIndex(strings.ToUpper(line), "\" TO ")
+	if !strings.HasPrefix(line, "\"") || end < 1 {
+		return errors.BULK_SYNTAX, HEXPORT
+	}
+	stmt := line[1:end]
+	rest := strings.Fields(line[end+len("\" TO "):])
+	if len(rest) == 0 {
+		return errors.BULK_SYNTAX, HEXPORT
+	}
+	file := rest[0]
+	clauses, ok := bulkClauses(rest[1:], _EXPORT_CLAUSES)
+	if !ok || clauses[""] != "" {
+		return errors.BULK_SYNTAX, HEXPORT
+	}
+	format, ok := bulkFormat(file, clauses["FORMAT"])
+	if !ok {
+		return errors.BULK_SYNTAX, HEXPORT
+	}
+
+	if !connected() {
+		return errors.NO_CONNECTION, ""
+	}
+
+	start := time.Now()
+	rows, err := DbN1ql.QueryRaw(stmt)
+	if rows == nil {
+		if err != nil {
+			return errors.DRIVER_QUERY, err.Error()
+		}
+		return 0, ""
+	}
+	defer rows.Close()
+
+	f, ferr := os.Create(file)
+	if ferr != nil {
+		return errors.FILE_OPEN, ferr.Error()
+	}
+	defer f.Close()
+
+	count, msgs, err_code, err_str := exportResults(rows, newDocWriter(bufio.NewWriter(f), format))
+	if showProgress() {
+		io.WriteString(W, "\n")
+	}
+	if err_code != 0 {
+		return err_code, err_str
+	}
+	for _, m := range msgs {
+		io.WriteString(W, m+"\n")
+	}
+	fmt.Fprintf(W, "Exported %d rows to %s in %v.\n", count, file, time.Since(start))
+
+	if len(msgs) == 0 && err != nil {
+		return errors.DRIVER_QUERY, err.Error()
+	}
+	return 0, ""
+}
+
+/*
+	Streams the results of a query service response to the writer,
+	one at a time, returning the number of results and the errors
+	and warnings of the response.
+*/
+func exportResults(r io.Reader, writer docWriter) (int, []string, int, string) {
+	count := 0
+	var msgs []string
+
+	dec := json.NewDecoder(r)
+	t, err := dec.Token()
+	if err != nil {
+		return 0, nil, errors.JSON_UNMARSHAL, err.Error()
+	} else if t != json.Delim('{') {
+		return 0, nil, errors.JSON_UNMARSHAL, fmt.Sprintf("unexpected %v", t)
+	}
+
+	for dec.More() {
+		t, err = dec.Token()
+		if err != nil {
+			return count, msgs, errors.JSON_UNMARSHAL, err.Error()
+		}
+
+		switch t {
+		case "signature":
+			var signature json.RawMessage
+			err = dec.Decode(&signature)
+			writer.signature(signature)
+
+		case "results":
+			_, err = dec.Token()
+			for err == nil && dec.More() {
+				var result json.RawMessage
+				err = dec.Decode(&result)
+				if err != nil {
+					break
+				}
+				err = writer.write(result)
+				if err != nil {
+					return count, msgs, errors.WRITE_FILE, err.Error()
+				}
+				count++
+				if count%_EXPORT_PROGRESS_ROWS == 0 {
+					progress("Exported %d rows", count)
+				}
+			}
+			if err == nil {
+				_, err = dec.Token()
+			}
+
+		case "errors", "warnings":
+			label := "ERROR"
+			if t == "warnings" {
+				label = "WARNING"
+			}
+			var list []queryMessage
+			err = dec.Decode(&list)
+			for _, m := range list {
+				msgs = append(msgs, fmt.Sprintf("%s %v : %v", label, m.Code, m.Msg))
+			}
+
+		default:
+			var skip json.RawMessage
+			err = dec.Decode(&skip)
+		}
+		if err != nil {
+			return count, msgs, errors.JSON_UNMARSHAL, err.Error()
+		}
+	}
+
+	err = writer.close()
+	if err != nil {
+		return count, msgs, errors.WRITE_FILE, err.Error()
+	}
+	return count, msgs, 0, ""
+}
+
+func (this *Export) PrintHelp(desc bool) (int, string) {
+	_, werr := io.WriteString(W, HEXPORT)
+	if desc {
+		err_code, err_str := printDesc(this.Name())
+		if err_code != 0 {
+			return err_code, err_str
+		}
+	}
+	_, werr = io.WriteString(W, "\n")
+	if werr != nil {
+		return errors.WRITER_OUTPUT, werr.Error()
+	}
+	return 0, ""
+}
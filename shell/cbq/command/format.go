@@ -0,0 +1,190 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package command
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/value"
+)
+
+/* Format Command */
+type Format struct {
+	ShellCommand
+}
+
+func (this *Format) Name() string {
+	return "FORMAT"
+}
+
+func (this *Format) CommandCompletion() bool {
+	return true
+}
+
+func (this *Format) MinArgs() int {
+	return ONE_ARG
+}
+
+func (this *Format) MaxArgs() int {
+	return MAX_ARGS
+}
+
+func (this *Format) ExecCommand(args []string) (int, string) {
+	/* Command to display a statement in canonical form, followed
+	   by lint warnings. The statement is formatted and checked by
+	   the query service, which knows keyspace sizes and indexes,
+	   but it is not executed.
+	*/
+	if len(args) < this.MinArgs() {
+		return errors.TOO_FEW_ARGS, ""
+	}
+
+	if !connected() {
+		return errors.NO_CONNECTION, ""
+	}
+
+	text, err_code, err_str := FormatStatement(strings.Join(args, " "))
+	if err_code != 0 {
+		return err_code, err_str
+	}
+	_, werr := io.WriteString(W, text)
+	if werr != nil {
+		return errors.WRITER_OUTPUT, werr.Error()
+	}
+	return 0, ""
+}
+
+/* The formatted statement, followed by one line per warning */
+func FormatStatement(stmt string) (string, int, string) {
+	params := url.Values{}
+	params.Set("statement", strings.TrimSuffix(strings.TrimSpace(stmt), ";"))
+	if qc, ok := QueryParam["query_context"]; ok {
+		v, err_code, _ := qc.Top()
+		if err_code == 0 {
+			if v.Type() == value.STRING {
+				params.Set("query_context", v.Actual().(string))
+			} else {
+				params.Set("query_context", ValToStr(v))
+			}
+		}
+	}
+
+	// engines can be query nodes, or cluster managers that proxy the query service
+	engine := strings.TrimSuffix(restConfig.engine, "/")
+	resp, err_code, err_str := formatRequest(engine+"/query/format", params)
+	if err_code == 0 && resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		resp, err_code, err_str = formatRequest(engine+"/_p/query/query/format", params)
+	}
+	if err_code != 0 {
+		return "", err_code, err_str
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", errors.FORMAT_STMT, err.Error()
+	}
+	if resp.StatusCode != http.StatusOK {
+		var result struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(body, &result) != nil || result.Message == "" {
+			return "", errors.FORMAT_STMT, resp.Status
+		}
+		return "", errors.FORMAT_STMT, result.Message
+	}
+
+	var result struct {
+		Statement string `json:"statement"`
+		Warnings  []struct {
+			Rule    string `json:"rule"`
+			Message string `json:"message"`
+		} `json:"warnings"`
+	}
+	if err = json.Unmarshal(body, &result); err != nil {
+		return "", errors.FORMAT_STMT, err.Error()
+	}
+
+	var b strings.Builder
+	b.WriteString(result.Statement)
+	b.WriteString(";\n")
+	for _, w := range result.Warnings {
+		fmt.Fprintf(&b, "WARNING %v : %v\n", w.Rule, w.Message)
+	}
+	return b.String(), 0, ""
+}
+
+func formatRequest(endpoint string, params url.Values) (*http.Response, int, string) {
+	req, err := http.NewRequest("POST", endpoint, strings.NewReader(params.Encode()))
+	if err != nil {
+		return nil, errors.FORMAT_STMT, err.Error()
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if restConfig.user != "" {
+		req.SetBasicAuth(restConfig.user, restConfig.pass)
+	}
+
+	client, err_code, err_str := restClient()
+	if err_code != 0 {
+		return nil, err_code, err_str
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errors.CONNECTION_REFUSED, err.Error()
+	}
+	return resp, 0, ""
+}
+
+/* A client with the TLS settings of the connection */
+func restClient() (*http.Client, int, string) {
+	config := &tls.Config{InsecureSkipVerify: SKIPVERIFY}
+	if restConfig.rootFile != "" {
+		pem, err := ioutil.ReadFile(restConfig.rootFile)
+		if err != nil {
+			return nil, errors.FILE_OPEN, err.Error()
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.FILE_OPEN, "no certificates in " + restConfig.rootFile
+		}
+	}
+	if restConfig.certFile != "" && restConfig.keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(restConfig.certFile, restConfig.keyFile)
+		if err != nil {
+			return nil, errors.FILE_OPEN, err.Error()
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: config}}, 0, ""
+}
+
+func (this *Format) PrintHelp(desc bool) (int, string) {
+	_, werr := io.WriteString(W, HFORMAT)
+	if desc {
+		err_code, err_str := printDesc(this.Name())
+		if err_code != 0 {
+			return err_code, err_str
+		}
+	}
+	_, werr = io.WriteString(W, "\n")
+	if werr != nil {
+		return errors.WRITER_OUTPUT, werr.Error()
+	}
+	return 0, ""
+}
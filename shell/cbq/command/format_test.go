@@ -0,0 +1,58 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package command
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/couchbase/query/errors"
+)
+
+func TestFormatStatement(t *testing.T) {
+	var statement, user string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/_p/query/query/format", func(w http.ResponseWriter, req *http.Request) {
+		statement = req.FormValue("statement")
+		user, _, _ = req.BasicAuth()
+		if statement == "selekt 1" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"code":3000,"message":"syntax error - at selekt"}`))
+			return
+		}
+		w.Write([]byte(`{"statement":"SELECT *\nFROM b\nORDER BY b.name",` +
+			`"warnings":[{"rule":"order_by_without_limit","message":"ORDER BY without LIMIT"}]}`))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	saved := restConfig
+	defer func() { restConfig = saved }()
+	restConfig.engine = srv.URL + "/"
+	restConfig.user = "alice"
+
+	// cluster managers proxy the query service
+	text, errCode, errStr := FormatStatement("select * from b order by b.name;")
+	if errCode != 0 {
+		t.Fatalf("Unexpected error %v %v", errCode, errStr)
+	}
+	if statement != "select * from b order by b.name" || user != "alice" {
+		t.Errorf("Unexpected request for %q by %q", statement, user)
+	}
+	expected := "SELECT *\nFROM b\nORDER BY b.name;\nWARNING order_by_without_limit : ORDER BY without LIMIT\n"
+	if text != expected {
+		t.Errorf("Expected %q, got %q", expected, text)
+	}
+
+	_, errCode, errStr = FormatStatement("selekt 1")
+	if errCode != errors.FORMAT_STMT || errStr != "syntax error - at selekt" {
+		t.Errorf("Expected the service error, got %v %v", errCode, errStr)
+	}
+}
@@ -0,0 +1,232 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/couchbase/query/errors"
+)
+
+const (
+	_IMPORT_BATCH_SIZE  = 500
+	_IMPORT_PARALLELISM = 4
+)
+
+var _IMPORT_CLAUSES = map[string]bool{
+	"INTO": true, "KEY": true, "FORMAT": true, "BATCH": true, "PARALLEL": true,
+}
+
+/* Import Command */
+type Import struct {
+	ShellCommand
+}
+
+func (this *Import) Name() string {
+	return "IMPORT"
+}
+
+func (this *Import) CommandCompletion() bool {
+	return true
+}
+
+func (this *Import) MinArgs() int {
+	return 3
+}
+
+func (this *Import) MaxArgs() int {
+	return MAX_ARGS
+}
+
+type importBatch struct {
+	number int
+	first  int
+	docs   []json.RawMessage
+}
+
+func (this *Import) ExecCommand(args []string) (int, string) {
+	/* Command to load documents from a file into a keyspace,
+	   using batched UPSERT statements run in parallel.
+	*/
+	if len(args) < this.MinArgs() {
+		return errors.TOO_FEW_ARGS, ""
+	}
+
+	file := args[0]
+	clauses, ok := bulkClauses(args[1:], _IMPORT_CLAUSES)
+	if !ok || clauses[""] != "" || clauses["INTO"] == "" || strings.Contains(clauses["INTO"], " ") {
+		return errors.BULK_SYNTAX, HIMPORT
+	}
+	keyspace := clauses["INTO"]
+	key, ok := clauses["KEY"]
+	if ok && key == "" {
+		return errors.BULK_SYNTAX, HIMPORT
+	} else if !ok {
+		key = "UUID()"
+	}
+	format, ok := bulkFormat(file, clauses["FORMAT"])
+	if !ok {
+		return errors.BULK_SYNTAX, HIMPORT
+	}
+	batchSize, ok := bulkNumber(clauses["BATCH"], _IMPORT_BATCH_SIZE)
+	if !ok {
+		return errors.BULK_SYNTAX, HIMPORT
+	}
+	parallelism, ok := bulkNumber(clauses["PARALLEL"], _IMPORT_PARALLELISM)
+	if !ok {
+		return errors.BULK_SYNTAX, HIMPORT
+	}
+
+	if !connected() {
+		return errors.NO_CONNECTION, ""
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return errors.FILE_OPEN, err.Error()
+	}
+	defer f.Close()
+
+	reader, err := newDocReader(f, format)
+	if err != nil {
+		return errors.READ_FILE, err.Error()
+	}
+
+	// The documents of a batch are the keyspace of an UPSERT ... SELECT
+	// so that the key expression can refer to their fields
+	prefix := "UPSERT INTO " + keyspace + " (KEY " + key + ") SELECT RAW d FROM ["
+	suffix := "] AS d"
+
+	var lock sync.Mutex
+	var mutations int64
+	failed := 0
+	start := time.Now()
+
+	batches := make(chan *importBatch, parallelism)
+	var wg sync.WaitGroup
+	for i := 0; i < parallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				n, msgs := execBatch(prefix, batch.docs, suffix)
+
+				lock.Lock()
+				mutations += n
+				if len(msgs) > 0 {
+					failed++
+					for _, m := range msgs {
+						progress("")
+						fmt.Fprintf(W, "Batch %d (rows %d-%d) : %s\n", batch.number, batch.first,
+							batch.first+len(batch.docs)-1, m)
+					}
+				}
+				progress("Imported %d rows", mutations)
+				lock.Unlock()
+			}
+		}()
+	}
+
+	rows := 0
+	number := 0
+	batch := &importBatch{number: 1, first: 1}
+	for {
+		var doc json.RawMessage
+		doc, err = reader.next()
+		if err != nil {
+			break
+		}
+		rows++
+		batch.docs = append(batch.docs, doc)
+		if len(batch.docs) == batchSize {
+			number++
+			batches <- batch
+			batch = &importBatch{number: number + 1, first: rows + 1}
+		}
+	}
+	if len(batch.docs) > 0 {
+		number++
+		batches <- batch
+	}
+	close(batches)
+	wg.Wait()
+
+	if showProgress() {
+		io.WriteString(W, "\n")
+	}
+	fmt.Fprintf(W, "Imported %d of %d rows into %s in %v. %d of %d batches failed.\n",
+		mutations, rows, keyspace, time.Since(start), failed, number)
+
+	if err != io.EOF {
+		return errors.READ_FILE, fmt.Sprintf("%v after row %d", err, rows)
+	}
+	return 0, ""
+}
+
+/*
+	Runs the statement for one batch, returning the number of
+	documents written and the errors reported for the batch.
+*/
+func execBatch(prefix string, docs []json.RawMessage, suffix string) (int64, []string) {
+	var b bytes.Buffer
+	b.WriteString(prefix)
+	for i, doc := range docs {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		b.Write(doc)
+	}
+	b.WriteString(suffix)
+
+	rows, err := DbN1ql.QueryRaw(b.String())
+	if rows == nil {
+		if err == nil {
+			return 0, nil
+		}
+		return 0, []string{err.Error()}
+	}
+	defer rows.Close()
+
+	var response queryResponse
+	derr := json.NewDecoder(rows).Decode(&response)
+	if derr != nil {
+		return 0, []string{derr.Error()}
+	}
+
+	msgs := make([]string, 0, len(response.Errors))
+	for _, e := range response.Errors {
+		msgs = append(msgs, fmt.Sprintf("ERROR %v : %v", e.Code, e.Msg))
+	}
+	if len(msgs) == 0 && err != nil {
+		msgs = append(msgs, err.Error())
+	}
+	return response.Metrics.MutationCount, msgs
+}
+
+func (this *Import) PrintHelp(desc bool) (int, string) {
+	_, werr := io.WriteString(W, HIMPORT)
+	if desc {
+		err_code, err_str := printDesc(this.Name())
+		if err_code != 0 {
+			return err_code, err_str
+		}
+	}
+	_, werr = io.WriteString(W, "\n")
+	if werr != nil {
+		return errors.WRITER_OUTPUT, werr.Error()
+	}
+	return 0, ""
+}
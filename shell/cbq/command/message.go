@@ -31,6 +31,8 @@ const (
 	UVIMODESL   = " Single-line vi style input mode \n\t Usage: -vi"
 	UVIMODEML   = " Multi-line vi style input mode \n\t Usage: -vim"
 	USCRIPT     = " Single command mode. Execute input command and exit shell. \n\t For example : -script \"select * from system:keyspaces\""
+	UBENCH      = " Benchmark mode. Run the \\BENCH command with the given arguments and exit shell. \n\t For example : -bench \"-n 1000 -c 4 select * from system:keyspaces\""
+	UPROFILE    = " Connection profile of the ~/.cbqrc file. Options given on the command line override the profile. \n\t For example : -profile prod"
 	UPRETTY     = " Pretty print the output."
 	UEXIT       = " Exit shell after first error encountered."
 	UINPUT      = " File to load commands from. \n\t For example : -file temp.txt"
@@ -94,7 +96,7 @@ const (
 	HELPMSG             = "\nHelp information for all shell commands.\n\n"
 	HALIAS              = "\\ALIAS [ name value ]\n"
 	HUNALIAS            = "\\UNALIAS name ...\n"
-	HCONNECT            = "\\CONNECT url\n"
+	HCONNECT            = "\\CONNECT url | @profile\n"
 	HDISCONNECT         = "\\DISCONNECT\n"
 	HCOPYRIGHT          = "\\COPYRIGHT\n"
 	HVERSION            = "\\VERSION\n"
@@ -106,6 +108,12 @@ const (
 	HUNSET              = "\\UNSET parameter\n"
 	HPOP                = "\\POP [ parameter ]\n"
 	HREDIRECT           = "\\REDIRECT OFF | filename \n"
+	HPLAN               = "\\PLAN [ ON | OFF ]\n"
+	HIMPORT             = "\\IMPORT filename INTO keyspace [ KEY expr ] [ FORMAT json | jsonl | csv ] [ BATCH size ] [ PARALLEL n ]\n"
+	HEXPORT             = "\\EXPORT \"statement\" TO filename [ FORMAT json | jsonl | csv ]\n"
+	HBENCH              = "\\BENCH [ -n runs ] [ -c concurrency ] [ -warmup runs ] [ -prepared ] [ -params filename ] statement\n"
+	HWATCH              = "\\WATCH [ -interval duration ] [ -diff ] statement\n"
+	HFORMAT             = "\\FORMAT statement\n"
 	HSOURCE             = "\\SOURCE filename\n"
 	HREFRESH_CLUSTERMAP = "\\REFRESH_CLUSTER_MAP\n"
 
@@ -115,11 +123,12 @@ const (
 		"\tExample : \n\t        \\ALIAS serverversion \"select version(), min_version()\"" +
 		" ;\n\t        \\ALIAS \"\\SET -max-parallelism 8\";\n"
 
-	DCONNECT = "Connect to the query service or cluster endpoint URL.\n" +
+	DCONNECT = "Connect to the query service or cluster endpoint URL, or with a profile of the ~/.cbqrc file.\n" +
 		"Default : http://localhost:8091\n" +
 		"\tExample : \n\t        \\CONNECT couchbase://172.6.23.2 ; \n\t        " +
 		"\\CONNECT http://172.6.23.2:8091 ;\n\t        " +
-		"\\CONNECT https://my.secure.node.com:18093 ;\n"
+		"\\CONNECT https://my.secure.node.com:18093 ;\n\t        " +
+		"\\CONNECT @prod ;\n"
 
 	DCOPYRIGHT = "Print Couchbase copyright information.\n" +
 		"\tExample : \n\t        \\COPYRIGHT;\n"
@@ -167,6 +176,34 @@ const (
 		"To return to STDOUT, execute \\REDIRECT OFF .\n" +
 		"\tExample : \n\t\t \\REDIRECT temp1.txt ;\n\t\t select * from `beer-sample`;\n\t\t \\REDIRECT OFF;"
 
+	DPLAN = "Display EXPLAIN plans and profile timings as operator trees (\\PLAN ON). " +
+		"The most expensive operators are highlighted. To return to JSON output, execute \\PLAN OFF .\n" +
+		"\tExample : \n\t\t \\PLAN ON ;\n\t\t \\SET -profile timings;\n\t\t select * from `beer-sample`;"
+
+	DIMPORT = "Load documents from a JSON, JSON lines or CSV file into a keyspace, using batched UPSERT statements " +
+		"run in parallel. The KEY expression is evaluated on each document and defaults to UUID(). " +
+		"The format defaults to the one of the file extension.\n" +
+		"\tExample : \n\t\t \\IMPORT beers.json INTO `beer-sample` KEY name || '-' || brewery_id BATCH 1000;"
+
+	DEXPORT = "Write the results of a statement to a file, as they are received. " +
+		"The format defaults to the one of the file extension.\n" +
+		"\tExample : \n\t\t \\EXPORT \"select name, abv from `beer-sample`\" TO beers.csv;"
+
+	DBENCH = "Run a statement repeatedly and display its throughput, and the percentiles of its latency " +
+		"and of the elapsed and execution times reported by the server. With -params, the statement is prepared " +
+		"and each execution uses named parameters picked at random from a JSON, JSON lines or CSV file.\n" +
+		"\tExample : \n\t\t \\BENCH -n 1000 -c 8 -warmup 10 -params ids.csv select * from `beer-sample` where abv > $abv;"
+
+	DWATCH = "Run a statement or shell command every interval (2s by default) and redraw its output, " +
+		"until interrupted with Ctrl-C. With -diff, the characters that changed since the previous run are highlighted.\n" +
+		"\tExample : \n\t\t \\WATCH -interval 5s -diff select requestId, elapsedTime from system:active_requests;"
+
+	DFORMAT = "Display a statement in canonical form, one clause, projection, join and condition per line, " +
+		"followed by warnings about ORDER BY without LIMIT, cartesian joins and deprecated functions. " +
+		"The statement is formatted and checked by the query service, which also warns about keyspace sizes and indexes, " +
+		"but it is not executed.\n" +
+		"\tExample : \n\t\t \\FORMAT select * from `beer-sample` b, `travel-sample` t order by b.name;"
+
 	DDEFAULT            = "Fix : Does not exist.\n"
 	DREFRESH_CLUSTERMAP = "Refresh the list of query APIs to reflect input service url as cluster. " +
 		"\tExample : \n\t\t \\REFRESH_CLUSTER_MAP;"
@@ -0,0 +1,397 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package command
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/value"
+	"github.com/mattn/go-runewidth"
+)
+
+/* The query parameter used to choose how results are displayed */
+const OUTPUT_FORMAT_PARAM = "output_format"
+
+const (
+	JSON_FORMAT     = "json"
+	TABLE_FORMAT    = "table"
+	CSV_FORMAT      = "csv"
+	TSV_FORMAT      = "tsv"
+	VERTICAL_FORMAT = "vertical"
+)
+
+var _OUTPUT_FORMATS = map[string]bool{
+	JSON_FORMAT:     true,
+	TABLE_FORMAT:    true,
+	CSV_FORMAT:      true,
+	TSV_FORMAT:      true,
+	VERTICAL_FORMAT: true,
+}
+
+/* Values wider than this are truncated in table output */
+const _MAX_COLUMN_WIDTH = 40
+
+/* Column name used for results that are not objects */
+const _VALUE_COLUMN = "$1"
+
+/*
+	OutputFormat returns the current output format, as set
+	by \SET -output_format. The default is json.
+*/
+func OutputFormat() string {
+	st, ok := QueryParam[OUTPUT_FORMAT_PARAM]
+	if !ok {
+		return JSON_FORMAT
+	}
+	v, err_code, _ := st.Top()
+	if err_code != 0 || v.Type() != value.STRING {
+		return JSON_FORMAT
+	}
+	return strings.ToLower(v.Actual().(string))
+}
+
+type queryResponse struct {
+	Signature json.RawMessage   `json:"signature"`
+	Results   []json.RawMessage `json:"results"`
+	Errors    []queryMessage    `json:"errors"`
+	Warnings  []queryMessage    `json:"warnings"`
+	Status    string            `json:"status"`
+	Metrics   struct {
+		ElapsedTime   string `json:"elapsedTime"`
+		MutationCount int64  `json:"mutationCount"`
+	} `json:"metrics"`
+}
+
+type queryMessage struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+}
+
+/*
+	WriteResults renders a query service response in the given
+	output format. Long output to a terminal goes through a pager.
+*/
+func WriteResults(r io.Reader, w io.Writer, format string) (int, string) {
+	var response queryResponse
+
+	err := json.NewDecoder(r).Decode(&response)
+	if err != nil {
+		return errors.JSON_UNMARSHAL, err.Error()
+	}
+
+	columns, rows := tabulate(response.Signature, response.Results)
+
+	var b bytes.Buffer
+	switch format {
+	case TABLE_FORMAT:
+		writeTable(&b, columns, rows)
+		writeSummary(&b, &response)
+	case VERTICAL_FORMAT:
+		writeVertical(&b, columns, rows)
+		writeSummary(&b, &response)
+	case CSV_FORMAT:
+		err = writeDelimited(&b, columns, rows, ',')
+	case TSV_FORMAT:
+		err = writeDelimited(&b, columns, rows, '\t')
+	default:
+		return errors.OUTPUT_FORMAT, format
+	}
+	if err != nil {
+		return errors.WRITER_OUTPUT, err.Error()
+	}
+
+	for _, e := range response.Errors {
+		fmt.Fprintf(&b, "ERROR %v : %v\n", e.Code, e.Msg)
+	}
+	for _, e := range response.Warnings {
+		fmt.Fprintf(&b, "WARNING %v : %v\n", e.Code, e.Msg)
+	}
+
+	return page(w, b.Bytes())
+}
+
+/*
+	Columns come from the projection, in order, followed by any other
+	field found in the results. Results that are not objects, like
+	those of SELECT RAW, are shown as a single column.
+*/
+func tabulate(signature json.RawMessage, results []json.RawMessage) ([]string, [][]json.RawMessage) {
+	var columns []string
+	index := make(map[string]int)
+	addColumn := func(name string) {
+		if _, ok := index[name]; !ok {
+			index[name] = len(columns)
+			columns = append(columns, name)
+		}
+	}
+
+	if names, _, ok := objectFields(signature); ok {
+		for _, name := range names {
+			if name != "*" {
+				addColumn(name)
+			}
+		}
+	}
+
+	objects := make([]map[string]json.RawMessage, len(results))
+	for i, r := range results {
+		names, fields, ok := objectFields(r)
+		if !ok {
+			rows := make([][]json.RawMessage, len(results))
+			for j, r := range results {
+				rows[j] = []json.RawMessage{r}
+			}
+			return []string{_VALUE_COLUMN}, rows
+		}
+		for _, name := range names {
+			addColumn(name)
+		}
+		objects[i] = fields
+	}
+
+	rows := make([][]json.RawMessage, len(objects))
+	for i, o := range objects {
+		rows[i] = make([]json.RawMessage, len(columns))
+		for name, v := range o {
+			rows[i][index[name]] = v
+		}
+	}
+	return columns, rows
+}
+
+/* The field names of a JSON object, in order, and their values */
+func objectFields(raw json.RawMessage) ([]string, map[string]json.RawMessage, bool) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	t, err := dec.Token()
+	if err != nil || t != json.Delim('{') {
+		return nil, nil, false
+	}
+
+	var names []string
+	fields := make(map[string]json.RawMessage)
+	for dec.More() {
+		t, err = dec.Token()
+		if err != nil {
+			return nil, nil, false
+		}
+		name, _ := t.(string)
+		var v json.RawMessage
+		if dec.Decode(&v) != nil {
+			return nil, nil, false
+		}
+		if _, ok := fields[name]; !ok {
+			names = append(names, name)
+		}
+		fields[name] = v
+	}
+	return names, fields, true
+}
+
+/*
+	The text of a value: strings are unquoted, objects and arrays
+	are compact JSON and missing values are empty.
+*/
+func cellText(raw json.RawMessage) string {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 {
+		return ""
+	}
+	switch raw[0] {
+	case '"':
+		var s string
+		if json.Unmarshal(raw, &s) == nil {
+			return s
+		}
+	case '{', '[':
+		var b bytes.Buffer
+		if json.Compact(&b, raw) == nil {
+			return b.String()
+		}
+	}
+	return string(raw)
+}
+
+func isNumber(raw json.RawMessage) bool {
+	raw = bytes.TrimSpace(raw)
+	return len(raw) > 0 && (raw[0] == '-' || (raw[0] >= '0' && raw[0] <= '9'))
+}
+
+var _CONTROL_CHARS = strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ", "\t", " ")
+
+func writeTable(w io.Writer, columns []string, rows [][]json.RawMessage) {
+	if len(columns) == 0 {
+		return
+	}
+
+	widths := make([]int, len(columns))
+	cells := make([][]string, len(rows))
+	for c, name := range columns {
+		widths[c] = runewidth.StringWidth(name)
+	}
+	for r, row := range rows {
+		cells[r] = make([]string, len(columns))
+		for c, v := range row {
+			s := _CONTROL_CHARS.Replace(cellText(v))
+			if runewidth.StringWidth(s) > _MAX_COLUMN_WIDTH {
+				s = runewidth.Truncate(s, _MAX_COLUMN_WIDTH, "...")
+			}
+			cells[r][c] = s
+			if n := runewidth.StringWidth(s); n > widths[c] {
+				widths[c] = n
+			}
+		}
+	}
+
+	separator := "+"
+	for _, n := range widths {
+		separator += strings.Repeat("-", n+2) + "+"
+	}
+	separator += "\n"
+
+	io.WriteString(w, separator)
+	line := "|"
+	for c, name := range columns {
+		line += " " + pad(name, widths[c], false) + " |"
+	}
+	io.WriteString(w, line+"\n")
+	io.WriteString(w, separator)
+	for r, row := range cells {
+		line = "|"
+		for c, s := range row {
+			line += " " + pad(s, widths[c], isNumber(rows[r][c])) + " |"
+		}
+		io.WriteString(w, line+"\n")
+	}
+	io.WriteString(w, separator)
+}
+
+func pad(s string, width int, right bool) string {
+	fill := strings.Repeat(" ", width-runewidth.StringWidth(s))
+	if right {
+		return fill + s
+	}
+	return s + fill
+}
+
+func writeVertical(w io.Writer, columns []string, rows [][]json.RawMessage) {
+	width := 0
+	for _, name := range columns {
+		if n := runewidth.StringWidth(name); n > width {
+			width = n
+		}
+	}
+
+	for r, row := range rows {
+		fmt.Fprintf(w, "%s %d. row %s\n", strings.Repeat("*", 27), r+1, strings.Repeat("*", 27))
+		for c, v := range row {
+			if v == nil {
+				continue
+			}
+			io.WriteString(w, pad(columns[c], width, true)+": "+cellText(v)+"\n")
+		}
+	}
+}
+
+var _NULL = []byte("null")
+
+/* CSV and TSV output only has the data, to be used by other tools */
+func writeDelimited(w io.Writer, columns []string, rows [][]json.RawMessage, delimiter rune) error {
+	if len(columns) == 0 {
+		return nil
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Comma = delimiter
+	err := cw.Write(columns)
+	if err != nil {
+		return err
+	}
+
+	record := make([]string, len(columns))
+	for _, row := range rows {
+		for c, v := range row {
+			record[c] = delimitedText(v)
+		}
+		err = cw.Write(record)
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+/* Nulls, like missing values, are empty in CSV and TSV output */
+func delimitedText(v json.RawMessage) string {
+	if bytes.Equal(bytes.TrimSpace(v), _NULL) {
+		return ""
+	}
+	return cellText(v)
+}
+
+func writeSummary(w io.Writer, response *queryResponse) {
+	rows := "rows"
+	if len(response.Results) == 1 {
+		rows = "row"
+	}
+	summary := fmt.Sprintf("(%d %s", len(response.Results), rows)
+	if response.Metrics.MutationCount > 0 {
+		summary += fmt.Sprintf(", %d mutations", response.Metrics.MutationCount)
+	}
+	if response.Status != "" {
+		summary += ", " + response.Status
+	}
+	if response.Metrics.ElapsedTime != "" {
+		summary += ", " + response.Metrics.ElapsedTime
+	}
+	io.WriteString(w, summary+")\n")
+}
+
+/*
+	Output to the terminal that does not fit on the screen is sent
+	through $PAGER, or less. Redirected output is written as is.
+*/
+func page(w io.Writer, out []byte) (int, string) {
+	f, ok := w.(*os.File)
+	if ok && f == os.Stdout {
+		height := terminalHeight(f)
+		if height > 0 && bytes.Count(out, []byte("\n")) >= height {
+			pager := os.Getenv("PAGER")
+			if pager == "" {
+				pager = "less -FRSX"
+				if WINDOWS {
+					pager = "more"
+				}
+			}
+			args := strings.Fields(pager)
+			cmd := exec.Command(args[0], args[1:]...)
+			cmd.Stdin = bytes.NewReader(out)
+			cmd.Stdout = os.Stdout
+			cmd.Stderr = os.Stderr
+			if cmd.Start() == nil {
+				cmd.Wait()
+				return 0, ""
+			}
+		}
+	}
+
+	_, err := w.Write(out)
+	if err != nil {
+		return errors.WRITER_OUTPUT, err.Error()
+	}
+	return 0, ""
+}
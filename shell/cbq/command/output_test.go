@@ -0,0 +1,75 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package command
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const _RESPONSE = `{
+"signature": {"name": "json", "age": "json"},
+"results": [
+	{"name": "alice", "age": 30, "address": {"city": "Paris"}},
+	{"name": "bob, jr", "age": null}
+],
+"status": "success",
+"metrics": {"elapsedTime": "1.2ms", "resultCount": 2}
+}`
+
+func TestWriteResults(t *testing.T) {
+	expected := map[string]string{
+		TABLE_FORMAT: "+---------+------+------------------+\n" +
+			"| name    | age  | address          |\n" +
+			"+---------+------+------------------+\n" +
+			"| alice   |   30 | {\"city\":\"Paris\"} |\n" +
+			"| bob, jr | null |                  |\n" +
+			"+---------+------+------------------+\n" +
+			"(2 rows, success, 1.2ms)\n",
+		CSV_FORMAT: "name,age,address\n" +
+			"alice,30,\"{\"\"city\"\":\"\"Paris\"\"}\"\n" +
+			"\"bob, jr\",,\n",
+		VERTICAL_FORMAT: "*************************** 1. row ***************************\n" +
+			"   name: alice\n" +
+			"    age: 30\n" +
+			"address: {\"city\":\"Paris\"}\n" +
+			"*************************** 2. row ***************************\n" +
+			"   name: bob, jr\n" +
+			"    age: null\n" +
+			"(2 rows, success, 1.2ms)\n",
+	}
+
+	for format, exp := range expected {
+		var b bytes.Buffer
+		errCode, errStr := WriteResults(strings.NewReader(_RESPONSE), &b, format)
+		if errCode != 0 {
+			t.Errorf("%s", HandleError(errCode, errStr))
+		} else if b.String() != exp {
+			t.Errorf("Unexpected %s output:\n%s\nexpected:\n%s", format, b.String(), exp)
+		}
+	}
+
+	errCode, _ := PushOrSet([]string{"-output_format", "html"}, false)
+	if errCode == 0 {
+		t.Errorf("Expected error for invalid output format")
+	}
+	errCode, errStr := PushOrSet([]string{"-output_format", "table"}, false)
+	if errCode != 0 {
+		t.Errorf("%s", HandleError(errCode, errStr))
+	} else if OutputFormat() != TABLE_FORMAT {
+		t.Errorf("Unexpected output format %s", OutputFormat())
+	}
+	errCode, errStr = PopValue_Helper(true, QueryParam, OUTPUT_FORMAT_PARAM)
+	if errCode != 0 {
+		t.Errorf("%s", HandleError(errCode, errStr))
+	} else if OutputFormat() != JSON_FORMAT {
+		t.Errorf("Unexpected output format %s after unset", OutputFormat())
+	}
+}
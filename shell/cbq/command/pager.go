@@ -0,0 +1,29 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+// +build !solaris
+
+package command
+
+import (
+	"os"
+
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+/* The number of lines of the terminal, or 0 if not a terminal */
+func terminalHeight(f *os.File) int {
+	if !terminal.IsTerminal(int(f.Fd())) {
+		return 0
+	}
+	_, height, err := terminal.GetSize(int(f.Fd()))
+	if err != nil {
+		return 0
+	}
+	return height
+}
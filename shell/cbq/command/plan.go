@@ -0,0 +1,81 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package command
+
+import (
+	"io"
+	"strings"
+
+	"github.com/couchbase/query/errors"
+)
+
+/* Plan Command */
+type Plan struct {
+	ShellCommand
+}
+
+func (this *Plan) Name() string {
+	return "PLAN"
+}
+
+func (this *Plan) CommandCompletion() bool {
+	return true
+}
+
+func (this *Plan) MinArgs() int {
+	return ZERO_ARGS
+}
+
+func (this *Plan) MaxArgs() int {
+	return ONE_ARG
+}
+
+func (this *Plan) ExecCommand(args []string) (int, string) {
+	/* Command to display plans and profiles as operator trees.
+	   Without arguments it displays the current setting.
+	*/
+	if len(args) > this.MaxArgs() {
+		return errors.TOO_MANY_ARGS, ""
+
+	} else if len(args) == 0 {
+		state := "OFF"
+		if PLAN_VIEW {
+			state = "ON"
+		}
+		_, werr := io.WriteString(W, state+"\n")
+		if werr != nil {
+			return errors.WRITER_OUTPUT, werr.Error()
+		}
+	} else {
+		switch strings.ToLower(args[0]) {
+		case "on":
+			PLAN_VIEW = true
+		case "off":
+			PLAN_VIEW = false
+		default:
+			return errors.PLAN_MODE, args[0]
+		}
+	}
+	return 0, ""
+}
+
+func (this *Plan) PrintHelp(desc bool) (int, string) {
+	_, werr := io.WriteString(W, HPLAN)
+	if desc {
+		err_code, err_str := printDesc(this.Name())
+		if err_code != 0 {
+			return err_code, err_str
+		}
+	}
+	_, werr = io.WriteString(W, "\n")
+	if werr != nil {
+		return errors.WRITER_OUTPUT, werr.Error()
+	}
+	return 0, ""
+}
@@ -0,0 +1,243 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/couchbase/query/errors"
+	"github.com/mattn/go-runewidth"
+)
+
+/* Fields of an operator holding its child operators, in display order */
+var _CHILD_FIELDS = []string{"~child", "~children", "children", "scan", "scans", "first", "second"}
+
+/* Profile statistics displayed for each operator, in order */
+var _STATS_FIELDS = []string{"#itemsIn", "#itemsOut", "execTime", "kernTime", "servTime"}
+
+/*
+	At most this many operators are highlighted as the hottest of a
+	profile, provided they account for enough of the total time.
+*/
+const (
+	_HOT_OPERATORS = 3
+	_HOT_PERCENT   = 10
+)
+
+/* Spans longer than this are truncated */
+const _MAX_SPANS_WIDTH = 60
+
+const (
+	_HOT_START = "\x1b[1;31m"
+	_HOT_END   = "\x1b[0m"
+)
+
+type planNode struct {
+	text     string
+	time     time.Duration
+	hot      bool
+	children []*planNode
+}
+
+/*
+	WritePlans displays EXPLAIN output and profile timings as operator
+	trees. Other responses are displayed in the given output format.
+*/
+func WritePlans(r io.Reader, w io.Writer, format string) (int, string) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return errors.ROWS_SCAN, err.Error()
+	}
+
+	var response struct {
+		queryResponse
+		Profile struct {
+			ExecutionTimings map[string]interface{} `json:"executionTimings"`
+		} `json:"profile"`
+	}
+	err = json.Unmarshal(data, &response)
+	if err != nil {
+		return errors.JSON_UNMARSHAL, err.Error()
+	}
+
+	color := false
+	if f, ok := w.(*os.File); ok && f == os.Stdout && !WINDOWS {
+		color = terminalHeight(f) > 0
+	}
+
+	var b bytes.Buffer
+	plans := explainPlans(response.Results)
+	if len(plans) > 0 {
+		for i, p := range plans {
+			if i > 0 {
+				b.WriteString("\n")
+			}
+			writePlan(&b, p, color)
+		}
+		for _, e := range response.Errors {
+			fmt.Fprintf(&b, "ERROR %v : %v\n", e.Code, e.Msg)
+		}
+		for _, e := range response.Warnings {
+			fmt.Fprintf(&b, "WARNING %v : %v\n", e.Code, e.Msg)
+		}
+		return page(w, b.Bytes())
+	}
+
+	if format == JSON_FORMAT {
+		_, err = w.Write(data)
+		if err != nil {
+			return errors.WRITER_OUTPUT, err.Error()
+		}
+	} else {
+		err_code, err_str := WriteResults(bytes.NewReader(data), w, format)
+		if err_code != 0 {
+			return err_code, err_str
+		}
+	}
+
+	if response.Profile.ExecutionTimings != nil {
+		b.WriteString("\nProfile :\n")
+		writePlan(&b, response.Profile.ExecutionTimings, color)
+		return page(w, b.Bytes())
+	}
+	return 0, ""
+}
+
+/* The plans returned by EXPLAIN, if every result is one */
+func explainPlans(results []json.RawMessage) []map[string]interface{} {
+	plans := make([]map[string]interface{}, 0, len(results))
+	for _, r := range results {
+		var result struct {
+			Plan map[string]interface{} `json:"plan"`
+		}
+		if json.Unmarshal(r, &result) != nil || result.Plan == nil {
+			return nil
+		}
+		plans = append(plans, result.Plan)
+	}
+	return plans
+}
+
+func writePlan(w io.Writer, op map[string]interface{}, color bool) {
+	root := buildPlanNode(op)
+
+	var nodes []*planNode
+	var total time.Duration
+	var collect func(n *planNode)
+	collect = func(n *planNode) {
+		if n.time > 0 {
+			nodes = append(nodes, n)
+			total += n.time
+		}
+		for _, c := range n.children {
+			collect(c)
+		}
+	}
+	collect(root)
+
+	// the hottest operators are those where most time was spent
+	sort.SliceStable(nodes, func(i, j int) bool { return nodes[i].time > nodes[j].time })
+	for i, n := range nodes {
+		percent := int(n.time * 100 / total)
+		if i == _HOT_OPERATORS || percent < _HOT_PERCENT {
+			break
+		}
+		n.hot = true
+		n.text += fmt.Sprintf("  <== %d%%", percent)
+	}
+
+	writePlanNode(w, root, "", "", color)
+}
+
+func writePlanNode(w io.Writer, n *planNode, prefix, childPrefix string, color bool) {
+	if n.hot && color {
+		io.WriteString(w, prefix+_HOT_START+n.text+_HOT_END+"\n")
+	} else {
+		io.WriteString(w, prefix+n.text+"\n")
+	}
+	for i, c := range n.children {
+		if i == len(n.children)-1 {
+			writePlanNode(w, c, childPrefix+"`-- ", childPrefix+"    ", color)
+		} else {
+			writePlanNode(w, c, childPrefix+"|-- ", childPrefix+"|   ", color)
+		}
+	}
+}
+
+/*
+	An operator line has its name, the index and keyspace it uses,
+	its spans, the optimizer estimates and the profile statistics.
+*/
+func buildPlanNode(op map[string]interface{}) *planNode {
+	n := &planNode{}
+	name, _ := op["#operator"].(string)
+	details := []string{name}
+
+	if index, ok := op["index"].(string); ok {
+		details = append(details, "index: "+index)
+	}
+	if keyspace, ok := op["keyspace"].(string); ok {
+		if bucket, ok := op["bucket"].(string); ok && bucket != "" {
+			scope, _ := op["scope"].(string)
+			keyspace = bucket + "." + scope + "." + keyspace
+		}
+		details = append(details, "keyspace: "+keyspace)
+	}
+	if spans, ok := op["spans"]; ok {
+		s, err := json.Marshal(spans)
+		if err == nil {
+			details = append(details, "spans: "+runewidth.Truncate(string(s), _MAX_SPANS_WIDTH, "..."))
+		}
+	}
+	if estimates, ok := op["optimizer_estimates"].(map[string]interface{}); ok {
+		for _, e := range []string{"cost", "cardinality"} {
+			if v, ok := estimates[e].(float64); ok {
+				details = append(details, e+": "+strconv.FormatFloat(v, 'g', 6, 64))
+			}
+		}
+	}
+	if stats, ok := op["#stats"].(map[string]interface{}); ok {
+		for _, s := range _STATS_FIELDS {
+			if v, ok := stats[s]; ok {
+				details = append(details, fmt.Sprintf("%s: %v", s, v))
+			}
+		}
+		n.time = statTime(stats, "execTime") + statTime(stats, "servTime")
+	}
+	n.text = strings.Join(details, "  ")
+
+	for _, f := range _CHILD_FIELDS {
+		switch child := op[f].(type) {
+		case map[string]interface{}:
+			n.children = append(n.children, buildPlanNode(child))
+		case []interface{}:
+			for _, c := range child {
+				if c, ok := c.(map[string]interface{}); ok {
+					n.children = append(n.children, buildPlanNode(c))
+				}
+			}
+		}
+	}
+	return n
+}
+
+func statTime(stats map[string]interface{}, name string) time.Duration {
+	s, _ := stats[name].(string)
+	d, _ := time.ParseDuration(s)
+	return d
+}
@@ -0,0 +1,75 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package command
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const _EXPLAIN_RESPONSE = `{
+"results": [{"plan": {"#operator": "Sequence", "~children": [
+	{"#operator": "IndexScan3", "index": "def_type", "bucket": "travel-sample", "scope": "inventory", "keyspace": "airline",
+	 "spans": [{"exact": true}], "optimizer_estimates": {"cost": 12.5, "cardinality": 187}},
+	{"#operator": "Parallel", "~child": {"#operator": "Sequence", "~children": [
+		{"#operator": "Filter"}, {"#operator": "InitialProject"}]}}]},
+	"text": "SELECT * FROM airline WHERE type = 'airline'"}],
+"status": "success"
+}`
+
+const _PROFILE_RESPONSE = `{
+"results": [{"a": 1}],
+"status": "success",
+"profile": {"executionTimings": {"#operator": "Sequence", "~children": [
+	{"#operator": "PrimaryScan3", "index": "#primary", "keyspace": "default",
+	 "#stats": {"#itemsOut": 1, "execTime": "50µs", "kernTime": "1ms", "servTime": "3ms"}},
+	{"#operator": "Fetch", "keyspace": "default", "#stats": {"#itemsIn": 1, "#itemsOut": 1, "servTime": "7ms"}},
+	{"#operator": "InitialProject", "#stats": {"#itemsIn": 1, "#itemsOut": 1, "execTime": "10µs"}}]}}
+}`
+
+func TestWritePlans(t *testing.T) {
+	var b bytes.Buffer
+	errCode, errStr := WritePlans(strings.NewReader(_EXPLAIN_RESPONSE), &b, JSON_FORMAT)
+	expected := "Sequence\n" +
+		"|-- IndexScan3  index: def_type  keyspace: travel-sample.inventory.airline  spans: [{\"exact\":true}]  cost: 12.5  cardinality: 187\n" +
+		"`-- Parallel\n" +
+		"    `-- Sequence\n" +
+		"        |-- Filter\n" +
+		"        `-- InitialProject\n"
+	if errCode != 0 {
+		t.Errorf("%s", HandleError(errCode, errStr))
+	} else if b.String() != expected {
+		t.Errorf("Unexpected plan:\n%s\nexpected:\n%s", b.String(), expected)
+	}
+
+	b.Reset()
+	errCode, errStr = WritePlans(strings.NewReader(_PROFILE_RESPONSE), &b, JSON_FORMAT)
+	expected = _PROFILE_RESPONSE + "\nProfile :\n" +
+		"Sequence\n" +
+		"|-- PrimaryScan3  index: #primary  keyspace: default  #itemsOut: 1  execTime: 50µs  kernTime: 1ms  servTime: 3ms  <== 30%\n" +
+		"|-- Fetch  keyspace: default  #itemsIn: 1  #itemsOut: 1  servTime: 7ms  <== 69%\n" +
+		"`-- InitialProject  #itemsIn: 1  #itemsOut: 1  execTime: 10µs\n"
+	if errCode != 0 {
+		t.Errorf("%s", HandleError(errCode, errStr))
+	} else if b.String() != expected {
+		t.Errorf("Unexpected profile:\n%s\nexpected:\n%s", b.String(), expected)
+	}
+
+	plan := COMMAND_LIST["\\plan"]
+	errCode, _ = plan.ExecCommand([]string{"maybe"})
+	if errCode == 0 {
+		t.Errorf("Expected error for invalid \\PLAN value")
+	}
+	errCode, errStr = plan.ExecCommand([]string{"ON"})
+	if errCode != 0 || !PLAN_VIEW {
+		t.Errorf("\\PLAN ON failed: %s", HandleError(errCode, errStr))
+	}
+	PLAN_VIEW = false
+}
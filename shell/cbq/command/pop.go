@@ -28,7 +28,7 @@ func (this *Pop) Name() string {
 }
 
 func (this *Pop) CommandCompletion() bool {
-	return false
+	return true
 }
 
 func (this *Pop) MinArgs() int {
@@ -112,7 +112,7 @@ func (this *Pop) ExecCommand(args []string) (int, string) {
 
 			if ok {
 				if QueryParam[vble].Len() == 0 {
-					n1ql.UnsetQueryParams(vble)
+					unsetQueryParam(vble)
 				} else {
 					err_code, err_str := setNewParamPop(vble, st_val)
 					if err_code != 0 {
@@ -121,7 +121,7 @@ func (this *Pop) ExecCommand(args []string) (int, string) {
 				}
 
 			} else {
-				n1ql.UnsetQueryParams(vble)
+				unsetQueryParam(vble)
 			}
 
 		} else if strings.HasPrefix(args[0], "$") {
@@ -246,7 +246,7 @@ func Popparam_Helper(param map[string]*Stack, isrestp bool, isnamep bool) (int,
 			if isnamep == true {
 				name = "$" + name
 			}
-			n1ql.UnsetQueryParams(name)
+			unsetQueryParam(name)
 		}
 
 		if err_code != 0 {
@@ -295,7 +295,7 @@ func setNewParamPop(name string, paramst *Stack) (int, string) {
 		}
 		nval = string(ac)
 	}
-	n1ql.SetQueryParams(name, nval)
+	setQueryParam(name, nval)
 	return 0, ""
 }
 
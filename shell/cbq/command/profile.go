@@ -0,0 +1,252 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package command
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/couchbase/godbc/n1ql"
+	"github.com/couchbase/query/errors"
+)
+
+/* Configuration file, in the home directory */
+const RC_FILE = ".cbqrc"
+
+/*
+	A connection profile. Passwords are not stored in the profile,
+	but read from an environment variable or a password file.
+*/
+type Profile struct {
+	Engine       string                     `json:"engine"`
+	User         string                     `json:"user"`
+	PasswordEnv  string                     `json:"password_env"`
+	PasswordFile string                     `json:"password_file"`
+	CACert       string                     `json:"cacert"`
+	Cert         string                     `json:"cert"`
+	Key          string                     `json:"key"`
+	NoSSLVerify  bool                       `json:"no-ssl-verify"`
+	Timeout      string                     `json:"timeout"`
+	OutputFormat string                     `json:"output_format"`
+	Params       map[string]json.RawMessage `json:"params"`
+	Startup      []string                   `json:"startup"`
+}
+
+type rcFile struct {
+	Default  string              `json:"default"`
+	Profiles map[string]*Profile `json:"profiles"`
+}
+
+/* Startup commands of the profile last connected to with \CONNECT */
+var profileStartup []string
+
+func rcPath() (string, int, string) {
+	home, err_code, err_str := GetHome()
+	if err_code != 0 {
+		return "", err_code, err_str
+	}
+	return filepath.Join(home, RC_FILE), 0, ""
+}
+
+func readRC() (*rcFile, int, string) {
+	path, err_code, err_str := rcPath()
+	if err_code != 0 {
+		return nil, err_code, err_str
+	}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, 0, ""
+	} else if err != nil {
+		return nil, errors.READ_FILE, err.Error()
+	}
+
+	rc := &rcFile{}
+	err = json.Unmarshal(b, rc)
+	if err != nil {
+		return nil, errors.PROFILE, path + " : " + err.Error()
+	}
+	return rc, 0, ""
+}
+
+/*
+	LoadProfile returns the named profile of the configuration file,
+	or its default profile if no name is given. There is no profile
+	if there is no configuration file or default.
+*/
+func LoadProfile(name string) (*Profile, int, string) {
+	rc, err_code, err_str := readRC()
+	if err_code != 0 {
+		return nil, err_code, err_str
+	}
+	if name == "" {
+		if rc == nil || rc.Default == "" {
+			return nil, 0, ""
+		}
+		name = rc.Default
+	}
+	if rc == nil {
+		return nil, errors.PROFILE, name + " : no " + RC_FILE + " file"
+	}
+
+	profile, ok := rc.Profiles[name]
+	if !ok || profile == nil {
+		return nil, errors.PROFILE, name + " : no such profile"
+	}
+	if profile.Engine == "" {
+		return nil, errors.PROFILE, name + " : no engine"
+	}
+	if profile.OutputFormat != "" && !_OUTPUT_FORMATS[strings.ToLower(profile.OutputFormat)] {
+		return nil, errors.OUTPUT_FORMAT, profile.OutputFormat
+	}
+	return profile, 0, ""
+}
+
+/* Profile names, for completion */
+func profileNames() []string {
+	rc, _, _ := readRC()
+	if rc == nil {
+		return nil
+	}
+	names := make([]string, 0, len(rc.Profiles))
+	for name, _ := range rc.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+/* The password of the profile user, from the environment or a file */
+func (this *Profile) Password() (string, int, string) {
+	if this.PasswordEnv != "" {
+		pwd, ok := os.LookupEnv(this.PasswordEnv)
+		if !ok {
+			return "", errors.PROFILE, "environment variable " + this.PasswordEnv + " not set"
+		}
+		return pwd, 0, ""
+	}
+	if this.PasswordFile != "" {
+		b, err := ioutil.ReadFile(expandHome(this.PasswordFile))
+		if err != nil {
+			return "", errors.READ_FILE, err.Error()
+		}
+		return strings.TrimRight(string(b), "\r\n"), 0, ""
+	}
+	return "", 0, ""
+}
+
+/*
+	Targets returns whether the profile is for the given engine, and
+	so whether its credentials and TLS settings can be sent to it.
+*/
+func (this *Profile) Targets(engine string) bool {
+	return normalizeEngine(engine) == normalizeEngine(this.Engine)
+}
+
+func normalizeEngine(engine string) string {
+	engine = strings.TrimRight(strings.ToLower(strings.TrimSpace(engine)), "/")
+	if engine != "" && !strings.Contains(engine, "://") {
+		engine = "http://" + engine
+	}
+	return engine
+}
+
+/* The TLS files of the profile, with ~ expanded */
+func (this *Profile) Files() (cacert, cert, key string) {
+	return expandHome(this.CACert), expandHome(this.Cert), expandHome(this.Key)
+}
+
+/*
+	SetParams sets the query parameters, named parameters and output
+	format of the profile, as \SET would. Parameter names starting
+	with $ are named parameters.
+*/
+func (this *Profile) SetParams() (int, string) {
+	if this.OutputFormat != "" {
+		err_code, err_str := PushOrSet([]string{"-" + OUTPUT_FORMAT_PARAM, this.OutputFormat}, false)
+		if err_code != 0 {
+			return err_code, err_str
+		}
+	}
+
+	names := make([]string, 0, len(this.Params))
+	for name, _ := range this.Params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		err_code, err_str := PushOrSet([]string{"-" + name, string(this.Params[name])}, false)
+		if err_code != 0 {
+			return err_code, err_str
+		}
+	}
+	return 0, ""
+}
+
+/*
+	SetConnection sets the credentials, TLS files and timeout of the
+	profile for the connections that follow.
+*/
+func (this *Profile) SetConnection() (int, string) {
+	if this.User != "" {
+		pwd, err_code, err_str := this.Password()
+		if err_code != 0 {
+			return err_code, err_str
+		}
+		creds, err := json.Marshal(Credentials{Credential{"user": this.User, "pass": pwd}})
+		if err != nil {
+			return errors.JSON_MARSHAL, err.Error()
+		}
+		n1ql.SetQueryParams("creds", string(creds))
+		SetUsernamePassword(this.User, pwd)
+	}
+
+	cacert, cert, key := this.Files()
+	if cacert != "" {
+		SetRootFile(cacert)
+	}
+	if cert != "" {
+		SetCertFile(cert)
+	}
+	if key != "" {
+		SetKeyFile(key)
+	}
+	if this.NoSSLVerify {
+		SKIPVERIFY = true
+		n1ql.SetSkipVerify(true)
+	}
+	if this.Timeout != "" {
+		n1ql.SetQueryParams("timeout", this.Timeout)
+	}
+	return 0, ""
+}
+
+/*
+	TakeProfileStartup returns the startup commands of the profile
+	last connected to with \CONNECT, if they have not been run yet.
+*/
+func TakeProfileStartup() []string {
+	rv := profileStartup
+	profileStartup = nil
+	return rv
+}
+
+func expandHome(path string) string {
+	if !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err_code, _ := GetHome()
+	if err_code != 0 {
+		return path
+	}
+	return filepath.Join(home, path[2:])
+}
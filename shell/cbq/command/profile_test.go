@@ -0,0 +1,130 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package command
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/couchbase/query/errors"
+)
+
+const _RC = `{
+"default": "local",
+"profiles": {
+	"local": {"engine": "http://localhost:8093", "output_format": "table"},
+	"prod": {"engine": "couchbases://prod", "user": "admin", "password_env": "CBQ_TEST_PASSWORD",
+		"cacert": "~/ca.pem", "params": {"scan_consistency": "request_plus", "$limit": 10},
+		"startup": ["\\SET -max_parallelism 4"]},
+	"file": {"engine": "http://localhost:8093", "user": "reader", "password_file": "~/pwd"},
+	"bad": {"engine": "http://localhost:8093", "output_format": "xml"}
+}
+}`
+
+func setHome(t *testing.T) func() {
+	dir, err := ioutil.TempDir("", "cbqrc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	home := os.Getenv("HOME")
+	os.Setenv("HOME", dir)
+	return func() {
+		os.Setenv("HOME", home)
+		os.RemoveAll(dir)
+	}
+}
+
+func TestLoadProfile(t *testing.T) {
+	defer setHome(t)()
+	home := os.Getenv("HOME")
+
+	profile, errCode, _ := LoadProfile("")
+	if errCode != 0 || profile != nil {
+		t.Errorf("Expected no profile without %s, got %v, %d", RC_FILE, profile, errCode)
+	}
+
+	err := ioutil.WriteFile(filepath.Join(home, RC_FILE), []byte(_RC), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	profile, errCode, errStr := LoadProfile("")
+	if errCode != 0 || profile == nil || profile.Engine != "http://localhost:8093" || profile.OutputFormat != TABLE_FORMAT {
+		t.Errorf("Unexpected default profile %v, %s", profile, HandleError(errCode, errStr))
+	}
+
+	profile, errCode, errStr = LoadProfile("prod")
+	if errCode != 0 {
+		t.Fatalf("%s", HandleError(errCode, errStr))
+	}
+	cacert, cert, _ := profile.Files()
+	if cacert != filepath.Join(home, "ca.pem") || cert != "" || string(profile.Params["$limit"]) != "10" ||
+		len(profile.Startup) != 1 || profile.Startup[0] != "\\SET -max_parallelism 4" {
+		t.Errorf("Unexpected profile %+v", profile)
+	}
+
+	for _, name := range []string{"none", "bad"} {
+		if _, errCode, _ = LoadProfile(name); errCode == 0 {
+			t.Errorf("Expected error for profile %s", name)
+		}
+	}
+
+	names := profileNames()
+	if strings.Join(names, " ") != "bad file local prod" {
+		t.Errorf("Unexpected profile names %v", names)
+	}
+}
+
+func TestProfilePassword(t *testing.T) {
+	defer setHome(t)()
+	home := os.Getenv("HOME")
+
+	profile := &Profile{User: "admin", PasswordEnv: "CBQ_TEST_PASSWORD"}
+	os.Unsetenv("CBQ_TEST_PASSWORD")
+	if _, errCode, _ := profile.Password(); errCode != errors.PROFILE {
+		t.Errorf("Expected error for unset password variable, got %d", errCode)
+	}
+	os.Setenv("CBQ_TEST_PASSWORD", "secret")
+	defer os.Unsetenv("CBQ_TEST_PASSWORD")
+	if pwd, errCode, _ := profile.Password(); errCode != 0 || pwd != "secret" {
+		t.Errorf("Unexpected password %q from environment", pwd)
+	}
+
+	err := ioutil.WriteFile(filepath.Join(home, "pwd"), []byte("from file\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	profile = &Profile{User: "reader", PasswordFile: "~/pwd"}
+	if pwd, errCode, _ := profile.Password(); errCode != 0 || pwd != "from file" {
+		t.Errorf("Unexpected password %q from file", pwd)
+	}
+}
+
+func TestProfileTargets(t *testing.T) {
+	profile := &Profile{Engine: "https://Prod.example.com:18093/"}
+	for _, engine := range []string{"https://prod.example.com:18093", "HTTPS://prod.example.com:18093/"} {
+		if !profile.Targets(engine) {
+			t.Errorf("Expected profile to be for %v", engine)
+		}
+	}
+	for _, engine := range []string{"https://other.example.com:18093", "http://prod.example.com:18093",
+		"prod.example.com:18093", ""} {
+		if profile.Targets(engine) {
+			t.Errorf("Expected profile not to be for %v", engine)
+		}
+	}
+
+	profile = &Profile{Engine: "localhost:8093"}
+	if !profile.Targets("http://localhost:8093") {
+		t.Errorf("Expected engines without a scheme to be http")
+	}
+}
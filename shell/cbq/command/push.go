@@ -12,7 +12,6 @@ import (
 	"encoding/json"
 	"io"
 
-	"github.com/couchbase/godbc/n1ql"
 	"github.com/couchbase/query/errors"
 	"github.com/couchbase/query/value"
 )
@@ -27,7 +26,7 @@ func (this *Push) Name() string {
 }
 
 func (this *Push) CommandCompletion() bool {
-	return false
+	return true
 }
 
 func (this *Push) MinArgs() int {
@@ -143,7 +142,7 @@ func Pushparam_Helper(param map[string]*Stack, isrestp bool, isnamep bool) (int,
 					val = string(ac)
 				}
 			}
-			n1ql.SetQueryParams(name, val)
+			setQueryParam(name, val)
 		}
 	}
 	return 0, ""
@@ -26,7 +26,7 @@ func (this *Refresh_cluster_map) Name() string {
 }
 
 func (this *Refresh_cluster_map) CommandCompletion() bool {
-	return false
+	return true
 }
 
 func (this *Refresh_cluster_map) MinArgs() int {
@@ -26,7 +26,7 @@ func (this *Set) Name() string {
 }
 
 func (this *Set) CommandCompletion() bool {
-	return false
+	return true
 }
 
 func (this *Set) MinArgs() int {
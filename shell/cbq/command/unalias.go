@@ -24,7 +24,7 @@ func (this *Unalias) Name() string {
 }
 
 func (this *Unalias) CommandCompletion() bool {
-	return false
+	return true
 }
 
 func (this *Unalias) MinArgs() int {
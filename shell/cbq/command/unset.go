@@ -27,7 +27,7 @@ func (this *Unset) Name() string {
 }
 
 func (this *Unset) CommandCompletion() bool {
-	return false
+	return true
 }
 
 func (this *Unset) MinArgs() int {
@@ -72,7 +72,7 @@ func (this *Unset) ExecCommand(args []string) (int, string) {
 			if err_code != 0 {
 				return err_code, err_str
 			}
-			n1ql.UnsetQueryParams(vble)
+			unsetQueryParam(vble)
 
 		} else if strings.HasPrefix(args[0], "$") {
 			// For User defined session variables
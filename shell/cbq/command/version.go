@@ -24,7 +24,7 @@ func (this *Version) Name() string {
 }
 
 func (this *Version) CommandCompletion() bool {
-	return false
+	return true
 }
 
 func (this *Version) MinArgs() int {
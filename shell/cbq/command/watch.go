@@ -0,0 +1,188 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package command
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/couchbase/query/errors"
+)
+
+const (
+	_WATCH_INTERVAL     = 2 * time.Second
+	_WATCH_MIN_INTERVAL = 100 * time.Millisecond
+
+	_CLEAR_SCREEN = "\x1b[H\x1b[2J"
+	_DIFF_START   = "\x1b[7m"
+	_DIFF_END     = "\x1b[0m"
+)
+
+var (
+	//Statement or command run repeatedly by \WATCH
+	WATCH_STMT = ""
+	//Time between the start of two runs
+	WATCH_INTERVAL = _WATCH_INTERVAL
+	//True if changes between runs are highlighted
+	WATCH_DIFF = false
+)
+
+/* Watch Command */
+type Watch struct {
+	ShellCommand
+}
+
+func (this *Watch) Name() string {
+	return "WATCH"
+}
+
+func (this *Watch) CommandCompletion() bool {
+	return true
+}
+
+func (this *Watch) MinArgs() int {
+	return ONE_ARG
+}
+
+func (this *Watch) MaxArgs() int {
+	return MAX_ARGS
+}
+
+func (this *Watch) ExecCommand(args []string) (int, string) {
+	/* Command to run a statement or shell command on a timer,
+	   until interrupted. Like \SOURCE, the runs are handled in
+	   the main package, which dispatches the statement.
+	*/
+	if len(args) < this.MinArgs() {
+		return errors.TOO_FEW_ARGS, ""
+	}
+
+	stmt, interval, diff, ok := watchArgs(args)
+	if !ok {
+		return errors.WATCH_ARGS, HWATCH
+	}
+
+	if !strings.HasPrefix(stmt, "\\") && !connected() {
+		return errors.NO_CONNECTION, ""
+	}
+
+	WATCH_STMT = stmt
+	WATCH_INTERVAL = interval
+	WATCH_DIFF = diff
+	return 0, ""
+}
+
+/*
+	Options come first, followed by the statement:
+	-interval duration and -diff.
+*/
+func watchArgs(args []string) (string, time.Duration, bool, bool) {
+	interval := _WATCH_INTERVAL
+	diff := false
+
+	i := 0
+	for ; i < len(args) && strings.HasPrefix(args[i], "-"); i++ {
+		switch strings.ToLower(args[i]) {
+		case "-diff":
+			diff = true
+		case "-interval":
+			i++
+			if i == len(args) {
+				return "", 0, false, false
+			}
+			d, err := time.ParseDuration(args[i])
+			if err != nil || d < _WATCH_MIN_INTERVAL {
+				return "", 0, false, false
+			}
+			interval = d
+		default:
+			return "", 0, false, false
+		}
+	}
+
+	stmt := strings.TrimSuffix(strings.TrimSpace(strings.Join(args[i:], " ")), ";")
+	if stmt == "" || strings.HasPrefix(strings.ToLower(stmt), "\\watch") {
+		return "", 0, false, false
+	}
+	return stmt, interval, diff, true
+}
+
+/*
+	WatchRedraw returns true if each run of \WATCH replaces the
+	output of the previous one. Otherwise the runs are appended.
+*/
+func WatchRedraw() bool {
+	return showProgress()
+}
+
+/* WatchHeader returns the line displayed above the output of each run */
+func WatchHeader(stmt string, interval time.Duration, at time.Time, redraw bool) string {
+	header := fmt.Sprintf("Every %v : %s    %s\n\n", interval, stmt, at.Format("2006-01-02 15:04:05"))
+	if redraw {
+		return _CLEAR_SCREEN + header
+	}
+	return header
+}
+
+/*
+	WatchDiff highlights the characters of the output that changed
+	since the previous run, line by line.
+*/
+func WatchDiff(prev, cur string) string {
+	if prev == "" {
+		return cur
+	}
+
+	prevLines := strings.Split(prev, "\n")
+	var b strings.Builder
+	for i, line := range strings.Split(cur, "\n") {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+
+		old := []rune{}
+		if i < len(prevLines) {
+			old = []rune(prevLines[i])
+		}
+		changed := false
+		for j, r := range []rune(line) {
+			diff := j >= len(old) || old[j] != r
+			if diff != changed {
+				if diff {
+					b.WriteString(_DIFF_START)
+				} else {
+					b.WriteString(_DIFF_END)
+				}
+				changed = diff
+			}
+			b.WriteRune(r)
+		}
+		if changed {
+			b.WriteString(_DIFF_END)
+		}
+	}
+	return b.String()
+}
+
+func (this *Watch) PrintHelp(desc bool) (int, string) {
+	_, werr := io.WriteString(W, HWATCH)
+	if desc {
+		err_code, err_str := printDesc(this.Name())
+		if err_code != 0 {
+			return err_code, err_str
+		}
+	}
+	_, werr = io.WriteString(W, "\n")
+	if werr != nil {
+		return errors.WRITER_OUTPUT, werr.Error()
+	}
+	return 0, ""
+}
@@ -0,0 +1,56 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package command
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatchArgs(t *testing.T) {
+	stmt, interval, diff, ok := watchArgs(strings.Fields("-interval 5s -diff select * from system:active_requests;"))
+	if !ok || stmt != "select * from system:active_requests" || interval != 5*time.Second || !diff {
+		t.Errorf("Unexpected arguments %q, %v, %v", stmt, interval, diff)
+	}
+
+	stmt, interval, diff, ok = watchArgs(strings.Fields("\\ECHO hello"))
+	if !ok || stmt != "\\ECHO hello" || interval != _WATCH_INTERVAL || diff {
+		t.Errorf("Unexpected default arguments %q, %v, %v", stmt, interval, diff)
+	}
+
+	for _, args := range []string{"-interval 5s", "-interval 1ms select 1", "-interval x select 1", "-x select 1",
+		"-interval", "\\watch select 1"} {
+		if _, _, _, ok = watchArgs(strings.Fields(args)); ok {
+			t.Errorf("Expected error for %s", args)
+		}
+	}
+}
+
+func TestWatchDiff(t *testing.T) {
+	if WatchDiff("", "a\nb") != "a\nb" {
+		t.Errorf("Unexpected highlight of the first run")
+	}
+
+	prev := "count 10\nsame\n"
+	cur := "count 12\nsame\nnew\n"
+	expected := "count 1" + _DIFF_START + "2" + _DIFF_END + "\nsame\n" + _DIFF_START + "new" + _DIFF_END + "\n"
+	if d := WatchDiff(prev, cur); d != expected {
+		t.Errorf("Unexpected diff %q, expected %q", d, expected)
+	}
+
+	at := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	header := WatchHeader("select 1", 2*time.Second, at, false)
+	if header != "Every 2s : select 1    2021-03-04 05:06:07\n\n" {
+		t.Errorf("Unexpected header %q", header)
+	}
+	if !strings.HasPrefix(WatchHeader("select 1", time.Second, at, true), _CLEAR_SCREEN) {
+		t.Errorf("Expected screen to be cleared")
+	}
+}
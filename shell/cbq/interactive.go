@@ -146,6 +146,34 @@ func handleScriptFlag(liner **liner.State) {
 	}
 }
 
+// Run the startup commands of a connection profile
+
+func runStartup(cmds []string, liner *liner.State) {
+	for _, cmd := range cmds {
+		err_code, err_str := dispatch_command(cmd, command.W, true, liner)
+		if err_code != 0 {
+			s_err := command.HandleError(err_code, err_str)
+			command.PrintError(s_err)
+		}
+	}
+}
+
+// Handle bench flag - benchmark mode
+
+func handleBenchFlag(liner **liner.State) {
+	if benchFlag != "" {
+		err_code, err_str := dispatch_command("\\bench "+benchFlag, command.W, false, *liner)
+		(*liner).Close()
+		os.Clearenv()
+		if err_code != 0 {
+			s_err := command.HandleError(err_code, err_str)
+			command.PrintError(s_err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+}
+
 /* This method is used to handle user interaction with the
    cli. After combining the multi line input, it is sent to
    the execute_inpu method which parses and executes the
@@ -177,6 +205,7 @@ func HandleInteractiveMode(prompt string) {
 	/* Create a new liner */
 	var liner = liner.NewLiner(viModeSingleLineFlag || viModeMultiLineFlag)
 	liner.SetMultiLineMode(!viModeSingleLineFlag)
+	liner.SetWordCompleter(command.CompleteWord)
 	defer liner.Close()
 
 	/* Load history from Home directory
@@ -193,7 +222,9 @@ func HandleInteractiveMode(prompt string) {
 	inputLine := []string{}
 	fullPrompt := prompt + QRY_PROMPT1
 
+	runStartup(startupCmds, liner)
 	handleScriptFlag(&liner)
+	handleBenchFlag(&liner)
 	handleIPModeFlag(&liner)
 
 	// End handling the options
@@ -196,6 +196,38 @@ func (s *scripts) Set(val string) error {
 	return nil
 }
 
+/*
+   Option        : -bench
+   Args          : <\BENCH arguments>
+   Benchmark mode
+*/
+
+var benchFlag string
+
+func init() {
+	const (
+		defaultval = ""
+		usage      = command.UBENCH
+	)
+	flag.StringVar(&benchFlag, "bench", defaultval, usage)
+}
+
+/*
+   Option        : -profile
+   Args          : <profile name>
+   Connection profile of the ~/.cbqrc file
+*/
+
+var profileFlag string
+
+func init() {
+	const (
+		defaultval = ""
+		usage      = command.UPROFILE
+	)
+	flag.StringVar(&profileFlag, "profile", defaultval, usage)
+}
+
 /*
    Option        : -pretty
    Default value : false
@@ -421,6 +453,9 @@ var (
 	DISCONNECT   bool
 	EXIT         bool
 	stringBuffer bytes.Buffer
+
+	// Startup commands of the profile given by -profile
+	startupCmds []string
 )
 
 func main() {
@@ -464,6 +499,27 @@ func main() {
 		os.Exit(0)
 	}
 
+	/* -profile : Use the connection profile, or the default profile
+	   of the ~/.cbqrc file for the options that are not given.
+	*/
+	profile, err_code, err_str := command.LoadProfile(profileFlag)
+	if err_code != 0 {
+		s_err := command.HandleError(err_code, err_str)
+		command.PrintError(s_err)
+		os.Exit(1)
+	}
+	if profile != nil && !profileApplies(profile) {
+		profile = nil
+	}
+	if profile != nil {
+		err_code, err_str = profileOptions(profile)
+		if err_code != 0 {
+			s_err := command.HandleError(err_code, err_str)
+			command.PrintError(s_err)
+			os.Exit(1)
+		}
+	}
+
 	/* Check for input url argument
 	 */
 
@@ -537,7 +593,7 @@ func main() {
 				creds = append(creds, command.Credential{"user": userFlag, "pass": string(password)})
 				// The driver needs the username/password to query the cluster endpoint,
 				// which may require authorization.
-				n1ql.SetUsernamePassword(userFlag, string(password))
+				command.SetUsernamePassword(userFlag, string(password))
 			}
 		} else {
 			s_err := command.HandleError(errors.INVALID_PASSWORD, err.Error())
@@ -564,7 +620,7 @@ func main() {
 		// un-authenticated servers.
 		// Dont output the statement if we are running in single command
 		// mode.
-		if len(scriptFlag) == 0 && rootFile == "" && certFile == "" && keyFile == "" {
+		if len(scriptFlag) == 0 && benchFlag == "" && rootFile == "" && certFile == "" && keyFile == "" {
 			_, werr := io.WriteString(command.W, command.STARTUPCREDS)
 
 			if werr != nil {
@@ -603,20 +659,20 @@ func main() {
 			os.Exit(1)
 		}
 		n1ql.SetQueryParams("creds", string(ac))
-		n1ql.SetUsernamePassword(creds[0]["user"], creds[0]["pass"])
+		command.SetUsernamePassword(creds[0]["user"], creds[0]["pass"])
 	}
 
 	n1ql.SetSkipVerify(noSSLVerify)
 	command.SKIPVERIFY = noSSLVerify
 	if certFile != "" {
-		n1ql.SetCertFile(certFile)
+		command.SetCertFile(certFile)
 	}
 	if keyFile != "" {
-		n1ql.SetKeyFile(keyFile)
+		command.SetKeyFile(keyFile)
 	}
 
 	if rootFile != "" {
-		n1ql.SetRootFile(rootFile)
+		command.SetRootFile(rootFile)
 	}
 
 	if strings.HasPrefix(strings.ToLower(serverFlag), "https://") && rootFile == "" && certFile == "" && keyFile == "" {
@@ -640,6 +696,8 @@ func main() {
 			command.SERVICE_URL = ""
 			SERVICE_URL = ""
 			noQueryService = true
+		} else {
+			go command.RefreshCompletion()
 		}
 
 		/* -quiet : Display Message only if flag not specified
@@ -678,8 +736,83 @@ func main() {
 
 	n1ql.SetCBUserAgentHeader("CBQ/" + command.SHELL_VERSION)
 
+	if profile != nil {
+		err_code, err_str = profile.SetParams()
+		if err_code != 0 {
+			s_err := command.HandleError(err_code, err_str)
+			command.PrintError(s_err)
+		}
+		startupCmds = profile.Startup
+	}
+
 	// Handle the inputFlag and ScriptFlag options in HandleInteractiveMode.
 	// This is so as to add these to the history.
 
 	HandleInteractiveMode(filepath.Base(os.Args[0]))
 }
+
+/* The flags given on the command line. */
+func flagsSet() map[string]bool {
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	return set
+}
+
+/*
+	A profile given with -profile is always used. The default profile
+	is only used when no engine is given, or for its own engine, so that
+	its credentials and TLS settings are never sent to another host.
+*/
+func profileApplies(profile *command.Profile) bool {
+	if profileFlag != "" {
+		return true
+	}
+	set := flagsSet()
+	if len(flag.Args()) > 0 {
+		return profile.Targets(flag.Args()[0])
+	} else if set["engine"] || set["e"] {
+		return profile.Targets(serverFlag)
+	}
+	return true
+}
+
+/* Sets the options not given on the command line from the profile. */
+func profileOptions(profile *command.Profile) (int, string) {
+	set := flagsSet()
+
+	if !set["engine"] && !set["e"] && len(flag.Args()) == 0 {
+		serverFlag = profile.Engine
+	}
+
+	// -credentials is never combined with the profile user
+	if userFlag == "" && credsFlag == "" && profile.User != "" {
+		userFlag = profile.User
+		if pwdFlag == "" {
+			pwd, err_code, err_str := profile.Password()
+			if err_code != 0 {
+				return err_code, err_str
+			}
+			pwdFlag = pwd
+		}
+	}
+
+	cacert, cert, key := profile.Files()
+	if rootFile == "" {
+		rootFile = cacert
+	}
+	if certFile == "" {
+		certFile = cert
+	}
+	if keyFile == "" {
+		keyFile = key
+	}
+	if !set["no-ssl-verify"] && !set["skip-verify"] {
+		noSSLVerify = profile.NoSSLVerify
+	}
+	if timeoutFlag == "" {
+		timeoutFlag = profile.Timeout
+	}
+	return 0, ""
+}
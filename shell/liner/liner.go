@@ -90,3 +90,15 @@ func (s *State) SetMultiLineMode(mlmode bool) {
 		s.vi.SetMultiLineMode(mlmode)
 	}
 }
+
+// WordCompleter takes the currently edited line with the cursor position and
+// returns the completion candidates for the partial word to be completed.
+type WordCompleter func(line string, pos int) (head string, completions []string, tail string)
+
+func (s *State) SetWordCompleter(f WordCompleter) {
+	if !s.viMode {
+		s.orig.SetWordCompleter(pliner.WordCompleter(f))
+	} else {
+		s.vi.SetWordCompleter(vliner.WordCompleter(f))
+	}
+}
@@ -85,8 +85,13 @@ type State struct {
 	cy              int
 	promptLines     int
 	displayStartPos int
+	completer       WordCompleter
 }
 
+// WordCompleter returns the completions for the word at pos in line, as well as the unchanged
+// text before and after the word.  It has the same semantics as the peterh/liner equivalent.
+type WordCompleter func(line string, pos int) (head string, completions []string, tail string)
+
 type digraph struct {
 	first  rune
 	second rune
@@ -693,6 +698,10 @@ func (s *State) SetMultiLineMode(mlmode bool) {
 	s.multiLine = mlmode
 }
 
+func (s *State) SetWordCompleter(f WordCompleter) {
+	s.completer = f
+}
+
 // these persist across invocations in contrast to shell-vi-mode equivalents in order to help with repeated statement invocations
 var fact, fr rune
 var curHist int = -1
@@ -1846,6 +1855,16 @@ func (s *State) inputText(prompt string, prefix []rune, suffix []rune, fixedSuff
 		} else if s.controlChars[ccVKILL] == r { // Ctrl+U typically
 			pos = 0
 			input = input[:0]
+		} else if _ASCII_TAB == r && nil != s.completer {
+			// complete up to the longest prefix shared by all candidates
+			line := append(append([]rune(nil), prefix...), input...)
+			head, completions, tail := s.completer(string(line), len(prefix)+pos)
+			h := []rune(head)
+			if 0 < len(completions) && len(prefix) <= len(h) {
+				h = append(h[len(prefix):], []rune(commonPrefix(completions))...)
+				pos = len(h)
+				input = append(h, []rune(tail)...)
+			}
 		} else if '\r' == r || '\n' == r {
 			done = 1
 		} else if _ASCII_ESC == r {
@@ -1860,6 +1879,19 @@ func (s *State) inputText(prompt string, prefix []rune, suffix []rune, fixedSuff
 	return input, done
 }
 
+func commonPrefix(words []string) string {
+	rv := []rune(words[0])
+	for _, w := range words[1:] {
+		wr := []rune(w)
+		i := 0
+		for i < len(rv) && i < len(wr) && rv[i] == wr[i] {
+			i++
+		}
+		rv = rv[:i]
+	}
+	return string(rv)
+}
+
 // we display control characters as composites so we need to deal with them explicitly
 func decode(r rune) []rune {
 	if _ASCII_NUL <= r && ' ' > r {
@@ -0,0 +1,154 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package test
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/functions"
+	"github.com/couchbase/query/functions/resolver"
+)
+
+// function storage is in metakv, so keep definitions in memory instead
+var memoryFunctions struct {
+	sync.Mutex
+	definitions map[string][]byte
+}
+
+type memoryFunction struct {
+	path []string
+}
+
+func newMemoryFunction(elem []string, namespace string, queryContext string) (functions.FunctionName, errors.Error) {
+	return &memoryFunction{path: []string{namespace, elem[len(elem)-1]}}, nil
+}
+
+func (name *memoryFunction) Path() []string {
+	return name.path
+}
+
+func (name *memoryFunction) Name() string {
+	return name.path[1]
+}
+
+func (name *memoryFunction) Key() string {
+	return strings.Join(name.path, ":")
+}
+
+func (name *memoryFunction) IsGlobal() bool {
+	return true
+}
+
+func (name *memoryFunction) QueryContext() string {
+	return ""
+}
+
+func (name *memoryFunction) Signature(object map[string]interface{}) {
+	object["namespace"] = name.path[0]
+	object["name"] = name.path[1]
+	object["type"] = "global"
+}
+
+func (name *memoryFunction) Load() (functions.FunctionBody, errors.Error) {
+	memoryFunctions.Lock()
+	definition := memoryFunctions.definitions[name.Key()]
+	memoryFunctions.Unlock()
+	if definition == nil {
+		return nil, nil
+	}
+	return resolver.MakeBody(name.Name(), definition)
+}
+
+func (name *memoryFunction) Save(body functions.FunctionBody, replace bool) errors.Error {
+	definition := make(map[string]interface{})
+	body.Body(definition)
+	bytes, err := json.Marshal(definition)
+	if err != nil {
+		return errors.NewFunctionEncodingError("encode", name.Name(), err)
+	}
+
+	memoryFunctions.Lock()
+	defer memoryFunctions.Unlock()
+	if _, ok := memoryFunctions.definitions[name.Key()]; ok && !replace {
+		return errors.NewDuplicateFunctionError(name.Name())
+	}
+	memoryFunctions.definitions[name.Key()] = bytes
+	return nil
+}
+
+func (name *memoryFunction) Delete() errors.Error {
+	memoryFunctions.Lock()
+	defer memoryFunctions.Unlock()
+	if _, ok := memoryFunctions.definitions[name.Key()]; !ok {
+		return errors.NewMissingFunctionError(name.Name())
+	}
+	delete(memoryFunctions.definitions, name.Key())
+	return nil
+}
+
+func (name *memoryFunction) CheckStorage() bool {
+	return false
+}
+
+func (name *memoryFunction) ResetStorage() {
+}
+
+func TestTableFunctions(t *testing.T) {
+	qc := start()
+	constructor := functions.Constructor
+	defer func() { functions.Constructor = constructor }()
+	functions.Constructor = newMemoryFunction
+	memoryFunctions.definitions = make(map[string][]byte)
+
+	for _, stmt := range []string{
+		"CREATE FUNCTION pairs(a, b) RETURNS TABLE { [ {\"x\": a}, {\"x\": b} ] }",
+		"CREATE FUNCTION pairs_select(a, b) RETURNS TABLE AS SELECT v AS x FROM [a, b] AS v",
+		"CREATE FUNCTION no_rows(a, b) RETURNS TABLE { NULL }",
+	} {
+		if _, _, err := Run(qc, true, stmt, nil, nil, _NAMESPACE); err != nil {
+			t.Fatalf("Unable to run %v: %v", stmt, err)
+		}
+	}
+
+	tests := []struct {
+		stmt     string
+		expected []interface{}
+	}{
+		{"SELECT t.x FROM pairs(1, 2) AS t",
+			[]interface{}{map[string]interface{}{"x": 1.0}, map[string]interface{}{"x": 2.0}}},
+		{"SELECT t.x FROM pairs_select(1, 2) AS t",
+			[]interface{}{map[string]interface{}{"x": 1.0}, map[string]interface{}{"x": 2.0}}},
+		{"SELECT t.x FROM pairs(3, 4) AS t WHERE t.x > 3",
+			[]interface{}{map[string]interface{}{"x": 4.0}}},
+		{"SELECT t.x FROM no_rows(1, 2) AS t",
+			[]interface{}{}},
+	}
+	for _, test := range tests {
+		r, _, err := Run(qc, true, test.stmt, nil, nil, _NAMESPACE)
+		if err != nil {
+			t.Errorf("Unable to run %v: %v", test.stmt, err)
+			continue
+		}
+		if !reflect.DeepEqual(r, test.expected) {
+			t.Errorf("Expected %v for %v, got %v", test.expected, test.stmt, r)
+		}
+	}
+
+	// the definition keeps the return type
+	var definition map[string]interface{}
+	json.Unmarshal(memoryFunctions.definitions["default:pairs_select"], &definition)
+	if definition["returns"] != "table" {
+		t.Errorf("Expected a table function, got %v", definition)
+	}
+}
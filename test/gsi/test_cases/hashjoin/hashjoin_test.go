@@ -61,6 +61,9 @@ func TestHashJoin(t *testing.T) {
 	// test HASH JOIN with index hints
 	runMatch("case_hashjoin_hints.json", false, true, qc, t)
 
+	// test HASH JOIN with optimizer hints in a hint comment
+	runMatch("case_hashjoin_optim_hints.json", false, true, qc, t)
+
 	// test HASH NEST on meta().id
 	runMatch("case_hashnest_metaid.json", false, true, qc, t)
 
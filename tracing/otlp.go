@@ -0,0 +1,239 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package tracing
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/couchbase/query/logging"
+)
+
+// Spans are exported in batches, in the OTLP/HTTP JSON encoding, by a single
+// goroutine, so that requests never wait on the collector.
+// If the collector can't keep up, spans are dropped.
+const (
+	_OTLP_TRACES_PATH = "/v1/traces"
+	_SERVICE_NAME     = "cbq-engine"
+	_SCOPE_NAME       = "github.com/couchbase/query"
+
+	_QUEUE_SIZE = 1024
+	_BATCH_SIZE = 512
+)
+
+var _FLUSH_INTERVAL = 5 * time.Second
+var _EXPORT_TIMEOUT = 10 * time.Second
+
+type exporter struct {
+	sync.RWMutex
+	collector string
+	queue     chan []*Span
+	client    *http.Client
+}
+
+var spanExporter = &exporter{
+	queue:  make(chan []*Span, _QUEUE_SIZE),
+	client: &http.Client{Timeout: _EXPORT_TIMEOUT},
+}
+
+var startOnce sync.Once
+
+func Enabled() bool {
+	spanExporter.RLock()
+	rv := spanExporter.collector != ""
+	spanExporter.RUnlock()
+	return rv
+}
+
+func Collector() string {
+	spanExporter.RLock()
+	rv := spanExporter.collector
+	spanExporter.RUnlock()
+	return rv
+}
+
+// The collector is either the base OTLP/HTTP endpoint, or the full traces URL
+func ValidCollector(collector string) bool {
+	u, err := url.Parse(collector)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// An empty collector turns tracing off
+func SetCollector(collector string) bool {
+	if collector != "" {
+		if !ValidCollector(collector) {
+			return false
+		}
+		if !strings.HasSuffix(collector, _OTLP_TRACES_PATH) {
+			collector = strings.TrimSuffix(collector, "/") + _OTLP_TRACES_PATH
+		}
+		startOnce.Do(func() {
+			go spanExporter.run()
+		})
+	}
+	spanExporter.Lock()
+	spanExporter.collector = collector
+	spanExporter.Unlock()
+	return true
+}
+
+func export(spans []*Span) {
+	select {
+	case spanExporter.queue <- spans:
+	default:
+		logging.Debugf("Tracing: export queue full, dropping %v spans", len(spans))
+	}
+}
+
+func (this *exporter) run() {
+	var batch []*Span
+
+	ticker := time.NewTicker(_FLUSH_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case spans := <-this.queue:
+			batch = append(batch, spans...)
+			if len(batch) < _BATCH_SIZE {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+		this.send(batch)
+		batch = nil
+	}
+}
+
+func (this *exporter) send(spans []*Span) {
+	collector := Collector()
+	if collector == "" {
+		return
+	}
+	body, err := json.Marshal(encodeSpans(spans))
+	if err != nil {
+		logging.Errorf("Tracing: cannot encode spans: %v", err)
+		return
+	}
+	resp, err := this.client.Post(collector, "application/json", bytes.NewReader(body))
+	if err != nil {
+		logging.Warnf("Tracing: cannot export spans to %v: %v", collector, err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		logging.Warnf("Tracing: collector %v returned %v", collector, resp.Status)
+	}
+}
+
+// OTLP/HTTP JSON: ids are hex encoded, 64 bit integers are strings
+func encodeSpans(spans []*Span) map[string]interface{} {
+	data := make([]interface{}, 0, len(spans))
+	for _, s := range spans {
+		s.Lock()
+		span := map[string]interface{}{
+			"traceId":           hex.EncodeToString(s.trace.traceId[:]),
+			"spanId":            hex.EncodeToString(s.id[:]),
+			"name":              s.name,
+			"kind":              int(s.kind),
+			"startTimeUnixNano": strconv.FormatInt(s.start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(s.end.UnixNano(), 10),
+		}
+		if !isZero(s.parentId[:]) {
+			span["parentSpanId"] = hex.EncodeToString(s.parentId[:])
+		}
+		if s.trace.state != "" {
+			span["traceState"] = s.trace.state
+		}
+		if len(s.attributes) > 0 {
+			span["attributes"] = encodeAttributes(s.attributes)
+		}
+		if len(s.events) > 0 {
+			events := make([]interface{}, len(s.events))
+			for i, e := range s.events {
+				event := map[string]interface{}{
+					"name":         e.name,
+					"timeUnixNano": strconv.FormatInt(e.time.UnixNano(), 10),
+				}
+				if len(e.attributes) > 0 {
+					event["attributes"] = encodeAttributes(e.attributes)
+				}
+				events[i] = event
+			}
+			span["events"] = events
+		}
+		if s.status != _STATUS_UNSET {
+			status := map[string]interface{}{"code": s.status}
+			if s.message != "" {
+				status["message"] = s.message
+			}
+			span["status"] = status
+		}
+		s.Unlock()
+		data = append(data, span)
+	}
+
+	return map[string]interface{}{
+		"resourceSpans": []interface{}{
+			map[string]interface{}{
+				"resource": map[string]interface{}{
+					"attributes": encodeAttributes(map[string]interface{}{"service.name": _SERVICE_NAME}),
+				},
+				"scopeSpans": []interface{}{
+					map[string]interface{}{
+						"scope": map[string]interface{}{"name": _SCOPE_NAME},
+						"spans": data,
+					},
+				},
+			},
+		},
+	}
+}
+
+func encodeAttributes(attributes map[string]interface{}) []interface{} {
+	rv := make([]interface{}, 0, len(attributes))
+	for k, v := range attributes {
+		var val map[string]interface{}
+
+		switch v := v.(type) {
+		case bool:
+			val = map[string]interface{}{"boolValue": v}
+		case int:
+			val = map[string]interface{}{"intValue": strconv.FormatInt(int64(v), 10)}
+		case int64:
+			val = map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}
+		case uint64:
+			if v > math.MaxInt64 {
+				v = math.MaxInt64
+			}
+			val = map[string]interface{}{"intValue": strconv.FormatUint(v, 10)}
+		case float64:
+			val = map[string]interface{}{"doubleValue": v}
+		case time.Duration:
+			val = map[string]interface{}{"stringValue": v.String()}
+		case string:
+			val = map[string]interface{}{"stringValue": v}
+		default:
+			b, _ := json.Marshal(v)
+			val = map[string]interface{}{"stringValue": string(b)}
+		}
+		rv = append(rv, map[string]interface{}{"key": k, "value": val})
+	}
+	return rv
+}
@@ -0,0 +1,235 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+/*
+Package tracing records spans for requests that carry a sampled W3C
+traceparent header, and exports them to an OTLP/HTTP collector.
+
+Requests are only traced when a collector has been configured: a Trace is
+started from the incoming headers, spans are started and ended as the
+request progresses, and the whole trace is handed to the exporter once the
+request completes.
+All Span and Trace methods can be called on nil receivers, so that callers
+need not check whether a request is being traced.
+*/
+package tracing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+)
+
+type SpanKind int
+
+// as defined by OTLP
+const (
+	INTERNAL SpanKind = 1
+	SERVER   SpanKind = 2
+	CLIENT   SpanKind = 3
+)
+
+const (
+	_STATUS_UNSET = 0
+	_STATUS_OK    = 1
+	_STATUS_ERROR = 2
+)
+
+const _FLAG_SAMPLED = 0x01
+
+type Trace struct {
+	traceId  [16]byte
+	parentId [8]byte
+	flags    byte
+	state    string
+	sync.Mutex
+	spans []*Span
+}
+
+type Span struct {
+	trace      *Trace
+	id         [8]byte
+	parentId   [8]byte
+	name       string
+	kind       SpanKind
+	start      time.Time
+	end        time.Time
+	status     int
+	message    string
+	sync.Mutex // attributes and events can be added by different operators
+	attributes map[string]interface{}
+	events     []event
+}
+
+type event struct {
+	name       string
+	time       time.Time
+	attributes map[string]interface{}
+}
+
+// Start a trace from the W3C traceparent and tracestate headers
+// Returns nil if tracing is off, or the parent is invalid or not sampled.
+func Parse(traceparent, tracestate string) *Trace {
+	if !Enabled() || traceparent == "" {
+		return nil
+	}
+
+	// version-traceid-parentid-flags
+	fields := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(fields) < 4 || len(fields[0]) != 2 || fields[0] == "ff" ||
+		(fields[0] == "00" && len(fields) != 4) {
+		return nil
+	}
+
+	rv := &Trace{state: strings.TrimSpace(tracestate)}
+	var flags [1]byte
+	if !decodeHex(rv.traceId[:], fields[1]) || !decodeHex(rv.parentId[:], fields[2]) ||
+		!decodeHex(flags[:], fields[3]) {
+		return nil
+	}
+	if isZero(rv.traceId[:]) || isZero(rv.parentId[:]) || flags[0]&_FLAG_SAMPLED == 0 {
+		return nil
+	}
+	rv.flags = flags[0]
+	return rv
+}
+
+func decodeHex(dst []byte, s string) bool {
+	if len(s) != 2*len(dst) || strings.ToLower(s) != s {
+		return false
+	}
+	_, err := hex.Decode(dst, []byte(s))
+	return err == nil
+}
+
+func isZero(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (this *Trace) TraceId() string {
+	if this == nil {
+		return ""
+	}
+	return hex.EncodeToString(this.traceId[:])
+}
+
+// Start a span, as a child of the remote parent if parent is nil
+func (this *Trace) StartSpan(name string, kind SpanKind, parent *Span) *Span {
+	if this == nil {
+		return nil
+	}
+	rv := &Span{
+		trace: this,
+		name:  name,
+		kind:  kind,
+		start: time.Now(),
+	}
+	if parent != nil {
+		rv.parentId = parent.id
+	} else {
+		rv.parentId = this.parentId
+	}
+	rand.Read(rv.id[:])
+	return rv
+}
+
+// Hand the ended spans to the exporter
+func (this *Trace) Finish() {
+	if this == nil {
+		return
+	}
+	this.Lock()
+	spans := this.spans
+	this.spans = nil
+	this.Unlock()
+	if len(spans) > 0 {
+		export(spans)
+	}
+}
+
+// Start a child span
+func (this *Span) StartChild(name string, kind SpanKind) *Span {
+	if this == nil {
+		return nil
+	}
+	return this.trace.StartSpan(name, kind, this)
+}
+
+func (this *Span) SpanId() string {
+	if this == nil {
+		return ""
+	}
+	return hex.EncodeToString(this.id[:])
+}
+
+func (this *Span) SetAttribute(key string, val interface{}) {
+	if this == nil {
+		return
+	}
+	this.Lock()
+	if this.attributes == nil {
+		this.attributes = make(map[string]interface{})
+	}
+	this.attributes[key] = val
+	this.Unlock()
+}
+
+func (this *Span) AddEvent(name string, attributes map[string]interface{}) {
+	if this == nil {
+		return
+	}
+	this.Lock()
+	this.events = append(this.events, event{name: name, time: time.Now(), attributes: attributes})
+	this.Unlock()
+}
+
+func (this *Span) SetError(message string) {
+	if this == nil {
+		return
+	}
+	this.Lock()
+	this.status = _STATUS_ERROR
+	this.message = message
+	this.Unlock()
+}
+
+func (this *Span) SetOk() {
+	if this == nil {
+		return
+	}
+	this.Lock()
+	if this.status == _STATUS_UNSET {
+		this.status = _STATUS_OK
+	}
+	this.Unlock()
+}
+
+// Spans can only be ended once
+func (this *Span) End() {
+	if this == nil {
+		return
+	}
+	this.Lock()
+	if !this.end.IsZero() {
+		this.Unlock()
+		return
+	}
+	this.end = time.Now()
+	this.Unlock()
+
+	this.trace.Lock()
+	this.trace.spans = append(this.trace.spans, this)
+	this.trace.Unlock()
+}
@@ -0,0 +1,126 @@
+//  Copyright 2021-Present Couchbase, Inc.
+//
+//  Use of this software is governed by the Business Source License included in
+//  the file licenses/Couchbase-BSL.txt.  As of the Change Date specified in that
+//  file, in accordance with the Business Source License, use of this software will
+//  be governed by the Apache License, Version 2.0, included in the file
+//  licenses/APL.txt.
+
+package tracing
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const _TEST_PARENT = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func init() {
+	_FLUSH_INTERVAL = 10 * time.Millisecond
+}
+
+func TestParse(t *testing.T) {
+	SetCollector("")
+	if Parse(_TEST_PARENT, "") != nil {
+		t.Errorf("Expected no trace with tracing off")
+	}
+
+	SetCollector("http://localhost:4318")
+	defer SetCollector("")
+	if Collector() != "http://localhost:4318/v1/traces" {
+		t.Errorf("Unexpected collector %v", Collector())
+	}
+
+	trace := Parse(_TEST_PARENT, "vendor=value")
+	if trace == nil || trace.TraceId() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("Expected trace 4bf92f3577b34da6a3ce929d0e0e4736, got %v", trace.TraceId())
+	}
+
+	for _, bad := range []string{
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", // not sampled
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01", // zero trace id
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", // zero parent id
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", // invalid version
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", // upper case
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",    // missing flags
+	} {
+		if Parse(bad, "") != nil {
+			t.Errorf("Expected no trace for %v", bad)
+		}
+	}
+
+	// nil traces and spans are no-ops
+	var nilTrace *Trace
+	span := nilTrace.StartSpan("query", SERVER, nil)
+	span.SetAttribute("a", 1)
+	span.StartChild("child", INTERNAL).End()
+	span.End()
+	nilTrace.Finish()
+}
+
+func TestExport(t *testing.T) {
+	received := make(chan map[string]interface{}, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != _OTLP_TRACES_PATH || req.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Unexpected export to %v (%v)", req.URL.Path, req.Header.Get("Content-Type"))
+		}
+		body, _ := ioutil.ReadAll(req.Body)
+		var data map[string]interface{}
+		if err := json.Unmarshal(body, &data); err != nil {
+			t.Errorf("Invalid export: %v", err)
+		}
+		received <- data
+	}))
+	defer collector.Close()
+
+	if !SetCollector(collector.URL) {
+		t.Fatalf("Collector %v rejected", collector.URL)
+	}
+	defer SetCollector("")
+
+	trace := Parse(_TEST_PARENT, "")
+	root := trace.StartSpan("query", SERVER, nil)
+	root.SetAttribute("db.statement", "SELECT 1")
+	execute := root.StartChild("execute", INTERNAL)
+	execute.AddEvent("fetch", map[string]interface{}{"count": uint64(3)})
+	execute.End()
+	root.SetError("failed")
+	root.End()
+	trace.Finish()
+
+	var data map[string]interface{}
+	select {
+	case data = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("No spans received")
+	}
+
+	resource := data["resourceSpans"].([]interface{})[0].(map[string]interface{})
+	spans := resource["scopeSpans"].([]interface{})[0].(map[string]interface{})["spans"].([]interface{})
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %v", len(spans))
+	}
+	child := spans[0].(map[string]interface{})
+	parent := spans[1].(map[string]interface{})
+	if child["name"] != "execute" || parent["name"] != "query" {
+		t.Errorf("Unexpected spans %v, %v", child["name"], parent["name"])
+	}
+	if parent["traceId"] != "4bf92f3577b34da6a3ce929d0e0e4736" || parent["parentSpanId"] != "00f067aa0ba902b7" {
+		t.Errorf("Unexpected root span ids %v", parent)
+	}
+	if child["parentSpanId"] != parent["spanId"] || child["traceId"] != parent["traceId"] {
+		t.Errorf("Child span %v not a child of %v", child, parent)
+	}
+	if parent["kind"] != float64(SERVER) || parent["status"].(map[string]interface{})["code"] != float64(_STATUS_ERROR) {
+		t.Errorf("Unexpected root span kind or status %v", parent)
+	}
+	event := child["events"].([]interface{})[0].(map[string]interface{})
+	attribute := event["attributes"].([]interface{})[0].(map[string]interface{})
+	if event["name"] != "fetch" || attribute["value"].(map[string]interface{})["intValue"] != "3" {
+		t.Errorf("Unexpected event %v", event)
+	}
+}